@@ -26,16 +26,22 @@ const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 // Client represents an OAuth2 client.
 type Client struct {
-	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Secret               string   `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
-	RedirectUris         []string `protobuf:"bytes,3,rep,name=redirect_uris,json=redirectUris,proto3" json:"redirect_uris,omitempty"`
-	TrustedPeers         []string `protobuf:"bytes,4,rep,name=trusted_peers,json=trustedPeers,proto3" json:"trusted_peers,omitempty"`
-	Public               bool     `protobuf:"varint,5,opt,name=public,proto3" json:"public,omitempty"`
-	Name                 string   `protobuf:"bytes,6,opt,name=name,proto3" json:"name,omitempty"`
-	LogoUrl              string   `protobuf:"bytes,7,opt,name=logo_url,json=logoUrl,proto3" json:"logo_url,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Id                      string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Secret                  string   `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
+	RedirectUris            []string `protobuf:"bytes,3,rep,name=redirect_uris,json=redirectUris,proto3" json:"redirect_uris,omitempty"`
+	TrustedPeers            []string `protobuf:"bytes,4,rep,name=trusted_peers,json=trustedPeers,proto3" json:"trusted_peers,omitempty"`
+	Public                  bool     `protobuf:"varint,5,opt,name=public,proto3" json:"public,omitempty"`
+	Name                    string   `protobuf:"bytes,6,opt,name=name,proto3" json:"name,omitempty"`
+	LogoUrl                 string   `protobuf:"bytes,7,opt,name=logo_url,json=logoUrl,proto3" json:"logo_url,omitempty"`
+	AllowedScopes           []string `protobuf:"bytes,8,rep,name=allowed_scopes,json=allowedScopes,proto3" json:"allowed_scopes,omitempty"`
+	AllowedAudiences        []string `protobuf:"bytes,9,rep,name=allowed_audiences,json=allowedAudiences,proto3" json:"allowed_audiences,omitempty"`
+	AllowedGrantTypes       []string `protobuf:"bytes,10,rep,name=allowed_grant_types,json=allowedGrantTypes,proto3" json:"allowed_grant_types,omitempty"`
+	AllowedResponseTypes    []string `protobuf:"bytes,11,rep,name=allowed_response_types,json=allowedResponseTypes,proto3" json:"allowed_response_types,omitempty"`
+	AllowedConnectors       []string `protobuf:"bytes,12,rep,name=allowed_connectors,json=allowedConnectors,proto3" json:"allowed_connectors,omitempty"`
+	ClientCredentialsScopes []string `protobuf:"bytes,13,rep,name=client_credentials_scopes,json=clientCredentialsScopes,proto3" json:"client_credentials_scopes,omitempty"`
+	XXX_NoUnkeyedLiteral    struct{} `json:"-"`
+	XXX_unrecognized        []byte   `json:"-"`
+	XXX_sizecache           int32    `json:"-"`
 }
 
 func (m *Client) Reset()         { *m = Client{} }
@@ -112,6 +118,48 @@ func (m *Client) GetLogoUrl() string {
 	return ""
 }
 
+func (m *Client) GetAllowedScopes() []string {
+	if m != nil {
+		return m.AllowedScopes
+	}
+	return nil
+}
+
+func (m *Client) GetAllowedAudiences() []string {
+	if m != nil {
+		return m.AllowedAudiences
+	}
+	return nil
+}
+
+func (m *Client) GetAllowedGrantTypes() []string {
+	if m != nil {
+		return m.AllowedGrantTypes
+	}
+	return nil
+}
+
+func (m *Client) GetAllowedResponseTypes() []string {
+	if m != nil {
+		return m.AllowedResponseTypes
+	}
+	return nil
+}
+
+func (m *Client) GetAllowedConnectors() []string {
+	if m != nil {
+		return m.AllowedConnectors
+	}
+	return nil
+}
+
+func (m *Client) GetClientCredentialsScopes() []string {
+	if m != nil {
+		return m.ClientCredentialsScopes
+	}
+	return nil
+}
+
 // CreateClientReq is a request to make a client.
 type CreateClientReq struct {
 	Client               *Client  `protobuf:"bytes,1,opt,name=client,proto3" json:"client,omitempty"`
@@ -283,14 +331,20 @@ func (m *DeleteClientResp) GetNotFound() bool {
 
 // UpdateClientReq is a request to update an exisitng client.
 type UpdateClientReq struct {
-	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	RedirectUris         []string `protobuf:"bytes,2,rep,name=redirect_uris,json=redirectUris,proto3" json:"redirect_uris,omitempty"`
-	TrustedPeers         []string `protobuf:"bytes,3,rep,name=trusted_peers,json=trustedPeers,proto3" json:"trusted_peers,omitempty"`
-	Name                 string   `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
-	LogoUrl              string   `protobuf:"bytes,5,opt,name=logo_url,json=logoUrl,proto3" json:"logo_url,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Id                      string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	RedirectUris            []string `protobuf:"bytes,2,rep,name=redirect_uris,json=redirectUris,proto3" json:"redirect_uris,omitempty"`
+	TrustedPeers            []string `protobuf:"bytes,3,rep,name=trusted_peers,json=trustedPeers,proto3" json:"trusted_peers,omitempty"`
+	Name                    string   `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	LogoUrl                 string   `protobuf:"bytes,5,opt,name=logo_url,json=logoUrl,proto3" json:"logo_url,omitempty"`
+	AllowedScopes           []string `protobuf:"bytes,6,rep,name=allowed_scopes,json=allowedScopes,proto3" json:"allowed_scopes,omitempty"`
+	AllowedAudiences        []string `protobuf:"bytes,7,rep,name=allowed_audiences,json=allowedAudiences,proto3" json:"allowed_audiences,omitempty"`
+	AllowedGrantTypes       []string `protobuf:"bytes,8,rep,name=allowed_grant_types,json=allowedGrantTypes,proto3" json:"allowed_grant_types,omitempty"`
+	AllowedResponseTypes    []string `protobuf:"bytes,9,rep,name=allowed_response_types,json=allowedResponseTypes,proto3" json:"allowed_response_types,omitempty"`
+	AllowedConnectors       []string `protobuf:"bytes,10,rep,name=allowed_connectors,json=allowedConnectors,proto3" json:"allowed_connectors,omitempty"`
+	ClientCredentialsScopes []string `protobuf:"bytes,11,rep,name=client_credentials_scopes,json=clientCredentialsScopes,proto3" json:"client_credentials_scopes,omitempty"`
+	XXX_NoUnkeyedLiteral    struct{} `json:"-"`
+	XXX_unrecognized        []byte   `json:"-"`
+	XXX_sizecache           int32    `json:"-"`
 }
 
 func (m *UpdateClientReq) Reset()         { *m = UpdateClientReq{} }
@@ -353,6 +407,48 @@ func (m *UpdateClientReq) GetLogoUrl() string {
 	return ""
 }
 
+func (m *UpdateClientReq) GetAllowedScopes() []string {
+	if m != nil {
+		return m.AllowedScopes
+	}
+	return nil
+}
+
+func (m *UpdateClientReq) GetAllowedAudiences() []string {
+	if m != nil {
+		return m.AllowedAudiences
+	}
+	return nil
+}
+
+func (m *UpdateClientReq) GetAllowedGrantTypes() []string {
+	if m != nil {
+		return m.AllowedGrantTypes
+	}
+	return nil
+}
+
+func (m *UpdateClientReq) GetAllowedResponseTypes() []string {
+	if m != nil {
+		return m.AllowedResponseTypes
+	}
+	return nil
+}
+
+func (m *UpdateClientReq) GetAllowedConnectors() []string {
+	if m != nil {
+		return m.AllowedConnectors
+	}
+	return nil
+}
+
+func (m *UpdateClientReq) GetClientCredentialsScopes() []string {
+	if m != nil {
+		return m.ClientCredentialsScopes
+	}
+	return nil
+}
+
 // UpdateClientResp returns the reponse form updating a client.
 type UpdateClientResp struct {
 	NotFound             bool     `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
@@ -393,6 +489,210 @@ func (m *UpdateClientResp) GetNotFound() bool {
 	return false
 }
 
+// AddClientSecretReq is a request to add a secret to a client.
+type AddClientSecretReq struct {
+	ClientId string `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	// The secret to add. A random secret is generated if empty.
+	Secret string `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
+	// Unix time after which the secret can't be used, or 0 if it never expires.
+	Expiry               int64    `protobuf:"varint,3,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddClientSecretReq) Reset()         { *m = AddClientSecretReq{} }
+func (m *AddClientSecretReq) String() string { return proto.CompactTextString(m) }
+func (*AddClientSecretReq) ProtoMessage()    {}
+func (*AddClientSecretReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{7}
+}
+
+func (m *AddClientSecretReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddClientSecretReq.Unmarshal(m, b)
+}
+func (m *AddClientSecretReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddClientSecretReq.Marshal(b, m, deterministic)
+}
+func (m *AddClientSecretReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddClientSecretReq.Merge(m, src)
+}
+func (m *AddClientSecretReq) XXX_Size() int {
+	return xxx_messageInfo_AddClientSecretReq.Size(m)
+}
+func (m *AddClientSecretReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddClientSecretReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddClientSecretReq proto.InternalMessageInfo
+
+func (m *AddClientSecretReq) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+func (m *AddClientSecretReq) GetSecret() string {
+	if m != nil {
+		return m.Secret
+	}
+	return ""
+}
+
+func (m *AddClientSecretReq) GetExpiry() int64 {
+	if m != nil {
+		return m.Expiry
+	}
+	return 0
+}
+
+// AddClientSecretResp returns the added secret, which is only stored hashed.
+type AddClientSecretResp struct {
+	NotFound bool `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	// The ID of the secret, used to remove it.
+	Id                   string   `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Secret               string   `protobuf:"bytes,3,opt,name=secret,proto3" json:"secret,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddClientSecretResp) Reset()         { *m = AddClientSecretResp{} }
+func (m *AddClientSecretResp) String() string { return proto.CompactTextString(m) }
+func (*AddClientSecretResp) ProtoMessage()    {}
+func (*AddClientSecretResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{8}
+}
+
+func (m *AddClientSecretResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddClientSecretResp.Unmarshal(m, b)
+}
+func (m *AddClientSecretResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddClientSecretResp.Marshal(b, m, deterministic)
+}
+func (m *AddClientSecretResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddClientSecretResp.Merge(m, src)
+}
+func (m *AddClientSecretResp) XXX_Size() int {
+	return xxx_messageInfo_AddClientSecretResp.Size(m)
+}
+func (m *AddClientSecretResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddClientSecretResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddClientSecretResp proto.InternalMessageInfo
+
+func (m *AddClientSecretResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+func (m *AddClientSecretResp) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *AddClientSecretResp) GetSecret() string {
+	if m != nil {
+		return m.Secret
+	}
+	return ""
+}
+
+// RemoveClientSecretReq is a request to remove a secret from a client.
+type RemoveClientSecretReq struct {
+	ClientId string `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	// The ID of the secret.
+	Id                   string   `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RemoveClientSecretReq) Reset()         { *m = RemoveClientSecretReq{} }
+func (m *RemoveClientSecretReq) String() string { return proto.CompactTextString(m) }
+func (*RemoveClientSecretReq) ProtoMessage()    {}
+func (*RemoveClientSecretReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{9}
+}
+
+func (m *RemoveClientSecretReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RemoveClientSecretReq.Unmarshal(m, b)
+}
+func (m *RemoveClientSecretReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RemoveClientSecretReq.Marshal(b, m, deterministic)
+}
+func (m *RemoveClientSecretReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RemoveClientSecretReq.Merge(m, src)
+}
+func (m *RemoveClientSecretReq) XXX_Size() int {
+	return xxx_messageInfo_RemoveClientSecretReq.Size(m)
+}
+func (m *RemoveClientSecretReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_RemoveClientSecretReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RemoveClientSecretReq proto.InternalMessageInfo
+
+func (m *RemoveClientSecretReq) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+func (m *RemoveClientSecretReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+// RemoveClientSecretResp determines if the secret is removed successfully.
+type RemoveClientSecretResp struct {
+	NotFound             bool     `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RemoveClientSecretResp) Reset()         { *m = RemoveClientSecretResp{} }
+func (m *RemoveClientSecretResp) String() string { return proto.CompactTextString(m) }
+func (*RemoveClientSecretResp) ProtoMessage()    {}
+func (*RemoveClientSecretResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{10}
+}
+
+func (m *RemoveClientSecretResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RemoveClientSecretResp.Unmarshal(m, b)
+}
+func (m *RemoveClientSecretResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RemoveClientSecretResp.Marshal(b, m, deterministic)
+}
+func (m *RemoveClientSecretResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RemoveClientSecretResp.Merge(m, src)
+}
+func (m *RemoveClientSecretResp) XXX_Size() int {
+	return xxx_messageInfo_RemoveClientSecretResp.Size(m)
+}
+func (m *RemoveClientSecretResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_RemoveClientSecretResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RemoveClientSecretResp proto.InternalMessageInfo
+
+func (m *RemoveClientSecretResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
 // Password is an email for password mapping managed by the storage.
 type Password struct {
 	Email string `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
@@ -409,7 +709,7 @@ func (m *Password) Reset()         { *m = Password{} }
 func (m *Password) String() string { return proto.CompactTextString(m) }
 func (*Password) ProtoMessage()    {}
 func (*Password) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{7}
+	return fileDescriptor_1b40cafcd4234784, []int{11}
 }
 
 func (m *Password) XXX_Unmarshal(b []byte) error {
@@ -470,7 +770,7 @@ func (m *CreatePasswordReq) Reset()         { *m = CreatePasswordReq{} }
 func (m *CreatePasswordReq) String() string { return proto.CompactTextString(m) }
 func (*CreatePasswordReq) ProtoMessage()    {}
 func (*CreatePasswordReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{8}
+	return fileDescriptor_1b40cafcd4234784, []int{12}
 }
 
 func (m *CreatePasswordReq) XXX_Unmarshal(b []byte) error {
@@ -510,7 +810,7 @@ func (m *CreatePasswordResp) Reset()         { *m = CreatePasswordResp{} }
 func (m *CreatePasswordResp) String() string { return proto.CompactTextString(m) }
 func (*CreatePasswordResp) ProtoMessage()    {}
 func (*CreatePasswordResp) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{9}
+	return fileDescriptor_1b40cafcd4234784, []int{13}
 }
 
 func (m *CreatePasswordResp) XXX_Unmarshal(b []byte) error {
@@ -553,7 +853,7 @@ func (m *UpdatePasswordReq) Reset()         { *m = UpdatePasswordReq{} }
 func (m *UpdatePasswordReq) String() string { return proto.CompactTextString(m) }
 func (*UpdatePasswordReq) ProtoMessage()    {}
 func (*UpdatePasswordReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{10}
+	return fileDescriptor_1b40cafcd4234784, []int{14}
 }
 
 func (m *UpdatePasswordReq) XXX_Unmarshal(b []byte) error {
@@ -607,7 +907,7 @@ func (m *UpdatePasswordResp) Reset()         { *m = UpdatePasswordResp{} }
 func (m *UpdatePasswordResp) String() string { return proto.CompactTextString(m) }
 func (*UpdatePasswordResp) ProtoMessage()    {}
 func (*UpdatePasswordResp) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{11}
+	return fileDescriptor_1b40cafcd4234784, []int{15}
 }
 
 func (m *UpdatePasswordResp) XXX_Unmarshal(b []byte) error {
@@ -647,7 +947,7 @@ func (m *DeletePasswordReq) Reset()         { *m = DeletePasswordReq{} }
 func (m *DeletePasswordReq) String() string { return proto.CompactTextString(m) }
 func (*DeletePasswordReq) ProtoMessage()    {}
 func (*DeletePasswordReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{12}
+	return fileDescriptor_1b40cafcd4234784, []int{16}
 }
 
 func (m *DeletePasswordReq) XXX_Unmarshal(b []byte) error {
@@ -687,7 +987,7 @@ func (m *DeletePasswordResp) Reset()         { *m = DeletePasswordResp{} }
 func (m *DeletePasswordResp) String() string { return proto.CompactTextString(m) }
 func (*DeletePasswordResp) ProtoMessage()    {}
 func (*DeletePasswordResp) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{13}
+	return fileDescriptor_1b40cafcd4234784, []int{17}
 }
 
 func (m *DeletePasswordResp) XXX_Unmarshal(b []byte) error {
@@ -726,7 +1026,7 @@ func (m *ListPasswordReq) Reset()         { *m = ListPasswordReq{} }
 func (m *ListPasswordReq) String() string { return proto.CompactTextString(m) }
 func (*ListPasswordReq) ProtoMessage()    {}
 func (*ListPasswordReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{14}
+	return fileDescriptor_1b40cafcd4234784, []int{18}
 }
 
 func (m *ListPasswordReq) XXX_Unmarshal(b []byte) error {
@@ -759,7 +1059,7 @@ func (m *ListPasswordResp) Reset()         { *m = ListPasswordResp{} }
 func (m *ListPasswordResp) String() string { return proto.CompactTextString(m) }
 func (*ListPasswordResp) ProtoMessage()    {}
 func (*ListPasswordResp) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{15}
+	return fileDescriptor_1b40cafcd4234784, []int{19}
 }
 
 func (m *ListPasswordResp) XXX_Unmarshal(b []byte) error {
@@ -798,7 +1098,7 @@ func (m *VersionReq) Reset()         { *m = VersionReq{} }
 func (m *VersionReq) String() string { return proto.CompactTextString(m) }
 func (*VersionReq) ProtoMessage()    {}
 func (*VersionReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{16}
+	return fileDescriptor_1b40cafcd4234784, []int{20}
 }
 
 func (m *VersionReq) XXX_Unmarshal(b []byte) error {
@@ -835,7 +1135,7 @@ func (m *VersionResp) Reset()         { *m = VersionResp{} }
 func (m *VersionResp) String() string { return proto.CompactTextString(m) }
 func (*VersionResp) ProtoMessage()    {}
 func (*VersionResp) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{17}
+	return fileDescriptor_1b40cafcd4234784, []int{21}
 }
 
 func (m *VersionResp) XXX_Unmarshal(b []byte) error {
@@ -886,7 +1186,7 @@ func (m *RefreshTokenRef) Reset()         { *m = RefreshTokenRef{} }
 func (m *RefreshTokenRef) String() string { return proto.CompactTextString(m) }
 func (*RefreshTokenRef) ProtoMessage()    {}
 func (*RefreshTokenRef) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{18}
+	return fileDescriptor_1b40cafcd4234784, []int{22}
 }
 
 func (m *RefreshTokenRef) XXX_Unmarshal(b []byte) error {
@@ -948,7 +1248,7 @@ func (m *ListRefreshReq) Reset()         { *m = ListRefreshReq{} }
 func (m *ListRefreshReq) String() string { return proto.CompactTextString(m) }
 func (*ListRefreshReq) ProtoMessage()    {}
 func (*ListRefreshReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{19}
+	return fileDescriptor_1b40cafcd4234784, []int{23}
 }
 
 func (m *ListRefreshReq) XXX_Unmarshal(b []byte) error {
@@ -988,7 +1288,7 @@ func (m *ListRefreshResp) Reset()         { *m = ListRefreshResp{} }
 func (m *ListRefreshResp) String() string { return proto.CompactTextString(m) }
 func (*ListRefreshResp) ProtoMessage()    {}
 func (*ListRefreshResp) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{20}
+	return fileDescriptor_1b40cafcd4234784, []int{24}
 }
 
 func (m *ListRefreshResp) XXX_Unmarshal(b []byte) error {
@@ -1030,7 +1330,7 @@ func (m *RevokeRefreshReq) Reset()         { *m = RevokeRefreshReq{} }
 func (m *RevokeRefreshReq) String() string { return proto.CompactTextString(m) }
 func (*RevokeRefreshReq) ProtoMessage()    {}
 func (*RevokeRefreshReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{21}
+	return fileDescriptor_1b40cafcd4234784, []int{25}
 }
 
 func (m *RevokeRefreshReq) XXX_Unmarshal(b []byte) error {
@@ -1078,7 +1378,7 @@ func (m *RevokeRefreshResp) Reset()         { *m = RevokeRefreshResp{} }
 func (m *RevokeRefreshResp) String() string { return proto.CompactTextString(m) }
 func (*RevokeRefreshResp) ProtoMessage()    {}
 func (*RevokeRefreshResp) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{22}
+	return fileDescriptor_1b40cafcd4234784, []int{26}
 }
 
 func (m *RevokeRefreshResp) XXX_Unmarshal(b []byte) error {
@@ -1118,7 +1418,7 @@ func (m *VerifyPasswordReq) Reset()         { *m = VerifyPasswordReq{} }
 func (m *VerifyPasswordReq) String() string { return proto.CompactTextString(m) }
 func (*VerifyPasswordReq) ProtoMessage()    {}
 func (*VerifyPasswordReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{23}
+	return fileDescriptor_1b40cafcd4234784, []int{27}
 }
 
 func (m *VerifyPasswordReq) XXX_Unmarshal(b []byte) error {
@@ -1165,7 +1465,7 @@ func (m *VerifyPasswordResp) Reset()         { *m = VerifyPasswordResp{} }
 func (m *VerifyPasswordResp) String() string { return proto.CompactTextString(m) }
 func (*VerifyPasswordResp) ProtoMessage()    {}
 func (*VerifyPasswordResp) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1b40cafcd4234784, []int{24}
+	return fileDescriptor_1b40cafcd4234784, []int{28}
 }
 
 func (m *VerifyPasswordResp) XXX_Unmarshal(b []byte) error {
@@ -1200,104 +1500,549 @@ func (m *VerifyPasswordResp) GetNotFound() bool {
 	return false
 }
 
-func init() {
-	proto.RegisterType((*Client)(nil), "api.Client")
-	proto.RegisterType((*CreateClientReq)(nil), "api.CreateClientReq")
-	proto.RegisterType((*CreateClientResp)(nil), "api.CreateClientResp")
-	proto.RegisterType((*DeleteClientReq)(nil), "api.DeleteClientReq")
-	proto.RegisterType((*DeleteClientResp)(nil), "api.DeleteClientResp")
-	proto.RegisterType((*UpdateClientReq)(nil), "api.UpdateClientReq")
-	proto.RegisterType((*UpdateClientResp)(nil), "api.UpdateClientResp")
-	proto.RegisterType((*Password)(nil), "api.Password")
-	proto.RegisterType((*CreatePasswordReq)(nil), "api.CreatePasswordReq")
-	proto.RegisterType((*CreatePasswordResp)(nil), "api.CreatePasswordResp")
-	proto.RegisterType((*UpdatePasswordReq)(nil), "api.UpdatePasswordReq")
-	proto.RegisterType((*UpdatePasswordResp)(nil), "api.UpdatePasswordResp")
-	proto.RegisterType((*DeletePasswordReq)(nil), "api.DeletePasswordReq")
-	proto.RegisterType((*DeletePasswordResp)(nil), "api.DeletePasswordResp")
-	proto.RegisterType((*ListPasswordReq)(nil), "api.ListPasswordReq")
-	proto.RegisterType((*ListPasswordResp)(nil), "api.ListPasswordResp")
-	proto.RegisterType((*VersionReq)(nil), "api.VersionReq")
-	proto.RegisterType((*VersionResp)(nil), "api.VersionResp")
-	proto.RegisterType((*RefreshTokenRef)(nil), "api.RefreshTokenRef")
-	proto.RegisterType((*ListRefreshReq)(nil), "api.ListRefreshReq")
-	proto.RegisterType((*ListRefreshResp)(nil), "api.ListRefreshResp")
-	proto.RegisterType((*RevokeRefreshReq)(nil), "api.RevokeRefreshReq")
-	proto.RegisterType((*RevokeRefreshResp)(nil), "api.RevokeRefreshResp")
-	proto.RegisterType((*VerifyPasswordReq)(nil), "api.VerifyPasswordReq")
-	proto.RegisterType((*VerifyPasswordResp)(nil), "api.VerifyPasswordResp")
+// Connector is a strategy used by dex for authenticating a user against another identity provider.
+type Connector struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// The type of the connector, e.g. "oidc" or "ldap".
+	Type string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Name string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	// JSON encoded configuration specific to the connector type.
+	Config               []byte   `protobuf:"bytes,4,opt,name=config,proto3" json:"config,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func init() { proto.RegisterFile("api/api.proto", fileDescriptor_1b40cafcd4234784) }
+func (m *Connector) Reset()         { *m = Connector{} }
+func (m *Connector) String() string { return proto.CompactTextString(m) }
+func (*Connector) ProtoMessage()    {}
+func (*Connector) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{29}
+}
 
-var fileDescriptor_1b40cafcd4234784 = []byte{
-	// 905 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x56, 0xeb, 0x6e, 0xdb, 0x36,
-	0x14, 0xb6, 0xad, 0xd8, 0x96, 0x8f, 0xef, 0x9c, 0x9b, 0xba, 0x2e, 0x06, 0xa4, 0x2c, 0x06, 0xa4,
-	0x18, 0xe0, 0xac, 0x1d, 0xb0, 0x01, 0x2b, 0xd6, 0x5d, 0xd2, 0x6e, 0x2d, 0xb0, 0x0d, 0x85, 0x30,
-	0xe7, 0xe7, 0x04, 0xc5, 0x3a, 0x4e, 0x88, 0x28, 0x92, 0x46, 0xd2, 0x71, 0xb2, 0x47, 0xd9, 0xdb,
-	0xec, 0xd7, 0x5e, 0xab, 0x20, 0x45, 0x29, 0xba, 0x38, 0x71, 0xfe, 0xf9, 0x7c, 0xe2, 0xb9, 0x7d,
-	0x87, 0xe7, 0xa3, 0xa1, 0xef, 0xc5, 0xec, 0xc8, 0x8b, 0xd9, 0x3c, 0xe6, 0x91, 0x8c, 0x88, 0xe5,
-	0xc5, 0x8c, 0xfe, 0x57, 0x87, 0xd6, 0x71, 0xc0, 0x30, 0x94, 0x64, 0x00, 0x0d, 0xe6, 0x4f, 0xeb,
-	0x07, 0xf5, 0xc3, 0x8e, 0xd3, 0x60, 0x3e, 0xd9, 0x87, 0x96, 0xc0, 0x25, 0x47, 0x39, 0x6d, 0x68,
-	0xcc, 0x58, 0xe4, 0x39, 0xf4, 0x39, 0xfa, 0x8c, 0xe3, 0x52, 0xba, 0x6b, 0xce, 0xc4, 0xd4, 0x3a,
-	0xb0, 0x0e, 0x3b, 0x4e, 0x2f, 0x05, 0x17, 0x9c, 0x09, 0x75, 0x48, 0xf2, 0xb5, 0x90, 0xe8, 0xbb,
-	0x31, 0x22, 0x17, 0xd3, 0xbd, 0xe4, 0x90, 0x01, 0x3f, 0x2a, 0x4c, 0x65, 0x88, 0xd7, 0xa7, 0x01,
-	0x5b, 0x4e, 0x9b, 0x07, 0xf5, 0x43, 0xdb, 0x31, 0x16, 0x21, 0xb0, 0x17, 0x7a, 0x97, 0x38, 0x6d,
-	0xe9, 0xbc, 0xfa, 0x37, 0x79, 0x02, 0x76, 0x10, 0x9d, 0x45, 0xee, 0x9a, 0x07, 0xd3, 0xb6, 0xc6,
-	0xdb, 0xca, 0x5e, 0xf0, 0x80, 0x7e, 0x03, 0xc3, 0x63, 0x8e, 0x9e, 0xc4, 0xa4, 0x11, 0x07, 0xff,
-	0x26, 0xcf, 0xa1, 0xb5, 0xd4, 0x86, 0xee, 0xa7, 0xfb, 0xaa, 0x3b, 0x57, 0x7d, 0x9b, 0xef, 0xe6,
-	0x13, 0xfd, 0x0b, 0x46, 0x45, 0x3f, 0x11, 0x93, 0x2f, 0x60, 0xe0, 0x05, 0x1c, 0x3d, 0xff, 0xc6,
-	0xc5, 0x6b, 0x26, 0xa4, 0xd0, 0x01, 0x6c, 0xa7, 0x6f, 0xd0, 0x77, 0x1a, 0xcc, 0xc5, 0x6f, 0xdc,
-	0x1d, 0xff, 0x19, 0x0c, 0xdf, 0x62, 0x80, 0xf9, 0xba, 0x4a, 0x1c, 0xd3, 0x23, 0x18, 0x15, 0x8f,
-	0x88, 0x98, 0x3c, 0x85, 0x4e, 0x18, 0x49, 0x77, 0x15, 0xad, 0x43, 0xdf, 0x64, 0xb7, 0xc3, 0x48,
-	0xfe, 0xa2, 0x6c, 0xfa, 0x6f, 0x1d, 0x86, 0x8b, 0xd8, 0xf7, 0xee, 0x09, 0x5a, 0x1d, 0x50, 0xe3,
-	0x21, 0x03, 0xb2, 0xb6, 0x0c, 0x28, 0x1d, 0xc4, 0xde, 0x1d, 0x83, 0x68, 0x16, 0x07, 0x71, 0x04,
-	0xa3, 0x62, 0x6d, 0xbb, 0xba, 0x61, 0x60, 0x7f, 0xf4, 0x84, 0xd8, 0x44, 0xdc, 0x27, 0x13, 0x68,
-	0xe2, 0xa5, 0xc7, 0x02, 0xd3, 0x48, 0x62, 0xa8, 0x0a, 0xce, 0x3d, 0x71, 0xae, 0x69, 0xee, 0x39,
-	0xfa, 0x37, 0x99, 0x81, 0xbd, 0x16, 0xc8, 0x75, 0x65, 0x96, 0x3e, 0x9c, 0xd9, 0xe4, 0x31, 0xb4,
-	0xd5, 0x6f, 0x97, 0xf9, 0xa6, 0xe8, 0x96, 0x32, 0x3f, 0xf8, 0xf4, 0x0d, 0x8c, 0x93, 0x61, 0xa7,
-	0x09, 0x15, 0x73, 0x2f, 0xc0, 0x8e, 0x8d, 0x69, 0x2e, 0x4a, 0x5f, 0x0f, 0x32, 0x3b, 0x93, 0x7d,
-	0xa6, 0xaf, 0x81, 0x94, 0xfd, 0x1f, 0x7c, 0x5d, 0xe8, 0x19, 0x8c, 0x13, 0x62, 0xf2, 0xc9, 0xb7,
-	0x37, 0xfc, 0x04, 0xec, 0x10, 0x37, 0x6e, 0xae, 0xe9, 0x76, 0x88, 0x9b, 0xf7, 0xaa, 0xef, 0x67,
-	0xd0, 0x53, 0x9f, 0x4a, 0xbd, 0x77, 0x43, 0xdc, 0x2c, 0x0c, 0x44, 0x5f, 0x02, 0x29, 0x27, 0xda,
-	0x35, 0x83, 0x17, 0x30, 0x4e, 0xae, 0xe0, 0xce, 0xda, 0x54, 0xf4, 0xf2, 0xd1, 0x5d, 0xd1, 0xc7,
-	0x30, 0xfc, 0x8d, 0x09, 0x99, 0x8b, 0x4d, 0x7f, 0x80, 0x51, 0x11, 0x12, 0x31, 0xf9, 0x12, 0x3a,
-	0x29, 0xd3, 0x8a, 0x42, 0xab, 0x3a, 0x89, 0xdb, 0xef, 0xb4, 0x07, 0x70, 0x82, 0x5c, 0xb0, 0x28,
-	0x54, 0xe1, 0xbe, 0x85, 0x6e, 0x66, 0x89, 0x38, 0x51, 0x2d, 0x7e, 0x85, 0xdc, 0x94, 0x6e, 0x2c,
-	0x32, 0x02, 0xa5, 0x77, 0x9a, 0xd2, 0xa6, 0xa3, 0xa5, 0xef, 0x1f, 0x18, 0x3a, 0xb8, 0xe2, 0x28,
-	0xce, 0xff, 0x8c, 0x2e, 0x30, 0x74, 0x70, 0x55, 0xd9, 0xa4, 0xa7, 0xd0, 0x49, 0x76, 0x59, 0xdd,
-	0xa7, 0x44, 0x05, 0xed, 0x04, 0xf8, 0xe0, 0x93, 0xcf, 0x01, 0x96, 0xfa, 0x46, 0xf8, 0xae, 0x27,
-	0xf5, 0x2a, 0x58, 0x4e, 0xc7, 0x20, 0x3f, 0x49, 0xe5, 0x1b, 0x78, 0x42, 0xaa, 0x71, 0xf9, 0x5a,
-	0xc9, 0x2c, 0xc7, 0x56, 0xc0, 0x42, 0xa0, 0x22, 0x7d, 0xa0, 0x38, 0x30, 0xf9, 0x15, 0xe3, 0xb9,
-	0x8b, 0x5b, 0x2f, 0x5c, 0xdc, 0x3f, 0x12, 0x06, 0xb3, 0xa3, 0x22, 0x26, 0xaf, 0x61, 0xc0, 0x13,
-	0xd3, 0x95, 0xaa, 0xf4, 0x94, 0xb2, 0x89, 0xa6, 0xac, 0xd4, 0x94, 0xd3, 0xe7, 0x39, 0x40, 0xd0,
-	0xf7, 0x30, 0x72, 0xf0, 0x2a, 0xba, 0xc0, 0x07, 0x24, 0xbf, 0x97, 0x00, 0xfa, 0x15, 0x8c, 0x4b,
-	0x91, 0x76, 0xdd, 0x86, 0x77, 0x30, 0x3e, 0x41, 0xce, 0x56, 0x37, 0xbb, 0xf7, 0x60, 0x96, 0x5b,
-	0x4d, 0x93, 0x38, 0xdb, 0xc5, 0xdf, 0x81, 0x94, 0xc3, 0x88, 0x58, 0x79, 0x5c, 0x29, 0x94, 0x61,
-	0x96, 0x38, 0xb5, 0x8b, 0x55, 0x35, 0x8a, 0x55, 0xbd, 0xfa, 0xbf, 0x09, 0xd6, 0x5b, 0xbc, 0x26,
-	0xdf, 0x43, 0x2f, 0xff, 0x1e, 0x90, 0x84, 0xce, 0xd2, 0xd3, 0x32, 0x7b, 0xb4, 0x05, 0x15, 0x31,
-	0xad, 0x29, 0xf7, 0xbc, 0xfa, 0x19, 0xf7, 0x92, 0x58, 0x1b, 0xf7, 0xb2, 0x4c, 0x26, 0xee, 0xf9,
-	0xa7, 0xc0, 0xb8, 0x97, 0x1e, 0x10, 0xe3, 0x5e, 0x7e, 0x33, 0x68, 0x8d, 0x1c, 0xc3, 0xa0, 0xa8,
-	0x4f, 0x64, 0x3f, 0x57, 0x68, 0x8e, 0xef, 0xd9, 0xe3, 0xad, 0x78, 0x1a, 0xa4, 0x28, 0x1f, 0x26,
-	0x48, 0x45, 0xbc, 0x4c, 0x90, 0xaa, 0xd6, 0x24, 0x41, 0x8a, 0x2a, 0x61, 0x82, 0x54, 0x54, 0xc6,
-	0x04, 0xa9, 0x4a, 0x0a, 0xad, 0x91, 0x37, 0xd0, 0xcf, 0x8b, 0x84, 0x30, 0x74, 0x94, 0xb4, 0xc4,
-	0xd0, 0x51, 0x96, 0x13, 0x5a, 0x23, 0x2f, 0x01, 0x7e, 0x45, 0x69, 0x84, 0x81, 0x0c, 0xf5, 0xb1,
-	0x5b, 0xd1, 0x98, 0x8d, 0x8a, 0x80, 0x76, 0xf9, 0x0e, 0xba, 0xb9, 0x45, 0x23, 0x9f, 0x65, 0xa1,
-	0x6f, 0x17, 0x65, 0x36, 0xa9, 0x82, 0xda, 0xf7, 0x47, 0xe8, 0x17, 0x56, 0x81, 0x3c, 0x32, 0xab,
-	0x58, 0x5c, 0xb4, 0xd9, 0xfe, 0x36, 0x38, 0x65, 0xad, 0x78, 0xa7, 0x0d, 0x6b, 0x95, 0x7d, 0x31,
-	0xac, 0x55, 0x17, 0x80, 0xd6, 0x7e, 0x9e, 0x00, 0x59, 0x46, 0x97, 0xf3, 0x65, 0xc4, 0x31, 0x12,
-	0x73, 0x1f, 0xaf, 0xd5, 0xd1, 0xd3, 0x96, 0xfe, 0xbf, 0xf7, 0xf5, 0xa7, 0x00, 0x00, 0x00, 0xff,
-	0xff, 0x49, 0x46, 0x0e, 0xa3, 0x00, 0x0a, 0x00, 0x00,
+func (m *Connector) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Connector.Unmarshal(m, b)
+}
+func (m *Connector) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Connector.Marshal(b, m, deterministic)
+}
+func (m *Connector) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Connector.Merge(m, src)
+}
+func (m *Connector) XXX_Size() int {
+	return xxx_messageInfo_Connector.Size(m)
+}
+func (m *Connector) XXX_DiscardUnknown() {
+	xxx_messageInfo_Connector.DiscardUnknown(m)
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+var xxx_messageInfo_Connector proto.InternalMessageInfo
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+func (m *Connector) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Connector) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *Connector) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Connector) GetConfig() []byte {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
+// CreateConnectorReq is a request to make a connector.
+type CreateConnectorReq struct {
+	Connector            *Connector `protobuf:"bytes,1,opt,name=connector,proto3" json:"connector,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *CreateConnectorReq) Reset()         { *m = CreateConnectorReq{} }
+func (m *CreateConnectorReq) String() string { return proto.CompactTextString(m) }
+func (*CreateConnectorReq) ProtoMessage()    {}
+func (*CreateConnectorReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{30}
+}
+
+func (m *CreateConnectorReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateConnectorReq.Unmarshal(m, b)
+}
+func (m *CreateConnectorReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateConnectorReq.Marshal(b, m, deterministic)
+}
+func (m *CreateConnectorReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateConnectorReq.Merge(m, src)
+}
+func (m *CreateConnectorReq) XXX_Size() int {
+	return xxx_messageInfo_CreateConnectorReq.Size(m)
+}
+func (m *CreateConnectorReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateConnectorReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateConnectorReq proto.InternalMessageInfo
+
+func (m *CreateConnectorReq) GetConnector() *Connector {
+	if m != nil {
+		return m.Connector
+	}
+	return nil
+}
+
+// CreateConnectorResp returns the response from creating a connector.
+type CreateConnectorResp struct {
+	AlreadyExists        bool     `protobuf:"varint,1,opt,name=already_exists,json=alreadyExists,proto3" json:"already_exists,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateConnectorResp) Reset()         { *m = CreateConnectorResp{} }
+func (m *CreateConnectorResp) String() string { return proto.CompactTextString(m) }
+func (*CreateConnectorResp) ProtoMessage()    {}
+func (*CreateConnectorResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{31}
+}
+
+func (m *CreateConnectorResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateConnectorResp.Unmarshal(m, b)
+}
+func (m *CreateConnectorResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateConnectorResp.Marshal(b, m, deterministic)
+}
+func (m *CreateConnectorResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateConnectorResp.Merge(m, src)
+}
+func (m *CreateConnectorResp) XXX_Size() int {
+	return xxx_messageInfo_CreateConnectorResp.Size(m)
+}
+func (m *CreateConnectorResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateConnectorResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateConnectorResp proto.InternalMessageInfo
+
+func (m *CreateConnectorResp) GetAlreadyExists() bool {
+	if m != nil {
+		return m.AlreadyExists
+	}
+	return false
+}
+
+// UpdateConnectorReq is a request to modify an existing connector.
+type UpdateConnectorReq struct {
+	// The id used to lookup the connector. This field cannot be modified
+	Id      string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	NewType string `protobuf:"bytes,2,opt,name=new_type,json=newType,proto3" json:"new_type,omitempty"`
+	NewName string `protobuf:"bytes,3,opt,name=new_name,json=newName,proto3" json:"new_name,omitempty"`
+	// Redacted secrets in the new config keep their current values.
+	NewConfig            []byte   `protobuf:"bytes,4,opt,name=new_config,json=newConfig,proto3" json:"new_config,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdateConnectorReq) Reset()         { *m = UpdateConnectorReq{} }
+func (m *UpdateConnectorReq) String() string { return proto.CompactTextString(m) }
+func (*UpdateConnectorReq) ProtoMessage()    {}
+func (*UpdateConnectorReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{32}
+}
+
+func (m *UpdateConnectorReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdateConnectorReq.Unmarshal(m, b)
+}
+func (m *UpdateConnectorReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdateConnectorReq.Marshal(b, m, deterministic)
+}
+func (m *UpdateConnectorReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdateConnectorReq.Merge(m, src)
+}
+func (m *UpdateConnectorReq) XXX_Size() int {
+	return xxx_messageInfo_UpdateConnectorReq.Size(m)
+}
+func (m *UpdateConnectorReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdateConnectorReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdateConnectorReq proto.InternalMessageInfo
+
+func (m *UpdateConnectorReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *UpdateConnectorReq) GetNewType() string {
+	if m != nil {
+		return m.NewType
+	}
+	return ""
+}
+
+func (m *UpdateConnectorReq) GetNewName() string {
+	if m != nil {
+		return m.NewName
+	}
+	return ""
+}
+
+func (m *UpdateConnectorReq) GetNewConfig() []byte {
+	if m != nil {
+		return m.NewConfig
+	}
+	return nil
+}
+
+// UpdateConnectorResp returns the response from modifying an existing connector.
+type UpdateConnectorResp struct {
+	NotFound             bool     `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdateConnectorResp) Reset()         { *m = UpdateConnectorResp{} }
+func (m *UpdateConnectorResp) String() string { return proto.CompactTextString(m) }
+func (*UpdateConnectorResp) ProtoMessage()    {}
+func (*UpdateConnectorResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{33}
+}
+
+func (m *UpdateConnectorResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdateConnectorResp.Unmarshal(m, b)
+}
+func (m *UpdateConnectorResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdateConnectorResp.Marshal(b, m, deterministic)
+}
+func (m *UpdateConnectorResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdateConnectorResp.Merge(m, src)
+}
+func (m *UpdateConnectorResp) XXX_Size() int {
+	return xxx_messageInfo_UpdateConnectorResp.Size(m)
+}
+func (m *UpdateConnectorResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdateConnectorResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdateConnectorResp proto.InternalMessageInfo
+
+func (m *UpdateConnectorResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+// DeleteConnectorReq is a request to delete a connector.
+type DeleteConnectorReq struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteConnectorReq) Reset()         { *m = DeleteConnectorReq{} }
+func (m *DeleteConnectorReq) String() string { return proto.CompactTextString(m) }
+func (*DeleteConnectorReq) ProtoMessage()    {}
+func (*DeleteConnectorReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{34}
+}
+
+func (m *DeleteConnectorReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteConnectorReq.Unmarshal(m, b)
+}
+func (m *DeleteConnectorReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteConnectorReq.Marshal(b, m, deterministic)
+}
+func (m *DeleteConnectorReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteConnectorReq.Merge(m, src)
+}
+func (m *DeleteConnectorReq) XXX_Size() int {
+	return xxx_messageInfo_DeleteConnectorReq.Size(m)
+}
+func (m *DeleteConnectorReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteConnectorReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteConnectorReq proto.InternalMessageInfo
+
+func (m *DeleteConnectorReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+// DeleteConnectorResp returns the response from deleting a connector.
+type DeleteConnectorResp struct {
+	NotFound             bool     `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteConnectorResp) Reset()         { *m = DeleteConnectorResp{} }
+func (m *DeleteConnectorResp) String() string { return proto.CompactTextString(m) }
+func (*DeleteConnectorResp) ProtoMessage()    {}
+func (*DeleteConnectorResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{35}
+}
+
+func (m *DeleteConnectorResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteConnectorResp.Unmarshal(m, b)
+}
+func (m *DeleteConnectorResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteConnectorResp.Marshal(b, m, deterministic)
+}
+func (m *DeleteConnectorResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteConnectorResp.Merge(m, src)
+}
+func (m *DeleteConnectorResp) XXX_Size() int {
+	return xxx_messageInfo_DeleteConnectorResp.Size(m)
+}
+func (m *DeleteConnectorResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteConnectorResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteConnectorResp proto.InternalMessageInfo
+
+func (m *DeleteConnectorResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+// ListConnectorReq is a request to enumerate connectors.
+type ListConnectorReq struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListConnectorReq) Reset()         { *m = ListConnectorReq{} }
+func (m *ListConnectorReq) String() string { return proto.CompactTextString(m) }
+func (*ListConnectorReq) ProtoMessage()    {}
+func (*ListConnectorReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{36}
+}
+
+func (m *ListConnectorReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListConnectorReq.Unmarshal(m, b)
+}
+func (m *ListConnectorReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListConnectorReq.Marshal(b, m, deterministic)
+}
+func (m *ListConnectorReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListConnectorReq.Merge(m, src)
+}
+func (m *ListConnectorReq) XXX_Size() int {
+	return xxx_messageInfo_ListConnectorReq.Size(m)
+}
+func (m *ListConnectorReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListConnectorReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListConnectorReq proto.InternalMessageInfo
+
+// ListConnectorResp returns a list of connectors. Secrets in their configs are redacted.
+type ListConnectorResp struct {
+	Connectors           []*Connector `protobuf:"bytes,1,rep,name=connectors,proto3" json:"connectors,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *ListConnectorResp) Reset()         { *m = ListConnectorResp{} }
+func (m *ListConnectorResp) String() string { return proto.CompactTextString(m) }
+func (*ListConnectorResp) ProtoMessage()    {}
+func (*ListConnectorResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b40cafcd4234784, []int{37}
+}
+
+func (m *ListConnectorResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListConnectorResp.Unmarshal(m, b)
+}
+func (m *ListConnectorResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListConnectorResp.Marshal(b, m, deterministic)
+}
+func (m *ListConnectorResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListConnectorResp.Merge(m, src)
+}
+func (m *ListConnectorResp) XXX_Size() int {
+	return xxx_messageInfo_ListConnectorResp.Size(m)
+}
+func (m *ListConnectorResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListConnectorResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListConnectorResp proto.InternalMessageInfo
+
+func (m *ListConnectorResp) GetConnectors() []*Connector {
+	if m != nil {
+		return m.Connectors
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Client)(nil), "api.Client")
+	proto.RegisterType((*CreateClientReq)(nil), "api.CreateClientReq")
+	proto.RegisterType((*CreateClientResp)(nil), "api.CreateClientResp")
+	proto.RegisterType((*DeleteClientReq)(nil), "api.DeleteClientReq")
+	proto.RegisterType((*DeleteClientResp)(nil), "api.DeleteClientResp")
+	proto.RegisterType((*UpdateClientReq)(nil), "api.UpdateClientReq")
+	proto.RegisterType((*UpdateClientResp)(nil), "api.UpdateClientResp")
+	proto.RegisterType((*AddClientSecretReq)(nil), "api.AddClientSecretReq")
+	proto.RegisterType((*AddClientSecretResp)(nil), "api.AddClientSecretResp")
+	proto.RegisterType((*RemoveClientSecretReq)(nil), "api.RemoveClientSecretReq")
+	proto.RegisterType((*RemoveClientSecretResp)(nil), "api.RemoveClientSecretResp")
+	proto.RegisterType((*Password)(nil), "api.Password")
+	proto.RegisterType((*CreatePasswordReq)(nil), "api.CreatePasswordReq")
+	proto.RegisterType((*CreatePasswordResp)(nil), "api.CreatePasswordResp")
+	proto.RegisterType((*UpdatePasswordReq)(nil), "api.UpdatePasswordReq")
+	proto.RegisterType((*UpdatePasswordResp)(nil), "api.UpdatePasswordResp")
+	proto.RegisterType((*DeletePasswordReq)(nil), "api.DeletePasswordReq")
+	proto.RegisterType((*DeletePasswordResp)(nil), "api.DeletePasswordResp")
+	proto.RegisterType((*ListPasswordReq)(nil), "api.ListPasswordReq")
+	proto.RegisterType((*ListPasswordResp)(nil), "api.ListPasswordResp")
+	proto.RegisterType((*VersionReq)(nil), "api.VersionReq")
+	proto.RegisterType((*VersionResp)(nil), "api.VersionResp")
+	proto.RegisterType((*RefreshTokenRef)(nil), "api.RefreshTokenRef")
+	proto.RegisterType((*ListRefreshReq)(nil), "api.ListRefreshReq")
+	proto.RegisterType((*ListRefreshResp)(nil), "api.ListRefreshResp")
+	proto.RegisterType((*RevokeRefreshReq)(nil), "api.RevokeRefreshReq")
+	proto.RegisterType((*RevokeRefreshResp)(nil), "api.RevokeRefreshResp")
+	proto.RegisterType((*VerifyPasswordReq)(nil), "api.VerifyPasswordReq")
+	proto.RegisterType((*VerifyPasswordResp)(nil), "api.VerifyPasswordResp")
+	proto.RegisterType((*Connector)(nil), "api.Connector")
+	proto.RegisterType((*CreateConnectorReq)(nil), "api.CreateConnectorReq")
+	proto.RegisterType((*CreateConnectorResp)(nil), "api.CreateConnectorResp")
+	proto.RegisterType((*UpdateConnectorReq)(nil), "api.UpdateConnectorReq")
+	proto.RegisterType((*UpdateConnectorResp)(nil), "api.UpdateConnectorResp")
+	proto.RegisterType((*DeleteConnectorReq)(nil), "api.DeleteConnectorReq")
+	proto.RegisterType((*DeleteConnectorResp)(nil), "api.DeleteConnectorResp")
+	proto.RegisterType((*ListConnectorReq)(nil), "api.ListConnectorReq")
+	proto.RegisterType((*ListConnectorResp)(nil), "api.ListConnectorResp")
+}
+
+func init() { proto.RegisterFile("api/api.proto", fileDescriptor_1b40cafcd4234784) }
+
+var fileDescriptor_1b40cafcd4234784 = []byte{
+	// 1352 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x58, 0xdb, 0x6e, 0xdb, 0x46,
+	0x13, 0x8e, 0x45, 0x5b, 0xa6, 0x46, 0x92, 0x25, 0xad, 0x6d, 0x89, 0x61, 0x10, 0xc0, 0x61, 0xfe,
+	0x1f, 0x70, 0x90, 0xd6, 0x69, 0xd2, 0x13, 0x90, 0xb4, 0x69, 0x1d, 0xe5, 0x08, 0xb4, 0x69, 0xc0,
+	0xc4, 0xb9, 0x68, 0x81, 0x0a, 0x0c, 0x39, 0x76, 0x88, 0xc8, 0x24, 0xbb, 0x4b, 0x59, 0x76, 0x5f,
+	0xae, 0x6f, 0xd3, 0x97, 0xe8, 0x4d, 0xb1, 0x07, 0x52, 0xcb, 0x83, 0x2d, 0xbb, 0x77, 0xdc, 0x6f,
+	0x66, 0xbe, 0xd9, 0xd9, 0x99, 0x9d, 0x59, 0x09, 0xba, 0x5e, 0x12, 0xde, 0xf3, 0x92, 0x70, 0x2f,
+	0xa1, 0x71, 0x1a, 0x13, 0xc3, 0x4b, 0x42, 0xe7, 0x6f, 0x03, 0x9a, 0xe3, 0x69, 0x88, 0x51, 0x4a,
+	0x36, 0xa0, 0x11, 0x06, 0xd6, 0xca, 0xce, 0xca, 0x6e, 0xcb, 0x6d, 0x84, 0x01, 0x19, 0x42, 0x93,
+	0xa1, 0x4f, 0x31, 0xb5, 0x1a, 0x02, 0x53, 0x2b, 0x72, 0x1b, 0xba, 0x14, 0x83, 0x90, 0xa2, 0x9f,
+	0x4e, 0x66, 0x34, 0x64, 0x96, 0xb1, 0x63, 0xec, 0xb6, 0xdc, 0x4e, 0x06, 0x1e, 0xd0, 0x90, 0x71,
+	0xa5, 0x94, 0xce, 0x58, 0x8a, 0xc1, 0x24, 0x41, 0xa4, 0xcc, 0x5a, 0x95, 0x4a, 0x0a, 0x7c, 0xc3,
+	0x31, 0xee, 0x21, 0x99, 0x7d, 0x98, 0x86, 0xbe, 0xb5, 0xb6, 0xb3, 0xb2, 0x6b, 0xba, 0x6a, 0x45,
+	0x08, 0xac, 0x46, 0xde, 0x31, 0x5a, 0x4d, 0xe1, 0x57, 0x7c, 0x93, 0xeb, 0x60, 0x4e, 0xe3, 0xa3,
+	0x78, 0x32, 0xa3, 0x53, 0x6b, 0x5d, 0xe0, 0xeb, 0x7c, 0x7d, 0x40, 0xa7, 0xe4, 0xff, 0xb0, 0xe1,
+	0x4d, 0xa7, 0xf1, 0x1c, 0x83, 0x09, 0xf3, 0xe3, 0x04, 0x99, 0x65, 0x0a, 0x67, 0x5d, 0x85, 0xbe,
+	0x15, 0x20, 0xb9, 0x0b, 0x83, 0x4c, 0xcd, 0x9b, 0x05, 0x21, 0x46, 0x3e, 0x32, 0xab, 0x25, 0x34,
+	0xfb, 0x4a, 0xb0, 0x9f, 0xe1, 0x64, 0x0f, 0x36, 0x33, 0xe5, 0x23, 0xea, 0x45, 0xe9, 0x24, 0x3d,
+	0xe3, 0xc4, 0x20, 0xd4, 0x33, 0x9e, 0x17, 0x5c, 0xf2, 0x8e, 0x0b, 0xc8, 0x57, 0x30, 0xcc, 0xf4,
+	0x29, 0xb2, 0x24, 0x8e, 0x18, 0x2a, 0x93, 0xb6, 0x30, 0xd9, 0x52, 0x52, 0x57, 0x09, 0xa5, 0xd5,
+	0xe7, 0x40, 0x32, 0x2b, 0x3f, 0x8e, 0x22, 0xf4, 0xd3, 0x98, 0x32, 0xab, 0x53, 0x70, 0x32, 0xce,
+	0x05, 0xe4, 0x21, 0x5c, 0xf7, 0x45, 0xae, 0x26, 0x3e, 0xc5, 0x00, 0xa3, 0x34, 0xf4, 0xa6, 0x2c,
+	0x8b, 0xb9, 0x2b, 0xac, 0x46, 0x52, 0x61, 0xbc, 0x90, 0xcb, 0xe8, 0x9d, 0x6f, 0xa0, 0x37, 0xa6,
+	0xe8, 0xa5, 0x28, 0xb3, 0xed, 0xe2, 0x1f, 0xe4, 0x36, 0x34, 0xa5, 0xb6, 0x48, 0x7a, 0xfb, 0x41,
+	0x7b, 0x8f, 0x17, 0x87, 0x92, 0x2b, 0x91, 0xf3, 0x3b, 0xf4, 0x8b, 0x76, 0x2c, 0x91, 0x07, 0x4e,
+	0xd1, 0x0b, 0xce, 0x26, 0x78, 0x1a, 0xb2, 0x94, 0x09, 0x02, 0xd3, 0xed, 0x2a, 0xf4, 0x99, 0x00,
+	0x35, 0xfe, 0xc6, 0xf9, 0xfc, 0xb7, 0xa0, 0xf7, 0x14, 0xa7, 0xa8, 0xef, 0xab, 0x54, 0x88, 0xce,
+	0x3d, 0xe8, 0x17, 0x55, 0x58, 0x42, 0x6e, 0x40, 0x2b, 0x8a, 0xd3, 0xc9, 0x61, 0x3c, 0x8b, 0x02,
+	0xe5, 0xdd, 0x8c, 0xe2, 0xf4, 0x39, 0x5f, 0x3b, 0x7f, 0x19, 0xd0, 0x3b, 0x48, 0x02, 0xef, 0x02,
+	0xd2, 0x6a, 0x15, 0x37, 0x2e, 0x53, 0xc5, 0x46, 0x4d, 0x15, 0x67, 0xd5, 0xba, 0x7a, 0x4e, 0xb5,
+	0xae, 0x2d, 0xab, 0xd6, 0xe6, 0xa5, 0xab, 0x75, 0xfd, 0x6a, 0xd5, 0x6a, 0x5e, 0xbd, 0x5a, 0x5b,
+	0x57, 0xae, 0x56, 0xf8, 0x4f, 0xd5, 0xda, 0xbe, 0xb8, 0x5a, 0xef, 0x41, 0xbf, 0x98, 0xc0, 0x65,
+	0x29, 0xf7, 0x80, 0xec, 0x07, 0x81, 0xd4, 0x7e, 0x2b, 0xfa, 0x14, 0x4f, 0xfa, 0x0d, 0x68, 0xa9,
+	0x2d, 0xe4, 0xb9, 0x37, 0x25, 0xf0, 0xea, 0xfc, 0xfe, 0x36, 0x84, 0x26, 0x9e, 0x26, 0x21, 0x3d,
+	0xb3, 0x8c, 0x9d, 0x95, 0x5d, 0xc3, 0x55, 0x2b, 0xe7, 0x57, 0xd8, 0xac, 0xb8, 0x58, 0xb2, 0x2d,
+	0x55, 0x75, 0x8d, 0x9a, 0x9e, 0x6a, 0xe8, 0x3e, 0x9d, 0xa7, 0xb0, 0xed, 0xe2, 0x71, 0x7c, 0x82,
+	0x57, 0x8a, 0xa0, 0xc4, 0xee, 0x7c, 0x0d, 0xc3, 0x3a, 0x96, 0x65, 0x67, 0x17, 0x82, 0xf9, 0xc6,
+	0x63, 0x6c, 0x1e, 0xd3, 0x80, 0x6c, 0xc1, 0x1a, 0x1e, 0x7b, 0xe1, 0x54, 0xf9, 0x92, 0x0b, 0x5e,
+	0xe2, 0x1f, 0x3d, 0xf6, 0x51, 0xb8, 0xea, 0xb8, 0xe2, 0x9b, 0xd8, 0x60, 0xce, 0x18, 0x52, 0x51,
+	0xfa, 0x32, 0x98, 0x7c, 0x4d, 0x46, 0xb0, 0xce, 0xbf, 0xf9, 0x9e, 0xe5, 0xad, 0x68, 0xf2, 0xe5,
+	0xab, 0xc0, 0x79, 0x0c, 0x03, 0xd9, 0x4d, 0x32, 0x87, 0x3c, 0xc6, 0x3b, 0x60, 0x26, 0x6a, 0xa9,
+	0x3a, 0x51, 0x57, 0x74, 0x8a, 0x5c, 0x27, 0x17, 0x3b, 0x8f, 0x80, 0x94, 0xed, 0x2f, 0xdd, 0x8f,
+	0x9c, 0x23, 0x18, 0xc8, 0xa2, 0xd2, 0x9d, 0xd7, 0x07, 0x7c, 0x1d, 0xcc, 0x08, 0xe7, 0x13, 0x2d,
+	0xe8, 0xf5, 0x08, 0xe7, 0x2f, 0x79, 0xdc, 0xb7, 0xa0, 0xc3, 0x45, 0xa5, 0xd8, 0xdb, 0x11, 0xce,
+	0x0f, 0x14, 0xe4, 0xdc, 0x07, 0x52, 0x76, 0xb4, 0x2c, 0x07, 0x77, 0x60, 0x20, 0x7b, 0xdc, 0xd2,
+	0xbd, 0x71, 0xf6, 0xb2, 0xea, 0x32, 0xf6, 0x01, 0xf4, 0x7e, 0x0a, 0x59, 0xaa, 0x71, 0x3b, 0x3f,
+	0x40, 0xbf, 0x08, 0xb1, 0x84, 0xdc, 0x85, 0x56, 0x76, 0xd2, 0xfc, 0x08, 0x8d, 0x6a, 0x26, 0x16,
+	0x72, 0xa7, 0x03, 0xf0, 0x1e, 0x29, 0x0b, 0xe3, 0x88, 0xd3, 0x7d, 0x0b, 0xed, 0x7c, 0xc5, 0x12,
+	0x59, 0xe7, 0xf4, 0x04, 0xa9, 0xda, 0xba, 0x5a, 0x91, 0x3e, 0xf0, 0x57, 0x87, 0x38, 0xd2, 0x35,
+	0x97, 0x7f, 0x3a, 0x7f, 0x42, 0xcf, 0xc5, 0x43, 0x8a, 0xec, 0xe3, 0xbb, 0xf8, 0x13, 0x46, 0x2e,
+	0x1e, 0x56, 0x5a, 0x75, 0xe1, 0x0e, 0x34, 0x4a, 0x77, 0xe0, 0x26, 0x80, 0x2f, 0x2a, 0x22, 0x98,
+	0x78, 0xa9, 0xe8, 0xb5, 0x86, 0xdb, 0x52, 0xc8, 0x7e, 0xca, 0x6d, 0xa7, 0x1e, 0x4b, 0x79, 0xba,
+	0x02, 0xf1, 0x9e, 0x30, 0x5c, 0x93, 0x03, 0x07, 0x0c, 0xf9, 0xa1, 0x6f, 0xf0, 0x33, 0x50, 0xfe,
+	0xf9, 0x89, 0x6b, 0x85, 0xbb, 0x52, 0x28, 0xdc, 0xd7, 0xd0, 0x2b, 0xa8, 0xb2, 0x84, 0x3c, 0x82,
+	0x0d, 0x2a, 0x97, 0x93, 0x94, 0x6f, 0x3d, 0x3b, 0xb2, 0x2d, 0x71, 0x64, 0xa5, 0xa0, 0xdc, 0x2e,
+	0xd5, 0x00, 0xe6, 0xbc, 0x84, 0xbe, 0x8b, 0x27, 0xf1, 0x27, 0xbc, 0x84, 0xf3, 0x0b, 0x0f, 0xc0,
+	0xf9, 0x02, 0x06, 0x25, 0xa6, 0x65, 0xd5, 0xf0, 0x0c, 0x06, 0xef, 0x91, 0x86, 0x87, 0x67, 0xcb,
+	0xef, 0x81, 0xad, 0x5d, 0x4d, 0xe5, 0x38, 0xbf, 0x8b, 0x3f, 0x03, 0x29, 0xd3, 0xb0, 0x84, 0x5b,
+	0x9c, 0x70, 0x34, 0xc4, 0xdc, 0x71, 0xb6, 0x2e, 0xee, 0xaa, 0x51, 0xda, 0xd5, 0x6f, 0xd0, 0xca,
+	0x87, 0x47, 0xa5, 0x04, 0x08, 0xac, 0xf2, 0xf9, 0xa4, 0xf6, 0x20, 0xbe, 0xf3, 0xb9, 0x6b, 0x68,
+	0x73, 0x77, 0x08, 0x4d, 0x3f, 0x8e, 0x0e, 0xc3, 0x23, 0xd1, 0x77, 0x3a, 0xae, 0x5a, 0x39, 0x4f,
+	0xb2, 0xbe, 0x91, 0xbb, 0xe0, 0x31, 0x7f, 0x06, 0xad, 0x7c, 0x90, 0xa9, 0xce, 0xb3, 0x21, 0xdf,
+	0x28, 0xb9, 0xd6, 0x42, 0xc1, 0xf9, 0x0e, 0x36, 0x2b, 0x1c, 0x97, 0x6f, 0x3e, 0x67, 0x59, 0x4f,
+	0x28, 0xec, 0xa0, 0x1c, 0xa7, 0xea, 0x3b, 0x5a, 0xac, 0xbc, 0xef, 0xf0, 0xf1, 0x9b, 0x89, 0xb4,
+	0x90, 0xb9, 0xe8, 0x35, 0x8f, 0xfa, 0x26, 0x00, 0x17, 0x15, 0x22, 0x6f, 0x45, 0x38, 0x1f, 0xcb,
+	0xe0, 0x1f, 0xc0, 0x66, 0xc5, 0xf5, 0xb2, 0x1a, 0xf9, 0x5f, 0xd6, 0x64, 0x2e, 0xda, 0x2e, 0x67,
+	0xae, 0x68, 0x2d, 0x63, 0x26, 0xb2, 0xf1, 0xe8, 0xbc, 0xce, 0x18, 0x06, 0x25, 0x8c, 0x25, 0x64,
+	0x0f, 0x40, 0x7b, 0x66, 0xc8, 0xbb, 0x55, 0x4e, 0x8f, 0xa6, 0xf1, 0xe0, 0x1f, 0x13, 0x8c, 0xa7,
+	0x78, 0x4a, 0xbe, 0x87, 0x8e, 0xfe, 0x62, 0x25, 0xf2, 0x3e, 0x96, 0x1e, 0xbf, 0xf6, 0x76, 0x0d,
+	0xca, 0x12, 0xe7, 0x1a, 0x37, 0xd7, 0x9f, 0x1e, 0xca, 0xbc, 0xf4, 0x9c, 0xb4, 0xb7, 0x6b, 0xd0,
+	0xcc, 0x5c, 0x7f, 0xac, 0x2a, 0xf3, 0xd2, 0x13, 0xd7, 0xde, 0xae, 0x41, 0x85, 0xf9, 0x73, 0xe8,
+	0x95, 0x1e, 0x19, 0x64, 0x24, 0x74, 0xab, 0xaf, 0x1b, 0xdb, 0xaa, 0x17, 0x08, 0x9e, 0x5f, 0x80,
+	0x54, 0x9f, 0x02, 0xc4, 0x56, 0xad, 0xa9, 0xe6, 0xa5, 0x61, 0xdf, 0x38, 0x57, 0x26, 0x08, 0xc7,
+	0xb0, 0x51, 0x9c, 0xbc, 0x64, 0xa8, 0x9d, 0xa0, 0xd6, 0x49, 0xec, 0x51, 0x2d, 0x9e, 0x91, 0x14,
+	0x07, 0xa3, 0x22, 0xa9, 0x8c, 0x65, 0x7b, 0x54, 0x8b, 0x67, 0x24, 0xc5, 0xf9, 0xa7, 0x48, 0x2a,
+	0xf3, 0xd3, 0x1e, 0xd5, 0xe2, 0x82, 0xe4, 0x31, 0x74, 0xf5, 0xf1, 0xc7, 0x54, 0x9e, 0x4a, 0x53,
+	0xd2, 0xde, 0xae, 0x41, 0x85, 0xfd, 0x7d, 0x80, 0x17, 0x98, 0xaa, 0x91, 0x47, 0x7a, 0x42, 0x6d,
+	0x31, 0x0e, 0xed, 0x7e, 0x11, 0x10, 0x26, 0x0f, 0xa1, 0xad, 0x8d, 0x10, 0xb2, 0x99, 0x53, 0x2f,
+	0x46, 0x80, 0xbd, 0x55, 0x05, 0x85, 0xed, 0x8f, 0xd0, 0x2d, 0x34, 0x79, 0xb2, 0xad, 0xb2, 0x55,
+	0x1c, 0x21, 0xf6, 0xb0, 0x0e, 0xce, 0x4e, 0xad, 0xd8, 0xad, 0xd5, 0xa9, 0x55, 0x26, 0x81, 0x3d,
+	0xaa, 0xc5, 0xb3, 0xea, 0x2c, 0xb5, 0x40, 0xa2, 0x67, 0x5b, 0xbf, 0xd3, 0xb6, 0x55, 0x2f, 0xc8,
+	0x78, 0x4a, 0x1d, 0x89, 0xe8, 0x09, 0xaf, 0xe1, 0xa9, 0x69, 0x60, 0x92, 0xa7, 0xd4, 0x7f, 0x88,
+	0x9e, 0xf3, 0x1a, 0x9e, 0x9a, 0x76, 0xe5, 0x5c, 0x23, 0xfb, 0xf2, 0x21, 0xa0, 0xfd, 0x78, 0x59,
+	0x24, 0xbe, 0x40, 0x32, 0xac, 0x83, 0x39, 0xc5, 0x93, 0x2d, 0x20, 0x7e, 0x7c, 0xbc, 0xe7, 0xc7,
+	0x14, 0x63, 0xb6, 0x17, 0xe0, 0x29, 0xd7, 0xfc, 0xd0, 0x14, 0x7f, 0xb5, 0x7c, 0xf9, 0xef, 0x00,
+	0x61, 0x1a, 0xdf, 0x2b, 0x7b, 0x11, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
 
 // DexClient is the client API for Dex service.
 //
@@ -1309,6 +2054,11 @@ type DexClient interface {
 	UpdateClient(ctx context.Context, in *UpdateClientReq, opts ...grpc.CallOption) (*UpdateClientResp, error)
 	// DeleteClient deletes the provided client.
 	DeleteClient(ctx context.Context, in *DeleteClientReq, opts ...grpc.CallOption) (*DeleteClientResp, error)
+	// AddClientSecret adds a secret to a client. Clients can authenticate with any
+	// of their secrets, which allows rotating them.
+	AddClientSecret(ctx context.Context, in *AddClientSecretReq, opts ...grpc.CallOption) (*AddClientSecretResp, error)
+	// RemoveClientSecret removes a secret from a client.
+	RemoveClientSecret(ctx context.Context, in *RemoveClientSecretReq, opts ...grpc.CallOption) (*RemoveClientSecretResp, error)
 	// CreatePassword creates a password.
 	CreatePassword(ctx context.Context, in *CreatePasswordReq, opts ...grpc.CallOption) (*CreatePasswordResp, error)
 	// UpdatePassword modifies existing password.
@@ -1327,6 +2077,14 @@ type DexClient interface {
 	RevokeRefresh(ctx context.Context, in *RevokeRefreshReq, opts ...grpc.CallOption) (*RevokeRefreshResp, error)
 	// VerifyPassword returns whether a password matches a hash for a specific email or not.
 	VerifyPassword(ctx context.Context, in *VerifyPasswordReq, opts ...grpc.CallOption) (*VerifyPasswordResp, error)
+	// CreateConnector creates a connector.
+	CreateConnector(ctx context.Context, in *CreateConnectorReq, opts ...grpc.CallOption) (*CreateConnectorResp, error)
+	// UpdateConnector modifies existing connector.
+	UpdateConnector(ctx context.Context, in *UpdateConnectorReq, opts ...grpc.CallOption) (*UpdateConnectorResp, error)
+	// DeleteConnector deletes the connector.
+	DeleteConnector(ctx context.Context, in *DeleteConnectorReq, opts ...grpc.CallOption) (*DeleteConnectorResp, error)
+	// ListConnectors lists all connector entries.
+	ListConnectors(ctx context.Context, in *ListConnectorReq, opts ...grpc.CallOption) (*ListConnectorResp, error)
 }
 
 type dexClient struct {
@@ -1364,6 +2122,24 @@ func (c *dexClient) DeleteClient(ctx context.Context, in *DeleteClientReq, opts
 	return out, nil
 }
 
+func (c *dexClient) AddClientSecret(ctx context.Context, in *AddClientSecretReq, opts ...grpc.CallOption) (*AddClientSecretResp, error) {
+	out := new(AddClientSecretResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/AddClientSecret", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) RemoveClientSecret(ctx context.Context, in *RemoveClientSecretReq, opts ...grpc.CallOption) (*RemoveClientSecretResp, error) {
+	out := new(RemoveClientSecretResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/RemoveClientSecret", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *dexClient) CreatePassword(ctx context.Context, in *CreatePasswordReq, opts ...grpc.CallOption) (*CreatePasswordResp, error) {
 	out := new(CreatePasswordResp)
 	err := c.cc.Invoke(ctx, "/api.Dex/CreatePassword", in, out, opts...)
@@ -1436,6 +2212,42 @@ func (c *dexClient) VerifyPassword(ctx context.Context, in *VerifyPasswordReq, o
 	return out, nil
 }
 
+func (c *dexClient) CreateConnector(ctx context.Context, in *CreateConnectorReq, opts ...grpc.CallOption) (*CreateConnectorResp, error) {
+	out := new(CreateConnectorResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/CreateConnector", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) UpdateConnector(ctx context.Context, in *UpdateConnectorReq, opts ...grpc.CallOption) (*UpdateConnectorResp, error) {
+	out := new(UpdateConnectorResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/UpdateConnector", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) DeleteConnector(ctx context.Context, in *DeleteConnectorReq, opts ...grpc.CallOption) (*DeleteConnectorResp, error) {
+	out := new(DeleteConnectorResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/DeleteConnector", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) ListConnectors(ctx context.Context, in *ListConnectorReq, opts ...grpc.CallOption) (*ListConnectorResp, error) {
+	out := new(ListConnectorResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/ListConnectors", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DexServer is the server API for Dex service.
 type DexServer interface {
 	// CreateClient creates a client.
@@ -1444,6 +2256,11 @@ type DexServer interface {
 	UpdateClient(context.Context, *UpdateClientReq) (*UpdateClientResp, error)
 	// DeleteClient deletes the provided client.
 	DeleteClient(context.Context, *DeleteClientReq) (*DeleteClientResp, error)
+	// AddClientSecret adds a secret to a client. Clients can authenticate with any
+	// of their secrets, which allows rotating them.
+	AddClientSecret(context.Context, *AddClientSecretReq) (*AddClientSecretResp, error)
+	// RemoveClientSecret removes a secret from a client.
+	RemoveClientSecret(context.Context, *RemoveClientSecretReq) (*RemoveClientSecretResp, error)
 	// CreatePassword creates a password.
 	CreatePassword(context.Context, *CreatePasswordReq) (*CreatePasswordResp, error)
 	// UpdatePassword modifies existing password.
@@ -1462,6 +2279,14 @@ type DexServer interface {
 	RevokeRefresh(context.Context, *RevokeRefreshReq) (*RevokeRefreshResp, error)
 	// VerifyPassword returns whether a password matches a hash for a specific email or not.
 	VerifyPassword(context.Context, *VerifyPasswordReq) (*VerifyPasswordResp, error)
+	// CreateConnector creates a connector.
+	CreateConnector(context.Context, *CreateConnectorReq) (*CreateConnectorResp, error)
+	// UpdateConnector modifies existing connector.
+	UpdateConnector(context.Context, *UpdateConnectorReq) (*UpdateConnectorResp, error)
+	// DeleteConnector deletes the connector.
+	DeleteConnector(context.Context, *DeleteConnectorReq) (*DeleteConnectorResp, error)
+	// ListConnectors lists all connector entries.
+	ListConnectors(context.Context, *ListConnectorReq) (*ListConnectorResp, error)
 }
 
 // UnimplementedDexServer can be embedded to have forward compatible implementations.
@@ -1477,6 +2302,12 @@ func (*UnimplementedDexServer) UpdateClient(ctx context.Context, req *UpdateClie
 func (*UnimplementedDexServer) DeleteClient(ctx context.Context, req *DeleteClientReq) (*DeleteClientResp, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteClient not implemented")
 }
+func (*UnimplementedDexServer) AddClientSecret(ctx context.Context, req *AddClientSecretReq) (*AddClientSecretResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddClientSecret not implemented")
+}
+func (*UnimplementedDexServer) RemoveClientSecret(ctx context.Context, req *RemoveClientSecretReq) (*RemoveClientSecretResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveClientSecret not implemented")
+}
 func (*UnimplementedDexServer) CreatePassword(ctx context.Context, req *CreatePasswordReq) (*CreatePasswordResp, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreatePassword not implemented")
 }
@@ -1501,6 +2332,18 @@ func (*UnimplementedDexServer) RevokeRefresh(ctx context.Context, req *RevokeRef
 func (*UnimplementedDexServer) VerifyPassword(ctx context.Context, req *VerifyPasswordReq) (*VerifyPasswordResp, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method VerifyPassword not implemented")
 }
+func (*UnimplementedDexServer) CreateConnector(ctx context.Context, req *CreateConnectorReq) (*CreateConnectorResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateConnector not implemented")
+}
+func (*UnimplementedDexServer) UpdateConnector(ctx context.Context, req *UpdateConnectorReq) (*UpdateConnectorResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateConnector not implemented")
+}
+func (*UnimplementedDexServer) DeleteConnector(ctx context.Context, req *DeleteConnectorReq) (*DeleteConnectorResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteConnector not implemented")
+}
+func (*UnimplementedDexServer) ListConnectors(ctx context.Context, req *ListConnectorReq) (*ListConnectorResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListConnectors not implemented")
+}
 
 func RegisterDexServer(s *grpc.Server, srv DexServer) {
 	s.RegisterService(&_Dex_serviceDesc, srv)
@@ -1560,6 +2403,42 @@ func _Dex_DeleteClient_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Dex_AddClientSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddClientSecretReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).AddClientSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/AddClientSecret",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).AddClientSecret(ctx, req.(*AddClientSecretReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_RemoveClientSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveClientSecretReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).RemoveClientSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/RemoveClientSecret",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).RemoveClientSecret(ctx, req.(*RemoveClientSecretReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Dex_CreatePassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CreatePasswordReq)
 	if err := dec(in); err != nil {
@@ -1704,6 +2583,78 @@ func _Dex_VerifyPassword_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Dex_CreateConnector_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateConnectorReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).CreateConnector(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/CreateConnector",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).CreateConnector(ctx, req.(*CreateConnectorReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_UpdateConnector_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateConnectorReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).UpdateConnector(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/UpdateConnector",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).UpdateConnector(ctx, req.(*UpdateConnectorReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_DeleteConnector_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteConnectorReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).DeleteConnector(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/DeleteConnector",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).DeleteConnector(ctx, req.(*DeleteConnectorReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_ListConnectors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListConnectorReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).ListConnectors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/ListConnectors",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).ListConnectors(ctx, req.(*ListConnectorReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Dex_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "api.Dex",
 	HandlerType: (*DexServer)(nil),
@@ -1720,6 +2671,14 @@ var _Dex_serviceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteClient",
 			Handler:    _Dex_DeleteClient_Handler,
 		},
+		{
+			MethodName: "AddClientSecret",
+			Handler:    _Dex_AddClientSecret_Handler,
+		},
+		{
+			MethodName: "RemoveClientSecret",
+			Handler:    _Dex_RemoveClientSecret_Handler,
+		},
 		{
 			MethodName: "CreatePassword",
 			Handler:    _Dex_CreatePassword_Handler,
@@ -1752,6 +2711,22 @@ var _Dex_serviceDesc = grpc.ServiceDesc{
 			MethodName: "VerifyPassword",
 			Handler:    _Dex_VerifyPassword_Handler,
 		},
+		{
+			MethodName: "CreateConnector",
+			Handler:    _Dex_CreateConnector_Handler,
+		},
+		{
+			MethodName: "UpdateConnector",
+			Handler:    _Dex_UpdateConnector_Handler,
+		},
+		{
+			MethodName: "DeleteConnector",
+			Handler:    _Dex_DeleteConnector_Handler,
+		},
+		{
+			MethodName: "ListConnectors",
+			Handler:    _Dex_ListConnectors_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/api.proto",
@@ -11,6 +11,7 @@ import (
 
 	"github.com/dexidp/dex/pkg/log"
 	"github.com/dexidp/dex/server"
+	"github.com/dexidp/dex/server/signer/pkcs11"
 	"github.com/dexidp/dex/storage"
 	"github.com/dexidp/dex/storage/etcd"
 	"github.com/dexidp/dex/storage/kubernetes"
@@ -22,6 +23,7 @@ import (
 type Config struct {
 	Issuer    string    `json:"issuer"`
 	Storage   Storage   `json:"storage"`
+	Signer    Signer    `json:"signer"`
 	Web       Web       `json:"web"`
 	Telemetry Telemetry `json:"telemetry"`
 	OAuth2    OAuth2    `json:"oauth2"`
@@ -62,10 +64,13 @@ func (c Config) Validate() error {
 		{c.Web.HTTP == "" && c.Web.HTTPS == "", "must supply a HTTP/HTTPS  address to listen on"},
 		{c.Web.HTTPS != "" && c.Web.TLSCert == "", "no cert specified for HTTPS"},
 		{c.Web.HTTPS != "" && c.Web.TLSKey == "", "no private key specified for HTTPS"},
+		{c.Web.HTTPS == "" && c.Web.TLSClientAuth, "cannot enable TLS client auth without a HTTPS address"},
+		{!c.Web.TLSClientAuth && c.Web.TLSClientCA != "", "cannot specify web TLS client CA without enabling TLS client auth"},
 		{c.GRPC.TLSCert != "" && c.GRPC.Addr == "", "no address specified for gRPC"},
 		{c.GRPC.TLSKey != "" && c.GRPC.Addr == "", "no address specified for gRPC"},
 		{(c.GRPC.TLSCert == "") != (c.GRPC.TLSKey == ""), "must specific both a gRPC TLS cert and key"},
 		{c.GRPC.TLSCert == "" && c.GRPC.TLSClientCA != "", "cannot specify gRPC TLS client CA without a gRPC TLS cert"},
+		{c.Signer.Config != nil && (c.OAuth2.SigningKeyAlgorithm != "" || c.OAuth2.SigningKeySize != 0), "cannot specify oauth2 signing key algorithm or size with a signer, configure them on the signer"},
 	}
 
 	var checkErrors []string
@@ -135,6 +140,21 @@ type OAuth2 struct {
 	AlwaysShowLoginScreen bool `json:"alwaysShowLoginScreen"`
 	// This is the connector that can be used for password grant
 	PasswordConnector string `json:"passwordConnector"`
+	// If specified, logging out also revokes all refresh tokens of the user
+	RevokeRefreshTokensOnLogout bool `json:"revokeRefreshTokensOnLogout"`
+	// If specified, clients can register themselves at the registration
+	// endpoint using this initial access token
+	InitialAccessToken string `json:"initialAccessToken"`
+	// Algorithm of the keys used to sign tokens: RS256 (default), ES256, ES384 or EdDSA.
+	// Can't be used with a signer.
+	SigningKeyAlgorithm string `json:"signingKeyAlgorithm"`
+	// Size of RSA signing keys in bits: 2048 (default), 3072 or 4096. Can't be
+	// used with a signer.
+	SigningKeySize int `json:"signingKeySize"`
+	// Format of access tokens: "jwt" (default) or "opaque"
+	AccessTokenFormat string `json:"accessTokenFormat"`
+	// Additional scopes mapped to the custom claims from connectors they grant
+	CustomScopes map[string][]string `json:"customScopes"`
 }
 
 // Web is the config format for the HTTP server.
@@ -144,6 +164,13 @@ type Web struct {
 	TLSCert        string   `json:"tlsCert"`
 	TLSKey         string   `json:"tlsKey"`
 	AllowedOrigins []string `json:"allowedOrigins"`
+
+	// If enabled, the HTTPS listener requests client certificates, which
+	// clients can authenticate with at the token endpoint.
+	TLSClientAuth bool `json:"tlsClientAuth"`
+	// CA bundle client certificates of tls_client_auth clients are verified
+	// against.
+	TLSClientCA string `json:"tlsClientCA"`
 }
 
 // Telemetry is the config format for telemetry including the HTTP server config.
@@ -210,6 +237,51 @@ func (s *Storage) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// Signer holds the configuration of an external signer keeping the signing
+// keys out of the storage.
+type Signer struct {
+	Type   string       `json:"type"`
+	Config SignerConfig `json:"config"`
+}
+
+// SignerConfig is a configuration that can create a signer.
+type SignerConfig interface {
+	Open(logger log.Logger) (server.Signer, error)
+}
+
+var signers = map[string]func() SignerConfig{
+	"pkcs11": func() SignerConfig { return new(pkcs11.Config) },
+}
+
+// UnmarshalJSON allows Signer to implement the unmarshaler interface to
+// dynamically determine the type of the signer config.
+func (s *Signer) UnmarshalJSON(b []byte) error {
+	var signer struct {
+		Type   string          `json:"type"`
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(b, &signer); err != nil {
+		return fmt.Errorf("parse signer: %v", err)
+	}
+	f, ok := signers[signer.Type]
+	if !ok {
+		return fmt.Errorf("unknown signer type %q", signer.Type)
+	}
+
+	signerConfig := f()
+	if len(signer.Config) != 0 {
+		data := []byte(os.ExpandEnv(string(signer.Config)))
+		if err := json.Unmarshal(data, signerConfig); err != nil {
+			return fmt.Errorf("parse signer config: %v", err)
+		}
+	}
+	*s = Signer{
+		Type:   signer.Type,
+		Config: signerConfig,
+	}
+	return nil
+}
+
 // Connector is a magical type that can unmarshal YAML dynamically. The
 // Type field determines the connector type, which is then customized for Config.
 type Connector struct {
@@ -277,8 +349,32 @@ type Expiry struct {
 	// IdTokens defines the duration of time for which the IdTokens will be valid.
 	IDTokens string `json:"idTokens"`
 
+	// AccessTokens defines the duration of time for which the access tokens will be valid.
+	// Defaults to the lifetime of ID tokens.
+	AccessTokens string `json:"accessTokens"`
+
 	// AuthRequests defines the duration of time for which the AuthRequests will be valid.
 	AuthRequests string `json:"authRequests"`
+
+	// DeviceRequests defines the duration of time for which the DeviceRequests will be valid.
+	DeviceRequests string `json:"deviceRequests"`
+
+	// Sessions defines the lifetime of a user's browser session. Sessions are disabled if unset.
+	Sessions string `json:"sessions"`
+
+	// SessionIdleTimeout defines the duration after which an unused browser session expires.
+	SessionIdleTimeout string `json:"sessionIdleTimeout"`
+
+	// RefreshTokens defines the absolute lifetime of refresh tokens. Refresh tokens never
+	// expire if unset.
+	RefreshTokens string `json:"refreshTokens"`
+
+	// RefreshTokenIdleTimeout defines the duration after which an unused refresh token expires.
+	RefreshTokenIdleTimeout string `json:"refreshTokenIdleTimeout"`
+
+	// RefreshTokenReuseInterval defines how long the previous refresh token stays usable after a
+	// refresh, for clients sending concurrent refresh requests.
+	RefreshTokenReuseInterval string `json:"refreshTokenReuseInterval"`
 }
 
 // Logger holds configuration required to customize logging for dex.
@@ -10,6 +10,7 @@ import (
 	"github.com/dexidp/dex/connector/mock"
 	"github.com/dexidp/dex/connector/oidc"
 	"github.com/dexidp/dex/server"
+	"github.com/dexidp/dex/server/signer/pkcs11"
 	"github.com/dexidp/dex/storage"
 	"github.com/dexidp/dex/storage/sql"
 )
@@ -59,6 +60,20 @@ func TestInvalidConfiguration(t *testing.T) {
 		t.Fatalf("Expected error message to be %q, got %q", wanted, got)
 	}
 }
+
+func TestInvalidSignerConfiguration(t *testing.T) {
+	configuration := Config{
+		Issuer:  "http://127.0.0.1:5556/dex",
+		Storage: Storage{Type: "sqlite3", Config: &sql.SQLite3{File: "examples/dex.db"}},
+		Web:     Web{HTTP: "127.0.0.1:5556"},
+		OAuth2:  OAuth2{SigningKeyAlgorithm: "EdDSA"},
+		Signer:  Signer{Type: "pkcs11", Config: &pkcs11.Config{Path: "/usr/lib/softhsm/libsofthsm2.so"}},
+	}
+	if err := configuration.Validate(); err == nil {
+		t.Fatal("expected signing key algorithm to be rejected with a signer")
+	}
+}
+
 func TestUnmarshalConfig(t *testing.T) {
 	rawConfig := []byte(`
 issuer: http://127.0.0.1:5556/dex
@@ -375,3 +390,40 @@ logger:
 		t.Errorf("got!=want: %s", diff)
 	}
 }
+
+func TestUnmarshalSignerConfig(t *testing.T) {
+	os.Setenv("DEX_TEST_PKCS11_PIN", "1234")
+	defer os.Unsetenv("DEX_TEST_PKCS11_PIN")
+
+	rawConfig := []byte(`
+signer:
+  type: pkcs11
+  config:
+    path: /usr/lib/softhsm/libsofthsm2.so
+    tokenLabel: dex
+    pin: $DEX_TEST_PKCS11_PIN
+    algorithm: ES256
+`)
+
+	want := Signer{
+		Type: "pkcs11",
+		Config: &pkcs11.Config{
+			Path:       "/usr/lib/softhsm/libsofthsm2.so",
+			TokenLabel: "dex",
+			Pin:        "1234",
+			Algorithm:  "ES256",
+		},
+	}
+
+	var c Config
+	if err := yaml.Unmarshal(rawConfig, &c); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if diff := pretty.Compare(c.Signer, want); diff != "" {
+		t.Errorf("got!=want: %s", diff)
+	}
+
+	if err := yaml.Unmarshal([]byte("signer:\n  type: kms\n"), &c); err == nil {
+		t.Errorf("expected unknown signer type to be rejected")
+	}
+}
@@ -175,6 +175,18 @@ func serve(cmd *cobra.Command, args []string) error {
 				}
 				c.StaticClients[i].Secret = os.Getenv(client.SecretEnv)
 			}
+			if err := validateClientDuration(client.RefreshTokenValidFor); err != nil {
+				return fmt.Errorf("invalid config: refreshTokenValidFor of client %q: %v", client.ID, err)
+			}
+			if err := validateClientDuration(client.RefreshTokenIdleTimeout); err != nil {
+				return fmt.Errorf("invalid config: refreshTokenIdleTimeout of client %q: %v", client.ID, err)
+			}
+			if err := validateClientDuration(client.IDTokenValidFor); err != nil {
+				return fmt.Errorf("invalid config: idTokenValidFor of client %q: %v", client.ID, err)
+			}
+			if err := validateClientDuration(client.AccessTokenValidFor); err != nil {
+				return fmt.Errorf("invalid config: accessTokenValidFor of client %q: %v", client.ID, err)
+			}
 			logger.Infof("config static client: %s", client.Name)
 		}
 		s = storage.WithStaticClients(s, c.StaticClients)
@@ -225,6 +237,9 @@ func serve(cmd *cobra.Command, args []string) error {
 	if c.OAuth2.PasswordConnector != "" {
 		logger.Infof("config using password grant connector: %s", c.OAuth2.PasswordConnector)
 	}
+	for scope, claims := range c.OAuth2.CustomScopes {
+		logger.Infof("config custom scope %s grants claims: %s", scope, claims)
+	}
 	if len(c.Web.AllowedOrigins) > 0 {
 		logger.Infof("config allowed origins: %s", c.Web.AllowedOrigins)
 	}
@@ -233,17 +248,46 @@ func serve(cmd *cobra.Command, args []string) error {
 	now := func() time.Time { return time.Now().UTC() }
 
 	serverConfig := server.Config{
-		SupportedResponseTypes: c.OAuth2.ResponseTypes,
-		SkipApprovalScreen:     c.OAuth2.SkipApprovalScreen,
-		AlwaysShowLoginScreen:  c.OAuth2.AlwaysShowLoginScreen,
-		PasswordConnector:      c.OAuth2.PasswordConnector,
-		AllowedOrigins:         c.Web.AllowedOrigins,
-		Issuer:                 c.Issuer,
-		Storage:                s,
-		Web:                    c.Frontend,
-		Logger:                 logger,
-		Now:                    now,
-		PrometheusRegistry:     prometheusRegistry,
+		SupportedResponseTypes:      c.OAuth2.ResponseTypes,
+		SkipApprovalScreen:          c.OAuth2.SkipApprovalScreen,
+		AlwaysShowLoginScreen:       c.OAuth2.AlwaysShowLoginScreen,
+		PasswordConnector:           c.OAuth2.PasswordConnector,
+		RevokeRefreshTokensOnLogout: c.OAuth2.RevokeRefreshTokensOnLogout,
+		InitialAccessToken:          c.OAuth2.InitialAccessToken,
+		SigningKeyAlgorithm:         c.OAuth2.SigningKeyAlgorithm,
+		SigningKeySize:              c.OAuth2.SigningKeySize,
+		AccessTokenFormat:           c.OAuth2.AccessTokenFormat,
+		CustomScopes:                c.OAuth2.CustomScopes,
+		AllowedOrigins:              c.Web.AllowedOrigins,
+		Issuer:                      c.Issuer,
+		Storage:                     s,
+		Web:                         c.Frontend,
+		Logger:                      logger,
+		Now:                         now,
+		PrometheusRegistry:          prometheusRegistry,
+	}
+	if c.Signer.Config != nil {
+		signer, err := c.Signer.Config.Open(logger)
+		if err != nil {
+			return fmt.Errorf("failed to initialize signer: %v", err)
+		}
+		logger.Infof("config signer: %s", c.Signer.Type)
+		serverConfig.Signer = signer
+	}
+	if c.Web.TLSClientAuth {
+		serverConfig.TLSClientAuth = true
+		if c.Web.TLSClientCA != "" {
+			cPool := x509.NewCertPool()
+			clientCA, err := ioutil.ReadFile(c.Web.TLSClientCA)
+			if err != nil {
+				return fmt.Errorf("invalid config: reading from web client CA file: %v", err)
+			}
+			if !cPool.AppendCertsFromPEM(clientCA) {
+				return errors.New("invalid config: failed to parse web client CA")
+			}
+			serverConfig.TLSClientCAs = cPool
+		}
+		logger.Infof("config TLS client auth enabled")
 	}
 	if c.Expiry.SigningKeys != "" {
 		signingKeys, err := time.ParseDuration(c.Expiry.SigningKeys)
@@ -261,6 +305,14 @@ func serve(cmd *cobra.Command, args []string) error {
 		logger.Infof("config id tokens valid for: %v", idTokens)
 		serverConfig.IDTokensValidFor = idTokens
 	}
+	if c.Expiry.AccessTokens != "" {
+		accessTokens, err := time.ParseDuration(c.Expiry.AccessTokens)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for access token expiry: %v", c.Expiry.AccessTokens, err)
+		}
+		logger.Infof("config access tokens valid for: %v", accessTokens)
+		serverConfig.AccessTokensValidFor = accessTokens
+	}
 	if c.Expiry.AuthRequests != "" {
 		authRequests, err := time.ParseDuration(c.Expiry.AuthRequests)
 		if err != nil {
@@ -269,6 +321,54 @@ func serve(cmd *cobra.Command, args []string) error {
 		logger.Infof("config auth requests valid for: %v", authRequests)
 		serverConfig.AuthRequestsValidFor = authRequests
 	}
+	if c.Expiry.DeviceRequests != "" {
+		deviceRequests, err := time.ParseDuration(c.Expiry.DeviceRequests)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for device request expiry: %v", c.Expiry.DeviceRequests, err)
+		}
+		logger.Infof("config device requests valid for: %v", deviceRequests)
+		serverConfig.DeviceRequestsValidFor = deviceRequests
+	}
+	if c.Expiry.Sessions != "" {
+		sessions, err := time.ParseDuration(c.Expiry.Sessions)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for session expiry: %v", c.Expiry.Sessions, err)
+		}
+		logger.Infof("config sessions valid for: %v", sessions)
+		serverConfig.SessionsValidFor = sessions
+	}
+	if c.Expiry.SessionIdleTimeout != "" {
+		sessionIdleTimeout, err := time.ParseDuration(c.Expiry.SessionIdleTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for session idle timeout: %v", c.Expiry.SessionIdleTimeout, err)
+		}
+		logger.Infof("config session idle timeout: %v", sessionIdleTimeout)
+		serverConfig.SessionIdleTimeout = sessionIdleTimeout
+	}
+	if c.Expiry.RefreshTokens != "" {
+		refreshTokens, err := time.ParseDuration(c.Expiry.RefreshTokens)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for refresh token expiry: %v", c.Expiry.RefreshTokens, err)
+		}
+		logger.Infof("config refresh tokens valid for: %v", refreshTokens)
+		serverConfig.RefreshTokensValidFor = refreshTokens
+	}
+	if c.Expiry.RefreshTokenIdleTimeout != "" {
+		refreshTokenIdleTimeout, err := time.ParseDuration(c.Expiry.RefreshTokenIdleTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for refresh token idle timeout: %v", c.Expiry.RefreshTokenIdleTimeout, err)
+		}
+		logger.Infof("config refresh token idle timeout: %v", refreshTokenIdleTimeout)
+		serverConfig.RefreshTokenIdleTimeout = refreshTokenIdleTimeout
+	}
+	if c.Expiry.RefreshTokenReuseInterval != "" {
+		refreshTokenReuseInterval, err := time.ParseDuration(c.Expiry.RefreshTokenReuseInterval)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for refresh token reuse interval: %v", c.Expiry.RefreshTokenReuseInterval, err)
+		}
+		logger.Infof("config refresh token reuse interval: %v", refreshTokenReuseInterval)
+		serverConfig.RefreshTokenReuseInterval = refreshTokenReuseInterval
+	}
 
 	serv, err := server.NewServer(context.Background(), serverConfig)
 	if err != nil {
@@ -303,6 +403,11 @@ func serve(cmd *cobra.Command, args []string) error {
 				MinVersion:               tls.VersionTLS12,
 			},
 		}
+		if c.Web.TLSClientAuth {
+			// Client certificates are verified by the server when clients
+			// authenticate, since self-signed certificates are allowed too.
+			httpsSrv.TLSConfig.ClientAuth = tls.RequestClientCert
+		}
 
 		logger.Infof("listening (https) on %s", c.Web.HTTPS)
 		go func() {
@@ -334,6 +439,22 @@ func serve(cmd *cobra.Command, args []string) error {
 	return <-errc
 }
 
+// validateClientDuration checks a per-client duration override. Empty values
+// keep the server default.
+func validateClientDuration(value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		return fmt.Errorf("negative duration %q", value)
+	}
+	return nil
+}
+
 var (
 	logLevels  = []string{"debug", "info", "error"}
 	logFormats = []string{"json", "text"}
@@ -31,6 +31,11 @@ type Identity struct {
 
 	Groups []string
 
+	// CustomClaims holds additional attributes of the user, mapped from the upstream
+	// identity provider according to the connector's configuration. Values must be
+	// JSON serializable.
+	CustomClaims map[string]interface{}
+
 	// ConnectorData holds data used by the connector for subsequent requests after initial
 	// authentication, such as access tokens for upstream provides.
 	//
@@ -98,3 +103,23 @@ type RefreshConnector interface {
 	// changes since the token was last refreshed.
 	Refresh(ctx context.Context, s Scopes, identity Identity) (Identity, error)
 }
+
+// TokenIdentityConnector is a connector that can validate tokens issued by the
+// upstream identity provider, allowing clients to exchange them for dex tokens.
+type TokenIdentityConnector interface {
+	// TokenIdentity is called when a client exchanges a token issued by the
+	// upstream provider. The connector should verify the token and return the
+	// identity it was issued to. subjectTokenType is a token type URI as
+	// defined in RFC 8693, e.g. "urn:ietf:params:oauth:token-type:id_token".
+	TokenIdentity(ctx context.Context, subjectTokenType, subjectToken string) (Identity, error)
+}
+
+// LogoutConnector is a connector that can end the user's session with the
+// upstream identity provider.
+type LogoutConnector interface {
+	// LogoutURL returns the URL to redirect the user to in order to log out
+	// upstream. The provider is expected to send the user back to callbackURL,
+	// passing state along. An empty URL means there's no upstream session to
+	// end.
+	LogoutURL(ctx context.Context, identity Identity, callbackURL, state string) (string, error)
+}
@@ -40,6 +40,9 @@ import (
 //         emailAttr: mail
 //         nameAttr: name
 //         preferredUsernameAttr: uid
+//         # Custom claims, mapped from attributes of the user entry.
+//         customClaims:
+//           department: departmentNumber
 //       groupSearch:
 //         # Would translate to the separate query per user matcher pair and aggregate results into a single group list:
 //         #  "(&(|(objectClass=posixGroup)(objectClass=groupOfNames))(memberUid=<user uid>))"
@@ -122,6 +125,10 @@ type Config struct {
 		// If this is set, the email claim of the id token will be constructed from the idAttr and
 		// value of emailSuffix. This should not include the @ character.
 		EmailSuffix string `json:"emailSuffix"` // No default.
+
+		// A mapping of custom claim names to attributes on the user entry. Attributes
+		// with a single value are mapped to a string, others to a list of strings.
+		CustomClaims map[string]string `json:"customClaims"`
 	} `json:"userSearch"`
 
 	// Group search configuration.
@@ -389,6 +396,21 @@ func (c *ldapConnector) identityFromEntry(user ldap.Entry) (ident connector.Iden
 	// TODO(ericchiang): Let this value be set from an attribute.
 	ident.EmailVerified = true
 
+	for claim, attr := range c.UserSearch.CustomClaims {
+		values := getAttrs(user, attr)
+		if len(values) == 0 {
+			continue
+		}
+		if ident.CustomClaims == nil {
+			ident.CustomClaims = make(map[string]interface{})
+		}
+		if len(values) == 1 {
+			ident.CustomClaims[claim] = values[0]
+		} else {
+			ident.CustomClaims[claim] = values
+		}
+	}
+
 	if len(missing) != 0 {
 		err := fmt.Errorf("ldap: entry %q missing following required attribute(s): %q", user.DN, missing)
 		return connector.Identity{}, err
@@ -427,6 +449,10 @@ func (c *ldapConnector) userEntry(conn *ldap.Conn, username string) (user ldap.E
 		req.Attributes = append(req.Attributes, c.UserSearch.PreferredUsernameAttrAttr)
 	}
 
+	for _, attr := range c.UserSearch.CustomClaims {
+		req.Attributes = append(req.Attributes, attr)
+	}
+
 	c.logger.Infof("performing ldap search %s %s %s",
 		req.BaseDN, scopeString(req.Scope), req.Filter)
 	resp, err := conn.Search(req)
@@ -29,7 +29,8 @@ func NewCallbackConnector(logger log.Logger) connector.Connector {
 }
 
 var (
-	_ connector.CallbackConnector = &Callback{}
+	_ connector.CallbackConnector      = &Callback{}
+	_ connector.TokenIdentityConnector = &Callback{}
 
 	_ connector.PasswordConnector = passwordConnector{}
 	_ connector.RefreshConnector  = passwordConnector{}
@@ -66,6 +67,14 @@ func (m *Callback) Refresh(ctx context.Context, s connector.Scopes, identity con
 	return m.Identity, nil
 }
 
+// TokenIdentity accepts any ID token and returns the connector's identity.
+func (m *Callback) TokenIdentity(ctx context.Context, subjectTokenType, subjectToken string) (connector.Identity, error) {
+	if subjectTokenType != "urn:ietf:params:oauth:token-type:id_token" {
+		return connector.Identity{}, fmt.Errorf("unsupported subject token type %q", subjectTokenType)
+	}
+	return m.Identity, nil
+}
+
 // CallbackConfig holds the configuration parameters for a connector which requires no interaction.
 type CallbackConfig struct{}
 
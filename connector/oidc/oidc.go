@@ -57,6 +57,10 @@ type Config struct {
 
 	// PromptType will be used fot the prompt parameter (when offline_access, by default prompt=consent)
 	PromptType string `json:"promptType"`
+
+	// A mapping of custom claim names to upstream claims, whose values are
+	// copied as they are.
+	CustomClaims map[string]string `json:"customClaims"`
 }
 
 // Domains that don't support basic auth. golang.org/x/oauth2 has an internal
@@ -100,6 +104,18 @@ func (c *Config) Open(id string, logger log.Logger) (conn connector.Connector, e
 
 	endpoint := provider.Endpoint()
 
+	// The end session endpoint is optional and not part of the core discovery
+	// document, so it isn't exposed by the provider.
+	//
+	// https://openid.net/specs/openid-connect-rpinitiated-1_0.html#OPMetadata
+	var providerClaims struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&providerClaims); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to parse provider metadata: %v", err)
+	}
+
 	if c.BasicAuthUnsupported != nil {
 		// Setting "basicAuthUnsupported" always overrides our detection.
 		if *c.BasicAuthUnsupported {
@@ -123,8 +139,9 @@ func (c *Config) Open(id string, logger log.Logger) (conn connector.Connector, e
 
 	clientID := c.ClientID
 	return &oidcConnector{
-		provider:    provider,
-		redirectURI: c.RedirectURI,
+		provider:      provider,
+		redirectURI:   c.RedirectURI,
+		endSessionURL: providerClaims.EndSessionEndpoint,
 		oauth2Config: &oauth2.Config{
 			ClientID:     clientID,
 			ClientSecret: c.ClientSecret,
@@ -144,17 +161,24 @@ func (c *Config) Open(id string, logger log.Logger) (conn connector.Connector, e
 		userIDKey:                 c.UserIDKey,
 		userNameKey:               c.UserNameKey,
 		promptType:                c.PromptType,
+		customClaims:              c.CustomClaims,
 	}, nil
 }
 
 var (
-	_ connector.CallbackConnector = (*oidcConnector)(nil)
-	_ connector.RefreshConnector  = (*oidcConnector)(nil)
+	_ connector.CallbackConnector      = (*oidcConnector)(nil)
+	_ connector.RefreshConnector       = (*oidcConnector)(nil)
+	_ connector.LogoutConnector        = (*oidcConnector)(nil)
+	_ connector.TokenIdentityConnector = (*oidcConnector)(nil)
 )
 
+// tokenTypeIDToken is the RFC 8693 token type of OpenID Connect ID tokens.
+const tokenTypeIDToken = "urn:ietf:params:oauth:token-type:id_token"
+
 type oidcConnector struct {
 	provider                  *oidc.Provider
 	redirectURI               string
+	endSessionURL             string
 	oauth2Config              *oauth2.Config
 	verifier                  *oidc.IDTokenVerifier
 	cancel                    context.CancelFunc
@@ -166,6 +190,7 @@ type oidcConnector struct {
 	userIDKey                 string
 	userNameKey               string
 	promptType                string
+	customClaims              map[string]string
 }
 
 func (c *oidcConnector) Close() error {
@@ -193,6 +218,28 @@ func (c *oidcConnector) LoginURL(s connector.Scopes, callbackURL, state string)
 	return c.oauth2Config.AuthCodeURL(state, opts...), nil
 }
 
+// LogoutURL returns the provider's end session endpoint, if it advertises one.
+// The callback URL has to be registered as a post logout redirect URI with the
+// provider.
+func (c *oidcConnector) LogoutURL(ctx context.Context, identity connector.Identity, callbackURL, state string) (string, error) {
+	if c.endSessionURL == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(c.endSessionURL)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to parse end session endpoint: %v", err)
+	}
+	v := u.Query()
+	v.Set("client_id", c.oauth2Config.ClientID)
+	v.Set("post_logout_redirect_uri", callbackURL)
+	if state != "" {
+		v.Set("state", state)
+	}
+	u.RawQuery = v.Encode()
+	return u.String(), nil
+}
+
 type oauth2Error struct {
 	error            string
 	errorDescription string
@@ -238,11 +285,27 @@ func (c *oidcConnector) Refresh(ctx context.Context, s connector.Scopes, identit
 	return c.createIdentity(ctx, identity, token)
 }
 
+// TokenIdentity verifies an ID token issued by the upstream provider to dex's
+// client ID and returns the identity it was issued to.
+func (c *oidcConnector) TokenIdentity(ctx context.Context, subjectTokenType, subjectToken string) (connector.Identity, error) {
+	if subjectTokenType != tokenTypeIDToken {
+		return connector.Identity{}, fmt.Errorf("oidc: unsupported subject token type %q", subjectTokenType)
+	}
+	return c.identityFromIDToken(ctx, subjectToken, nil)
+}
+
 func (c *oidcConnector) createIdentity(ctx context.Context, identity connector.Identity, token *oauth2.Token) (connector.Identity, error) {
 	rawIDToken, ok := token.Extra("id_token").(string)
 	if !ok {
 		return identity, errors.New("oidc: no id_token in token response")
 	}
+	return c.identityFromIDToken(ctx, rawIDToken, token)
+}
+
+// identityFromIDToken verifies the ID token and maps its claims to an
+// identity. token is nil if there's no token response, in which case the
+// userinfo endpoint isn't queried and there's no refresh token to store.
+func (c *oidcConnector) identityFromIDToken(ctx context.Context, rawIDToken string, token *oauth2.Token) (identity connector.Identity, err error) {
 	idToken, err := c.verifier.Verify(ctx, rawIDToken)
 	if err != nil {
 		return identity, fmt.Errorf("oidc: failed to verify ID Token: %v", err)
@@ -254,7 +317,7 @@ func (c *oidcConnector) createIdentity(ctx context.Context, identity connector.I
 	}
 
 	// We immediately want to run getUserInfo if configured before we validate the claims
-	if c.getUserInfo {
+	if c.getUserInfo && token != nil {
 		userInfo, err := c.provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
 		if err != nil {
 			return identity, fmt.Errorf("oidc: error loading userinfo: %v", err)
@@ -310,8 +373,9 @@ func (c *oidcConnector) createIdentity(ctx context.Context, identity connector.I
 		}
 	}
 
-	cd := connectorData{
-		RefreshToken: []byte(token.RefreshToken),
+	var cd connectorData
+	if token != nil {
+		cd.RefreshToken = []byte(token.RefreshToken)
 	}
 
 	connData, err := json.Marshal(&cd)
@@ -335,6 +399,17 @@ func (c *oidcConnector) createIdentity(ctx context.Context, identity connector.I
 		identity.UserID = userID
 	}
 
+	for claim, key := range c.customClaims {
+		value, ok := claims[key]
+		if !ok {
+			continue
+		}
+		if identity.CustomClaims == nil {
+			identity.CustomClaims = make(map[string]interface{})
+		}
+		identity.CustomClaims[claim] = value
+	}
+
 	if c.insecureEnableGroups {
 		vs, ok := claims["groups"].([]interface{})
 		if ok {
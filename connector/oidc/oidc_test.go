@@ -2,6 +2,7 @@ package oidc
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/base64"
@@ -11,6 +12,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strings"
 	"testing"
@@ -51,9 +53,11 @@ func TestHandleCallback(t *testing.T) {
 		userNameKey               string
 		insecureSkipEmailVerified bool
 		scopes                    []string
+		customClaims              map[string]string
 		expectUserID              string
 		expectUserName            string
 		expectedEmailField        string
+		expectCustomClaims        map[string]interface{}
 		token                     map[string]interface{}
 	}{
 		{
@@ -135,6 +139,25 @@ func TestHandleCallback(t *testing.T) {
 				"email":     "emailvalue",
 			},
 		},
+		{
+			name:               "withCustomClaims",
+			customClaims:       map[string]string{"department": "dept", "roles": "roles", "missing": "missing"},
+			expectUserID:       "subvalue",
+			expectUserName:     "namevalue",
+			expectedEmailField: "emailvalue",
+			expectCustomClaims: map[string]interface{}{
+				"department": "engineering",
+				"roles":      []interface{}{"admin", "dev"},
+			},
+			token: map[string]interface{}{
+				"sub":            "subvalue",
+				"name":           "namevalue",
+				"email":          "emailvalue",
+				"email_verified": true,
+				"dept":           "engineering",
+				"roles":          []string{"admin", "dev"},
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -163,6 +186,7 @@ func TestHandleCallback(t *testing.T) {
 				UserNameKey:               tc.userNameKey,
 				InsecureSkipEmailVerified: tc.insecureSkipEmailVerified,
 				BasicAuthUnsupported:      &basicAuth,
+				CustomClaims:              tc.customClaims,
 			}
 
 			conn, err := newConnector(config)
@@ -184,10 +208,94 @@ func TestHandleCallback(t *testing.T) {
 			expectEquals(t, identity.Username, tc.expectUserName)
 			expectEquals(t, identity.Email, tc.expectedEmailField)
 			expectEquals(t, identity.EmailVerified, true)
+			expectEquals(t, identity.CustomClaims, tc.expectCustomClaims)
 		})
 	}
 }
 
+func TestLogoutURL(t *testing.T) {
+	testServer, err := setupServer(map[string]interface{}{})
+	if err != nil {
+		t.Fatal("failed to setup test server", err)
+	}
+	defer testServer.Close()
+
+	conn, err := newConnector(Config{
+		Issuer:       testServer.URL,
+		ClientID:     "clientID",
+		ClientSecret: "clientSecret",
+		RedirectURI:  fmt.Sprintf("%v/callback", testServer.URL),
+	})
+	if err != nil {
+		t.Fatal("failed to create new connector", err)
+	}
+
+	logoutURL, err := conn.LogoutURL(context.Background(), connector.Identity{}, "https://dex.example.com/logout/callback", "foo")
+	if err != nil {
+		t.Fatal("failed to get logout URL", err)
+	}
+
+	u, err := url.Parse(logoutURL)
+	if err != nil {
+		t.Fatal("failed to parse logout URL", err)
+	}
+	expectEquals(t, u.Path, "/logout")
+	expectEquals(t, u.Query().Get("client_id"), "clientID")
+	expectEquals(t, u.Query().Get("post_logout_redirect_uri"), "https://dex.example.com/logout/callback")
+	expectEquals(t, u.Query().Get("state"), "foo")
+}
+
+func TestTokenIdentity(t *testing.T) {
+	testServer, err := setupServer(map[string]interface{}{
+		"sub":            "subvalue",
+		"name":           "namevalue",
+		"email":          "emailvalue",
+		"email_verified": true,
+	})
+	if err != nil {
+		t.Fatal("failed to setup test server", err)
+	}
+	defer testServer.Close()
+
+	conn, err := newConnector(Config{
+		Issuer:       testServer.URL,
+		ClientID:     "clientID",
+		ClientSecret: "clientSecret",
+		RedirectURI:  fmt.Sprintf("%v/callback", testServer.URL),
+	})
+	if err != nil {
+		t.Fatal("failed to create new connector", err)
+	}
+
+	// Get an ID token issued to dex's client ID from the test server.
+	resp, err := http.PostForm(testServer.URL+"/token", url.Values{})
+	if err != nil {
+		t.Fatal("failed to get token", err)
+	}
+	defer resp.Body.Close()
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		t.Fatal("failed to decode token response", err)
+	}
+
+	identity, err := conn.TokenIdentity(context.Background(), tokenTypeIDToken, tokenResp.IDToken)
+	if err != nil {
+		t.Fatal("token identity failed", err)
+	}
+	expectEquals(t, identity.UserID, "subvalue")
+	expectEquals(t, identity.Username, "namevalue")
+	expectEquals(t, identity.Email, "emailvalue")
+
+	if _, err := conn.TokenIdentity(context.Background(), "urn:ietf:params:oauth:token-type:access_token", tokenResp.IDToken); err == nil {
+		t.Error("expected unsupported subject token type to fail")
+	}
+	if _, err := conn.TokenIdentity(context.Background(), tokenTypeIDToken, tokenResp.IDToken[:len(tokenResp.IDToken)-4]+"AAAA"); err == nil {
+		t.Error("expected tampered token to fail")
+	}
+}
+
 func setupServer(tok map[string]interface{}) (*httptest.Server, error) {
 	key, err := rsa.GenerateKey(rand.Reader, 1024)
 	if err != nil {
@@ -241,6 +349,7 @@ func setupServer(tok map[string]interface{}) (*httptest.Server, error) {
 			"authorization_endpoint": fmt.Sprintf("%s/authorize", url),
 			"userinfo_endpoint":      fmt.Sprintf("%s/userinfo", url),
 			"jwks_uri":               fmt.Sprintf("%s/keys", url),
+			"end_session_endpoint":   fmt.Sprintf("%s/logout", url),
 		})
 	})
 
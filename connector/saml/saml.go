@@ -3,13 +3,18 @@ package saml
 
 import (
 	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/rand"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"strings"
 	"time"
 
@@ -86,6 +91,10 @@ type Config struct {
 	SSOIssuer    string `json:"ssoIssuer"`
 	SSOURL       string `json:"ssoURL"`
 
+	// URL of the IdP's single logout service using the HTTP-Redirect binding.
+	// If set, logging out of dex also ends the user's session with the IdP.
+	SLOURL string `json:"sloURL"`
+
 	// X509 CA file or raw data to verify XML signatures.
 	CA     string `json:"ca"`
 	CAData []byte `json:"caData"`
@@ -104,6 +113,10 @@ type Config struct {
 	FilterGroups  bool     `json:"filterGroups"`
 	RedirectURI   string   `json:"redirectURI"`
 
+	// A mapping of custom claim names to assertion attribute names. Attributes
+	// with a single value are mapped to a string, others to a list of strings.
+	CustomClaims map[string]string `json:"customClaims"`
+
 	// Requested format of the NameID. The NameID value is is mapped to the ID Token
 	// 'sub' claim.
 	//
@@ -160,6 +173,7 @@ func (c *Config) openConnector(logger log.Logger) (*provider, error) {
 		entityIssuer:  c.EntityIssuer,
 		ssoIssuer:     c.SSOIssuer,
 		ssoURL:        c.SSOURL,
+		sloURL:        c.SLOURL,
 		now:           time.Now,
 		usernameAttr:  c.UsernameAttr,
 		emailAttr:     c.EmailAttr,
@@ -168,6 +182,7 @@ func (c *Config) openConnector(logger log.Logger) (*provider, error) {
 		allowedGroups: c.AllowedGroups,
 		filterGroups:  c.FilterGroups,
 		redirectURI:   c.RedirectURI,
+		customClaims:  c.CustomClaims,
 		logger:        logger,
 
 		nameIDPolicyFormat: c.NameIDPolicyFormat,
@@ -230,6 +245,7 @@ type provider struct {
 	entityIssuer string
 	ssoIssuer    string
 	ssoURL       string
+	sloURL       string
 
 	now func() time.Time
 
@@ -243,6 +259,7 @@ type provider struct {
 	groupsDelim   string
 	allowedGroups []string
 	filterGroups  bool
+	customClaims  map[string]string
 
 	redirectURI string
 
@@ -279,6 +296,67 @@ func (p *provider) POSTData(s connector.Scopes, id string) (action, value string
 	return p.ssoURL, base64.StdEncoding.EncodeToString(data), nil
 }
 
+// LogoutURL returns a URL that sends a logout request for the user to the IdP
+// using the HTTP-Redirect binding. The request is unsigned, and the IdP returns
+// its response to the single logout URL registered for dex, which is expected
+// to be callbackURL. The state is passed along as RelayState.
+//
+// See: https://docs.oasis-open.org/security/saml/v2.0/saml-bindings-2.0-os.pdf
+// "3.4 HTTP Redirect Binding"
+func (p *provider) LogoutURL(ctx context.Context, identity connector.Identity, callbackURL, state string) (string, error) {
+	if p.sloURL == "" || identity.UserID == "" {
+		return "", nil
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("generate logout request ID: %v", err)
+	}
+	r := &logoutRequest{
+		// IDs must not start with a number.
+		ID:           "_" + hex.EncodeToString(id),
+		IssueInstant: xmlTime(p.now()),
+		Destination:  p.sloURL,
+		NameID: logoutNameID{
+			Format: p.nameIDPolicyFormat,
+			Value:  identity.UserID,
+		},
+	}
+	if p.entityIssuer != "" {
+		r.Issuer = &issuer{Issuer: p.entityIssuer}
+	}
+
+	data, err := xml.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("marshal logout request: %v", err)
+	}
+
+	// "3.4.4.1 DEFLATE Encoding"
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return "", fmt.Errorf("compress logout request: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return "", fmt.Errorf("compress logout request: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("compress logout request: %v", err)
+	}
+
+	u, err := url.Parse(p.sloURL)
+	if err != nil {
+		return "", fmt.Errorf("parse sloURL: %v", err)
+	}
+	v := u.Query()
+	v.Set("SAMLRequest", base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if state != "" {
+		v.Set("RelayState", state)
+	}
+	u.RawQuery = v.Encode()
+	return u.String(), nil
+}
+
 // HandlePOST interprets a request from a SAML provider attempting to verify a
 // user's identity.
 //
@@ -394,6 +472,22 @@ func (p *provider) HandlePOST(s connector.Scopes, samlResponse, inResponseTo str
 		return ident, fmt.Errorf("no attribute with name %q: %s", p.usernameAttr, attributes.names())
 	}
 
+	// Grab the custom claims, skipping attributes the IdP didn't send.
+	for claim, attr := range p.customClaims {
+		values, ok := attributes.all(attr)
+		if !ok {
+			continue
+		}
+		if ident.CustomClaims == nil {
+			ident.CustomClaims = make(map[string]interface{})
+		}
+		if len(values) == 1 {
+			ident.CustomClaims[claim] = values[0]
+		} else {
+			ident.CustomClaims[claim] = values
+		}
+	}
+
 	if len(p.allowedGroups) == 0 && (!s.Groups || p.groupsAttr == "") {
 		// Groups not requested or not configured. We're done.
 		return ident, nil
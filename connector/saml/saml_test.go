@@ -1,11 +1,16 @@
 package saml
 
 import (
+	"bytes"
+	"compress/flate"
+	"context"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
+	"encoding/xml"
 	"errors"
 	"io/ioutil"
+	"net/url"
 	"sort"
 	"testing"
 	"time"
@@ -54,6 +59,7 @@ type responseTest struct {
 	groupsAttr    string
 	allowedGroups []string
 	filterGroups  bool
+	customClaims  map[string]string
 
 	// Expected outcome of the test.
 	wantErr   bool
@@ -100,6 +106,34 @@ func TestGroups(t *testing.T) {
 	test.run(t)
 }
 
+func TestCustomClaims(t *testing.T) {
+	test := responseTest{
+		caFile:       "testdata/ca.crt",
+		respFile:     "testdata/good-resp.xml",
+		now:          "2017-04-04T04:34:59.330Z",
+		usernameAttr: "Name",
+		emailAttr:    "email",
+		customClaims: map[string]string{
+			"display_name": "Name",
+			"roles":        "groups",
+			"department":   "department", // Not in the response.
+		},
+		inResponseTo: "6zmm5mguyebwvajyf2sdwwcw6m",
+		redirectURI:  "http://127.0.0.1:5556/dex/callback",
+		wantIdent: connector.Identity{
+			UserID:        "eric.chiang+okta@coreos.com",
+			Username:      "Eric",
+			Email:         "eric.chiang+okta@coreos.com",
+			EmailVerified: true,
+			CustomClaims: map[string]interface{}{
+				"display_name": "Eric",
+				"roles":        []string{"Everyone", "Admins"},
+			},
+		},
+	}
+	test.run(t)
+}
+
 func TestGroupsWhitelist(t *testing.T) {
 	test := responseTest{
 		caFile:        "testdata/ca.crt",
@@ -413,6 +447,7 @@ func (r responseTest) run(t *testing.T) {
 		EntityIssuer:  r.entityIssuer,
 		AllowedGroups: r.allowedGroups,
 		FilterGroups:  r.filterGroups,
+		CustomClaims:  r.customClaims,
 		// Never logging in, don't need this.
 		SSOURL: "http://foo.bar/",
 	}
@@ -454,6 +489,70 @@ func (r responseTest) run(t *testing.T) {
 	}
 }
 
+func TestLogoutURL(t *testing.T) {
+	c := Config{
+		CA:           "testdata/ca.crt",
+		UsernameAttr: "Name",
+		EmailAttr:    "email",
+		RedirectURI:  "http://127.0.0.1:5556/dex/callback",
+		EntityIssuer: "http://127.0.0.1:5556/dex/callback",
+		SSOURL:       "http://foo.bar/sso",
+		SLOURL:       "http://foo.bar/slo",
+	}
+	conn, err := c.openConnector(logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ident := connector.Identity{UserID: "eric.chiang+okta@coreos.com"}
+	logoutURL, err := conn.LogoutURL(context.Background(), ident, "http://127.0.0.1:5556/dex/logout/callback", "foo")
+	if err != nil {
+		t.Fatalf("logout URL: %v", err)
+	}
+
+	u, err := url.Parse(logoutURL)
+	if err != nil {
+		t.Fatalf("parse logout URL: %v", err)
+	}
+	if u.Host != "foo.bar" || u.Path != "/slo" {
+		t.Errorf("expected logout URL to point at sloURL, got %q", logoutURL)
+	}
+	if got := u.Query().Get("RelayState"); got != "foo" {
+		t.Errorf("expected RelayState %q got %q", "foo", got)
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	if err != nil {
+		t.Fatalf("decode SAMLRequest: %v", err)
+	}
+	data, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatalf("inflate SAMLRequest: %v", err)
+	}
+	var req struct {
+		Destination string `xml:"Destination,attr"`
+		NameID      struct {
+			Format string `xml:"Format,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"NameID"`
+	}
+	if err := xml.Unmarshal(data, &req); err != nil {
+		t.Fatalf("unmarshal logout request: %v", err)
+	}
+	if req.Destination != c.SLOURL {
+		t.Errorf("expected destination %q got %q", c.SLOURL, req.Destination)
+	}
+	if req.NameID.Value != ident.UserID || req.NameID.Format != nameIDFormatPersistent {
+		t.Errorf("unexpected NameID %+v", req.NameID)
+	}
+
+	// Without an sloURL there's nothing to log out of.
+	conn.sloURL = ""
+	if logoutURL, err := conn.LogoutURL(context.Background(), ident, "", ""); err != nil || logoutURL != "" {
+		t.Errorf("expected no logout URL, got %q, %v", logoutURL, err)
+	}
+}
+
 func TestConfigCAData(t *testing.T) {
 	logger := logrus.New()
 	validPEM, err := ioutil.ReadFile("testdata/ca.crt")
@@ -144,6 +144,30 @@ type issuer struct {
 	Issuer  string   `xml:",chardata"`
 }
 
+// logoutRequest is sent to the IdP's single logout service.
+//
+// See: https://docs.oasis-open.org/security/saml/v2.0/saml-core-2.0-os.pdf
+// "3.7.1 Element <LogoutRequest>"
+type logoutRequest struct {
+	XMLName xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol LogoutRequest"`
+
+	ID      string      `xml:"ID,attr"`
+	Version samlVersion `xml:"Version,attr"`
+
+	IssueInstant xmlTime `xml:"IssueInstant,attr,omitempty"`
+	Destination  string  `xml:"Destination,attr,omitempty"`
+
+	Issuer *issuer      `xml:"Issuer,omitempty"`
+	NameID logoutNameID `xml:"NameID"`
+}
+
+type logoutNameID struct {
+	XMLName xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:assertion NameID"`
+
+	Format string `xml:"Format,attr,omitempty"`
+	Value  string `xml:",chardata"`
+}
+
 type nameIDPolicy struct {
 	XMLName     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol NameIDPolicy"`
 	AllowCreate bool     `xml:"AllowCreate,attr,omitempty"`
@@ -1,9 +1,14 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
@@ -16,7 +21,7 @@ import (
 
 // apiVersion increases every time a new call is added to the API. Clients should use this info
 // to determine if the server supports specific features.
-const apiVersion = 2
+const apiVersion = 4
 
 const (
 	// recCost is the recommended bcrypt cost, which balances hash strength and
@@ -53,15 +58,26 @@ func (d dexAPI) CreateClient(ctx context.Context, req *api.CreateClientReq) (*ap
 	if req.Client.Secret == "" {
 		req.Client.Secret = storage.NewID() + storage.NewID()
 	}
+	// Only a hash of the secret is stored, the response is the only time it's
+	// returned.
+	secret := storage.NewClientSecret(req.Client.Secret, time.Time{})
 
 	c := storage.Client{
 		ID:           req.Client.Id,
-		Secret:       req.Client.Secret,
+		Secrets:      []storage.ClientSecret{secret},
 		RedirectURIs: req.Client.RedirectUris,
 		TrustedPeers: req.Client.TrustedPeers,
 		Public:       req.Client.Public,
 		Name:         req.Client.Name,
 		LogoURL:      req.Client.LogoUrl,
+
+		AllowedScopes:           req.Client.AllowedScopes,
+		ClientCredentialsScopes: req.Client.ClientCredentialsScopes,
+		AllowedAudiences:        req.Client.AllowedAudiences,
+
+		AllowedGrantTypes:    req.Client.AllowedGrantTypes,
+		AllowedResponseTypes: req.Client.AllowedResponseTypes,
+		AllowedConnectors:    req.Client.AllowedConnectors,
 	}
 	if err := d.s.CreateClient(c); err != nil {
 		if err == storage.ErrAlreadyExists {
@@ -94,6 +110,24 @@ func (d dexAPI) UpdateClient(ctx context.Context, req *api.UpdateClientReq) (*ap
 		if req.LogoUrl != "" {
 			old.LogoURL = req.LogoUrl
 		}
+		if req.AllowedScopes != nil {
+			old.AllowedScopes = req.AllowedScopes
+		}
+		if req.ClientCredentialsScopes != nil {
+			old.ClientCredentialsScopes = req.ClientCredentialsScopes
+		}
+		if req.AllowedAudiences != nil {
+			old.AllowedAudiences = req.AllowedAudiences
+		}
+		if req.AllowedGrantTypes != nil {
+			old.AllowedGrantTypes = req.AllowedGrantTypes
+		}
+		if req.AllowedResponseTypes != nil {
+			old.AllowedResponseTypes = req.AllowedResponseTypes
+		}
+		if req.AllowedConnectors != nil {
+			old.AllowedConnectors = req.AllowedConnectors
+		}
 		return old, nil
 	})
 
@@ -119,6 +153,57 @@ func (d dexAPI) DeleteClient(ctx context.Context, req *api.DeleteClientReq) (*ap
 	return &api.DeleteClientResp{}, nil
 }
 
+func (d dexAPI) AddClientSecret(ctx context.Context, req *api.AddClientSecretReq) (*api.AddClientSecretResp, error) {
+	if req.ClientId == "" {
+		return nil, errors.New("add client secret: no client ID supplied")
+	}
+	if req.Secret == "" {
+		req.Secret = storage.NewID() + storage.NewID()
+	}
+	var expiry time.Time
+	if req.Expiry != 0 {
+		expiry = time.Unix(req.Expiry, 0)
+	}
+	secret := storage.NewClientSecret(req.Secret, expiry)
+
+	err := d.s.UpdateClient(req.ClientId, func(old storage.Client) (storage.Client, error) {
+		old.Secrets = append(old.Secrets, secret)
+		return old, nil
+	})
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return &api.AddClientSecretResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to add client secret: %v", err)
+		return nil, fmt.Errorf("add client secret: %v", err)
+	}
+	return &api.AddClientSecretResp{Id: secret.ID, Secret: req.Secret}, nil
+}
+
+func (d dexAPI) RemoveClientSecret(ctx context.Context, req *api.RemoveClientSecretReq) (*api.RemoveClientSecretResp, error) {
+	if req.ClientId == "" {
+		return nil, errors.New("remove client secret: no client ID supplied")
+	}
+
+	err := d.s.UpdateClient(req.ClientId, func(old storage.Client) (storage.Client, error) {
+		for i, secret := range old.Secrets {
+			if secret.ID == req.Id {
+				old.Secrets = append(old.Secrets[:i:i], old.Secrets[i+1:]...)
+				return old, nil
+			}
+		}
+		return old, storage.ErrNotFound
+	})
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return &api.RemoveClientSecretResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to remove client secret: %v", err)
+		return nil, fmt.Errorf("remove client secret: %v", err)
+	}
+	return &api.RemoveClientSecretResp{}, nil
+}
+
 // checkCost returns an error if the hash provided does not meet lower or upper
 // bound cost requirements.
 func checkCost(hash []byte) error {
@@ -366,3 +451,237 @@ func (d dexAPI) RevokeRefresh(ctx context.Context, req *api.RevokeRefreshReq) (*
 
 	return &api.RevokeRefreshResp{}, nil
 }
+
+// redactedValue replaces secrets in connector configs returned by the API.
+const redactedValue = "******"
+
+func (d dexAPI) CreateConnector(ctx context.Context, req *api.CreateConnectorReq) (*api.CreateConnectorResp, error) {
+	if req.Connector == nil {
+		return nil, errors.New("no connector supplied")
+	}
+	if req.Connector.Id == "" {
+		return nil, errors.New("no connector ID supplied")
+	}
+	if req.Connector.Type == "" {
+		return nil, errors.New("no connector type supplied")
+	}
+
+	c := storage.Connector{
+		ID:              req.Connector.Id,
+		Type:            req.Connector.Type,
+		Name:            req.Connector.Name,
+		ResourceVersion: storage.NewID(),
+		Config:          req.Connector.Config,
+	}
+	if err := d.validateConnector(c); err != nil {
+		return nil, fmt.Errorf("create connector: %v", err)
+	}
+	if err := d.s.CreateConnector(c); err != nil {
+		if err == storage.ErrAlreadyExists {
+			return &api.CreateConnectorResp{AlreadyExists: true}, nil
+		}
+		d.logger.Errorf("api: failed to create connector: %v", err)
+		return nil, fmt.Errorf("create connector: %v", err)
+	}
+
+	return &api.CreateConnectorResp{}, nil
+}
+
+func (d dexAPI) UpdateConnector(ctx context.Context, req *api.UpdateConnectorReq) (*api.UpdateConnectorResp, error) {
+	if req.Id == "" {
+		return nil, errors.New("no connector ID supplied")
+	}
+
+	c, err := d.s.GetConnector(req.Id)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return &api.UpdateConnectorResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to get connector: %v", err)
+		return nil, fmt.Errorf("update connector: %v", err)
+	}
+	if req.NewType != "" {
+		c.Type = req.NewType
+	}
+	if req.NewName != "" {
+		c.Name = req.NewName
+	}
+	if len(req.NewConfig) != 0 {
+		config, err := restoreConnectorSecrets(req.NewConfig, c.Config)
+		if err != nil {
+			return nil, fmt.Errorf("update connector: %v", err)
+		}
+		c.Config = config
+	}
+	// Opening the connector may be slow, so it's validated before the update
+	// rather than within it.
+	if err := d.validateConnector(c); err != nil {
+		return nil, fmt.Errorf("update connector: %v", err)
+	}
+	// Servers reopen the connector when its resource version changes.
+	c.ResourceVersion = storage.NewID()
+
+	updater := func(old storage.Connector) (storage.Connector, error) {
+		return c, nil
+	}
+	if err := d.s.UpdateConnector(req.Id, updater); err != nil {
+		if err == storage.ErrNotFound {
+			return &api.UpdateConnectorResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to update connector: %v", err)
+		return nil, fmt.Errorf("update connector: %v", err)
+	}
+	return &api.UpdateConnectorResp{}, nil
+}
+
+func (d dexAPI) DeleteConnector(ctx context.Context, req *api.DeleteConnectorReq) (*api.DeleteConnectorResp, error) {
+	if req.Id == "" {
+		return nil, errors.New("no connector ID supplied")
+	}
+
+	if err := d.s.DeleteConnector(req.Id); err != nil {
+		if err == storage.ErrNotFound {
+			return &api.DeleteConnectorResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to delete connector: %v", err)
+		return nil, fmt.Errorf("delete connector: %v", err)
+	}
+	return &api.DeleteConnectorResp{}, nil
+}
+
+func (d dexAPI) ListConnectors(ctx context.Context, req *api.ListConnectorReq) (*api.ListConnectorResp, error) {
+	connectorList, err := d.s.ListConnectors()
+	if err != nil {
+		d.logger.Errorf("api: failed to list connectors: %v", err)
+		return nil, fmt.Errorf("list connectors: %v", err)
+	}
+
+	var connectors []*api.Connector
+	for _, connector := range connectorList {
+		config, err := redactConnectorSecrets(connector.Config)
+		if err != nil {
+			d.logger.Errorf("api: failed to redact config of connector %q: %v", connector.ID, err)
+			return nil, fmt.Errorf("list connectors: %v", err)
+		}
+		c := api.Connector{
+			Id:     connector.ID,
+			Type:   connector.Type,
+			Name:   connector.Name,
+			Config: config,
+		}
+		connectors = append(connectors, &c)
+	}
+
+	return &api.ListConnectorResp{
+		Connectors: connectors,
+	}, nil
+}
+
+// validateConnector checks the connector's config by opening it. The opened
+// connector is only used for the check and closed right away.
+func (d dexAPI) validateConnector(c storage.Connector) error {
+	if c.Type == LocalConnector {
+		// The local connector has no config, its passwords live in the storage.
+		return nil
+	}
+	conn, err := openConnector(d.logger, c)
+	if err != nil {
+		return err
+	}
+	if closer, ok := conn.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			d.logger.Errorf("api: failed to close connector %q: %v", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// isSecretConfigKey reports whether a connector config field holds a secret,
+// e.g. "clientSecret" or "bindPW".
+func isSecretConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	return strings.Contains(key, "secret") || strings.Contains(key, "password") || key == "bindpw"
+}
+
+func decodeConnectorConfig(config []byte) (interface{}, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(config))
+	// Keep numbers as they are when encoding the config again.
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to decode connector config: %v", err)
+	}
+	return v, nil
+}
+
+// redactConnectorSecrets replaces the values of secret fields in a connector
+// config with redactedValue.
+func redactConnectorSecrets(config []byte) ([]byte, error) {
+	if len(config) == 0 {
+		return config, nil
+	}
+	v, err := decodeConnectorConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactSecrets(v))
+}
+
+func redactSecrets(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if _, ok := value.(string); ok && isSecretConfigKey(key) {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactSecrets(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactSecrets(value)
+		}
+	}
+	return v
+}
+
+// restoreConnectorSecrets replaces redacted secrets in a new connector config
+// with their values from the old config, so configs returned by
+// ListConnectors can be modified and sent back.
+func restoreConnectorSecrets(newConfig, oldConfig []byte) ([]byte, error) {
+	n, err := decodeConnectorConfig(newConfig)
+	if err != nil {
+		return nil, err
+	}
+	var o interface{}
+	if len(oldConfig) != 0 {
+		if o, err = decodeConnectorConfig(oldConfig); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(restoreSecrets(n, o))
+}
+
+func restoreSecrets(newValue, oldValue interface{}) interface{} {
+	switch n := newValue.(type) {
+	case map[string]interface{}:
+		o, _ := oldValue.(map[string]interface{})
+		for key, value := range n {
+			if value == redactedValue && isSecretConfigKey(key) {
+				n[key] = o[key]
+				continue
+			}
+			n[key] = restoreSecrets(value, o[key])
+		}
+	case []interface{}:
+		o, _ := oldValue.([]interface{})
+		for i, value := range n {
+			var old interface{}
+			if i < len(o) {
+				old = o[i]
+			}
+			n[i] = restoreSecrets(value, old)
+		}
+	}
+	return newValue
+}
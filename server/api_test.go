@@ -403,11 +403,18 @@ func TestUpdateClient(t *testing.T) {
 			setup:   createClient,
 			cleanup: deleteClient,
 			req: &api.UpdateClientReq{
-				Id:           "test",
-				RedirectUris: []string{"https://redirect"},
-				TrustedPeers: []string{"test"},
-				Name:         "test",
-				LogoUrl:      "https://logout",
+				Id:               "test",
+				RedirectUris:     []string{"https://redirect"},
+				TrustedPeers:     []string{"test"},
+				Name:             "test",
+				LogoUrl:          "https://logout",
+				AllowedScopes:    []string{"openid"},
+				AllowedAudiences: []string{"https://api.example.com"},
+
+				ClientCredentialsScopes: []string{"read"},
+				AllowedGrantTypes:       []string{"authorization_code"},
+				AllowedResponseTypes:    []string{"code"},
+				AllowedConnectors:       []string{"github"},
 			},
 			wantErr: false,
 			want: &api.UpdateClientResp{
@@ -489,6 +496,36 @@ func TestUpdateClient(t *testing.T) {
 						t.Errorf("expected trusted peer: %s", peer)
 					}
 				}
+				for _, scope := range tc.req.AllowedScopes {
+					if !find(scope, client.AllowedScopes) {
+						t.Errorf("expected allowed scope: %s", scope)
+					}
+				}
+				for _, scope := range tc.req.ClientCredentialsScopes {
+					if !find(scope, client.ClientCredentialsScopes) {
+						t.Errorf("expected client credentials scope: %s", scope)
+					}
+				}
+				for _, aud := range tc.req.AllowedAudiences {
+					if !find(aud, client.AllowedAudiences) {
+						t.Errorf("expected allowed audience: %s", aud)
+					}
+				}
+				for _, grantType := range tc.req.AllowedGrantTypes {
+					if !find(grantType, client.AllowedGrantTypes) {
+						t.Errorf("expected allowed grant type: %s", grantType)
+					}
+				}
+				for _, responseType := range tc.req.AllowedResponseTypes {
+					if !find(responseType, client.AllowedResponseTypes) {
+						t.Errorf("expected allowed response type: %s", responseType)
+					}
+				}
+				for _, conn := range tc.req.AllowedConnectors {
+					if !find(conn, client.AllowedConnectors) {
+						t.Errorf("expected allowed connector: %s", conn)
+					}
+				}
 			}
 
 			if tc.cleanup != nil {
@@ -498,6 +535,203 @@ func TestUpdateClient(t *testing.T) {
 	}
 }
 
+// Attempts to create a client, then add, rotate and remove its secrets.
+func TestClientSecret(t *testing.T) {
+	logger := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: &logrus.TextFormatter{DisableColors: true},
+		Level:     logrus.DebugLevel,
+	}
+
+	s := memory.New(logger)
+	client := newAPI(s, logger, t)
+	defer client.Close()
+	ctx := context.Background()
+
+	createResp, err := client.CreateClient(ctx, &api.CreateClientReq{
+		Client: &api.Client{Id: "test", RedirectUris: []string{"https://example.com/callback"}},
+	})
+	if err != nil {
+		t.Fatalf("unable to create the client: %v", err)
+	}
+	stored, err := s.GetClient("test")
+	if err != nil {
+		t.Fatalf("unable to get the client: %v", err)
+	}
+	if stored.Secret != "" || len(stored.Secrets) != 1 {
+		t.Fatalf("expected a single hashed secret, got %#v", stored)
+	}
+	if !stored.Secrets[0].Matches(createResp.Client.Secret, time.Now()) {
+		t.Errorf("expected the returned secret to match the stored hash")
+	}
+
+	addResp, err := client.AddClientSecret(ctx, &api.AddClientSecretReq{
+		ClientId: "test",
+		Expiry:   time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("unable to add a client secret: %v", err)
+	}
+	if addResp.NotFound || addResp.Id == "" || addResp.Secret == "" {
+		t.Fatalf("unexpected add client secret response %v", addResp)
+	}
+	stored, err = s.GetClient("test")
+	if err != nil {
+		t.Fatalf("unable to get the client: %v", err)
+	}
+	if len(stored.Secrets) != 2 || !stored.Secrets[1].Matches(addResp.Secret, time.Now()) {
+		t.Errorf("expected the added secret to be stored, got %#v", stored.Secrets)
+	}
+	if stored.Secrets[1].Matches(addResp.Secret, time.Now().Add(2*time.Hour)) {
+		t.Errorf("expected the added secret to expire")
+	}
+
+	// Retire the original secret.
+	removeResp, err := client.RemoveClientSecret(ctx, &api.RemoveClientSecretReq{
+		ClientId: "test",
+		Id:       stored.Secrets[0].ID,
+	})
+	if err != nil {
+		t.Fatalf("unable to remove the client secret: %v", err)
+	}
+	if removeResp.NotFound {
+		t.Errorf("expected the client secret to be found")
+	}
+	stored, err = s.GetClient("test")
+	if err != nil {
+		t.Fatalf("unable to get the client: %v", err)
+	}
+	if len(stored.Secrets) != 1 || stored.Secrets[0].ID != addResp.Id {
+		t.Errorf("expected only the added secret to remain, got %#v", stored.Secrets)
+	}
+
+	removeResp, err = client.RemoveClientSecret(ctx, &api.RemoveClientSecretReq{ClientId: "test", Id: "unknown"})
+	if err != nil {
+		t.Fatalf("unable to remove the client secret: %v", err)
+	}
+	if !removeResp.NotFound {
+		t.Errorf("expected unknown client secret not to be found")
+	}
+	addResp, err = client.AddClientSecret(ctx, &api.AddClientSecretReq{ClientId: "unknown"})
+	if err != nil {
+		t.Fatalf("unable to add a client secret: %v", err)
+	}
+	if !addResp.NotFound {
+		t.Errorf("expected unknown client not to be found")
+	}
+}
+
+// Attempts to create, update, list and delete a test Connector
+func TestConnector(t *testing.T) {
+	logger := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: &logrus.TextFormatter{DisableColors: true},
+		Level:     logrus.DebugLevel,
+	}
+
+	s := memory.New(logger)
+	client := newAPI(s, logger, t)
+	defer client.Close()
+
+	ctx := context.Background()
+	c := api.Connector{
+		Id:     "test",
+		Type:   "mockPassword",
+		Name:   "Test",
+		Config: []byte(`{"username": "foo", "password": "secret"}`),
+	}
+
+	if _, err := client.CreateConnector(ctx, &api.CreateConnectorReq{Connector: &c}); err != nil {
+		t.Fatalf("Unable to create connector: %v", err)
+	}
+
+	// Attempt to create the same connector twice.
+	resp, err := client.CreateConnector(ctx, &api.CreateConnectorReq{Connector: &c})
+	if err != nil {
+		t.Fatalf("Unable to create connector: %v", err)
+	}
+	if !resp.AlreadyExists {
+		t.Errorf("Created connector %s twice", c.Id)
+	}
+
+	// Configs the connector type can't open are rejected.
+	invalid := api.Connector{Id: "invalid", Type: "mockPassword", Config: []byte(`{"username": "foo"}`)}
+	if _, err := client.CreateConnector(ctx, &api.CreateConnectorReq{Connector: &invalid}); err == nil {
+		t.Errorf("Expected creating a connector with an invalid config to fail")
+	}
+	unknown := api.Connector{Id: "unknown", Type: "unknown"}
+	if _, err := client.CreateConnector(ctx, &api.CreateConnectorReq{Connector: &unknown}); err == nil {
+		t.Errorf("Expected creating a connector of an unknown type to fail")
+	}
+
+	listResp, err := client.ListConnectors(ctx, &api.ListConnectorReq{})
+	if err != nil {
+		t.Fatalf("Unable to list connectors: %v", err)
+	}
+	if len(listResp.Connectors) != 1 {
+		t.Fatalf("Expected 1 connector, got %d", len(listResp.Connectors))
+	}
+	listed := listResp.Connectors[0]
+	if string(listed.Config) != `{"password":"******","username":"foo"}` {
+		t.Errorf("Expected password to be redacted, got config %s", listed.Config)
+	}
+
+	old, err := s.GetConnector("test")
+	if err != nil {
+		t.Fatalf("Unable to get connector: %v", err)
+	}
+
+	// Send the listed config back with a new username, the redacted password
+	// keeps its value.
+	updateReq := api.UpdateConnectorReq{
+		Id:        "test",
+		NewName:   "New Test",
+		NewConfig: []byte(`{"username": "bar", "password": "******"}`),
+	}
+	if _, err := client.UpdateConnector(ctx, &updateReq); err != nil {
+		t.Fatalf("Unable to update connector: %v", err)
+	}
+
+	conn, err := s.GetConnector("test")
+	if err != nil {
+		t.Fatalf("Unable to get connector: %v", err)
+	}
+	if conn.Name != "New Test" {
+		t.Errorf("Expected name %q got %q", "New Test", conn.Name)
+	}
+	if string(conn.Config) != `{"password":"secret","username":"bar"}` {
+		t.Errorf("Expected password to be restored, got config %s", conn.Config)
+	}
+	if conn.ResourceVersion == old.ResourceVersion {
+		t.Errorf("Expected resource version to change on update")
+	}
+
+	if _, err := client.UpdateConnector(ctx, &api.UpdateConnectorReq{Id: "test", NewConfig: []byte(`{"username": ""}`)}); err == nil {
+		t.Errorf("Expected updating a connector with an invalid config to fail")
+	}
+	if unchanged, err := s.GetConnector("test"); err != nil || unchanged.ResourceVersion != conn.ResourceVersion {
+		t.Errorf("Expected a failed update to leave the connector unchanged")
+	}
+	updateResp, err := client.UpdateConnector(ctx, &api.UpdateConnectorReq{Id: "missing", NewName: "Missing"})
+	if err != nil {
+		t.Fatalf("Unable to update connector: %v", err)
+	}
+	if !updateResp.NotFound {
+		t.Errorf("Expected updating a missing connector to return not found")
+	}
+
+	if _, err := client.DeleteConnector(ctx, &api.DeleteConnectorReq{Id: "test"}); err != nil {
+		t.Fatalf("Unable to delete connector: %v", err)
+	}
+	deleteResp, err := client.DeleteConnector(ctx, &api.DeleteConnectorReq{Id: "test"})
+	if err != nil {
+		t.Fatalf("Unable to delete connector: %v", err)
+	}
+	if !deleteResp.NotFound {
+		t.Errorf("Expected deleting a missing connector to return not found")
+	}
+}
+
 func find(item string, items []string) bool {
 	for _, i := range items {
 		if item == i {
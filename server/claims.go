@@ -0,0 +1,241 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// Members of the claims request parameter.
+const (
+	claimsRequestIDToken  = "id_token"
+	claimsRequestUserInfo = "userinfo"
+)
+
+// claimsRequest is the claims request parameter, which requests individual
+// claims to be returned in ID tokens and userinfo responses regardless of the
+// scopes.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#ClaimsParameter
+type claimsRequest struct {
+	IDToken  map[string]*claimRequest `json:"id_token,omitempty"`
+	UserInfo map[string]*claimRequest `json:"userinfo,omitempty"`
+}
+
+// claimRequest holds the constraints on a requested claim. A nil claimRequest
+// requests the claim without constraints.
+type claimRequest struct {
+	Essential bool          `json:"essential,omitempty"`
+	Value     interface{}   `json:"value,omitempty"`
+	Values    []interface{} `json:"values,omitempty"`
+}
+
+// parseClaimsRequest parses the claims request parameter. An empty parameter
+// returns nil.
+func parseClaimsRequest(raw string) (*claimsRequest, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var req claimsRequest
+	if err := json.Unmarshal([]byte(raw), &req); err != nil {
+		return nil, fmt.Errorf("malformed claims request: %v", err)
+	}
+	return &req, nil
+}
+
+// member returns the claims requested in the given member of the request.
+func (req *claimsRequest) member(name string) map[string]*claimRequest {
+	if req == nil {
+		return nil
+	}
+	switch name {
+	case claimsRequestIDToken:
+		return req.IDToken
+	case claimsRequestUserInfo:
+		return req.UserInfo
+	}
+	return nil
+}
+
+// matches reports whether value satisfies the value or values constraint.
+func (r *claimRequest) matches(value interface{}) bool {
+	if r == nil {
+		return true
+	}
+	if r.Value != nil {
+		return jsonEqual(r.Value, value)
+	}
+	if len(r.Values) == 0 {
+		return true
+	}
+	for _, v := range r.Values {
+		if jsonEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonEqual compares a value from a request with a claim by their JSON encoding,
+// since JSON numbers and arrays decode to different types than claims use.
+func jsonEqual(a, b interface{}) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(x, y)
+}
+
+// isCustomClaim reports whether a custom scope grants access to the claim.
+func (s *Server) isCustomClaim(name string) bool {
+	for _, claims := range s.customScopes {
+		for _, claim := range claims {
+			if claim == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimScopes maps the claims of the standard scopes to the scope granting them.
+var claimScopes = map[string]string{
+	"email":              scopeEmail,
+	"email_verified":     scopeEmail,
+	"name":               scopeProfile,
+	"preferred_username": scopeProfile,
+	"groups":             scopeGroups,
+	"federated_claims":   scopeFederatedID,
+}
+
+// claimAllowed reports whether a client may request the claim individually,
+// which requires it to be allowed a scope granting the claim.
+func (s *Server) claimAllowed(client storage.Client, name string) bool {
+	if scope, ok := claimScopes[name]; ok {
+		return clientAllows(client.AllowedScopes, scope)
+	}
+	for scope, claims := range s.customScopes {
+		if clientAllows(client.AllowedScopes, scope) && contains(claims, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// availableClaims returns the claims about the user which can be requested
+// individually, leaving out empty ones.
+func (s *Server) availableClaims(claims storage.Claims, connID string) map[string]interface{} {
+	available := map[string]interface{}{
+		"email_verified": claims.EmailVerified,
+		"federated_claims": federatedIDClaims{
+			ConnectorID: connID,
+			UserID:      claims.UserID,
+		},
+	}
+	if claims.Email != "" {
+		available["email"] = claims.Email
+	}
+	if claims.Username != "" {
+		available["name"] = claims.Username
+	}
+	if claims.PreferredUsername != "" {
+		available["preferred_username"] = claims.PreferredUsername
+	}
+	if len(claims.Groups) > 0 {
+		available["groups"] = claims.Groups
+	}
+	for name, value := range claims.CustomClaims {
+		if s.isCustomClaim(name) {
+			available[name] = value
+		}
+	}
+	return available
+}
+
+// requestedClaims returns the claims about the user requested in the member of
+// the claims request. Claims not matching the requested value are left out.
+func (s *Server) requestedClaims(rawRequest, member, clientID string, claims storage.Claims, connID string) (map[string]interface{}, error) {
+	req, err := parseClaimsRequest(rawRequest)
+	if err != nil {
+		return nil, err
+	}
+	requested := req.member(member)
+	if len(requested) == 0 {
+		return nil, nil
+	}
+
+	client, err := s.storage.GetClient(clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client: %v", err)
+	}
+
+	available := s.availableClaims(claims, connID)
+	found := make(map[string]interface{})
+	for name, r := range requested {
+		value, ok := available[name]
+		if ok && r.matches(value) && s.claimAllowed(client, name) {
+			found[name] = value
+		}
+	}
+	return found, nil
+}
+
+// extraClaims returns the claims added to the ones granted by the standard
+// scopes: custom claims granted by custom scopes and claims requested through
+// the claims request parameter.
+func (s *Server) extraClaims(clientID string, claims storage.Claims, scopes []string, connID, claimsRequest, member string) (map[string]interface{}, error) {
+	extra := s.customClaims(claims.CustomClaims, scopes)
+	requested, err := s.requestedClaims(claimsRequest, member, clientID, claims, connID)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range requested {
+		if extra == nil {
+			extra = make(map[string]interface{})
+		}
+		if _, ok := extra[name]; !ok {
+			extra[name] = value
+		}
+	}
+	return extra, nil
+}
+
+// checkClaimsRequest verifies the logged in user satisfies the claims request.
+// A requested subject has to match the user's, and essential claims must be
+// available with the requested value.
+func (s *Server) checkClaimsRequest(rawRequest string, claims storage.Claims, connID string) error {
+	req, err := parseClaimsRequest(rawRequest)
+	if err != nil || req == nil {
+		return err
+	}
+
+	subject, err := newSubject(claims, connID)
+	if err != nil {
+		return fmt.Errorf("failed to marshal subject: %v", err)
+	}
+	available := s.availableClaims(claims, connID)
+	for _, requested := range []map[string]*claimRequest{req.IDToken, req.UserInfo} {
+		for name, r := range requested {
+			if name == "sub" {
+				if !r.matches(subject) {
+					return errors.New("user does not match the requested subject")
+				}
+				continue
+			}
+			if r == nil || !r.Essential {
+				continue
+			}
+			if value, ok := available[name]; !ok || !r.matches(value) {
+				return fmt.Errorf("essential claim %q is not available", name)
+			}
+		}
+	}
+	return nil
+}
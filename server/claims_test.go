@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestClaimsRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	claims := storage.Claims{
+		UserID:        "user",
+		Username:      "jane",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		Groups:        []string{"admins"},
+		CustomClaims:  map[string]interface{}{"department": "engineering"},
+	}
+	claimsRequest := `{
+		"id_token": {"email": {"essential": true}, "groups": {"value": ["users"]}, "department": null},
+		"userinfo": {"name": null, "groups": {"values": [["admins"]]}}
+	}`
+
+	for _, format := range []string{accessTokenFormatJWT, accessTokenFormatOpaque} {
+		httpServer, s := newTestServer(ctx, t, func(c *Config) {
+			c.AccessTokenFormat = format
+			c.CustomScopes = map[string][]string{"department": {"department"}}
+		})
+		defer httpServer.Close()
+
+		for _, client := range []storage.Client{
+			{ID: "foo", Secret: "secret"},
+			{ID: "restricted", Secret: "secret", AllowedScopes: []string{"openid", "groups"}},
+		} {
+			if err := s.storage.CreateClient(client); err != nil {
+				t.Fatalf("%s: failed to create client: %v", format, err)
+			}
+		}
+
+		// Only the openid scope, all other claims are requested individually.
+		scopes := []string{"openid"}
+		accessToken, _, err := s.newAccessToken("foo", claims, scopes, "mock", claimsRequest, storage.Confirmation{})
+		if err != nil {
+			t.Fatalf("%s: failed to create access token: %v", format, err)
+		}
+		idToken, _, err := s.newIDToken("foo", claims, scopes, "", accessToken, "mock", claimsRequest, time.Time{})
+		if err != nil {
+			t.Fatalf("%s: failed to create id token: %v", format, err)
+		}
+		jws, err := jose.ParseSigned(idToken)
+		if err != nil {
+			t.Fatalf("%s: failed to parse id token: %v", format, err)
+		}
+		var tok map[string]interface{}
+		if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &tok); err != nil {
+			t.Fatalf("%s: failed to decode id token: %v", format, err)
+		}
+		if tok["email"] != claims.Email || tok["department"] != "engineering" {
+			t.Errorf("%s: expected requested claims in id token, got %v", format, tok)
+		}
+		for _, name := range []string{"groups", "name"} {
+			if _, ok := tok[name]; ok {
+				t.Errorf("%s: unexpected claim %q in id token", format, name)
+			}
+		}
+
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 got %d: %s", format, rr.Code, rr.Body.String())
+		}
+		var info map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+			t.Fatalf("%s: failed to decode userinfo: %v", format, err)
+		}
+		if info["name"] != claims.Username || info["groups"] == nil {
+			t.Errorf("%s: expected requested claims in userinfo, got %v", format, info)
+		}
+		for _, name := range []string{"email", "department"} {
+			if _, ok := info[name]; ok {
+				t.Errorf("%s: unexpected claim %q in userinfo", format, name)
+			}
+		}
+
+		// Clients only get the claims of the scopes they are allowed.
+		idToken, _, err = s.newIDToken("restricted", claims, scopes, "", "", "mock", `{"id_token": {"email": null, "groups": null, "department": null}}`, time.Time{})
+		if err != nil {
+			t.Fatalf("%s: failed to create id token: %v", format, err)
+		}
+		if jws, err = jose.ParseSigned(idToken); err != nil {
+			t.Fatalf("%s: failed to parse id token: %v", format, err)
+		}
+		tok = nil
+		if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &tok); err != nil {
+			t.Fatalf("%s: failed to decode id token: %v", format, err)
+		}
+		if tok["groups"] == nil {
+			t.Errorf("%s: expected allowed claim in id token, got %v", format, tok)
+		}
+		for _, name := range []string{"email", "department"} {
+			if _, ok := tok[name]; ok {
+				t.Errorf("%s: unexpected claim %q of a disallowed scope in id token", format, name)
+			}
+		}
+	}
+}
+
+func TestCheckClaimsRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	claims := storage.Claims{UserID: "user", Username: "jane", Email: "jane@example.com", EmailVerified: true}
+	subject, err := newSubject(claims, "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		claimsRequest string
+		wantErr       bool
+	}{
+		{name: "no claims request"},
+		{name: "voluntary claim", claimsRequest: `{"id_token":{"groups":null}}`},
+		{name: "essential claim", claimsRequest: `{"userinfo":{"email":{"essential":true}}}`},
+		{name: "essential claim missing", claimsRequest: `{"id_token":{"groups":{"essential":true}}}`, wantErr: true},
+		{name: "essential claim mismatch", claimsRequest: `{"id_token":{"email":{"essential":true,"value":"john@example.com"}}}`, wantErr: true},
+		{name: "subject", claimsRequest: `{"id_token":{"sub":{"value":"` + subject + `"}}}`},
+		{name: "subject mismatch", claimsRequest: `{"id_token":{"sub":{"value":"someone-else"}}}`, wantErr: true},
+	}
+	for _, tc := range tests {
+		err := s.checkClaimsRequest(tc.claimsRequest, claims, "mock")
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: wantErr=%t, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+
+	// Unsatisfied claims requests are rejected when sending the response.
+	authReq := storage.AuthRequest{
+		ID:            storage.NewID(),
+		ClientID:      "foo",
+		ResponseTypes: []string{responseTypeCode},
+		Scopes:        []string{"openid"},
+		RedirectURI:   "https://example.com/callback",
+		State:         "state",
+		LoggedIn:      true,
+		Claims:        claims,
+		ConnectorID:   "mock",
+		Expiry:        time.Now().Add(time.Minute),
+		ClaimsRequest: `{"id_token":{"sub":{"value":"someone-else"}}}`,
+	}
+	if err := s.storage.CreateAuthRequest(authReq); err != nil {
+		t.Fatalf("failed to create auth request: %v", err)
+	}
+	s.skipApproval = true
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/approval?req="+authReq.ID, nil))
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d: %s", rr.Code, rr.Body.String())
+	}
+	u, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Query().Get("error"); got != errAccessDenied {
+		t.Errorf("expected error %q, got %q", errAccessDenied, got)
+	}
+	if u.Query().Get("code") != "" {
+		t.Errorf("expected no code to be issued")
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// Token endpoint authentication methods.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
+const (
+	authMethodNone              = "none"
+	authMethodClientSecretBasic = "client_secret_basic"
+	authMethodClientSecretPost  = "client_secret_post"
+	authMethodClientSecretJWT   = "client_secret_jwt"
+	authMethodPrivateKeyJWT     = "private_key_jwt"
+
+	// Mutual-TLS client authentication methods.
+	//
+	// https://tools.ietf.org/html/rfc8705#section-2.1.1
+	authMethodTLSClientAuth           = "tls_client_auth"
+	authMethodSelfSignedTLSClientAuth = "self_signed_tls_client_auth"
+)
+
+// authMethods are the token endpoint authentication methods advertised by the
+// discovery document.
+var authMethods = []string{
+	authMethodClientSecretBasic,
+	authMethodClientSecretPost,
+	authMethodClientSecretJWT,
+	authMethodPrivateKeyJWT,
+}
+
+// clientAssertionTypeJWTBearer is the client_assertion_type of JWTs clients
+// authenticate with.
+//
+// https://tools.ietf.org/html/rfc7523#section-2.2
+const clientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientSecretJWTAlgs are the algorithms of client_secret_jwt assertions, which
+// are signed with the client secret. private_key_jwt assertions are signed with
+// the same algorithms as request objects.
+var clientSecretJWTAlgs = []string{
+	string(jose.HS256), string(jose.HS384), string(jose.HS512),
+}
+
+// clientAuthMethodAllowed reports whether the client may authenticate with the
+// method. Clients without restrictions may use all methods.
+func clientAuthMethodAllowed(client storage.Client, method string) bool {
+	return len(client.TokenEndpointAuthMethods) == 0 || contains(client.TokenEndpointAuthMethods, method)
+}
+
+// verifyClientSecret reports whether secret is one of the client's secrets. The
+// plaintext secret of static and client_secret_jwt clients is compared in
+// constant time, other secrets against their hashes. Clients without secrets,
+// like those authenticating with keys or certificates, never match.
+func (s *Server) verifyClientSecret(client storage.Client, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	if client.Secret != "" && subtle.ConstantTimeCompare([]byte(client.Secret), []byte(secret)) == 1 {
+		return true
+	}
+	now := s.now()
+	for _, hashed := range client.Secrets {
+		if hashed.Matches(secret, now) {
+			return true
+		}
+	}
+	return false
+}
+
+type clientAssertionClaims struct {
+	Issuer    string      `json:"iss"`
+	Subject   string      `json:"sub"`
+	Audience  interface{} `json:"aud"`
+	Expiry    json.Number `json:"exp"`
+	NotBefore json.Number `json:"nbf"`
+	JWTID     string      `json:"jti"`
+}
+
+// verifyClientAssertion authenticates a client by the JWT it signed with its
+// secret or private key. It returns the client and the authentication method.
+//
+// https://tools.ietf.org/html/rfc7523#section-3
+func (s *Server) verifyClientAssertion(ctx context.Context, clientID, assertionType, assertion string) (storage.Client, string, error) {
+	if assertionType != clientAssertionTypeJWTBearer {
+		return storage.Client{}, "", fmt.Errorf("unsupported client assertion type %q", assertionType)
+	}
+	jws, err := jose.ParseSigned(assertion)
+	if err != nil {
+		return storage.Client{}, "", fmt.Errorf("malformed client assertion: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return storage.Client{}, "", errors.New("client assertion must have exactly one signature")
+	}
+
+	// The client is identified by the subject, which is verified once the
+	// signature has been checked with the client's keys.
+	var unverified clientAssertionClaims
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &unverified); err != nil {
+		return storage.Client{}, "", fmt.Errorf("malformed client assertion claims: %v", err)
+	}
+	if clientID == "" {
+		clientID = unverified.Subject
+	}
+	client, err := s.storage.GetClient(clientID)
+	if err != nil {
+		return storage.Client{}, "", fmt.Errorf("failed to get client %q: %v", clientID, err)
+	}
+
+	var (
+		method  string
+		payload []byte
+	)
+	switch alg := jws.Signatures[0].Header.Algorithm; {
+	case contains(clientSecretJWTAlgs, alg):
+		method = authMethodClientSecretJWT
+		if client.Secret == "" {
+			return storage.Client{}, "", errors.New("client has no secret")
+		}
+		payload, err = jws.Verify([]byte(client.Secret))
+	case contains(requestObjectAlgs, alg):
+		method = authMethodPrivateKeyJWT
+		keys, err := s.clientKeySet(client)
+		if err != nil {
+			return storage.Client{}, "", err
+		}
+		payload, err = keys.VerifySignature(ctx, assertion)
+		if err != nil {
+			return storage.Client{}, "", err
+		}
+	default:
+		return storage.Client{}, "", fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return storage.Client{}, "", fmt.Errorf("failed to verify signature: %v", err)
+	}
+
+	var claims clientAssertionClaims
+	d := json.NewDecoder(strings.NewReader(string(payload)))
+	d.UseNumber()
+	if err := d.Decode(&claims); err != nil {
+		return storage.Client{}, "", fmt.Errorf("malformed client assertion claims: %v", err)
+	}
+	if claims.Issuer != client.ID || claims.Subject != client.ID {
+		return storage.Client{}, "", errors.New("issuer and subject must be the client ID")
+	}
+	if !audienceContains(claims.Audience, s.issuerURL.String()) && !audienceContains(claims.Audience, s.absURL("/token")) {
+		return storage.Client{}, "", fmt.Errorf("unexpected audience %v", claims.Audience)
+	}
+	if claims.JWTID == "" {
+		return storage.Client{}, "", errors.New("missing jti claim")
+	}
+	now := s.now()
+	expiry, ok := numericDate(claims.Expiry)
+	if !ok {
+		return storage.Client{}, "", errors.New("missing exp claim")
+	}
+	if !now.Before(expiry) {
+		return storage.Client{}, "", errors.New("client assertion expired")
+	}
+	if nbf, ok := numericDate(claims.NotBefore); ok && now.Before(nbf) {
+		return storage.Client{}, "", errors.New("client assertion not valid yet")
+	}
+
+	// Assertions are recorded until they expire so they can only be used once.
+	id := sha256.Sum256([]byte(client.ID + "\x00" + claims.JWTID))
+	err = s.storage.CreateClientAssertion(storage.ClientAssertion{
+		ID:       hex.EncodeToString(id[:]),
+		ClientID: client.ID,
+		Expiry:   expiry,
+	})
+	if err != nil {
+		if err == storage.ErrAlreadyExists {
+			return storage.Client{}, "", errors.New("client assertion replayed")
+		}
+		return storage.Client{}, "", fmt.Errorf("failed to store client assertion: %v", err)
+	}
+	return client, method, nil
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestAuthenticateClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	key := jose.JSONWebKey{Key: testKey, KeyID: "client-key", Algorithm: string(jose.RS256), Use: "sig"}
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.Public()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const hmacSecret = "a-shared-secret-of-at-least-32-bytes"
+
+	current := storage.NewClientSecret("current-secret", time.Now().Add(time.Hour))
+	next := storage.NewClientSecret("next-secret", time.Time{})
+	expired := storage.NewClientSecret("expired-secret", time.Now().Add(-time.Minute))
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{
+			{
+				ID:                       "jwt",
+				Secret:                   "jwt-secret",
+				JWKS:                     string(jwks),
+				TokenEndpointAuthMethods: []string{authMethodPrivateKeyJWT},
+			},
+			{
+				ID:     "hmac",
+				Secret: hmacSecret,
+			},
+			{
+				ID:                       "basic",
+				Secret:                   "basic-secret",
+				TokenEndpointAuthMethods: []string{authMethodClientSecretBasic},
+			},
+			{
+				ID:      "hashed",
+				Secrets: []storage.ClientSecret{current, next, expired},
+			},
+			{
+				ID:   "no-secret",
+				JWKS: string(jwks),
+			},
+		})
+	})
+	defer httpServer.Close()
+
+	sign := func(alg jose.SignatureAlgorithm, signingKey interface{}, claims map[string]interface{}) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: signingKey}, nil)
+		if err != nil {
+			t.Fatalf("failed to create signer: %v", err)
+		}
+		payload, err := json.Marshal(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jws, err := signer.Sign(payload)
+		if err != nil {
+			t.Fatalf("failed to sign client assertion: %v", err)
+		}
+		assertion, err := jws.CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return assertion
+	}
+	assertionClaims := func(clientID, jti string) map[string]interface{} {
+		return map[string]interface{}{
+			"iss": clientID,
+			"sub": clientID,
+			"aud": s.absURL("/token"),
+			"exp": time.Now().Add(time.Minute).Unix(),
+			"jti": jti,
+		}
+	}
+	assertionForm := func(assertion string) url.Values {
+		return url.Values{
+			"client_assertion_type": {clientAssertionTypeJWTBearer},
+			"client_assertion":      {assertion},
+		}
+	}
+
+	privateKeyJWT := sign(jose.RS256, &key, assertionClaims("jwt", "jti-1"))
+	wrongAudience := assertionClaims("jwt", "jti-2")
+	wrongAudience["aud"] = "https://other.example.com"
+	expiredAssertion := assertionClaims("jwt", "jti-3")
+	expiredAssertion["exp"] = time.Now().Add(-time.Minute).Unix()
+	noJTI := assertionClaims("jwt", "")
+	delete(noJTI, "jti")
+
+	tests := []struct {
+		name       string
+		form       url.Values
+		basicAuth  []string
+		wantClient string
+	}{
+		{name: "private_key_jwt", form: assertionForm(privateKeyJWT), wantClient: "jwt"},
+		{name: "replayed assertion", form: assertionForm(privateKeyJWT)},
+		{name: "wrong audience", form: assertionForm(sign(jose.RS256, &key, wrongAudience))},
+		{name: "expired assertion", form: assertionForm(sign(jose.RS256, &key, expiredAssertion))},
+		{name: "missing jti", form: assertionForm(sign(jose.RS256, &key, noJTI))},
+		{name: "unsupported assertion type", form: url.Values{
+			"client_assertion_type": {"urn:example:unknown"},
+			"client_assertion":      {sign(jose.RS256, &key, assertionClaims("jwt", "jti-4"))},
+		}},
+		{name: "client_secret_jwt", form: assertionForm(sign(jose.HS256, []byte(hmacSecret), assertionClaims("hmac", "jti-1"))), wantClient: "hmac"},
+		{name: "client_secret_jwt with wrong secret", form: assertionForm(sign(jose.HS256, []byte("wrong-secret-of-at-least-32-bytes"), assertionClaims("hmac", "jti-2")))},
+		{name: "disallowed client_secret_jwt", form: assertionForm(sign(jose.HS256, []byte("jwt-secret"), assertionClaims("jwt", "jti-5")))},
+		{name: "disallowed client_secret_post", form: url.Values{"client_id": {"basic"}, "client_secret": {"basic-secret"}}},
+		{name: "client_secret_basic", basicAuth: []string{"basic", "basic-secret"}, wantClient: "basic"},
+		{name: "client_secret_post", form: url.Values{"client_id": {"hmac"}, "client_secret": {hmacSecret}}, wantClient: "hmac"},
+		{name: "hashed secret", basicAuth: []string{"hashed", "current-secret"}, wantClient: "hashed"},
+		{name: "rotated hashed secret", basicAuth: []string{"hashed", "next-secret"}, wantClient: "hashed"},
+		{name: "expired hashed secret", basicAuth: []string{"hashed", "expired-secret"}},
+		{name: "empty secret of hashed client", form: url.Values{"client_id": {"hashed"}}},
+		{name: "empty secret of client without secrets", form: url.Values{"client_id": {"no-secret"}, "client_secret": {""}}},
+		{name: "empty basic auth secret of client without secrets", basicAuth: []string{"no-secret", ""}},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(tc.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tc.basicAuth != nil {
+			req.SetBasicAuth(tc.basicAuth[0], tc.basicAuth[1])
+		}
+		rr := httptest.NewRecorder()
+		client, ok := s.authenticateClient(rr, req, false)
+		if tc.wantClient == "" {
+			if ok {
+				t.Errorf("%s: expected client authentication to fail", tc.name)
+			} else if rr.Code != http.StatusUnauthorized {
+				t.Errorf("%s: expected 401 got %d: %s", tc.name, rr.Code, rr.Body.String())
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: client authentication failed: %s", tc.name, rr.Body.String())
+			continue
+		}
+		if client.ID != tc.wantClient {
+			t.Errorf("%s: expected client %q got %q", tc.name, tc.wantClient, client.ID)
+		}
+	}
+}
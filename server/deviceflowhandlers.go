@@ -0,0 +1,383 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+const (
+	deviceCallbackURI = "/device/callback"
+
+	// Minimum number of seconds a device must wait between polls of the
+	// token endpoint, and the amount the interval grows by on "slow_down".
+	devicePollIntervalSeconds = 5
+)
+
+// Status values of a storage.DeviceToken.
+const (
+	deviceTokenPending  = "pending"
+	deviceTokenComplete = "complete"
+	// The device received the token response, which is no longer stored.
+	deviceTokenRedeemed = "redeemed"
+)
+
+type deviceCodeResponse struct {
+	// The unique device code for device authentication
+	DeviceCode string `json:"device_code"`
+	// The code the user will exchange via a browser and log in
+	UserCode string `json:"user_code"`
+	// The url to verify the user code.
+	VerificationURI string `json:"verification_uri"`
+	// The verification uri with the user code appended for pre-filling form
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	// The lifetime of the device code
+	ExpireTime int `json:"expires_in"`
+	// How often the device is allowed to poll to verify that the user login occurred
+	PollInterval int `json:"interval"`
+}
+
+// handleDeviceCode handles the device authorization request.
+// See: https://tools.ietf.org/html/rfc8628#section-3.1
+func (s *Server) handleDeviceCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.tokenErrHelper(w, errInvalidRequest, "Invalid device code request type.", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "Failed to parse request body.", http.StatusBadRequest)
+		return
+	}
+
+	// Devices are usually unable to keep a secret, so public clients may omit it.
+	client, ok := s.authenticateClient(w, r, true)
+	if !ok {
+		return
+	}
+	if !clientAllows(client.AllowedGrantTypes, grantTypeDeviceCode) {
+		s.tokenErrHelper(w, errUnauthorizedClient, "Client can't use the device flow.", http.StatusBadRequest)
+		return
+	}
+
+	// Some clients, like the old go-oidc, provide extra whitespace. Tolerate this.
+	scopes := strings.Fields(r.PostFormValue("scope"))
+	if disallowed := disallowedScopes(client, scopes); len(disallowed) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Client can't request scope(s) %q", disallowed), http.StatusBadRequest)
+		return
+	}
+
+	now := s.now()
+	expiry := now.Add(s.deviceRequestsValidFor)
+
+	deviceReq := storage.DeviceRequest{
+		UserCode:   storage.NewUserCode(),
+		DeviceCode: storage.NewDeviceCode(),
+		ClientID:   client.ID,
+		Scopes:     scopes,
+		Expiry:     expiry,
+	}
+	if err := s.storage.CreateDeviceRequest(deviceReq); err != nil {
+		s.logger.Errorf("failed to store device request: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	deviceToken := storage.DeviceToken{
+		DeviceCode:          deviceReq.DeviceCode,
+		ClientID:            client.ID,
+		Status:              deviceTokenPending,
+		Expiry:              expiry,
+		LastRequestTime:     now,
+		PollIntervalSeconds: devicePollIntervalSeconds,
+	}
+	if err := s.storage.CreateDeviceToken(deviceToken); err != nil {
+		s.logger.Errorf("failed to store device token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	verificationURI := s.absURL("/device")
+	v := url.Values{}
+	v.Set("user_code", deviceReq.UserCode)
+
+	resp := deviceCodeResponse{
+		DeviceCode:              deviceReq.DeviceCode,
+		UserCode:                deviceReq.UserCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?" + v.Encode(),
+		ExpireTime:              int(s.deviceRequestsValidFor.Seconds()),
+		PollInterval:            devicePollIntervalSeconds,
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Errorf("failed to marshal device code response: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// handleDeviceExchange renders the form where the user enters the code shown
+// on their device.
+func (s *Server) handleDeviceExchange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.renderError(r, w, http.StatusBadRequest, "Unsupported request method.")
+		return
+	}
+
+	userCode := r.URL.Query().Get("user_code")
+	if err := s.templates.device(r, w, s.absPath("/device/auth/verify_code"), userCode, false); err != nil {
+		s.logger.Errorf("Server template error: %v", err)
+	}
+}
+
+// verifyUserCode looks up the device request for the submitted user code and
+// starts a regular authorization code flow on behalf of the device.
+func (s *Server) verifyUserCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.renderError(r, w, http.StatusBadRequest, "Unsupported request method.")
+		return
+	}
+
+	userCode := normalizeUserCode(r.PostFormValue("user_code"))
+	if userCode == "" {
+		s.renderInvalidUserCode(w, r, userCode)
+		return
+	}
+
+	deviceReq, err := s.storage.GetDeviceRequest(userCode)
+	if err != nil || s.now().After(deviceReq.Expiry) {
+		if err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get device request: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+			return
+		}
+		s.renderInvalidUserCode(w, r, userCode)
+		return
+	}
+
+	deviceToken, err := s.storage.GetDeviceToken(deviceReq.DeviceCode)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get device token: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+			return
+		}
+		s.renderInvalidUserCode(w, r, userCode)
+		return
+	}
+	if deviceToken.Status != deviceTokenPending {
+		s.renderInvalidUserCode(w, r, userCode)
+		return
+	}
+
+	v := url.Values{}
+	v.Set("client_id", deviceReq.ClientID)
+	v.Set("state", deviceReq.UserCode)
+	v.Set("response_type", responseTypeCode)
+	v.Set("redirect_uri", s.absURL(deviceCallbackURI))
+	v.Set("scope", strings.Join(deviceReq.Scopes, " "))
+
+	http.Redirect(w, r, s.absPath("/auth")+"?"+v.Encode(), http.StatusFound)
+}
+
+func (s *Server) renderInvalidUserCode(w http.ResponseWriter, r *http.Request, userCode string) {
+	if err := s.templates.device(r, w, s.absPath("/device/auth/verify_code"), userCode, true); err != nil {
+		s.logger.Errorf("Server template error: %v", err)
+	}
+}
+
+// handleDeviceCallback receives the auth code at the end of the browser login,
+// exchanges it for tokens and stores them for the polling device.
+func (s *Server) handleDeviceCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.renderError(r, w, http.StatusBadRequest, "Unsupported request method.")
+		return
+	}
+
+	q := r.URL.Query()
+	if errType := q.Get("error"); errType != "" {
+		description := q.Get("error_description")
+		s.logger.Errorf("device login failed: %s: %s", errType, description)
+		s.renderError(r, w, http.StatusUnauthorized, fmt.Sprintf("Login failed: %s", description))
+		return
+	}
+
+	userCode := q.Get("state")
+	code := q.Get("code")
+	if userCode == "" || code == "" {
+		s.renderError(r, w, http.StatusBadRequest, "Request was missing parameters.")
+		return
+	}
+
+	authCode, err := s.storage.GetAuthCode(code)
+	if err != nil || s.now().After(authCode.Expiry) {
+		if err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get auth code: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+			return
+		}
+		s.renderError(r, w, http.StatusBadRequest, "Invalid or expired auth code.")
+		return
+	}
+
+	deviceReq, err := s.storage.GetDeviceRequest(userCode)
+	if err != nil || s.now().After(deviceReq.Expiry) {
+		if err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get device request: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+			return
+		}
+		s.renderError(r, w, http.StatusBadRequest, "Invalid or expired user code.")
+		return
+	}
+
+	if authCode.ClientID != deviceReq.ClientID || authCode.RedirectURI != s.absURL(deviceCallbackURI) {
+		s.renderError(r, w, http.StatusBadRequest, "Auth code was not issued for this device request.")
+		return
+	}
+
+	client, err := s.storage.GetClient(deviceReq.ClientID)
+	if err != nil {
+		s.logger.Errorf("failed to get client %q: %v", deviceReq.ClientID, err)
+		s.renderError(r, w, http.StatusInternalServerError, "Failed to retrieve client.")
+		return
+	}
+
+	resp, err := s.exchangeAuthCode(authCode, client, storage.Confirmation{})
+	if err != nil {
+		s.renderError(r, w, http.StatusInternalServerError, "Failed to issue tokens.")
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Errorf("failed to marshal device token response: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "")
+		return
+	}
+
+	errAlreadyComplete := errors.New("device token already complete")
+	if err := s.storage.UpdateDeviceToken(deviceReq.DeviceCode, func(old storage.DeviceToken) (storage.DeviceToken, error) {
+		if old.Status != deviceTokenPending {
+			return old, errAlreadyComplete
+		}
+		old.Status = deviceTokenComplete
+		old.Token = string(data)
+		return old, nil
+	}); err != nil {
+		if err == errAlreadyComplete {
+			s.renderError(r, w, http.StatusBadRequest, "Device request has already been completed.")
+			return
+		}
+		s.logger.Errorf("failed to update device token: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+
+	if err := s.templates.deviceSuccess(r, w, client.Name); err != nil {
+		s.logger.Errorf("Server template error: %v", err)
+	}
+}
+
+// handleDeviceToken handles the device polling the token endpoint. Only the
+// client the device request was issued to can poll for the token, which is
+// handed out once.
+// See: https://tools.ietf.org/html/rfc8628#section-3.4
+func (s *Server) handleDeviceToken(w http.ResponseWriter, r *http.Request, client storage.Client) {
+	deviceCode := r.PostFormValue("device_code")
+	if deviceCode == "" {
+		s.tokenErrHelper(w, errInvalidRequest, "No device code received.", http.StatusBadRequest)
+		return
+	}
+
+	now := s.now()
+
+	deviceToken, err := s.storage.GetDeviceToken(deviceCode)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get device token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		} else {
+			s.tokenErrHelper(w, errInvalidGrant, "Invalid device code.", http.StatusBadRequest)
+		}
+		return
+	}
+	if deviceToken.ClientID != client.ID {
+		s.logger.Errorf("client %s trying to claim device token of client %s", client.ID, deviceToken.ClientID)
+		s.tokenErrHelper(w, errInvalidGrant, "Invalid device code.", http.StatusBadRequest)
+		return
+	}
+	if now.After(deviceToken.Expiry) {
+		s.tokenErrHelper(w, errExpiredToken, "", http.StatusBadRequest)
+		return
+	}
+
+	switch deviceToken.Status {
+	case deviceTokenPending:
+		pollInterval := time.Duration(deviceToken.PollIntervalSeconds) * time.Second
+		slowDown := now.Before(deviceToken.LastRequestTime.Add(pollInterval))
+
+		if err := s.storage.UpdateDeviceToken(deviceCode, func(old storage.DeviceToken) (storage.DeviceToken, error) {
+			old.LastRequestTime = now
+			if slowDown {
+				old.PollIntervalSeconds += devicePollIntervalSeconds
+			}
+			return old, nil
+		}); err != nil {
+			s.logger.Errorf("failed to update device token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+
+		if slowDown {
+			s.tokenErrHelper(w, errSlowDown, "", http.StatusBadRequest)
+		} else {
+			s.tokenErrHelper(w, errAuthorizationPending, "", http.StatusBadRequest)
+		}
+	case deviceTokenComplete:
+		errRedeemed := errors.New("device token already redeemed")
+		if err := s.storage.UpdateDeviceToken(deviceCode, func(old storage.DeviceToken) (storage.DeviceToken, error) {
+			if old.Status != deviceTokenComplete {
+				return old, errRedeemed
+			}
+			old.Status = deviceTokenRedeemed
+			old.Token = ""
+			return old, nil
+		}); err != nil {
+			if err == errRedeemed {
+				s.tokenErrHelper(w, errInvalidGrant, "Device code has already been redeemed.", http.StatusBadRequest)
+				return
+			}
+			s.logger.Errorf("failed to update device token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(deviceToken.Token)))
+		w.Write([]byte(deviceToken.Token))
+	case deviceTokenRedeemed:
+		s.tokenErrHelper(w, errInvalidGrant, "Device code has already been redeemed.", http.StatusBadRequest)
+	default:
+		s.logger.Errorf("device token %q has unknown status %q", deviceCode, deviceToken.Status)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+	}
+}
+
+// normalizeUserCode tolerates lower case input, surrounding whitespace and a
+// missing separator in user entered codes.
+func normalizeUserCode(userCode string) string {
+	userCode = strings.ToUpper(strings.TrimSpace(userCode))
+	if len(userCode) == 8 && !strings.Contains(userCode, "-") {
+		userCode = userCode[:4] + "-" + userCode[4:]
+	}
+	return userCode
+}
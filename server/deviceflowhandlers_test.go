@@ -0,0 +1,308 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestHandleDeviceCode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	client := storage.Client{ID: "device", Secret: "secret"}
+	if err := s.storage.CreateClient(client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		form         url.Values
+		expectedCode int
+	}{
+		{"valid request", url.Values{"client_id": {"device"}, "client_secret": {"secret"}, "scope": {"openid profile"}}, http.StatusOK},
+		{"wrong secret", url.Values{"client_id": {"device"}, "client_secret": {"wrong"}}, http.StatusUnauthorized},
+		{"unknown client", url.Values{"client_id": {"unknown"}}, http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/device/code", strings.NewReader(tc.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			var resp deviceCodeResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.PollInterval != devicePollIntervalSeconds {
+				t.Errorf("expected interval %d got %d", devicePollIntervalSeconds, resp.PollInterval)
+			}
+			if resp.VerificationURI != s.absURL("/device") {
+				t.Errorf("unexpected verification_uri %q", resp.VerificationURI)
+			}
+
+			deviceReq, err := s.storage.GetDeviceRequest(resp.UserCode)
+			if err != nil {
+				t.Fatalf("failed to get device request: %v", err)
+			}
+			if deviceReq.DeviceCode != resp.DeviceCode || deviceReq.ClientID != client.ID {
+				t.Errorf("unexpected device request %#v", deviceReq)
+			}
+			deviceToken, err := s.storage.GetDeviceToken(resp.DeviceCode)
+			if err != nil {
+				t.Fatalf("failed to get device token: %v", err)
+			}
+			if deviceToken.Status != deviceTokenPending {
+				t.Errorf("expected status %q got %q", deviceTokenPending, deviceToken.Status)
+			}
+		})
+	}
+}
+
+func TestHandleDeviceToken(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		token         storage.DeviceToken
+		expectedCode  int
+		expectedError string
+	}{
+		{
+			name: "pending",
+			token: storage.DeviceToken{
+				Status:              deviceTokenPending,
+				Expiry:              now.Add(time.Minute),
+				LastRequestTime:     now.Add(-time.Minute),
+				PollIntervalSeconds: devicePollIntervalSeconds,
+			},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errAuthorizationPending,
+		},
+		{
+			name: "polling too fast",
+			token: storage.DeviceToken{
+				Status:              deviceTokenPending,
+				Expiry:              now.Add(time.Minute),
+				LastRequestTime:     now,
+				PollIntervalSeconds: devicePollIntervalSeconds,
+			},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errSlowDown,
+		},
+		{
+			name: "expired",
+			token: storage.DeviceToken{
+				Status:              deviceTokenPending,
+				Expiry:              now.Add(-time.Minute),
+				LastRequestTime:     now.Add(-time.Minute),
+				PollIntervalSeconds: devicePollIntervalSeconds,
+			},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errExpiredToken,
+		},
+		{
+			name: "complete",
+			token: storage.DeviceToken{
+				Status:              deviceTokenComplete,
+				Token:               `{"access_token":"foo"}`,
+				Expiry:              now.Add(time.Minute),
+				LastRequestTime:     now.Add(-time.Minute),
+				PollIntervalSeconds: devicePollIntervalSeconds,
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "issued to another client",
+			token: storage.DeviceToken{
+				ClientID:            "other",
+				Status:              deviceTokenComplete,
+				Token:               `{"access_token":"foo"}`,
+				Expiry:              now.Add(time.Minute),
+				LastRequestTime:     now.Add(-time.Minute),
+				PollIntervalSeconds: devicePollIntervalSeconds,
+			},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errInvalidGrant,
+		},
+		{
+			name: "redeemed",
+			token: storage.DeviceToken{
+				Status:              deviceTokenRedeemed,
+				Expiry:              now.Add(time.Minute),
+				LastRequestTime:     now.Add(-time.Minute),
+				PollIntervalSeconds: devicePollIntervalSeconds,
+			},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errInvalidGrant,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			httpServer, s := newTestServer(ctx, t, func(c *Config) {
+				c.Now = func() time.Time { return now }
+			})
+			defer httpServer.Close()
+
+			if err := s.storage.CreateClient(storage.Client{ID: "device", Public: true}); err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+			tc.token.DeviceCode = storage.NewDeviceCode()
+			if tc.token.ClientID == "" {
+				tc.token.ClientID = "device"
+			}
+			if err := s.storage.CreateDeviceToken(tc.token); err != nil {
+				t.Fatalf("failed to create device token: %v", err)
+			}
+
+			poll := func() *httptest.ResponseRecorder {
+				form := url.Values{
+					"grant_type":  {grantTypeDeviceCode},
+					"client_id":   {"device"},
+					"device_code": {tc.token.DeviceCode},
+				}
+				req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+				rr := httptest.NewRecorder()
+				s.ServeHTTP(rr, req)
+				return rr
+			}
+			rr := poll()
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+
+			if tc.expectedError == "" {
+				if rr.Body.String() != tc.token.Token {
+					t.Errorf("expected stored token %q got %q", tc.token.Token, rr.Body.String())
+				}
+				// The token response is only handed out once.
+				if rr := poll(); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), errInvalidGrant) {
+					t.Errorf("expected redeemed device code to be rejected, got %d: %s", rr.Code, rr.Body.String())
+				}
+				return
+			}
+			var resp struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Error != tc.expectedError {
+				t.Errorf("expected error %q got %q", tc.expectedError, resp.Error)
+			}
+		})
+	}
+}
+
+func TestHandleDeviceCallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	client := storage.Client{ID: "device", Name: "Device", Public: true}
+	if err := s.storage.CreateClient(client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	expiry := s.now().Add(time.Minute)
+	deviceReq := storage.DeviceRequest{
+		UserCode:   storage.NewUserCode(),
+		DeviceCode: storage.NewDeviceCode(),
+		ClientID:   client.ID,
+		Scopes:     []string{"openid"},
+		Expiry:     expiry,
+	}
+	if err := s.storage.CreateDeviceRequest(deviceReq); err != nil {
+		t.Fatalf("failed to create device request: %v", err)
+	}
+	if err := s.storage.CreateDeviceToken(storage.DeviceToken{
+		DeviceCode:          deviceReq.DeviceCode,
+		Status:              deviceTokenPending,
+		Expiry:              expiry,
+		LastRequestTime:     s.now(),
+		PollIntervalSeconds: devicePollIntervalSeconds,
+	}); err != nil {
+		t.Fatalf("failed to create device token: %v", err)
+	}
+	authCode := storage.AuthCode{
+		ID:          storage.NewID(),
+		ClientID:    client.ID,
+		ConnectorID: "mock",
+		RedirectURI: s.absURL(deviceCallbackURI),
+		Scopes:      []string{"openid"},
+		Expiry:      expiry,
+	}
+	if err := s.storage.CreateAuthCode(authCode); err != nil {
+		t.Fatalf("failed to create auth code: %v", err)
+	}
+
+	v := url.Values{"state": {deviceReq.UserCode}, "code": {authCode.ID}}
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", deviceCallbackURI+"?"+v.Encode(), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	deviceToken, err := s.storage.GetDeviceToken(deviceReq.DeviceCode)
+	if err != nil {
+		t.Fatalf("failed to get device token: %v", err)
+	}
+	if deviceToken.Status != deviceTokenComplete {
+		t.Errorf("expected status %q got %q", deviceTokenComplete, deviceToken.Status)
+	}
+	var resp accessTokenResponse
+	if err := json.Unmarshal([]byte(deviceToken.Token), &resp); err != nil {
+		t.Fatalf("failed to decode stored token: %v", err)
+	}
+	if resp.IDToken == "" || resp.AccessToken == "" {
+		t.Errorf("expected stored token to contain an ID and access token, got %q", deviceToken.Token)
+	}
+
+	// The auth code has been redeemed so replaying the callback must fail.
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", deviceCallbackURI+"?"+v.Encode(), nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 on replay got %d", rr.Code)
+	}
+}
+
+func TestNormalizeUserCode(t *testing.T) {
+	tests := map[string]string{
+		"BCDF-GHJK":   "BCDF-GHJK",
+		" bcdf-ghjk ": "BCDF-GHJK",
+		"bcdfghjk":    "BCDF-GHJK",
+		"":            "",
+	}
+	for in, want := range tests {
+		if got := normalizeUserCode(in); got != want {
+			t.Errorf("normalizeUserCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
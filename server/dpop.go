@@ -0,0 +1,205 @@
+package server
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+const (
+	// dpopHeader is the HTTP header clients send their DPoP proofs in.
+	dpopHeader = "DPoP"
+
+	// dpopProofType is the "typ" header of DPoP proofs.
+	dpopProofType = "dpop+jwt"
+
+	// tokenTypeDPoP is the token type, and authorization scheme, of DPoP bound
+	// access tokens.
+	tokenTypeDPoP = "DPoP"
+
+	// DPoP proofs are created for a single request, so they're only accepted
+	// shortly before or after they have been issued.
+	dpopProofsValidFor = time.Minute
+)
+
+type dpopProofClaims struct {
+	JWTID           string      `json:"jti"`
+	HTTPMethod      string      `json:"htm"`
+	HTTPURI         string      `json:"htu"`
+	IssuedAt        json.Number `json:"iat"`
+	AccessTokenHash string      `json:"ath"`
+}
+
+// verifyDPoPProof validates the DPoP proof of the request and returns the JWK
+// SHA-256 thumbprint of the key it was signed with. The thumbprint is empty if
+// the request has no proof. htu is the URI the proof must have been created for,
+// and accessToken, if set, the access token it must be bound to.
+//
+// https://tools.ietf.org/html/rfc9449#section-4.3
+func (s *Server) verifyDPoPProof(r *http.Request, htu, accessToken string) (string, error) {
+	proofs := r.Header.Values(dpopHeader)
+	switch len(proofs) {
+	case 0:
+		return "", nil
+	case 1:
+	default:
+		return "", errors.New("more than one DPoP proof")
+	}
+
+	jws, err := jose.ParseSigned(proofs[0])
+	if err != nil {
+		return "", fmt.Errorf("malformed DPoP proof: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return "", errors.New("DPoP proof must have exactly one signature")
+	}
+	header := jws.Signatures[0].Protected
+	if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != dpopProofType {
+		return "", fmt.Errorf("unexpected DPoP proof type %q", typ)
+	}
+	if !contains(requestObjectAlgs, header.Algorithm) {
+		return "", fmt.Errorf("unsupported DPoP proof signing algorithm %q", header.Algorithm)
+	}
+	key := header.JSONWebKey
+	if key == nil || !key.IsPublic() {
+		return "", errors.New("DPoP proof must contain a public key")
+	}
+	payload, err := jws.Verify(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify DPoP proof signature: %v", err)
+	}
+
+	var claims dpopProofClaims
+	d := json.NewDecoder(strings.NewReader(string(payload)))
+	d.UseNumber()
+	if err := d.Decode(&claims); err != nil {
+		return "", fmt.Errorf("malformed DPoP proof claims: %v", err)
+	}
+	if claims.JWTID == "" {
+		return "", errors.New("missing jti claim")
+	}
+	if claims.HTTPMethod != r.Method {
+		return "", fmt.Errorf("DPoP proof created for method %q", claims.HTTPMethod)
+	}
+	// The query and fragment parts of the URI are ignored.
+	u, err := url.Parse(claims.HTTPURI)
+	if err != nil {
+		return "", fmt.Errorf("malformed htu claim: %v", err)
+	}
+	u.RawQuery, u.Fragment = "", ""
+	if u.String() != htu {
+		return "", fmt.Errorf("DPoP proof created for URI %q", claims.HTTPURI)
+	}
+	issuedAt, ok := numericDate(claims.IssuedAt)
+	if !ok {
+		return "", errors.New("missing iat claim")
+	}
+	now := s.now()
+	if now.Before(issuedAt.Add(-dpopProofsValidFor)) || now.After(issuedAt.Add(dpopProofsValidFor)) {
+		return "", errors.New("DPoP proof expired or issued in the future")
+	}
+	if accessToken != "" {
+		hash := sha256.Sum256([]byte(accessToken))
+		if claims.AccessTokenHash != base64.RawURLEncoding.EncodeToString(hash[:]) {
+			return "", errors.New("DPoP proof not bound to the access token")
+		}
+	}
+
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute key thumbprint: %v", err)
+	}
+	jkt := base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	// Proofs are recorded until they're too old to be accepted, so they can
+	// only be used once.
+	id := sha256.Sum256([]byte(jkt + "\x00" + claims.JWTID))
+	err = s.storage.CreateDPoPProof(storage.DPoPProof{
+		ID:     hex.EncodeToString(id[:]),
+		Expiry: issuedAt.Add(dpopProofsValidFor),
+	})
+	if err != nil {
+		if err == storage.ErrAlreadyExists {
+			return "", errors.New("DPoP proof replayed")
+		}
+		return "", fmt.Errorf("failed to store DPoP proof: %v", err)
+	}
+	return jkt, nil
+}
+
+// tokenConfirmation returns the key and client certificate the tokens issued
+// by a token request are bound to, which is the zero value for bearer tokens.
+// Clients requiring DPoP must send a proof.
+func (s *Server) tokenConfirmation(r *http.Request, client storage.Client) (storage.Confirmation, error) {
+	jkt, err := s.verifyDPoPProof(r, s.absURL("/token"), "")
+	if err != nil {
+		return storage.Confirmation{}, err
+	}
+	if jkt == "" && client.RequireDPoP {
+		return storage.Confirmation{}, errors.New("client requires a DPoP proof")
+	}
+	cnf := storage.Confirmation{JKT: jkt}
+	if cert := clientCertificate(r); s.tlsClientAuth && cert != nil {
+		cnf.X5TS256 = certificateThumbprint(cert)
+	}
+	return cnf, nil
+}
+
+// checkDPoPBinding verifies an access token presented to a protected resource
+// with the authorization scheme is used by its holder. Tokens bound to a key
+// must be presented with the DPoP scheme and a proof signed by that key. On
+// failure the error has already been written to w.
+//
+// https://tools.ietf.org/html/rfc9449#section-7.1
+func (s *Server) checkDPoPBinding(w http.ResponseWriter, r *http.Request, htu, scheme, accessToken string, cnf storage.Confirmation) bool {
+	if cnf.JKT == "" && scheme != tokenTypeDPoP {
+		return true
+	}
+	reject := func(typ, description string) bool {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`%s algs="%s", error="%s"`, tokenTypeDPoP, strings.Join(requestObjectAlgs, " "), typ))
+		s.tokenErrHelper(w, typ, description, http.StatusUnauthorized)
+		return false
+	}
+	if scheme != tokenTypeDPoP {
+		return reject(errInvalidToken, "DPoP bound access token presented as bearer token.")
+	}
+	jkt, err := s.verifyDPoPProof(r, htu, accessToken)
+	switch {
+	case err != nil:
+		return reject(errInvalidDPoPProof, err.Error())
+	case jkt == "":
+		return reject(errInvalidDPoPProof, "Missing DPoP proof.")
+	case jkt != cnf.JKT:
+		return reject(errInvalidToken, "Access token is not bound to the key of the DPoP proof.")
+	}
+	return true
+}
+
+// accessTokenType returns the token type of an access token bound to cnf.
+func accessTokenType(cnf storage.Confirmation) string {
+	if cnf.JKT != "" {
+		return tokenTypeDPoP
+	}
+	return "bearer"
+}
+
+// confirmationClaim returns the cnf claim of a token bound to cnf, or nil for
+// bearer tokens.
+func confirmationClaim(cnf storage.Confirmation) *storage.Confirmation {
+	if cnf == (storage.Confirmation{}) {
+		return nil
+	}
+	return &cnf
+}
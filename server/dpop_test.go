@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/server/internal"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestDPoP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{
+			{ID: "foo", Secret: "foo-secret"},
+			{ID: "strict", Secret: "strict-secret", RequireDPoP: true},
+		})
+	})
+	defer httpServer.Close()
+
+	newKey := func() *jose.JSONWebKey {
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		return &jose.JSONWebKey{Key: priv, Algorithm: string(jose.ES256)}
+	}
+	thumbprint := func(key *jose.JSONWebKey) string {
+		pub := key.Public()
+		b, err := pub.Thumbprint(crypto.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	key, otherKey := newKey(), newKey()
+
+	jti := 0
+	proof := func(key *jose.JSONWebKey, htm, htu, accessToken string) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key.Key}, &jose.SignerOptions{
+			EmbedJWK:     true,
+			ExtraHeaders: map[jose.HeaderKey]interface{}{jose.HeaderType: dpopProofType},
+		})
+		if err != nil {
+			t.Fatalf("failed to create signer: %v", err)
+		}
+		jti++
+		claims := map[string]interface{}{
+			"jti": strings.Repeat("x", jti),
+			"htm": htm,
+			"htu": htu,
+			"iat": time.Now().Unix(),
+		}
+		if accessToken != "" {
+			hash := sha256.Sum256([]byte(accessToken))
+			claims["ath"] = base64.RawURLEncoding.EncodeToString(hash[:])
+		}
+		payload, err := json.Marshal(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jws, err := signer.Sign(payload)
+		if err != nil {
+			t.Fatalf("failed to sign proof: %v", err)
+		}
+		p, err := jws.CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	tokenURL := s.absURL("/token")
+	requestToken := func(form url.Values, clientID, secret, dpop string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, secret)
+		if dpop != "" {
+			req.Header.Set(dpopHeader, dpop)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+	clientCredentials := url.Values{"grant_type": {grantTypeClientCredentials}}
+
+	replayed := proof(key, "POST", tokenURL, "")
+	rejected := []struct {
+		name     string
+		clientID string
+		secret   string
+		dpop     string
+	}{
+		{"wrong method", "foo", "foo-secret", proof(key, "GET", tokenURL, "")},
+		{"wrong uri", "foo", "foo-secret", proof(key, "POST", s.absURL("/userinfo"), "")},
+		{"malformed proof", "foo", "foo-secret", "not-a-jwt"},
+		{"missing proof", "strict", "strict-secret", ""},
+	}
+	for _, tc := range rejected {
+		rr := requestToken(clientCredentials, tc.clientID, tc.secret, tc.dpop)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), errInvalidDPoPProof) {
+			t.Errorf("%s: expected %q error, got %d: %s", tc.name, errInvalidDPoPProof, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := requestToken(clientCredentials, "foo", "foo-secret", replayed)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp accessTokenResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode token response: %v", err)
+	}
+	if resp.TokenType != tokenTypeDPoP {
+		t.Errorf("expected token type %q got %q", tokenTypeDPoP, resp.TokenType)
+	}
+	jws, err := jose.ParseSigned(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to parse access token: %v", err)
+	}
+	var claims accessTokenClaims
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+		t.Fatalf("failed to decode access token claims: %v", err)
+	}
+	if claims.Confirmation == nil || claims.Confirmation.JKT != thumbprint(key) {
+		t.Errorf("expected access token bound to %q, got %#v", thumbprint(key), claims.Confirmation)
+	}
+
+	if rr := requestToken(clientCredentials, "foo", "foo-secret", replayed); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected replayed proof to be rejected, got %d", rr.Code)
+	}
+
+	// Bound access tokens can only be used with a proof of the same key.
+	userInfo := func(scheme, dpop string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", scheme+" "+resp.AccessToken)
+		if dpop != "" {
+			req.Header.Set(dpopHeader, dpop)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+	userInfoURL := s.absURL("/userinfo")
+	userInfoTests := []struct {
+		name     string
+		scheme   string
+		dpop     string
+		wantCode int
+	}{
+		{"bearer scheme", "Bearer", "", http.StatusUnauthorized},
+		{"missing proof", tokenTypeDPoP, "", http.StatusUnauthorized},
+		{"proof without ath", tokenTypeDPoP, proof(key, "GET", userInfoURL, ""), http.StatusUnauthorized},
+		{"proof of other key", tokenTypeDPoP, proof(otherKey, "GET", userInfoURL, resp.AccessToken), http.StatusUnauthorized},
+		{"valid proof", tokenTypeDPoP, proof(key, "GET", userInfoURL, resp.AccessToken), http.StatusOK},
+	}
+	for _, tc := range userInfoTests {
+		rr := userInfo(tc.scheme, tc.dpop)
+		if rr.Code != tc.wantCode {
+			t.Errorf("userinfo %s: expected %d got %d: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+		}
+		if tc.wantCode == http.StatusUnauthorized && !strings.HasPrefix(rr.Header().Get("WWW-Authenticate"), tokenTypeDPoP) {
+			t.Errorf("userinfo %s: expected DPoP challenge, got %q", tc.name, rr.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// Bound refresh tokens can only be refreshed with a proof of the same key.
+	refresh := storage.RefreshToken{
+		ID:           storage.NewID(),
+		Token:        storage.NewID(),
+		ClientID:     "foo",
+		ConnectorID:  "mock",
+		Scopes:       []string{"openid", "offline_access"},
+		Claims:       storage.Claims{UserID: "user", Username: "jane"},
+		Confirmation: storage.Confirmation{JKT: thumbprint(key)},
+		CreatedAt:    time.Now(),
+		LastUsed:     time.Now(),
+	}
+	if err := s.storage.CreateRefresh(refresh); err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+	if err := s.storage.CreateOfflineSessions(storage.OfflineSessions{
+		UserID:  "user",
+		ConnID:  "mock",
+		Refresh: map[string]*storage.RefreshTokenRef{"foo": {ID: refresh.ID, ClientID: "foo"}},
+	}); err != nil {
+		t.Fatalf("failed to create offline session: %v", err)
+	}
+	refreshToken, err := internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: refresh.Token})
+	if err != nil {
+		t.Fatalf("failed to marshal refresh token: %v", err)
+	}
+	refreshForm := url.Values{"grant_type": {grantTypeRefreshToken}, "refresh_token": {refreshToken}}
+
+	if rr := requestToken(refreshForm, "foo", "foo-secret", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected refresh without proof to be rejected, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := requestToken(refreshForm, "foo", "foo-secret", proof(otherKey, "POST", tokenURL, "")); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected refresh with proof of other key to be rejected, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = requestToken(refreshForm, "foo", "foo-secret", proof(key, "POST", tokenURL, ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected refresh to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode token response: %v", err)
+	}
+	if resp.TokenType != tokenTypeDPoP {
+		t.Errorf("expected refreshed token type %q got %q", tokenTypeDPoP, resp.TokenType)
+	}
+}
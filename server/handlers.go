@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -153,29 +154,77 @@ type discovery struct {
 	Token         string   `json:"token_endpoint"`
 	Keys          string   `json:"jwks_uri"`
 	UserInfo      string   `json:"userinfo_endpoint"`
+	Revocation    string   `json:"revocation_endpoint"`
+	Introspection string   `json:"introspection_endpoint"`
+	EndSession    string   `json:"end_session_endpoint"`
+	Registration  string   `json:"registration_endpoint,omitempty"`
 	ResponseTypes []string `json:"response_types_supported"`
 	Subjects      []string `json:"subject_types_supported"`
 	IDTokenAlgs   []string `json:"id_token_signing_alg_values_supported"`
 	Scopes        []string `json:"scopes_supported"`
 	AuthMethods   []string `json:"token_endpoint_auth_methods_supported"`
+	AuthAlgs      []string `json:"token_endpoint_auth_signing_alg_values_supported"`
 	Claims        []string `json:"claims_supported"`
+
+	ClaimsParameter   bool     `json:"claims_parameter_supported"`
+	CodeChallengeAlgs []string `json:"code_challenge_methods_supported"`
+	DeviceEndpoint    string   `json:"device_authorization_endpoint"`
+	GrantTypes        []string `json:"grant_types_supported"`
+
+	RequestParameter    bool     `json:"request_parameter_supported"`
+	RequestURIParameter bool     `json:"request_uri_parameter_supported"`
+	RequireRequestURIs  bool     `json:"require_request_uri_registration"`
+	RequestObjectAlgs   []string `json:"request_object_signing_alg_values_supported"`
+
+	PushedAuthRequest         string `json:"pushed_authorization_request_endpoint"`
+	RequirePushedAuthRequests bool   `json:"require_pushed_authorization_requests"`
+
+	DPoPAlgs []string `json:"dpop_signing_alg_values_supported"`
+
+	TLSBoundAccessTokens bool `json:"tls_client_certificate_bound_access_tokens,omitempty"`
 }
 
-func (s *Server) discoveryHandler() (http.HandlerFunc, error) {
+func (s *Server) discoveryHandler() http.HandlerFunc {
 	d := discovery{
-		Issuer:      s.issuerURL.String(),
-		Auth:        s.absURL("/auth"),
-		Token:       s.absURL("/token"),
-		Keys:        s.absURL("/keys"),
-		UserInfo:    s.absURL("/userinfo"),
-		Subjects:    []string{"public"},
-		IDTokenAlgs: []string{string(jose.RS256)},
-		Scopes:      []string{"openid", "email", "groups", "profile", "offline_access"},
-		AuthMethods: []string{"client_secret_basic"},
+		Issuer:        s.issuerURL.String(),
+		Auth:          s.absURL("/auth"),
+		Token:         s.absURL("/token"),
+		Keys:          s.absURL("/keys"),
+		UserInfo:      s.absURL("/userinfo"),
+		Revocation:    s.absURL("/token/revoke"),
+		Introspection: s.absURL("/token/introspect"),
+		EndSession:    s.absURL("/logout"),
+		Subjects:      []string{"public"},
+		Scopes:        []string{"openid", "email", "groups", "profile", "offline_access"},
+		AuthMethods:   authMethods,
 		Claims: []string{
 			"aud", "email", "email_verified", "exp",
 			"iat", "iss", "locale", "name", "sub",
 		},
+		ClaimsParameter:   true,
+		CodeChallengeAlgs: []string{codeChallengeMethodS256, codeChallengeMethodPlain},
+		DeviceEndpoint:    s.absURL("/device/code"),
+		GrantTypes:        []string{grantTypeAuthorizationCode, grantTypeRefreshToken, grantTypeDeviceCode, grantTypeClientCredentials, grantTypeTokenExchange},
+
+		RequestParameter:    true,
+		RequestURIParameter: true,
+		RequireRequestURIs:  true,
+		RequestObjectAlgs:   requestObjectAlgs,
+
+		PushedAuthRequest: s.absURL("/par"),
+
+		DPoPAlgs: requestObjectAlgs,
+	}
+	d.AuthAlgs = append(append([]string(nil), clientSecretJWTAlgs...), requestObjectAlgs...)
+	if s.passwordConnector != "" {
+		d.GrantTypes = append(d.GrantTypes, grantTypePassword)
+	}
+	if s.registrationEnabled() {
+		d.Registration = s.absURL(registrationURI)
+	}
+	if s.tlsClientAuth {
+		d.AuthMethods = append(append([]string(nil), authMethods...), authMethodTLSClientAuth, authMethodSelfSignedTLSClientAuth)
+		d.TLSBoundAccessTokens = true
 	}
 
 	for responseType := range s.supportedResponseTypes {
@@ -183,16 +232,47 @@ func (s *Server) discoveryHandler() (http.HandlerFunc, error) {
 	}
 	sort.Strings(d.ResponseTypes)
 
-	data, err := json.MarshalIndent(d, "", "  ")
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal discovery data: %v", err)
+	var customScopes, customClaims []string
+	seen := make(map[string]bool)
+	for scope, claims := range s.customScopes {
+		customScopes = append(customScopes, scope)
+		for _, claim := range claims {
+			if !seen[claim] {
+				seen[claim] = true
+				customClaims = append(customClaims, claim)
+			}
+		}
 	}
+	sort.Strings(customScopes)
+	sort.Strings(customClaims)
+	d.Scopes = append(d.Scopes, customScopes...)
+	d.Claims = append(d.Claims, customClaims...)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The signing algorithms change as keys of a different type are
+		// rotated in.
+		keys, err := s.storage.GetKeys()
+		if err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get keys: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Internal server error.")
+			return
+		}
+		d := d
+		d.IDTokenAlgs = signingAlgorithms(keys)
+		if len(d.IDTokenAlgs) == 0 {
+			d.IDTokenAlgs = []string{string(jose.RS256)}
+		}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			s.logger.Errorf("failed to marshal discovery data: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Internal server error.")
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 		w.Write(data)
-	}), nil
+	}
 }
 
 // handleAuthorization handles the OAuth2 auth endpoint.
@@ -230,12 +310,42 @@ func (s *Server) handleAuthorization(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	connectors, err := s.storage.ListConnectors()
+	// Skip the connector login if the user has already logged in with dex.
+	if session, ok := s.reusableSession(r, *authReq); ok {
+		redirectURL, err := s.resumeSession(*authReq, session)
+		if err != nil {
+			s.logger.Errorf("Failed to resume session: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Login error.")
+			return
+		}
+		http.Redirect(w, r, redirectURL, http.StatusFound)
+		return
+	}
+
+	allConnectors, err := s.storage.ListConnectors()
 	if err != nil {
 		s.logger.Errorf("Failed to get list of connectors: %v", err)
 		s.renderError(r, w, http.StatusInternalServerError, "Failed to retrieve connector list.")
 		return
 	}
+	client, err := s.storage.GetClient(authReq.ClientID)
+	if err != nil {
+		s.logger.Errorf("Failed to get client: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Failed to retrieve client.")
+		return
+	}
+
+	// Only offer the connectors the client is allowed to use.
+	var connectors []storage.Connector
+	for _, c := range allConnectors {
+		if clientAllows(client.AllowedConnectors, c.ID) {
+			connectors = append(connectors, c)
+		}
+	}
+	if len(connectors) == 0 {
+		s.renderError(r, w, http.StatusBadRequest, "No connectors are allowed for this client.")
+		return
+	}
 
 	// Redirect if a client chooses a specific connector_id
 	if authReq.ConnectorID != "" {
@@ -297,6 +407,17 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	client, err := s.storage.GetClient(authReq.ClientID)
+	if err != nil {
+		s.logger.Errorf("Failed to get client: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	if !clientAllows(client.AllowedConnectors, connID) {
+		s.renderError(r, w, http.StatusBadRequest, "Connector not allowed for this client.")
+		return
+	}
+
 	// Set the connector being used for the login.
 	if authReq.ConnectorID != connID {
 		updater := func(a storage.AuthRequest) (storage.AuthRequest, error) {
@@ -381,7 +502,7 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
-		redirectURL, err := s.finalizeLogin(identity, authReq, conn.Connector)
+		redirectURL, err := s.finalizeLogin(w, identity, authReq, conn.Connector)
 		if err != nil {
 			s.logger.Errorf("Failed to finalize login: %v", err)
 			s.renderError(r, w, http.StatusInternalServerError, "Login error.")
@@ -464,7 +585,7 @@ func (s *Server) handleConnectorCallback(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	redirectURL, err := s.finalizeLogin(identity, authReq, conn.Connector)
+	redirectURL, err := s.finalizeLogin(w, identity, authReq, conn.Connector)
 	if err != nil {
 		s.logger.Errorf("Failed to finalize login: %v", err)
 		s.renderError(r, w, http.StatusInternalServerError, "Login error.")
@@ -474,9 +595,9 @@ func (s *Server) handleConnectorCallback(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
 }
 
-// finalizeLogin associates the user's identity with the current AuthRequest, then returns
-// the approval page's path.
-func (s *Server) finalizeLogin(identity connector.Identity, authReq storage.AuthRequest, conn connector.Connector) (string, error) {
+// finalizeLogin associates the user's identity with the current AuthRequest, starts a
+// browser session if enabled, then returns the approval page's path.
+func (s *Server) finalizeLogin(w http.ResponseWriter, identity connector.Identity, authReq storage.AuthRequest, conn connector.Connector) (string, error) {
 	claims := storage.Claims{
 		UserID:            identity.UserID,
 		Username:          identity.Username,
@@ -484,12 +605,14 @@ func (s *Server) finalizeLogin(identity connector.Identity, authReq storage.Auth
 		Email:             identity.Email,
 		EmailVerified:     identity.EmailVerified,
 		Groups:            identity.Groups,
+		CustomClaims:      identity.CustomClaims,
 	}
 
 	updater := func(a storage.AuthRequest) (storage.AuthRequest, error) {
 		a.LoggedIn = true
 		a.Claims = claims
 		a.ConnectorData = identity.ConnectorData
+		a.AuthTime = s.now()
 		return a, nil
 	}
 	if err := s.storage.UpdateAuthRequest(authReq.ID, updater); err != nil {
@@ -504,6 +627,13 @@ func (s *Server) finalizeLogin(identity connector.Identity, authReq storage.Auth
 	s.logger.Infof("login successful: connector %q, username=%q, preferred_username=%q, email=%q, groups=%q",
 		authReq.ConnectorID, claims.Username, claims.PreferredUsername, email, claims.Groups)
 
+	if s.sessionsEnabled() {
+		// Failing to start a session only means the user has to log in again next time.
+		if err := s.createSession(w, authReq, claims, identity.ConnectorData); err != nil {
+			s.logger.Errorf("Failed to create session: %v", err)
+		}
+	}
+
 	returnURL := path.Join(s.issuerURL.Path, "/approval") + "?req=" + authReq.ID
 	_, ok := conn.(connector.RefreshConnector)
 	if !ok {
@@ -603,6 +733,17 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 		return
 	}
 
+	if err := s.checkClaimsRequest(authReq.ClaimsRequest, authReq.Claims, authReq.ConnectorID); err != nil {
+		s.logger.Infof("claims request of client %q not satisfied: %v", authReq.ClientID, err)
+		err := &authErr{authReq.State, authReq.RedirectURI, errAccessDenied, "Requested claims can't be satisfied."}
+		if handler, ok := err.Handle(); ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		s.renderError(r, w, http.StatusBadRequest, "Requested claims can't be satisfied.")
+		return
+	}
+
 	var (
 		// Was the initial request using the implicit or hybrid flow instead of
 		// the "normal" code flow?
@@ -613,11 +754,11 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 
 		// ID token returned immediately if the response_type includes "id_token".
 		// Only valid for implicit and hybrid flows.
-		idToken       string
-		idTokenExpiry time.Time
+		idToken string
 
 		// Access token
-		accessToken string
+		accessToken       string
+		accessTokenExpiry time.Time
 	)
 
 	for _, responseType := range authReq.ResponseTypes {
@@ -633,6 +774,9 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 				Expiry:        s.now().Add(time.Minute * 30),
 				RedirectURI:   authReq.RedirectURI,
 				ConnectorData: authReq.ConnectorData,
+				PKCE:          authReq.PKCE,
+				ClaimsRequest: authReq.ClaimsRequest,
+				AuthTime:      authReq.AuthTime,
 			}
 			if err := s.storage.CreateAuthCode(code); err != nil {
 				s.logger.Errorf("Failed to create auth code: %v", err)
@@ -654,14 +798,14 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 			implicitOrHybrid = true
 			var err error
 
-			accessToken, err = s.newAccessToken(authReq.ClientID, authReq.Claims, authReq.Scopes, authReq.Nonce, authReq.ConnectorID)
+			accessToken, accessTokenExpiry, err = s.newAccessToken(authReq.ClientID, authReq.Claims, authReq.Scopes, authReq.ConnectorID, authReq.ClaimsRequest, storage.Confirmation{})
 			if err != nil {
 				s.logger.Errorf("failed to create new access token: %v", err)
 				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 				return
 			}
 
-			idToken, idTokenExpiry, err = s.newIDToken(authReq.ClientID, authReq.Claims, authReq.Scopes, authReq.Nonce, accessToken, authReq.ConnectorID)
+			idToken, _, err = s.newIDToken(authReq.ClientID, authReq.Claims, authReq.Scopes, authReq.Nonce, accessToken, authReq.ConnectorID, authReq.ClaimsRequest, authReq.AuthTime)
 			if err != nil {
 				s.logger.Errorf("failed to create ID token: %v", err)
 				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
@@ -683,7 +827,7 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 			//
 			// https://openid.net/specs/openid-connect-core-1_0.html#HybridAuthResponse
 			if code.ID == "" {
-				v.Set("expires_in", strconv.Itoa(int(idTokenExpiry.Sub(s.now()).Seconds())))
+				v.Set("expires_in", strconv.Itoa(int(accessTokenExpiry.Sub(s.now()).Seconds())))
 			}
 		}
 		if code.ID != "" {
@@ -718,21 +862,44 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 	http.Redirect(w, r, u.String(), http.StatusSeeOther)
 }
 
-func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+// clientCredentials returns the client ID and secret of a request, read from
+// the HTTP basic auth header or, if that isn't set, from the form body.
+func clientCredentials(r *http.Request) (clientID, clientSecret string, err error) {
 	clientID, clientSecret, ok := r.BasicAuth()
-	if ok {
-		var err error
-		if clientID, err = url.QueryUnescape(clientID); err != nil {
-			s.tokenErrHelper(w, errInvalidRequest, "client_id improperly encoded", http.StatusBadRequest)
-			return
+	if !ok {
+		return r.PostFormValue("client_id"), r.PostFormValue("client_secret"), nil
+	}
+	if clientID, err = url.QueryUnescape(clientID); err != nil {
+		return "", "", errors.New("client_id improperly encoded")
+	}
+	if clientSecret, err = url.QueryUnescape(clientSecret); err != nil {
+		return "", "", errors.New("client_secret improperly encoded")
+	}
+	return clientID, clientSecret, nil
+}
+
+// authenticateClient returns the client a request authenticates as. If
+// allowPublic is set, public clients may omit their secret. On failure the
+// token error has already been written to w.
+func (s *Server) authenticateClient(w http.ResponseWriter, r *http.Request, allowPublic bool) (storage.Client, bool) {
+	if assertionType := r.PostFormValue("client_assertion_type"); assertionType != "" {
+		client, method, err := s.verifyClientAssertion(r.Context(), r.PostFormValue("client_id"), assertionType, r.PostFormValue("client_assertion"))
+		if err != nil {
+			s.logger.Errorf("failed to verify client assertion: %v", err)
+			s.tokenErrHelper(w, errInvalidClient, "Invalid client assertion.", http.StatusUnauthorized)
+			return storage.Client{}, false
 		}
-		if clientSecret, err = url.QueryUnescape(clientSecret); err != nil {
-			s.tokenErrHelper(w, errInvalidRequest, "client_secret improperly encoded", http.StatusBadRequest)
-			return
+		if !clientAuthMethodAllowed(client, method) {
+			s.tokenErrHelper(w, errInvalidClient, fmt.Sprintf("Client can't authenticate with %q.", method), http.StatusUnauthorized)
+			return storage.Client{}, false
 		}
-	} else {
-		clientID = r.PostFormValue("client_id")
-		clientSecret = r.PostFormValue("client_secret")
+		return client, true
+	}
+
+	clientID, clientSecret, err := clientCredentials(r)
+	if err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, err.Error(), http.StatusBadRequest)
+		return storage.Client{}, false
 	}
 
 	client, err := s.storage.GetClient(clientID)
@@ -743,28 +910,92 @@ func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
 		} else {
 			s.tokenErrHelper(w, errInvalidClient, "Invalid client credentials.", http.StatusUnauthorized)
 		}
-		return
+		return storage.Client{}, false
+	}
+
+	method := authMethodClientSecretPost
+	if _, _, ok := r.BasicAuth(); ok {
+		method = authMethodClientSecretBasic
 	}
-	if client.Secret != clientSecret {
+	switch {
+	case allowPublic && client.Public && clientSecret == "":
+		method = authMethodNone
+	case s.tlsClientAuth && clientSecret == "" && clientCertificate(r) != nil:
+		if method, err = s.verifyClientCertificate(client, r); err != nil {
+			s.logger.Errorf("failed to verify client certificate: %v", err)
+			s.tokenErrHelper(w, errInvalidClient, "Invalid client certificate.", http.StatusUnauthorized)
+			return storage.Client{}, false
+		}
+	case s.verifyClientSecret(client, clientSecret):
+	default:
 		s.tokenErrHelper(w, errInvalidClient, "Invalid client credentials.", http.StatusUnauthorized)
+		return storage.Client{}, false
+	}
+	if !clientAuthMethodAllowed(client, method) {
+		s.tokenErrHelper(w, errInvalidClient, fmt.Sprintf("Client can't authenticate with %q.", method), http.StatusUnauthorized)
+		return storage.Client{}, false
+	}
+	return client, true
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	// Public clients exchanging a code with a PKCE code_verifier may omit the
+	// client secret. The verifier is checked against the code challenge in
+	// handleAuthCode.
+	//
+	// https://tools.ietf.org/html/rfc7636#section-4.5
+	isPKCE := r.PostFormValue("grant_type") == grantTypeAuthorizationCode && r.PostFormValue("code_verifier") != ""
+	// Devices polling for their tokens are public clients as well.
+	//
+	// https://tools.ietf.org/html/rfc8628#section-3.4
+	isDeviceCode := r.PostFormValue("grant_type") == grantTypeDeviceCode
+
+	client, ok := s.authenticateClient(w, r, isPKCE || isDeviceCode)
+	if !ok {
 		return
 	}
 
 	grantType := r.PostFormValue("grant_type")
+	if !clientAllows(client.AllowedGrantTypes, grantType) {
+		s.tokenErrHelper(w, errUnauthorizedClient, fmt.Sprintf("Client can't use grant type %q.", grantType), http.StatusBadRequest)
+		return
+	}
+
+	// Tokens are bound to the key of the DPoP proof sent with the request.
+	//
+	// https://tools.ietf.org/html/rfc9449#section-5
+	cnf, err := s.tokenConfirmation(r, client)
+	if err != nil {
+		s.tokenErrHelper(w, errInvalidDPoPProof, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	switch grantType {
 	case grantTypeAuthorizationCode:
-		s.handleAuthCode(w, r, client)
+		s.handleAuthCode(w, r, client, cnf)
 	case grantTypeRefreshToken:
-		s.handleRefreshToken(w, r, client)
+		s.handleRefreshToken(w, r, client, cnf)
 	case grantTypePassword:
-		s.handlePasswordGrant(w, r, client)
+		s.handlePasswordGrant(w, r, client, cnf)
+	case grantTypeDeviceCode:
+		// Device tokens are minted once the user approved the request, so
+		// they can't be bound to the key of the polling device.
+		if client.RequireDPoP {
+			s.tokenErrHelper(w, errUnauthorizedClient, "Clients requiring DPoP can't use the device code grant.", http.StatusBadRequest)
+			return
+		}
+		s.handleDeviceToken(w, r, client)
+	case grantTypeClientCredentials:
+		s.handleClientCredentialsGrant(w, r, client, cnf)
+	case grantTypeTokenExchange:
+		s.handleTokenExchange(w, r, client, cnf)
 	default:
 		s.tokenErrHelper(w, errInvalidGrant, "", http.StatusBadRequest)
 	}
 }
 
 // handle an access token request https://tools.ietf.org/html/rfc6749#section-4.1.3
-func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client storage.Client) {
+func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client storage.Client, cnf storage.Confirmation) {
 	code := r.PostFormValue("code")
 	redirectURI := r.PostFormValue("redirect_uri")
 
@@ -784,24 +1015,59 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 		return
 	}
 
-	accessToken, err := s.newAccessToken(client.ID, authCode.Claims, authCode.Scopes, authCode.Nonce, authCode.ConnectorID)
+	// RFC 7636 (PKCE)
+	codeChallengeFromStorage := authCode.PKCE.CodeChallenge
+	codeVerifier := r.PostFormValue("code_verifier")
+
+	switch {
+	case codeVerifier != "" && codeChallengeFromStorage != "":
+		calculatedCodeChallenge, err := calculateCodeChallenge(codeVerifier, authCode.PKCE.CodeChallengeMethod)
+		if err != nil {
+			s.logger.Errorf("failed to calculate code challenge: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(codeChallengeFromStorage), []byte(calculatedCodeChallenge)) != 1 {
+			s.tokenErrHelper(w, errInvalidGrant, "Invalid code_verifier.", http.StatusBadRequest)
+			return
+		}
+	case codeVerifier != "":
+		// Received no code_challenge on /auth, but a code_verifier on /token
+		s.tokenErrHelper(w, errInvalidRequest, "No PKCE flow started. Cannot check code_verifier.", http.StatusBadRequest)
+		return
+	case codeChallengeFromStorage != "":
+		// Received PKCE request on /auth, but no code_verifier on /token
+		s.tokenErrHelper(w, errInvalidGrant, "Expecting parameter code_verifier in PKCE flow.", http.StatusBadRequest)
+		return
+	}
+
+	tokenResponse, err := s.exchangeAuthCode(authCode, client, cnf)
 	if err != nil {
-		s.logger.Errorf("failed to create new access token: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		return
 	}
+	s.writeAccessToken(w, tokenResponse)
+}
 
-	idToken, expiry, err := s.newIDToken(client.ID, authCode.Claims, authCode.Scopes, authCode.Nonce, accessToken, authCode.ConnectorID)
+// exchangeAuthCode mints the tokens for a validated auth code and deletes the
+// code from storage. The access and refresh tokens are bound to cnf. Failures
+// are logged and are all server errors.
+func (s *Server) exchangeAuthCode(authCode storage.AuthCode, client storage.Client, cnf storage.Confirmation) (*accessTokenResponse, error) {
+	accessToken, expiry, err := s.newAccessToken(client.ID, authCode.Claims, authCode.Scopes, authCode.ConnectorID, authCode.ClaimsRequest, cnf)
+	if err != nil {
+		s.logger.Errorf("failed to create new access token: %v", err)
+		return nil, err
+	}
+
+	idToken, _, err := s.newIDToken(client.ID, authCode.Claims, authCode.Scopes, authCode.Nonce, accessToken, authCode.ConnectorID, authCode.ClaimsRequest, authCode.AuthTime)
 	if err != nil {
 		s.logger.Errorf("failed to create ID token: %v", err)
-		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
-	if err := s.storage.DeleteAuthCode(code); err != nil {
+	if err := s.storage.DeleteAuthCode(authCode.ID); err != nil {
 		s.logger.Errorf("failed to delete auth code: %v", err)
-		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
 	reqRefresh := func() bool {
@@ -811,7 +1077,6 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 		conn, err := s.getConnector(authCode.ConnectorID)
 		if err != nil {
 			s.logger.Errorf("connector with ID %q not found: %v", authCode.ConnectorID, err)
-			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 			return false
 		}
 
@@ -838,23 +1103,24 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 			Claims:        authCode.Claims,
 			Nonce:         authCode.Nonce,
 			ConnectorData: authCode.ConnectorData,
+			ClaimsRequest: authCode.ClaimsRequest,
+			Confirmation:  cnf,
 			CreatedAt:     s.now(),
 			LastUsed:      s.now(),
 		}
+		refresh.Expiry = s.refreshTokenExpiry(client, refresh.CreatedAt, refresh.LastUsed)
 		token := &internal.RefreshToken{
 			RefreshId: refresh.ID,
 			Token:     refresh.Token,
 		}
 		if refreshToken, err = internal.Marshal(token); err != nil {
 			s.logger.Errorf("failed to marshal refresh token: %v", err)
-			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 
 		if err := s.storage.CreateRefresh(refresh); err != nil {
 			s.logger.Errorf("failed to create refresh token: %v", err)
-			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 
 		// deleteToken determines if we need to delete the newly created refresh token
@@ -866,8 +1132,6 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 				// Delete newly created refresh token from storage.
 				if err := s.storage.DeleteRefresh(refresh.ID); err != nil {
 					s.logger.Errorf("failed to delete refresh token: %v", err)
-					s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
-					return
 				}
 			}
 		}()
@@ -883,9 +1147,8 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 		if session, err := s.storage.GetOfflineSessions(refresh.Claims.UserID, refresh.ConnectorID); err != nil {
 			if err != storage.ErrNotFound {
 				s.logger.Errorf("failed to get offline session: %v", err)
-				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 				deleteToken = true
-				return
+				return nil, err
 			}
 			offlineSessions := storage.OfflineSessions{
 				UserID:  refresh.Claims.UserID,
@@ -898,18 +1161,16 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 			// the newly received refreshtoken.
 			if err := s.storage.CreateOfflineSessions(offlineSessions); err != nil {
 				s.logger.Errorf("failed to create offline session: %v", err)
-				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 				deleteToken = true
-				return
+				return nil, err
 			}
 		} else {
 			if oldTokenRef, ok := session.Refresh[tokenRef.ClientID]; ok {
 				// Delete old refresh token from storage.
 				if err := s.storage.DeleteRefresh(oldTokenRef.ID); err != nil && err != storage.ErrNotFound {
 					s.logger.Errorf("failed to delete refresh token: %v", err)
-					s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 					deleteToken = true
-					return
+					return nil, err
 				}
 			}
 
@@ -919,17 +1180,113 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 				return old, nil
 			}); err != nil {
 				s.logger.Errorf("failed to update offline session: %v", err)
-				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 				deleteToken = true
-				return
+				return nil, err
 			}
 		}
 	}
-	s.writeAccessToken(w, idToken, accessToken, refreshToken, expiry)
+	return s.toAccessTokenResponse(idToken, accessToken, refreshToken, expiry, cnf), nil
+}
+
+// refreshTokenExpiry returns the time a refresh token of the client, created at
+// createdAt and last used at lastUsed, stops being valid. The zero time is
+// returned for tokens that never expire.
+func (s *Server) refreshTokenExpiry(client storage.Client, createdAt, lastUsed time.Time) time.Time {
+	validFor := s.clientDuration(client.ID, "refresh token lifetime", client.RefreshTokenValidFor, s.refreshTokensValidFor)
+	idleTimeout := s.clientDuration(client.ID, "refresh token idle timeout", client.RefreshTokenIdleTimeout, s.refreshTokenIdleTimeout)
+
+	var expiry time.Time
+	if validFor > 0 {
+		expiry = createdAt.Add(validFor)
+	}
+	if idleTimeout > 0 {
+		if idleExpiry := lastUsed.Add(idleTimeout); expiry.IsZero() || idleExpiry.Before(expiry) {
+			expiry = idleExpiry
+		}
+	}
+	return expiry
+}
+
+// refreshTokenExpired reports whether the refresh token reached its absolute
+// lifetime or idle timeout. The expiry stored with the token is authoritative,
+// so changing the configured lifetimes only affects tokens issued or refreshed
+// afterwards, the same way garbage collection sees them.
+func (s *Server) refreshTokenExpired(refresh storage.RefreshToken) bool {
+	return !refresh.Expiry.IsZero() && s.now().After(refresh.Expiry)
+}
+
+// tokensValidFor returns the lifetimes of ID and access tokens issued to the
+// client, which may override the server-wide ones.
+func (s *Server) tokensValidFor(clientID string) (idTokens, accessTokens time.Duration) {
+	idTokens, accessTokens = s.idTokensValidFor, s.accessTokensValidFor
+	client, err := s.storage.GetClient(clientID)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get client %s, using the default token lifetimes: %v", clientID, err)
+		}
+		return idTokens, accessTokens
+	}
+	// Tokens that are expired when issued are useless, so zero values keep the
+	// defaults as well.
+	if d := s.clientDuration(client.ID, "ID token lifetime", client.IDTokenValidFor, idTokens); d > 0 {
+		idTokens = d
+	}
+	if d := s.clientDuration(client.ID, "access token lifetime", client.AccessTokenValidFor, accessTokens); d > 0 {
+		accessTokens = d
+	}
+	return idTokens, accessTokens
+}
+
+// clientDuration returns the client's override of a server-wide duration, or
+// def if the client doesn't set one.
+func (s *Server) clientDuration(clientID, name, value string, def time.Duration) time.Duration {
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		s.logger.Errorf("client %s has an invalid %s %q, using the default: %v", clientID, name, value, err)
+		return def
+	}
+	return d
+}
+
+// maxRotatedRefreshTokens bounds the previous values kept per refresh token to
+// detect their reuse. Older values are rejected without being recognized.
+const maxRotatedRefreshTokens = 100
+
+// errRefreshTokenClaimed is returned by the refresh token updater when another
+// request refreshed the token concurrently.
+var errRefreshTokenClaimed = errors.New("refresh token claimed twice")
+
+// refreshTokenRotated reports whether token is a previous value of the refresh
+// token.
+func refreshTokenRotated(refresh storage.RefreshToken, token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, rotated := range append([]string{refresh.ObsoleteToken}, refresh.RotatedTokens...) {
+		if subtle.ConstantTimeCompare([]byte(rotated), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshTokenReuseAllowed reports whether the previous value of a refresh token
+// is presented within the reuse interval following its rotation.
+func (s *Server) refreshTokenReuseAllowed(refresh storage.RefreshToken, token string) bool {
+	if s.refreshTokenReuseInterval <= 0 || refresh.ObsoleteToken == "" {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(refresh.ObsoleteToken), []byte(token)) != 1 {
+		return false
+	}
+	return !s.now().After(refresh.LastUsed.Add(s.refreshTokenReuseInterval))
 }
 
 // handle a refresh token request https://tools.ietf.org/html/rfc6749#section-6
-func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, client storage.Client) {
+func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, client storage.Client, cnf storage.Confirmation) {
 	code := r.PostFormValue("refresh_token")
 	scope := r.PostFormValue("scope")
 	if code == "" {
@@ -963,9 +1320,48 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		s.tokenErrHelper(w, errInvalidRequest, "Refresh token is invalid or has already been claimed by another client.", http.StatusBadRequest)
 		return
 	}
+	// Refresh tokens bound to a key can only be used with a proof of the same key.
+	//
+	// https://tools.ietf.org/html/rfc9449#section-5
+	if refresh.Confirmation.JKT != "" && refresh.Confirmation.JKT != cnf.JKT {
+		s.tokenErrHelper(w, errInvalidDPoPProof, "Refresh token is bound to the key of another DPoP proof.", http.StatusBadRequest)
+		return
+	}
+	// As are refresh tokens bound to a client certificate.
+	//
+	// https://tools.ietf.org/html/rfc8705#section-4
+	if refresh.Confirmation.X5TS256 != "" && refresh.Confirmation.X5TS256 != cnf.X5TS256 {
+		s.tokenErrHelper(w, errInvalidGrant, "Refresh token is bound to another client certificate.", http.StatusBadRequest)
+		return
+	}
+
+	// A refresh token identifies a token family: its ID stays the same while its
+	// value is rotated by every refresh. Presenting a rotated value means the token
+	// leaked, so the token family is revoked, unless the client merely sent
+	// concurrent refresh requests within the reuse interval. The refresh tokens of
+	// other clients are left alone. Values which were never issued are only rejected.
+	reused := false
 	if refresh.Token != token.Token {
-		s.logger.Errorf("refresh token with id %s claimed twice", refresh.ID)
-		s.tokenErrHelper(w, errInvalidRequest, "Refresh token is invalid or has already been claimed by another client.", http.StatusBadRequest)
+		switch {
+		case s.refreshTokenReuseAllowed(refresh, token.Token):
+			reused = true
+		case refreshTokenRotated(refresh, token.Token):
+			s.securityEvent(securityEventRefreshTokenReuse, "refresh token with id %s of user %s claimed twice by client %s, revoking it",
+				refresh.ID, refresh.Claims.UserID, client.ID)
+			if err := s.deleteRefreshToken(refresh); err != nil {
+				s.logger.Errorf("failed to revoke refresh token: %v", err)
+			}
+			s.tokenErrHelper(w, errInvalidGrant, "Refresh token is invalid or has already been claimed by another client.", http.StatusBadRequest)
+			return
+		default:
+			s.logger.Errorf("client %s presented an unknown value of refresh token with id %s", client.ID, refresh.ID)
+			s.tokenErrHelper(w, errInvalidGrant, "Refresh token is invalid or has already been claimed by another client.", http.StatusBadRequest)
+			return
+		}
+	}
+	if s.refreshTokenExpired(refresh) {
+		s.logger.Infof("refresh token with id %s expired at %v", refresh.ID, refresh.Expiry)
+		s.tokenErrHelper(w, errInvalidGrant, "Refresh token expired.", http.StatusBadRequest)
 		return
 	}
 
@@ -1026,6 +1422,7 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		Email:             refresh.Claims.Email,
 		EmailVerified:     refresh.Claims.EmailVerified,
 		Groups:            refresh.Claims.Groups,
+		CustomClaims:      refresh.Claims.CustomClaims,
 		ConnectorData:     connectorData,
 	}
 
@@ -1051,16 +1448,17 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		Email:             ident.Email,
 		EmailVerified:     ident.EmailVerified,
 		Groups:            ident.Groups,
+		CustomClaims:      ident.CustomClaims,
 	}
 
-	accessToken, err := s.newAccessToken(client.ID, claims, scopes, refresh.Nonce, refresh.ConnectorID)
+	accessToken, expiry, err := s.newAccessToken(client.ID, claims, scopes, refresh.ConnectorID, refresh.ClaimsRequest, cnf)
 	if err != nil {
 		s.logger.Errorf("failed to create new access token: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		return
 	}
 
-	idToken, expiry, err := s.newIDToken(client.ID, claims, scopes, refresh.Nonce, accessToken, refresh.ConnectorID)
+	idToken, _, err := s.newIDToken(client.ID, claims, scopes, refresh.Nonce, accessToken, refresh.ConnectorID, refresh.ClaimsRequest, time.Time{})
 	if err != nil {
 		s.logger.Errorf("failed to create ID token: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
@@ -1071,6 +1469,11 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		RefreshId: refresh.ID,
 		Token:     storage.NewID(),
 	}
+	if reused {
+		// Hand out the current value again rather than rotating the token, so
+		// all concurrent requests end up with the same refresh token.
+		newToken.Token = refresh.Token
+	}
 	rawNewToken, err := internal.Marshal(newToken)
 	if err != nil {
 		s.logger.Errorf("failed to marshal refresh token: %v", err)
@@ -1081,9 +1484,20 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 	lastUsed := s.now()
 	updater := func(old storage.RefreshToken) (storage.RefreshToken, error) {
 		if old.Token != refresh.Token {
-			return old, errors.New("refresh token claimed twice")
+			return old, errRefreshTokenClaimed
+		}
+		if !reused {
+			if old.ObsoleteToken != "" {
+				old.RotatedTokens = append(old.RotatedTokens, old.ObsoleteToken)
+				if n := len(old.RotatedTokens); n > maxRotatedRefreshTokens {
+					old.RotatedTokens = old.RotatedTokens[n-maxRotatedRefreshTokens:]
+				}
+			}
+			old.ObsoleteToken = old.Token
+			old.Token = newToken.Token
+			old.LastUsed = lastUsed
+			old.Expiry = s.refreshTokenExpiry(client, old.CreatedAt, lastUsed)
 		}
-		old.Token = newToken.Token
 		// Update the claims of the refresh token.
 		//
 		// UserID intentionally ignored for now.
@@ -1092,13 +1506,25 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		old.Claims.Email = ident.Email
 		old.Claims.EmailVerified = ident.EmailVerified
 		old.Claims.Groups = ident.Groups
-		old.LastUsed = lastUsed
+		old.Claims.CustomClaims = ident.CustomClaims
 
 		// ConnectorData has been moved to OfflineSession
 		old.ConnectorData = []byte{}
 		return old, nil
 	}
 
+	// Update refresh token in the storage. A concurrent request may have
+	// refreshed it since it was read, in which case this one lost the race.
+	if err := s.storage.UpdateRefreshToken(refresh.ID, updater); err != nil {
+		if err == errRefreshTokenClaimed {
+			s.tokenErrHelper(w, errInvalidGrant, "Refresh token is invalid or has already been claimed by another client.", http.StatusBadRequest)
+			return
+		}
+		s.logger.Errorf("failed to update refresh token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
 	// Update LastUsed time stamp in refresh token reference object
 	// in offline session for the user.
 	if err := s.storage.UpdateOfflineSessions(refresh.Claims.UserID, refresh.ConnectorID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
@@ -1114,45 +1540,454 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		return
 	}
 
-	// Update refresh token in the storage.
-	if err := s.storage.UpdateRefreshToken(refresh.ID, updater); err != nil {
-		s.logger.Errorf("failed to update refresh token: %v", err)
+	resp := s.toAccessTokenResponse(idToken, accessToken, rawNewToken, expiry, cnf)
+	s.writeAccessToken(w, resp)
+}
+
+// handleRevokeToken handles a token revocation request.
+// See: https://tools.ietf.org/html/rfc7009#section-2
+func (s *Server) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.tokenErrHelper(w, errInvalidRequest, "Invalid revocation request type.", http.StatusBadRequest)
+		return
+	}
+
+	// Public clients can't keep a secret and only identify themselves.
+	client, ok := s.authenticateClient(w, r, true)
+	if !ok {
+		return
+	}
+
+	code := r.PostFormValue("token")
+	if code == "" {
+		s.tokenErrHelper(w, errInvalidRequest, "No token in request.", http.StatusBadRequest)
+		return
+	}
+
+	token := new(internal.RefreshToken)
+	if err := internal.Unmarshal(code, token); err != nil {
+		// Same backward compatibility as handleRefreshToken, see there.
+		token = &internal.RefreshToken{RefreshId: code, Token: ""}
+	}
+
+	refresh, err := s.storage.GetRefresh(token.RefreshId)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get refresh token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		// Opaque access tokens are stored and can be revoked.
+		accessToken, err := s.storage.GetAccessToken(code)
+		if err == nil {
+			if accessToken.ClientID != client.ID {
+				s.logger.Errorf("client %s trying to revoke token for client %s", client.ID, accessToken.ClientID)
+				s.tokenErrHelper(w, errInvalidRequest, "Token was not issued to this client.", http.StatusBadRequest)
+				return
+			}
+			if err := s.storage.DeleteAccessToken(accessToken.ID); err != nil && err != storage.ErrNotFound {
+				s.logger.Errorf("failed to revoke access token: %v", err)
+				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("failed to get access token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		// JWT access tokens are self-contained and stay valid until they expire.
+		if _, err := jose.ParseSigned(code); err == nil && r.PostFormValue("token_type_hint") == "access_token" {
+			s.tokenErrHelper(w, errUnsupportedTokenType, "JWT access tokens can't be revoked.", http.StatusBadRequest)
+			return
+		}
+		// Invalid tokens don't cause an error response, the client can't do
+		// anything about them anyway.
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if refresh.Token != token.Token {
+		// The token has already been rotated by a refresh and is no longer valid.
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if refresh.ClientID != client.ID {
+		s.logger.Errorf("client %s trying to revoke token for client %s", client.ID, refresh.ClientID)
+		s.tokenErrHelper(w, errInvalidRequest, "Token was not issued to this client.", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.deleteRefreshToken(refresh); err != nil {
+		s.logger.Errorf("failed to revoke refresh token: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		return
 	}
 
-	s.writeAccessToken(w, idToken, accessToken, rawNewToken, expiry)
+	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
-	const prefix = "Bearer "
+// deleteRefreshToken deletes a refresh token and removes the reference to it
+// from the user's offline sessions.
+func (s *Server) deleteRefreshToken(refresh storage.RefreshToken) error {
+	updater := func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+		if ref, ok := old.Refresh[refresh.ClientID]; ok && ref.ID == refresh.ID {
+			delete(old.Refresh, refresh.ClientID)
+		}
+		return old, nil
+	}
+	if err := s.storage.UpdateOfflineSessions(refresh.Claims.UserID, refresh.ConnectorID, updater); err != nil && err != storage.ErrNotFound {
+		return fmt.Errorf("failed to update offline session: %v", err)
+	}
+
+	if err := s.storage.DeleteRefresh(refresh.ID); err != nil && err != storage.ErrNotFound {
+		return fmt.Errorf("failed to delete refresh token: %v", err)
+	}
+	return nil
+}
+
+// deleteOfflineSessions deletes an offline session and the refresh tokens it
+// references.
+func (s *Server) deleteOfflineSessions(session storage.OfflineSessions) error {
+	for _, ref := range session.Refresh {
+		if err := s.storage.DeleteRefresh(ref.ID); err != nil && err != storage.ErrNotFound {
+			return fmt.Errorf("failed to delete refresh token: %v", err)
+		}
+	}
+	if err := s.storage.DeleteOfflineSessions(session.UserID, session.ConnID); err != nil && err != storage.ErrNotFound {
+		return fmt.Errorf("failed to delete offline session: %v", err)
+	}
+	return nil
+}
+
+type introspectionResponse struct {
+	Active    bool     `json:"active"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  audience `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	Expiry    int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	JWTID     string   `json:"jti,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+
+	Confirmation *storage.Confirmation `json:"cnf,omitempty"`
+}
+
+// handleIntrospectToken handles a token introspection request.
+// See: https://tools.ietf.org/html/rfc7662#section-2
+func (s *Server) handleIntrospectToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.tokenErrHelper(w, errInvalidRequest, "Invalid introspection request type.", http.StatusBadRequest)
+		return
+	}
+
+	// Introspection reveals information about tokens, so only confidential
+	// clients may use it.
+	client, ok := s.authenticateClient(w, r, false)
+	if !ok {
+		return
+	}
+	if client.Public {
+		s.tokenErrHelper(w, errInvalidClient, "Public clients can't introspect tokens.", http.StatusUnauthorized)
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		s.tokenErrHelper(w, errInvalidRequest, "No token in request.", http.StatusBadRequest)
+		return
+	}
+
+	var (
+		resp  *introspectionResponse
+		found bool
+		err   error
+	)
+	// ID tokens and JWT access tokens are signed, opaque access tokens and
+	// refresh tokens are looked up in storage.
+	if _, jwsErr := jose.ParseSigned(token); jwsErr == nil {
+		resp, err = s.introspectJWT(token)
+	} else if resp, found, err = s.introspectAccessToken(token); err == nil && !found {
+		resp, err = s.introspectRefreshToken(token)
+	}
+	if err != nil {
+		s.logger.Errorf("failed to introspect token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Errorf("failed to marshal introspection response: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// introspectJWT verifies a token signed by dex. Tokens with an invalid
+// signature, issuer or expiry are reported as inactive.
+func (s *Server) introspectJWT(token string) (*introspectionResponse, error) {
+	// ID tokens are signed with the same keys, but aren't access tokens.
+	if jws, err := jose.ParseSigned(token); err != nil || !isAccessTokenJWT(jws) {
+		return &introspectionResponse{Active: false}, nil
+	}
+	keySet := &storageKeySet{s.storage}
+	payload, err := keySet.VerifySignature(context.Background(), token)
+	if err != nil {
+		return &introspectionResponse{Active: false}, nil
+	}
+
+	var claims idTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return &introspectionResponse{Active: false}, nil
+	}
+	if claims.Issuer != s.issuerURL.String() || s.now().Unix() >= claims.Expiry {
+		return &introspectionResponse{Active: false}, nil
+	}
+
+	clientID := claims.AuthorizingParty
+	if clientID == "" && len(claims.Audience) == 1 {
+		clientID = claims.Audience[0]
+	}
+	// Access tokens name their client and scopes explicitly.
+	var accessClaims accessTokenClaims
+	if err := json.Unmarshal(payload, &accessClaims); err == nil && accessClaims.ClientID != "" {
+		clientID = accessClaims.ClientID
+	}
+	var cnf storage.Confirmation
+	if accessClaims.Confirmation != nil {
+		cnf = *accessClaims.Confirmation
+	}
+	return &introspectionResponse{
+		Active:       true,
+		ClientID:     clientID,
+		Subject:      claims.Subject,
+		Audience:     claims.Audience,
+		Issuer:       claims.Issuer,
+		Scope:        accessClaims.Scope,
+		Expiry:       claims.Expiry,
+		IssuedAt:     claims.IssuedAt,
+		JWTID:        accessClaims.JWTID,
+		TokenType:    introspectionTokenType(cnf),
+		Username:     claims.PreferredUsername,
+		Groups:       claims.Groups,
+		Confirmation: confirmationClaim(cnf),
+	}, nil
+}
+
+// introspectAccessToken looks up an opaque access token in storage. ok is
+// false if no access token with this value exists.
+func (s *Server) introspectAccessToken(token string) (resp *introspectionResponse, ok bool, err error) {
+	t, err := s.storage.GetAccessToken(token)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get access token: %v", err)
+	}
+	if !s.now().Before(t.Expiry) {
+		return &introspectionResponse{Active: false}, true, nil
+	}
+	return &introspectionResponse{
+		Active:       true,
+		ClientID:     t.ClientID,
+		Subject:      t.Subject,
+		Audience:     t.Audience,
+		Issuer:       s.issuerURL.String(),
+		Scope:        strings.Join(t.Scopes, " "),
+		Expiry:       t.Expiry.Unix(),
+		IssuedAt:     t.CreatedAt.Unix(),
+		TokenType:    introspectionTokenType(t.Confirmation),
+		Username:     t.Claims.Username,
+		Groups:       t.Claims.Groups,
+		Confirmation: confirmationClaim(t.Confirmation),
+	}, true, nil
+}
+
+// introspectionTokenType returns the token_type of an introspected access token
+// bound to cnf.
+func introspectionTokenType(cnf storage.Confirmation) string {
+	if cnf.JKT != "" {
+		return tokenTypeDPoP
+	}
+	return "Bearer"
+}
+
+// introspectRefreshToken looks up a refresh token in storage. Unknown and
+// already rotated refresh tokens are reported as inactive.
+func (s *Server) introspectRefreshToken(code string) (*introspectionResponse, error) {
+	token := new(internal.RefreshToken)
+	if err := internal.Unmarshal(code, token); err != nil {
+		// Same backward compatibility as handleRefreshToken, see there.
+		token = &internal.RefreshToken{RefreshId: code, Token: ""}
+	}
+
+	refresh, err := s.storage.GetRefresh(token.RefreshId)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return &introspectionResponse{Active: false}, nil
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %v", err)
+	}
+	if refresh.Token != token.Token || s.refreshTokenExpired(refresh) {
+		return &introspectionResponse{Active: false}, nil
+	}
+
+	subject, err := internal.Marshal(&internal.IDTokenSubject{
+		UserId: refresh.Claims.UserID,
+		ConnId: refresh.ConnectorID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal subject: %v", err)
+	}
+	resp := &introspectionResponse{
+		Active:   true,
+		ClientID: refresh.ClientID,
+		Subject:  subject,
+		Audience: audience{refresh.ClientID},
+		Issuer:   s.issuerURL.String(),
+		Scope:    strings.Join(refresh.Scopes, " "),
+		IssuedAt: refresh.CreatedAt.Unix(),
+		Username: refresh.Claims.Username,
+		Groups:   refresh.Claims.Groups,
+
+		Confirmation: confirmationClaim(refresh.Confirmation),
+	}
+	if !refresh.Expiry.IsZero() {
+		resp.Expiry = refresh.Expiry.Unix()
+	}
+	return resp, nil
+}
 
+func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	// DPoP bound access tokens are presented with their own scheme.
+	var scheme, rawToken string
 	auth := r.Header.Get("authorization")
-	if len(auth) < len(prefix) || !strings.EqualFold(prefix, auth[:len(prefix)]) {
+	for _, prefix := range []string{"Bearer ", tokenTypeDPoP + " "} {
+		if len(auth) >= len(prefix) && strings.EqualFold(prefix, auth[:len(prefix)]) {
+			scheme, rawToken = prefix[:len(prefix)-1], auth[len(prefix):]
+		}
+	}
+	if scheme == "" {
 		w.Header().Set("WWW-Authenticate", "Bearer")
 		s.tokenErrHelper(w, errAccessDenied, "Invalid bearer token.", http.StatusUnauthorized)
 		return
 	}
-	rawIDToken := auth[len(prefix):]
 
-	verifier := oidc.NewVerifier(s.issuerURL.String(), &storageKeySet{s.storage}, &oidc.Config{SkipClientIDCheck: true})
-	idToken, err := verifier.Verify(r.Context(), rawIDToken)
-	if err != nil {
-		s.tokenErrHelper(w, errAccessDenied, err.Error(), http.StatusForbidden)
+	var (
+		info   userInfo
+		custom map[string]interface{}
+		cnf    storage.Confirmation
+	)
+	if jws, err := jose.ParseSigned(rawToken); err == nil {
+		// ID tokens are signed with the same keys, but aren't access tokens.
+		if !isAccessTokenJWT(jws) {
+			s.tokenErrHelper(w, errAccessDenied, "Invalid access token.", http.StatusForbidden)
+			return
+		}
+		keys, err := s.storage.GetKeys()
+		if err != nil {
+			s.logger.Errorf("failed to get keys: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		verifier := oidc.NewVerifier(s.issuerURL.String(), &storageKeySet{s.storage}, &oidc.Config{
+			SkipClientIDCheck:    true,
+			SupportedSigningAlgs: signingAlgorithms(keys),
+		})
+		token, err := verifier.Verify(r.Context(), rawToken)
+		if err != nil {
+			s.tokenErrHelper(w, errAccessDenied, err.Error(), http.StatusForbidden)
+			return
+		}
+		var (
+			raw   map[string]interface{}
+			bound struct {
+				Confirmation storage.Confirmation `json:"cnf"`
+			}
+		)
+		if err := token.Claims(&info); err != nil {
+			s.tokenErrHelper(w, errServerError, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := token.Claims(&raw); err != nil {
+			s.tokenErrHelper(w, errServerError, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := token.Claims(&bound); err != nil {
+			s.tokenErrHelper(w, errServerError, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cnf = bound.Confirmation
+		// Custom claims granted by scopes or requested through the claims
+		// request are embedded in access tokens.
+		for name, value := range raw {
+			if s.isCustomClaim(name) {
+				if custom == nil {
+					custom = make(map[string]interface{})
+				}
+				custom[name] = value
+			}
+		}
+	} else {
+		t, err := s.storage.GetAccessToken(rawToken)
+		if err != nil {
+			if err != storage.ErrNotFound {
+				s.logger.Errorf("failed to get access token: %v", err)
+				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+				return
+			}
+			s.tokenErrHelper(w, errAccessDenied, "Invalid access token.", http.StatusForbidden)
+			return
+		}
+		if !s.now().Before(t.Expiry) {
+			s.tokenErrHelper(w, errAccessDenied, "Access token has expired.", http.StatusForbidden)
+			return
+		}
+		cnf = t.Confirmation
+		info = userInfo{
+			Subject:        t.Subject,
+			identityClaims: newIdentityClaims(t.Claims, t.Scopes, t.ConnectorID),
+		}
+		if custom, err = s.extraClaims(t.ClientID, t.Claims, t.Scopes, t.ConnectorID, t.ClaimsRequest, claimsRequestUserInfo); err != nil {
+			s.logger.Errorf("failed to get userinfo claims: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !s.checkDPoPBinding(w, r, s.absURL("/userinfo"), scheme, rawToken, cnf) {
 		return
 	}
-
-	var claims json.RawMessage
-	if err := idToken.Claims(&claims); err != nil {
-		s.tokenErrHelper(w, errServerError, err.Error(), http.StatusInternalServerError)
+	if !s.checkCertificateBinding(w, r, cnf) {
 		return
 	}
 
+	claims, err := marshalClaims(info, custom)
+	if err != nil {
+		s.logger.Errorf("failed to marshal userinfo: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(claims)
 }
 
-func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, client storage.Client) {
+// userInfo holds the claims about the user returned by the userinfo endpoint.
+type userInfo struct {
+	Subject string `json:"sub"`
+
+	identityClaims
+}
+
+func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, client storage.Client, cnf storage.Confirmation) {
 	// Parse the fields
 	if err := r.ParseForm(); err != nil {
 		s.tokenErrHelper(w, errInvalidRequest, "Couldn't parse data", http.StatusBadRequest)
@@ -1176,6 +2011,9 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 			hasOpenIDScope = true
 		case scopeOfflineAccess, scopeEmail, scopeProfile, scopeGroups, scopeFederatedID:
 		default:
+			if s.isCustomScope(scope) {
+				continue
+			}
 			peerID, ok := parseCrossClientScope(scope)
 			if !ok {
 				unrecognized = append(unrecognized, scope)
@@ -1204,9 +2042,17 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 		s.tokenErrHelper(w, errInvalidRequest, fmt.Sprintf("Client can't request scope(s) %q", invalidScopes), http.StatusBadRequest)
 		return
 	}
+	if disallowed := disallowedScopes(client, scopes); len(disallowed) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Client can't request scope(s) %q", disallowed), http.StatusBadRequest)
+		return
+	}
 
 	// Which connector
 	connID := s.passwordConnector
+	if !clientAllows(client.AllowedConnectors, connID) {
+		s.tokenErrHelper(w, errUnauthorizedClient, "Client can't use the password connector.", http.StatusBadRequest)
+		return
+	}
 	conn, err := s.getConnector(connID)
 	if err != nil {
 		s.tokenErrHelper(w, errInvalidRequest, "Requested connector does not exist.", http.StatusBadRequest)
@@ -1240,10 +2086,17 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 		Email:             identity.Email,
 		EmailVerified:     identity.EmailVerified,
 		Groups:            identity.Groups,
+		CustomClaims:      identity.CustomClaims,
 	}
 
-	accessToken := storage.NewID()
-	idToken, expiry, err := s.newIDToken(client.ID, claims, scopes, nonce, accessToken, connID)
+	accessToken, expiry, err := s.newAccessToken(client.ID, claims, scopes, connID, "", cnf)
+	if err != nil {
+		s.logger.Errorf("password grant failed to create new access token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	idToken, _, err := s.newIDToken(client.ID, claims, scopes, nonce, accessToken, connID, "", time.Time{})
 	if err != nil {
 		s.tokenErrHelper(w, errServerError, fmt.Sprintf("failed to create ID token: %v", err), http.StatusInternalServerError)
 		return
@@ -1276,9 +2129,11 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 			Claims:      claims,
 			Nonce:       nonce,
 			// ConnectorData: authCode.ConnectorData,
-			CreatedAt: s.now(),
-			LastUsed:  s.now(),
+			Confirmation: cnf,
+			CreatedAt:    s.now(),
+			LastUsed:     s.now(),
 		}
+		refresh.Expiry = s.refreshTokenExpiry(client, refresh.CreatedAt, refresh.LastUsed)
 		token := &internal.RefreshToken{
 			RefreshId: refresh.ID,
 			Token:     refresh.Token,
@@ -1368,23 +2223,196 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 		}
 	}
 
-	s.writeAccessToken(w, idToken, accessToken, refreshToken, expiry)
+	resp := s.toAccessTokenResponse(idToken, accessToken, refreshToken, expiry, cnf)
+	s.writeAccessToken(w, resp)
 }
 
-func (s *Server) writeAccessToken(w http.ResponseWriter, idToken, accessToken, refreshToken string, expiry time.Time) {
-	resp := struct {
-		AccessToken  string `json:"access_token"`
-		TokenType    string `json:"token_type"`
-		ExpiresIn    int    `json:"expires_in"`
-		RefreshToken string `json:"refresh_token,omitempty"`
-		IDToken      string `json:"id_token"`
-	}{
+// handleClientCredentialsGrant issues a token for the client itself. The
+// requested scopes and audiences must be allowed for the client.
+//
+// https://tools.ietf.org/html/rfc6749#section-4.4
+func (s *Server) handleClientCredentialsGrant(w http.ResponseWriter, r *http.Request, client storage.Client, cnf storage.Confirmation) {
+	if err := r.ParseForm(); err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "Couldn't parse data", http.StatusBadRequest)
+		return
+	}
+	if client.Public {
+		s.tokenErrHelper(w, errUnauthorizedClient, "Public clients can't use the client credentials grant.", http.StatusBadRequest)
+		return
+	}
+
+	scopes := strings.Fields(r.Form.Get("scope"))
+	for _, scope := range scopes {
+		if !contains(client.ClientCredentialsScopes, scope) {
+			s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Scope %q is not allowed for this client.", scope), http.StatusBadRequest)
+			return
+		}
+	}
+	audiences := r.Form["audience"]
+	for _, aud := range audiences {
+		if !contains(client.AllowedAudiences, aud) {
+			s.tokenErrHelper(w, errInvalidTarget, fmt.Sprintf("Audience %q is not allowed for this client.", aud), http.StatusBadRequest)
+			return
+		}
+	}
+
+	accessToken, expiry, err := s.newClientCredentialsToken(client.ID, scopes, audiences, cnf)
+	if err != nil {
+		s.logger.Errorf("client credentials grant failed to create new access token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	resp := s.toAccessTokenResponse("", accessToken, "", expiry, cnf)
+	s.writeAccessToken(w, resp)
+}
+
+// handleTokenExchange exchanges a token issued by an upstream provider for a
+// dex token. The connector named by the connector_id parameter validates the
+// subject token and returns the identity it was issued to.
+//
+// https://tools.ietf.org/html/rfc8693
+func (s *Server) handleTokenExchange(w http.ResponseWriter, r *http.Request, client storage.Client, cnf storage.Confirmation) {
+	if err := r.ParseForm(); err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "Couldn't parse data", http.StatusBadRequest)
+		return
+	}
+	q := r.Form
+
+	subjectToken := q.Get("subject_token")
+	subjectTokenType := q.Get("subject_token_type")
+	if subjectToken == "" || subjectTokenType == "" {
+		s.tokenErrHelper(w, errInvalidRequest, "Missing subject_token or subject_token_type.", http.StatusBadRequest)
+		return
+	}
+	if q.Get("actor_token") != "" {
+		s.tokenErrHelper(w, errInvalidRequest, "Delegation with actor_token is not supported.", http.StatusBadRequest)
+		return
+	}
+
+	requestedTokenType := q.Get("requested_token_type")
+	switch requestedTokenType {
+	case "":
+		requestedTokenType = tokenTypeAccessToken
+	case tokenTypeAccessToken, tokenTypeIDToken:
+	default:
+		s.tokenErrHelper(w, errInvalidRequest, fmt.Sprintf("Unsupported requested_token_type %q.", requestedTokenType), http.StatusBadRequest)
+		return
+	}
+
+	scopes := strings.Fields(q.Get("scope"))
+	if len(scopes) == 0 {
+		scopes = []string{scopeOpenID}
+	}
+	for _, scope := range scopes {
+		switch scope {
+		case scopeOpenID, scopeEmail, scopeProfile, scopeGroups, scopeFederatedID:
+		default:
+			if s.isCustomScope(scope) {
+				continue
+			}
+			s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Scope %q can't be requested in a token exchange.", scope), http.StatusBadRequest)
+			return
+		}
+	}
+	if disallowed := disallowedScopes(client, scopes); len(disallowed) > 0 {
+		s.tokenErrHelper(w, errInvalidScope, fmt.Sprintf("Client can't request scope(s) %q", disallowed), http.StatusBadRequest)
+		return
+	}
+
+	// Audiences other than the client itself are requested the same way as
+	// with cross-client scopes, so the peer client must trust this client.
+	for _, aud := range q["audience"] {
+		isTrusted, err := s.validateCrossClientTrust(client.ID, aud)
+		if err != nil {
+			s.logger.Errorf("token exchange failed to validate cross client trust: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		if !isTrusted {
+			s.tokenErrHelper(w, errInvalidTarget, fmt.Sprintf("Audience %q is not allowed for this client.", aud), http.StatusBadRequest)
+			return
+		}
+		scopes = append(scopes, scopeCrossClientPrefix+aud)
+	}
+
+	connID := q.Get("connector_id")
+	if !clientAllows(client.AllowedConnectors, connID) {
+		s.tokenErrHelper(w, errUnauthorizedClient, "Client can't use the requested connector.", http.StatusBadRequest)
+		return
+	}
+	conn, err := s.getConnector(connID)
+	if err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "Requested connector does not exist.", http.StatusBadRequest)
+		return
+	}
+	tokenConn, ok := conn.Connector.(connector.TokenIdentityConnector)
+	if !ok {
+		s.tokenErrHelper(w, errInvalidRequest, "Requested connector does not support token exchange.", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := tokenConn.TokenIdentity(r.Context(), subjectTokenType, subjectToken)
+	if err != nil {
+		s.logger.Errorf("token exchange failed to validate subject token with connector %q: %v", connID, err)
+		s.tokenErrHelper(w, errInvalidGrant, "Invalid subject_token.", http.StatusBadRequest)
+		return
+	}
+
+	claims := storage.Claims{
+		UserID:            identity.UserID,
+		Username:          identity.Username,
+		PreferredUsername: identity.PreferredUsername,
+		Email:             identity.Email,
+		EmailVerified:     identity.EmailVerified,
+		Groups:            identity.Groups,
+		CustomClaims:      identity.CustomClaims,
+	}
+
+	var (
+		token     string
+		expiry    time.Time
+		tokenType = "N_A" // The issued token isn't an OAuth2 access token.
+	)
+	if requestedTokenType == tokenTypeAccessToken {
+		token, expiry, err = s.newAccessToken(client.ID, claims, scopes, connID, "", cnf)
+		tokenType = accessTokenType(cnf)
+	} else {
+		token, expiry, err = s.newIDToken(client.ID, claims, scopes, "", "", connID, "", time.Time{})
+	}
+	if err != nil {
+		s.logger.Errorf("token exchange failed to create new token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	resp := s.toAccessTokenResponse("", token, "", expiry, cnf)
+	resp.TokenType = tokenType
+	resp.IssuedTokenType = requestedTokenType
+	s.writeAccessToken(w, resp)
+}
+
+type accessTokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int    `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+func (s *Server) toAccessTokenResponse(idToken, accessToken, refreshToken string, expiry time.Time, cnf storage.Confirmation) *accessTokenResponse {
+	return &accessTokenResponse{
 		accessToken,
-		"bearer",
+		accessTokenType(cnf),
 		int(expiry.Sub(s.now()).Seconds()),
 		refreshToken,
 		idToken,
+		"",
 	}
+}
+
+func (s *Server) writeAccessToken(w http.ResponseWriter, resp *accessTokenResponse) {
 	data, err := json.Marshal(resp)
 	if err != nil {
 		s.logger.Errorf("failed to marshal access token response: %v", err)
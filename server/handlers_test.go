@@ -7,8 +7,16 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/server/internal"
 	"github.com/dexidp/dex/storage"
 )
 
@@ -119,3 +127,1319 @@ func TestHandleInvalidSAMLCallbacks(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleAuthCodePKCE(t *testing.T) {
+	// challenge is the S256 code challenge of verifier.
+	const (
+		verifier  = "dBjftJeZ4CVP-mJ92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "ngF5GsXcbwljx6u133FFr3Xht9xooA_DuaX_3QwODtc"
+	)
+
+	tests := []struct {
+		name         string
+		client       storage.Client
+		pkce         storage.PKCE
+		form         url.Values
+		expectedCode int
+	}{
+		{
+			name:         "S256 with valid verifier",
+			client:       storage.Client{ID: "foo", Secret: "bar"},
+			pkce:         storage.PKCE{CodeChallenge: challenge, CodeChallengeMethod: codeChallengeMethodS256},
+			form:         url.Values{"client_secret": {"bar"}, "code_verifier": {verifier}},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "plain with valid verifier",
+			client:       storage.Client{ID: "foo", Secret: "bar"},
+			pkce:         storage.PKCE{CodeChallenge: verifier, CodeChallengeMethod: codeChallengeMethodPlain},
+			form:         url.Values{"client_secret": {"bar"}, "code_verifier": {verifier}},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "invalid verifier",
+			client:       storage.Client{ID: "foo", Secret: "bar"},
+			pkce:         storage.PKCE{CodeChallenge: challenge, CodeChallengeMethod: codeChallengeMethodS256},
+			form:         url.Values{"client_secret": {"bar"}, "code_verifier": {"wrong"}},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "missing verifier",
+			client:       storage.Client{ID: "foo", Secret: "bar"},
+			pkce:         storage.PKCE{CodeChallenge: challenge, CodeChallengeMethod: codeChallengeMethodS256},
+			form:         url.Values{"client_secret": {"bar"}},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "verifier without challenge",
+			client:       storage.Client{ID: "foo", Secret: "bar"},
+			form:         url.Values{"client_secret": {"bar"}, "code_verifier": {verifier}},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "public client without secret",
+			client:       storage.Client{ID: "foo", Secret: "bar", Public: true},
+			pkce:         storage.PKCE{CodeChallenge: challenge, CodeChallengeMethod: codeChallengeMethodS256},
+			form:         url.Values{"code_verifier": {verifier}},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "confidential client without secret",
+			client:       storage.Client{ID: "foo", Secret: "bar"},
+			pkce:         storage.PKCE{CodeChallenge: challenge, CodeChallengeMethod: codeChallengeMethodS256},
+			form:         url.Values{"code_verifier": {verifier}},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			httpServer, s := newTestServer(ctx, t, nil)
+			defer httpServer.Close()
+
+			if err := s.storage.CreateClient(tc.client); err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+			code := storage.AuthCode{
+				ID:          storage.NewID(),
+				ClientID:    tc.client.ID,
+				ConnectorID: "mock",
+				RedirectURI: "https://example.com/callback",
+				Scopes:      []string{"openid"},
+				Expiry:      s.now().Add(time.Minute),
+				PKCE:        tc.pkce,
+			}
+			if err := s.storage.CreateAuthCode(code); err != nil {
+				t.Fatalf("failed to create auth code: %v", err)
+			}
+
+			form := tc.form
+			form.Set("grant_type", grantTypeAuthorizationCode)
+			form.Set("client_id", tc.client.ID)
+			form.Set("code", code.ID)
+			form.Set("redirect_uri", code.RedirectURI)
+
+			req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Errorf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleRevokeToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		clientID     string
+		token        func(refresh storage.RefreshToken) string
+		expectedCode int
+		revoked      bool
+	}{
+		{
+			name:     "revoke refresh token",
+			clientID: "foo",
+			token: func(refresh storage.RefreshToken) string {
+				token, _ := internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: refresh.Token})
+				return token
+			},
+			expectedCode: http.StatusOK,
+			revoked:      true,
+		},
+		{
+			name:     "unknown token",
+			clientID: "foo",
+			token: func(storage.RefreshToken) string {
+				token, _ := internal.Marshal(&internal.RefreshToken{RefreshId: storage.NewID(), Token: storage.NewID()})
+				return token
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:     "token issued to another client",
+			clientID: "bar",
+			token: func(refresh storage.RefreshToken) string {
+				token, _ := internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: refresh.Token})
+				return token
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			httpServer, s := newTestServer(ctx, t, nil)
+			defer httpServer.Close()
+
+			for _, id := range []string{"foo", "bar"} {
+				if err := s.storage.CreateClient(storage.Client{ID: id, Secret: "secret"}); err != nil {
+					t.Fatalf("failed to create client: %v", err)
+				}
+			}
+
+			refresh := storage.RefreshToken{
+				ID:          storage.NewID(),
+				Token:       storage.NewID(),
+				ClientID:    "foo",
+				ConnectorID: "mock",
+				Scopes:      []string{"openid", "offline_access"},
+				Claims:      storage.Claims{UserID: "user"},
+				CreatedAt:   s.now(),
+				LastUsed:    s.now(),
+			}
+			if err := s.storage.CreateRefresh(refresh); err != nil {
+				t.Fatalf("failed to create refresh token: %v", err)
+			}
+			if err := s.storage.CreateOfflineSessions(storage.OfflineSessions{
+				UserID: "user",
+				ConnID: "mock",
+				Refresh: map[string]*storage.RefreshTokenRef{
+					"foo": {ID: refresh.ID, ClientID: "foo"},
+				},
+			}); err != nil {
+				t.Fatalf("failed to create offline session: %v", err)
+			}
+
+			form := url.Values{
+				"client_id":     {tc.clientID},
+				"client_secret": {"secret"},
+				"token":         {tc.token(refresh)},
+			}
+			req := httptest.NewRequest("POST", "/token/revoke", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+
+			_, err := s.storage.GetRefresh(refresh.ID)
+			if tc.revoked != (err == storage.ErrNotFound) {
+				t.Errorf("expected revoked=%t, got error %v", tc.revoked, err)
+			}
+			session, err := s.storage.GetOfflineSessions("user", "mock")
+			if err != nil {
+				t.Fatalf("failed to get offline session: %v", err)
+			}
+			if _, ok := session.Refresh["foo"]; ok == tc.revoked {
+				t.Errorf("expected offline session reference removed=%t", tc.revoked)
+			}
+		})
+	}
+}
+
+func TestRevokeOpaqueAccessToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AccessTokenFormat = accessTokenFormatOpaque
+	})
+	defer httpServer.Close()
+
+	for _, id := range []string{"foo", "bar"} {
+		if err := s.storage.CreateClient(storage.Client{ID: id, Secret: "secret"}); err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+	}
+	accessToken, _, err := s.newAccessToken("foo", storage.Claims{UserID: "user"}, []string{"openid"}, "mock", "", storage.Confirmation{})
+	if err != nil {
+		t.Fatalf("failed to create access token: %v", err)
+	}
+
+	post := func(path, clientID string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"client_id":       {clientID},
+			"client_secret":   {"secret"},
+			"token":           {accessToken},
+			"token_type_hint": {"access_token"},
+		}
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+	active := func() bool {
+		rr := post("/token/introspect", "foo")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200 from introspection, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp introspectionResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode introspection response: %v", err)
+		}
+		return resp.Active
+	}
+
+	if !active() {
+		t.Fatal("expected access token to be active")
+	}
+	if rr := post("/token/revoke", "bar"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected revocation by another client to fail, got %d", rr.Code)
+	}
+	if !active() {
+		t.Fatal("expected access token to stay active after failed revocation")
+	}
+	if rr := post("/token/revoke", "foo"); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from revocation, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if active() {
+		t.Error("expected revoked access token to be inactive")
+	}
+	if rr := post("/token/revoke", "foo"); rr.Code != http.StatusOK {
+		t.Errorf("expected revoking an already revoked token to succeed, got %d", rr.Code)
+	}
+}
+
+func TestHandleIntrospectToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Now = func() time.Time { return now }
+	})
+	defer httpServer.Close()
+
+	if err := s.storage.CreateClient(storage.Client{ID: "foo", Secret: "secret"}); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if err := s.storage.CreateClient(storage.Client{ID: "public", Public: true}); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	claims := storage.Claims{UserID: "user", Username: "jane", Groups: []string{"a", "b"}}
+	idToken, _, err := s.newIDToken("foo", claims, []string{"openid", "groups"}, "", "", "mock", "", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+
+	refresh := storage.RefreshToken{
+		ID:          storage.NewID(),
+		Token:       storage.NewID(),
+		ClientID:    "foo",
+		ConnectorID: "mock",
+		Scopes:      []string{"openid", "offline_access"},
+		Claims:      claims,
+		CreatedAt:   now,
+		LastUsed:    now,
+		Expiry:      now.Add(24 * time.Hour),
+	}
+	if err := s.storage.CreateRefresh(refresh); err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+	refreshToken, err := internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: refresh.Token})
+	if err != nil {
+		t.Fatalf("failed to marshal refresh token: %v", err)
+	}
+	rotatedToken, err := internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: storage.NewID()})
+	if err != nil {
+		t.Fatalf("failed to marshal refresh token: %v", err)
+	}
+
+	accessToken, _, err := s.newAccessToken("foo", claims, []string{"openid", "groups"}, "mock", "", storage.Confirmation{})
+	if err != nil {
+		t.Fatalf("failed to create access token: %v", err)
+	}
+	s.accessTokenFormat = accessTokenFormatOpaque
+	opaqueToken, _, err := s.newAccessToken("foo", claims, []string{"openid", "groups"}, "mock", "", storage.Confirmation{})
+	if err != nil {
+		t.Fatalf("failed to create opaque access token: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		client       url.Values
+		token        string
+		now          time.Time
+		expectedCode int
+		active       bool
+		scope        string
+	}{
+		{
+			// ID tokens aren't access tokens, though signed with the same keys.
+			name:         "valid ID token",
+			client:       url.Values{"client_id": {"foo"}, "client_secret": {"secret"}},
+			token:        idToken,
+			now:          now,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "expired ID token",
+			client:       url.Values{"client_id": {"foo"}, "client_secret": {"secret"}},
+			token:        idToken,
+			now:          now.Add(48 * time.Hour),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "tampered ID token",
+			client:       url.Values{"client_id": {"foo"}, "client_secret": {"secret"}},
+			token:        idToken[:len(idToken)-4] + "AAAA",
+			now:          now,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "valid access token",
+			client:       url.Values{"client_id": {"foo"}, "client_secret": {"secret"}},
+			token:        accessToken,
+			now:          now,
+			expectedCode: http.StatusOK,
+			active:       true,
+			scope:        "openid groups",
+		},
+		{
+			name:         "valid opaque access token",
+			client:       url.Values{"client_id": {"foo"}, "client_secret": {"secret"}},
+			token:        opaqueToken,
+			now:          now,
+			expectedCode: http.StatusOK,
+			active:       true,
+			scope:        "openid groups",
+		},
+		{
+			name:         "expired opaque access token",
+			client:       url.Values{"client_id": {"foo"}, "client_secret": {"secret"}},
+			token:        opaqueToken,
+			now:          now.Add(48 * time.Hour),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "valid refresh token",
+			client:       url.Values{"client_id": {"foo"}, "client_secret": {"secret"}},
+			token:        refreshToken,
+			now:          now,
+			expectedCode: http.StatusOK,
+			active:       true,
+			scope:        "openid offline_access",
+		},
+		{
+			name:         "expired refresh token",
+			client:       url.Values{"client_id": {"foo"}, "client_secret": {"secret"}},
+			token:        refreshToken,
+			now:          now.Add(48 * time.Hour),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "rotated refresh token",
+			client:       url.Values{"client_id": {"foo"}, "client_secret": {"secret"}},
+			token:        rotatedToken,
+			now:          now,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "public client",
+			client:       url.Values{"client_id": {"public"}},
+			token:        idToken,
+			now:          now,
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s.now = func() time.Time { return tc.now }
+
+			form := tc.client
+			form.Set("token", tc.token)
+			req := httptest.NewRequest("POST", "/token/introspect", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Active bool     `json:"active"`
+				Sub    string   `json:"sub"`
+				Aud    string   `json:"aud"`
+				Scope  string   `json:"scope"`
+				Exp    int64    `json:"exp"`
+				Groups []string `json:"groups"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Active != tc.active {
+				t.Fatalf("expected active=%t got %s", tc.active, rr.Body.String())
+			}
+			if !tc.active {
+				if rr.Body.String() != `{"active":false}` {
+					t.Errorf("expected no claims for inactive token, got %s", rr.Body.String())
+				}
+				return
+			}
+			if resp.Sub == "" || resp.Aud != "foo" || len(resp.Groups) != 2 || resp.Scope != tc.scope || resp.Exp == 0 {
+				t.Errorf("unexpected introspection response %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleUserInfo(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	claims := storage.Claims{UserID: "user", Username: "jane", Email: "jane@example.com", EmailVerified: true}
+	scopes := []string{"openid", "email"}
+
+	for _, format := range []string{accessTokenFormatJWT, accessTokenFormatOpaque} {
+		t.Run(format, func(t *testing.T) {
+			httpServer, s := newTestServer(ctx, t, func(c *Config) {
+				c.Now = func() time.Time { return now }
+				c.AccessTokenFormat = format
+				c.AccessTokensValidFor = time.Minute
+			})
+			defer httpServer.Close()
+
+			accessToken, expiry, err := s.newAccessToken("foo", claims, scopes, "mock", "", storage.Confirmation{})
+			if err != nil {
+				t.Fatalf("failed to create access token: %v", err)
+			}
+			if !expiry.Equal(now.Add(time.Minute)) {
+				t.Errorf("expected access token to expire at %s, got %s", now.Add(time.Minute), expiry)
+			}
+
+			jws, jwsErr := jose.ParseSigned(accessToken)
+			if format == accessTokenFormatJWT {
+				if jwsErr != nil {
+					t.Fatalf("failed to parse JWT access token: %v", jwsErr)
+				}
+				if typ := jws.Signatures[0].Header.ExtraHeaders[jose.HeaderType]; typ != accessTokenJWTType {
+					t.Errorf("expected typ header %q, got %v", accessTokenJWTType, typ)
+				}
+				var tok accessTokenClaims
+				if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &tok); err != nil {
+					t.Fatalf("failed to decode claims: %v", err)
+				}
+				if tok.ClientID != "foo" || tok.JWTID == "" || tok.Scope != "openid email" || tok.Email != claims.Email {
+					t.Errorf("unexpected access token claims %+v", tok)
+				}
+			} else if jwsErr == nil {
+				t.Fatalf("expected opaque access token, got a JWT")
+			}
+
+			userInfo := func(token string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest("GET", "/userinfo", nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				rr := httptest.NewRecorder()
+				s.ServeHTTP(rr, req)
+				return rr
+			}
+
+			rr := userInfo(accessToken)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+			}
+			var info struct {
+				Subject       string `json:"sub"`
+				Email         string `json:"email"`
+				EmailVerified bool   `json:"email_verified"`
+				ClientID      string `json:"client_id"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+				t.Fatalf("failed to decode userinfo: %v", err)
+			}
+			if info.Subject == "" || info.Email != claims.Email || !info.EmailVerified || info.ClientID != "" {
+				t.Errorf("unexpected userinfo response %s", rr.Body.String())
+			}
+
+			if format == accessTokenFormatOpaque {
+				s.now = func() time.Time { return now.Add(2 * time.Minute) }
+				if rr := userInfo(accessToken); rr.Code != http.StatusForbidden {
+					t.Errorf("expected expired access token to be rejected, got %d", rr.Code)
+				}
+			}
+
+			idToken, _, err := s.newIDToken("foo", claims, scopes, "", "", "mock", "", time.Time{})
+			if err != nil {
+				t.Fatalf("failed to create ID token: %v", err)
+			}
+			if rr := userInfo(idToken); rr.Code != http.StatusForbidden {
+				t.Errorf("expected ID token to be rejected, got %d", rr.Code)
+			}
+		})
+	}
+}
+
+func TestHandleUserInfoCustomClaims(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	claims := storage.Claims{
+		UserID:        "user",
+		Username:      "jane",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		CustomClaims: map[string]interface{}{
+			"department": "engineering",
+			"badge":      "1234",
+		},
+	}
+
+	for _, format := range []string{accessTokenFormatJWT, accessTokenFormatOpaque} {
+		httpServer, s := newTestServer(ctx, t, func(c *Config) {
+			c.AccessTokenFormat = format
+			c.CustomScopes = map[string][]string{"department": {"department"}}
+		})
+		defer httpServer.Close()
+
+		for _, tc := range []struct {
+			scopes         []string
+			wantDepartment bool
+		}{
+			{scopes: []string{"openid", "email"}},
+			{scopes: []string{"openid", "email", "department"}, wantDepartment: true},
+		} {
+			accessToken, _, err := s.newAccessToken("foo", claims, tc.scopes, "mock", "", storage.Confirmation{})
+			if err != nil {
+				t.Fatalf("%s: failed to create access token: %v", format, err)
+			}
+			idToken, _, err := s.newIDToken("foo", claims, tc.scopes, "", accessToken, "mock", "", time.Time{})
+			if err != nil {
+				t.Fatalf("%s: failed to create id token: %v", format, err)
+			}
+			jws, err := jose.ParseSigned(idToken)
+			if err != nil {
+				t.Fatalf("%s: failed to parse id token: %v", format, err)
+			}
+
+			req := httptest.NewRequest("GET", "/userinfo", nil)
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%s: expected 200 got %d: %s", format, rr.Code, rr.Body.String())
+			}
+
+			for name, payload := range map[string][]byte{
+				"id token": jws.UnsafePayloadWithoutVerification(),
+				"userinfo": rr.Body.Bytes(),
+			} {
+				var got map[string]interface{}
+				if err := json.Unmarshal(payload, &got); err != nil {
+					t.Fatalf("%s: failed to decode %s: %v", format, name, err)
+				}
+				if _, ok := got["badge"]; ok {
+					t.Errorf("%s: %s contains claim not granted by any scope: %s", format, name, payload)
+				}
+				department, ok := got["department"]
+				if ok != tc.wantDepartment || (ok && department != "engineering") {
+					t.Errorf("%s: scopes %q, unexpected %s: %s", format, tc.scopes, name, payload)
+				}
+				if got["email"] != claims.Email {
+					t.Errorf("%s: %s lost standard claims: %s", format, name, payload)
+				}
+			}
+		}
+	}
+}
+
+func TestHandleAuthorizationAllowedConnectors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServerMultipleConnectors(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{
+			{
+				ID:                "foo",
+				RedirectURIs:      []string{"https://example.com/foo"},
+				AllowedConnectors: []string{"mock2"},
+			},
+		})
+	})
+	defer httpServer.Close()
+
+	params := url.Values{
+		"client_id":     {"foo"},
+		"redirect_uri":  {"https://example.com/foo"},
+		"response_type": {"code"},
+		"scope":         {"openid"},
+	}
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/auth?"+params.Encode(), nil))
+
+	// With a single allowed connector the login page is skipped.
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected redirect to the connector, got %d: %s", rr.Code, rr.Body.String())
+	}
+	u, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != s.absPath("/auth", "mock2") {
+		t.Errorf("expected redirect to the allowed connector, got %q", u.Path)
+	}
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", s.absPath("/auth", "mock")+"?"+u.RawQuery, nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected disallowed connector to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestHandleClientCredentialsGrant(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	clients := []storage.Client{
+		{
+			ID:                      "service",
+			Secret:                  "secret",
+			ClientCredentialsScopes: []string{"read", "write"},
+			AllowedAudiences:        []string{"https://api.example.com"},
+		},
+		{ID: "public", Secret: "secret", Public: true, ClientCredentialsScopes: []string{"read"}},
+		{
+			ID:                      "interactive",
+			Secret:                  "secret",
+			ClientCredentialsScopes: []string{"read"},
+			AllowedGrantTypes:       []string{grantTypeAuthorizationCode, grantTypeRefreshToken},
+		},
+		// Scopes allowed on behalf of users don't apply to the client itself.
+		{ID: "user-scopes", Secret: "secret", AllowedScopes: []string{"read"}},
+	}
+	for _, client := range clients {
+		if err := s.storage.CreateClient(client); err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name             string
+		form             url.Values
+		expectedCode     int
+		expectedError    string
+		expectedAudience audience
+		expectedScope    string
+	}{
+		{
+			name:             "no scopes",
+			form:             url.Values{"client_id": {"service"}, "client_secret": {"secret"}},
+			expectedCode:     http.StatusOK,
+			expectedAudience: audience{"service"},
+		},
+		{
+			name: "allowed scopes and audience",
+			form: url.Values{
+				"client_id":     {"service"},
+				"client_secret": {"secret"},
+				"scope":         {"read write"},
+				"audience":      {"https://api.example.com"},
+			},
+			expectedCode:     http.StatusOK,
+			expectedAudience: audience{"https://api.example.com"},
+			expectedScope:    "read write",
+		},
+		{
+			name:          "scope not allowed",
+			form:          url.Values{"client_id": {"service"}, "client_secret": {"secret"}, "scope": {"read admin"}},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errInvalidScope,
+		},
+		{
+			name:          "only allowed on behalf of users",
+			form:          url.Values{"client_id": {"user-scopes"}, "client_secret": {"secret"}, "scope": {"read"}},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errInvalidScope,
+		},
+		{
+			name:          "audience not allowed",
+			form:          url.Values{"client_id": {"service"}, "client_secret": {"secret"}, "audience": {"https://evil.example.com"}},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errInvalidTarget,
+		},
+		{
+			name:          "wrong secret",
+			form:          url.Values{"client_id": {"service"}, "client_secret": {"wrong"}},
+			expectedCode:  http.StatusUnauthorized,
+			expectedError: errInvalidClient,
+		},
+		{
+			name:          "public client",
+			form:          url.Values{"client_id": {"public"}, "client_secret": {"secret"}},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errUnauthorizedClient,
+		},
+		{
+			name:          "grant type not allowed",
+			form:          url.Values{"client_id": {"interactive"}, "client_secret": {"secret"}, "scope": {"read"}},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errUnauthorizedClient,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.form.Set("grant_type", grantTypeClientCredentials)
+			req := httptest.NewRequest("POST", "/token", strings.NewReader(tc.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+
+			if tc.expectedError != "" {
+				var resp struct {
+					Error string `json:"error"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if resp.Error != tc.expectedError {
+					t.Errorf("expected error %q got %q", tc.expectedError, resp.Error)
+				}
+				return
+			}
+
+			var resp accessTokenResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.IDToken != "" || resp.RefreshToken != "" {
+				t.Errorf("expected only an access token, got %#v", resp)
+			}
+
+			keySet := &storageKeySet{s.storage}
+			payload, err := keySet.VerifySignature(ctx, resp.AccessToken)
+			if err != nil {
+				t.Fatalf("failed to verify access token: %v", err)
+			}
+			var claims accessTokenClaims
+			if err := json.Unmarshal(payload, &claims); err != nil {
+				t.Fatalf("failed to decode claims: %v", err)
+			}
+			if claims.Subject != "service" || claims.ClientID != "service" {
+				t.Errorf("expected token for client %q, got sub=%q client_id=%q", "service", claims.Subject, claims.ClientID)
+			}
+			if len(claims.Audience) != len(tc.expectedAudience) || claims.Audience[0] != tc.expectedAudience[0] {
+				t.Errorf("expected audience %v got %v", tc.expectedAudience, claims.Audience)
+			}
+			if claims.Scope != tc.expectedScope {
+				t.Errorf("expected scope %q got %q", tc.expectedScope, claims.Scope)
+			}
+		})
+	}
+}
+
+func TestHandleRefreshTokenExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now().UTC().Round(time.Second)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Now = func() time.Time { return now }
+		c.RefreshTokensValidFor = 24 * time.Hour
+		c.RefreshTokenIdleTimeout = time.Hour
+	})
+	defer httpServer.Close()
+
+	clients := []storage.Client{
+		{ID: "default", Secret: "secret"},
+		{ID: "no-idle-timeout", Secret: "secret", RefreshTokenIdleTimeout: "0s"},
+		{ID: "short-lived", Secret: "secret", RefreshTokenValidFor: "1h"},
+	}
+	for _, client := range clients {
+		if err := s.storage.CreateClient(client); err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		clientID  string
+		createdAt time.Time
+		lastUsed  time.Time
+		// Expiry stored with the token, computed from the configuration if zero.
+		expiry time.Time
+
+		expectedCode   int
+		expectedExpiry time.Time
+	}{
+		{
+			name:           "recently used",
+			clientID:       "default",
+			createdAt:      now.Add(-2 * time.Hour),
+			lastUsed:       now.Add(-30 * time.Minute),
+			expectedCode:   http.StatusOK,
+			expectedExpiry: now.Add(time.Hour),
+		},
+		{
+			name:           "close to absolute lifetime",
+			clientID:       "default",
+			createdAt:      now.Add(-(23*time.Hour + 30*time.Minute)),
+			lastUsed:       now.Add(-time.Minute),
+			expectedCode:   http.StatusOK,
+			expectedExpiry: now.Add(30 * time.Minute),
+		},
+		{
+			name:         "idle for too long",
+			clientID:     "default",
+			createdAt:    now.Add(-3 * time.Hour),
+			lastUsed:     now.Add(-2 * time.Hour),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "past absolute lifetime",
+			clientID:     "default",
+			createdAt:    now.Add(-25 * time.Hour),
+			lastUsed:     now.Add(-time.Minute),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:           "client without idle timeout",
+			clientID:       "no-idle-timeout",
+			createdAt:      now.Add(-3 * time.Hour),
+			lastUsed:       now.Add(-2 * time.Hour),
+			expectedCode:   http.StatusOK,
+			expectedExpiry: now.Add(21 * time.Hour),
+		},
+		{
+			name:         "client with shorter lifetime",
+			clientID:     "short-lived",
+			createdAt:    now.Add(-2 * time.Hour),
+			lastUsed:     now.Add(-time.Minute),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			// The token was issued while a shorter idle timeout was configured.
+			name:         "expired before configuration change",
+			clientID:     "default",
+			createdAt:    now.Add(-2 * time.Hour),
+			lastUsed:     now.Add(-30 * time.Minute),
+			expiry:       now.Add(-time.Minute),
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			userID := storage.NewID()
+			refresh := storage.RefreshToken{
+				ID:          storage.NewID(),
+				Token:       storage.NewID(),
+				ClientID:    tc.clientID,
+				ConnectorID: "mock",
+				Scopes:      []string{"openid", "offline_access"},
+				Claims:      storage.Claims{UserID: userID, Username: "jane"},
+				CreatedAt:   tc.createdAt,
+				LastUsed:    tc.lastUsed,
+				Expiry:      tc.expiry,
+			}
+			if refresh.Expiry.IsZero() {
+				client, err := s.storage.GetClient(tc.clientID)
+				if err != nil {
+					t.Fatalf("failed to get client: %v", err)
+				}
+				refresh.Expiry = s.refreshTokenExpiry(client, tc.createdAt, tc.lastUsed)
+			}
+			if err := s.storage.CreateRefresh(refresh); err != nil {
+				t.Fatalf("failed to create refresh token: %v", err)
+			}
+			if err := s.storage.CreateOfflineSessions(storage.OfflineSessions{
+				UserID:  userID,
+				ConnID:  "mock",
+				Refresh: map[string]*storage.RefreshTokenRef{tc.clientID: {ID: refresh.ID, ClientID: tc.clientID}},
+			}); err != nil {
+				t.Fatalf("failed to create offline session: %v", err)
+			}
+
+			token, err := internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: refresh.Token})
+			if err != nil {
+				t.Fatalf("failed to marshal refresh token: %v", err)
+			}
+			form := url.Values{
+				"grant_type":    {grantTypeRefreshToken},
+				"refresh_token": {token},
+				"client_id":     {tc.clientID},
+				"client_secret": {"secret"},
+			}
+			req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+
+			if tc.expectedCode != http.StatusOK {
+				var resp struct {
+					Error string `json:"error"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if resp.Error != errInvalidGrant {
+					t.Errorf("expected error %q got %q", errInvalidGrant, resp.Error)
+				}
+				return
+			}
+
+			updated, err := s.storage.GetRefresh(refresh.ID)
+			if err != nil {
+				t.Fatalf("failed to get refresh token: %v", err)
+			}
+			if !updated.Expiry.Equal(tc.expectedExpiry) {
+				t.Errorf("expected refresh token to expire at %v, got %v", tc.expectedExpiry, updated.Expiry)
+			}
+		})
+	}
+}
+
+func TestHandleRefreshTokenReuse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	var racing *racingRefreshStorage
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Now = func() time.Time { return now }
+		c.RefreshTokenReuseInterval = 5 * time.Second
+		racing = &racingRefreshStorage{Storage: c.Storage}
+		c.Storage = racing
+	})
+	defer httpServer.Close()
+
+	if err := s.storage.CreateClient(storage.Client{ID: "foo", Secret: "secret"}); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	newRefresh := func(clientID string) storage.RefreshToken {
+		refresh := storage.RefreshToken{
+			ID:          storage.NewID(),
+			Token:       storage.NewID(),
+			ClientID:    clientID,
+			ConnectorID: "mock",
+			Scopes:      []string{"openid", "offline_access"},
+			Claims:      storage.Claims{UserID: "user", Username: "jane"},
+			CreatedAt:   now,
+			LastUsed:    now,
+		}
+		if err := s.storage.CreateRefresh(refresh); err != nil {
+			t.Fatalf("failed to create refresh token: %v", err)
+		}
+		return refresh
+	}
+	refresh := newRefresh("foo")
+	// The refresh token of another client in the same offline session.
+	other := newRefresh("bar")
+	if err := s.storage.CreateOfflineSessions(storage.OfflineSessions{
+		UserID: "user",
+		ConnID: "mock",
+		Refresh: map[string]*storage.RefreshTokenRef{
+			"foo": {ID: refresh.ID, ClientID: "foo"},
+			"bar": {ID: other.ID, ClientID: "bar"},
+		},
+	}); err != nil {
+		t.Fatalf("failed to create offline session: %v", err)
+	}
+
+	doRefresh := func(refreshToken string) (accessTokenResponse, string) {
+		form := url.Values{
+			"grant_type":    {grantTypeRefreshToken},
+			"refresh_token": {refreshToken},
+			"client_id":     {"foo"},
+			"client_secret": {"secret"},
+		}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+
+		var resp accessTokenResponse
+		if rr.Code != http.StatusOK {
+			var errResp struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &errResp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			return resp, errResp.Error
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp, ""
+	}
+
+	token, err := internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: refresh.Token})
+	if err != nil {
+		t.Fatalf("failed to marshal refresh token: %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	resp, errType := doRefresh(token)
+	if errType != "" {
+		t.Fatalf("failed to refresh token: %s", errType)
+	}
+	rotated := resp.RefreshToken
+	if rotated == token {
+		t.Fatalf("expected refresh token to be rotated")
+	}
+
+	// A concurrent request with the previous token gets the current one.
+	now = now.Add(2 * time.Second)
+	resp, errType = doRefresh(token)
+	if errType != "" {
+		t.Fatalf("expected previous refresh token to be accepted within the reuse interval, got %s", errType)
+	}
+	if resp.RefreshToken != rotated {
+		t.Errorf("expected the current refresh token to be returned again")
+	}
+
+	// Of two concurrent requests with the current token, the one losing the
+	// race is rejected.
+	racing.race = true
+	if _, errType := doRefresh(rotated); errType != errInvalidGrant {
+		t.Fatalf("expected error %q for a concurrent refresh, got %q", errInvalidGrant, errType)
+	}
+	current, err := s.storage.GetRefresh(refresh.ID)
+	if err != nil {
+		t.Fatalf("failed to get refresh token: %v", err)
+	}
+	rotated, err = internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: current.Token})
+	if err != nil {
+		t.Fatalf("failed to marshal refresh token: %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if resp, errType = doRefresh(rotated); errType != "" {
+		t.Fatalf("failed to refresh token: %s", errType)
+	}
+	rotated = resp.RefreshToken
+
+	// Values which were never issued are rejected without revoking anything.
+	unknown, err := internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: storage.NewID()})
+	if err != nil {
+		t.Fatalf("failed to marshal refresh token: %v", err)
+	}
+	if _, errType := doRefresh(unknown); errType != errInvalidGrant {
+		t.Fatalf("expected error %q for an unknown refresh token, got %q", errInvalidGrant, errType)
+	}
+	if _, err := s.storage.GetRefresh(refresh.ID); err != nil {
+		t.Fatalf("expected refresh token to be kept, got error %v", err)
+	}
+
+	// Past the reuse interval, replaying any earlier token revokes the token
+	// family, but not the refresh tokens of other clients.
+	now = now.Add(time.Minute)
+	if _, errType := doRefresh(token); errType != errInvalidGrant {
+		t.Fatalf("expected error %q when replaying a rotated refresh token, got %q", errInvalidGrant, errType)
+	}
+	if _, err := s.storage.GetRefresh(refresh.ID); err != storage.ErrNotFound {
+		t.Errorf("expected refresh token to be revoked, got error %v", err)
+	}
+	if _, err := s.storage.GetRefresh(other.ID); err != nil {
+		t.Errorf("expected refresh token of another client to be kept, got error %v", err)
+	}
+	session, err := s.storage.GetOfflineSessions("user", "mock")
+	if err != nil {
+		t.Fatalf("expected offline session to be kept, got error %v", err)
+	}
+	if _, ok := session.Refresh["foo"]; ok {
+		t.Errorf("expected revoked refresh token to be removed from the offline session")
+	}
+	if _, ok := session.Refresh["bar"]; !ok {
+		t.Errorf("expected refresh token of another client to stay in the offline session")
+	}
+	if _, errType := doRefresh(rotated); errType == "" {
+		t.Errorf("expected current refresh token to be revoked along with the rotated one")
+	}
+
+	if n := testutil.ToFloat64(s.securityEvents.WithLabelValues(securityEventRefreshTokenReuse)); n != 1 {
+		t.Errorf("expected 1 refresh token reuse event, got %v", n)
+	}
+}
+
+// racingRefreshStorage rotates a refresh token right before it's updated when
+// race is set, as a concurrent refresh request would.
+type racingRefreshStorage struct {
+	storage.Storage
+	race bool
+}
+
+func (r *racingRefreshStorage) UpdateRefreshToken(id string, updater func(old storage.RefreshToken) (storage.RefreshToken, error)) error {
+	if r.race {
+		r.race = false
+		if err := r.Storage.UpdateRefreshToken(id, func(old storage.RefreshToken) (storage.RefreshToken, error) {
+			old.RotatedTokens = append(old.RotatedTokens, old.ObsoleteToken)
+			old.ObsoleteToken = old.Token
+			old.Token = storage.NewID()
+			return old, nil
+		}); err != nil {
+			return err
+		}
+	}
+	return r.Storage.UpdateRefreshToken(id, updater)
+}
+
+func TestHandleTokenExchange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	clients := []storage.Client{
+		{ID: "foo", Secret: "secret"},
+		{ID: "bar", Secret: "secret", TrustedPeers: []string{"foo"}},
+	}
+	for _, client := range clients {
+		if err := s.storage.CreateClient(client); err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+	}
+	if err := s.storage.CreateConnector(storage.Connector{
+		ID:     "password",
+		Type:   "mockPassword",
+		Name:   "Password",
+		Config: []byte(`{"username": "foo", "password": "password"}`),
+	}); err != nil {
+		t.Fatalf("failed to create connector: %v", err)
+	}
+
+	tests := []struct {
+		name              string
+		form              url.Values
+		expectedCode      int
+		expectedError     string
+		expectedTokenType string
+		expectedAudience  audience
+	}{
+		{
+			name:              "default requested token type",
+			form:              url.Values{},
+			expectedCode:      http.StatusOK,
+			expectedTokenType: tokenTypeAccessToken,
+			expectedAudience:  audience{"foo"},
+		},
+		{
+			name:              "id token with trusted audience",
+			form:              url.Values{"requested_token_type": {tokenTypeIDToken}, "audience": {"bar"}, "scope": {"openid email"}},
+			expectedCode:      http.StatusOK,
+			expectedTokenType: tokenTypeIDToken,
+			expectedAudience:  audience{"bar", "foo"},
+		},
+		{
+			name:          "untrusted audience",
+			form:          url.Values{"audience": {"baz"}},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errInvalidTarget,
+		},
+		{
+			name:          "offline access",
+			form:          url.Values{"scope": {"openid offline_access"}},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errInvalidScope,
+		},
+		{
+			name:          "unsupported subject token type",
+			form:          url.Values{"subject_token_type": {"urn:ietf:params:oauth:token-type:saml2"}},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errInvalidGrant,
+		},
+		{
+			name:          "missing subject token",
+			form:          url.Values{"subject_token": {""}},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errInvalidRequest,
+		},
+		{
+			name:          "connector without token exchange",
+			form:          url.Values{"connector_id": {"password"}},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: errInvalidRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{
+				"grant_type":         {grantTypeTokenExchange},
+				"client_id":          {"foo"},
+				"client_secret":      {"secret"},
+				"connector_id":       {"mock"},
+				"subject_token":      {"upstream-token"},
+				"subject_token_type": {tokenTypeIDToken},
+			}
+			for key, values := range tc.form {
+				form[key] = values
+			}
+			req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+
+			if tc.expectedError != "" {
+				var resp struct {
+					Error string `json:"error"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if resp.Error != tc.expectedError {
+					t.Errorf("expected error %q got %q", tc.expectedError, resp.Error)
+				}
+				return
+			}
+
+			var resp accessTokenResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.IssuedTokenType != tc.expectedTokenType {
+				t.Errorf("expected issued token type %q got %q", tc.expectedTokenType, resp.IssuedTokenType)
+			}
+			if resp.IDToken != "" || resp.RefreshToken != "" {
+				t.Errorf("expected only the issued token, got %#v", resp)
+			}
+
+			keySet := &storageKeySet{s.storage}
+			payload, err := keySet.VerifySignature(ctx, resp.AccessToken)
+			if err != nil {
+				t.Fatalf("failed to verify issued token: %v", err)
+			}
+			var claims idTokenClaims
+			if err := json.Unmarshal(payload, &claims); err != nil {
+				t.Fatalf("failed to decode claims: %v", err)
+			}
+			if !reflect.DeepEqual(claims.Audience, tc.expectedAudience) {
+				t.Errorf("expected audience %v got %v", tc.expectedAudience, claims.Audience)
+			}
+			var sub internal.IDTokenSubject
+			if err := internal.Unmarshal(claims.Subject, &sub); err != nil {
+				t.Fatalf("failed to unmarshal subject: %v", err)
+			}
+			if sub.ConnId != "mock" || sub.UserId != "0-385-28089-0" {
+				t.Errorf("expected subject of the mock connector's identity, got %#v", sub)
+			}
+		})
+	}
+}
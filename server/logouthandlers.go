@@ -0,0 +1,307 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/server/internal"
+	"github.com/dexidp/dex/storage"
+)
+
+const logoutCallbackURI = "/logout/callback"
+
+// logoutCookieName is the name of the cookie holding the token that the
+// logout confirmation form must post back, so other sites can't log users out.
+const logoutCookieName = "dex_logout"
+
+// idTokenHintGracePeriod is how long after expiry an id_token_hint still
+// counts as proof that the logout request comes from the client.
+const idTokenHintGracePeriod = 10 * time.Minute
+
+// handleLogout handles RP-initiated logout.
+// See: https://openid.net/specs/openid-connect-rpinitiated-1_0.html
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.renderError(r, w, http.StatusBadRequest, "Unsupported request method.")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.renderError(r, w, http.StatusBadRequest, "Failed to parse request.")
+		return
+	}
+
+	idTokenHint := r.Form.Get("id_token_hint")
+	postLogoutRedirectURI := r.Form.Get("post_logout_redirect_uri")
+	clientID := r.Form.Get("client_id")
+	state := r.Form.Get("state")
+
+	var (
+		claims *idTokenClaims
+		// Whether the hint is recent enough to log out without confirmation.
+		validHint bool
+	)
+	if idTokenHint != "" {
+		var err error
+		if claims, err = s.parseIDTokenHint(idTokenHint); err != nil {
+			s.logger.Errorf("Invalid id_token_hint: %v", err)
+			s.renderError(r, w, http.StatusBadRequest, "Invalid id_token_hint.")
+			return
+		}
+		if clientID == "" && len(claims.Audience) > 0 {
+			clientID = claims.Audience[0]
+		} else if clientID != "" && !claims.Audience.contains(clientID) {
+			s.renderError(r, w, http.StatusBadRequest, "client_id doesn't match the audience of id_token_hint.")
+			return
+		}
+		validHint = s.now().Before(time.Unix(claims.Expiry, 0).Add(idTokenHintGracePeriod))
+	}
+
+	// Where to send the user once they're logged out. Without a redirect URI
+	// dex shows its own logout page.
+	var redirectURL string
+	if postLogoutRedirectURI != "" {
+		if clientID == "" {
+			s.renderError(r, w, http.StatusBadRequest, "post_logout_redirect_uri requires id_token_hint or client_id.")
+			return
+		}
+		client, err := s.storage.GetClient(clientID)
+		if err != nil {
+			if err == storage.ErrNotFound {
+				s.renderError(r, w, http.StatusBadRequest, fmt.Sprintf("Invalid client_id (%q).", clientID))
+				return
+			}
+			s.logger.Errorf("Failed to get client %q: %v", clientID, err)
+			s.renderError(r, w, http.StatusInternalServerError, "Failed to retrieve client.")
+			return
+		}
+		if !validatePostLogoutRedirectURI(client, postLogoutRedirectURI) {
+			s.renderError(r, w, http.StatusBadRequest, fmt.Sprintf("Unregistered post_logout_redirect_uri (%q).", postLogoutRedirectURI))
+			return
+		}
+		redirectURL = postLogoutURL(postLogoutRedirectURI, state)
+	}
+
+	// Without a valid hint anyone could link to this endpoint, so ask the user
+	// to confirm first.
+	if !validHint && !s.logoutConfirmed(r) {
+		s.confirmLogout(w, r, idTokenHint, clientID, postLogoutRedirectURI, state)
+		return
+	}
+	if _, err := r.Cookie(logoutCookieName); err == nil {
+		http.SetCookie(w, s.cookie(logoutCookieName, "", time.Unix(0, 0)))
+	}
+
+	if err := s.deleteSession(w, r); err != nil {
+		s.logger.Errorf("Failed to end session: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Failed to log out.")
+		return
+	}
+
+	if claims != nil {
+		var sub internal.IDTokenSubject
+		if err := internal.Unmarshal(claims.Subject, &sub); err != nil {
+			s.logger.Errorf("Failed to unmarshal ID token subject: %v", err)
+			s.renderError(r, w, http.StatusBadRequest, "Invalid id_token_hint.")
+			return
+		}
+
+		upstreamURL, err := s.endSession(r.Context(), sub.UserId, sub.ConnId, redirectURL, validHint)
+		if err != nil {
+			s.logger.Errorf("Failed to end session: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Failed to log out.")
+			return
+		}
+		if upstreamURL != "" {
+			http.Redirect(w, r, upstreamURL, http.StatusFound)
+			return
+		}
+	}
+
+	s.finishLogout(w, r, redirectURL)
+}
+
+// confirmLogout renders a form that posts the logout request back along with
+// a token that is also set as a cookie.
+func (s *Server) confirmLogout(w http.ResponseWriter, r *http.Request, idTokenHint, clientID, postLogoutRedirectURI, state string) {
+	token := storage.NewID()
+	http.SetCookie(w, s.cookie(logoutCookieName, token, s.now().Add(time.Hour)))
+
+	params := url.Values{"logout_token": {token}}
+	for name, value := range map[string]string{
+		"id_token_hint":            idTokenHint,
+		"client_id":                clientID,
+		"post_logout_redirect_uri": postLogoutRedirectURI,
+		"state":                    state,
+	} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	if err := s.templates.logoutConfirm(r, w, params); err != nil {
+		s.logger.Errorf("Server template error: %v", err)
+	}
+}
+
+// logoutConfirmed reports whether the request was posted from the logout
+// confirmation form.
+func (s *Server) logoutConfirmed(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	token := r.PostForm.Get("logout_token")
+	cookie, err := r.Cookie(logoutCookieName)
+	if err != nil || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) == 1
+}
+
+// handleLogoutCallback is where upstream providers send the user after ending
+// their upstream session. OIDC providers pass the final redirect URL as
+// "state", SAML providers as "RelayState".
+func (s *Server) handleLogoutCallback(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.renderError(r, w, http.StatusBadRequest, "Failed to parse request.")
+		return
+	}
+
+	redirectURL := r.Form.Get("state")
+	if redirectURL == "" {
+		redirectURL = r.Form.Get("RelayState")
+	}
+	if redirectURL != "" {
+		// The state has been through the upstream provider, don't trust it.
+		ok, err := s.isPostLogoutURL(redirectURL)
+		if err != nil {
+			s.logger.Errorf("Failed to list clients: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Failed to retrieve clients.")
+			return
+		}
+		if !ok {
+			s.renderError(r, w, http.StatusBadRequest, "Invalid logout state.")
+			return
+		}
+	}
+
+	s.finishLogout(w, r, redirectURL)
+}
+
+func (s *Server) finishLogout(w http.ResponseWriter, r *http.Request, redirectURL string) {
+	if redirectURL != "" {
+		http.Redirect(w, r, redirectURL, http.StatusFound)
+		return
+	}
+	if err := s.templates.logout(r, w); err != nil {
+		s.logger.Errorf("Server template error: %v", err)
+	}
+}
+
+// endSession revokes the user's refresh tokens, if configured and revoke is
+// set, and returns the URL to end the upstream session with, if the connector
+// supports it.
+func (s *Server) endSession(ctx context.Context, userID, connID, redirectURL string, revoke bool) (string, error) {
+	session, err := s.storage.GetOfflineSessions(userID, connID)
+	if err != nil && err != storage.ErrNotFound {
+		return "", fmt.Errorf("failed to get offline session: %v", err)
+	}
+	hasSession := err == nil
+
+	if s.revokeRefreshTokensOnLogout && revoke && hasSession {
+		if err := s.deleteOfflineSessions(session); err != nil {
+			return "", err
+		}
+	}
+
+	conn, err := s.getConnector(connID)
+	if err != nil {
+		// The connector may have been removed since the token was issued.
+		s.logger.Errorf("Failed to get connector with id %q: %v", connID, err)
+		return "", nil
+	}
+	logoutConn, ok := conn.Connector.(connector.LogoutConnector)
+	if !ok {
+		return "", nil
+	}
+
+	identity := connector.Identity{UserID: userID}
+	if hasSession {
+		identity.ConnectorData = session.ConnectorData
+	}
+	// Upstream providers always return to dex, which then sends the user on
+	// to the final redirect URL.
+	upstreamURL, err := logoutConn.LogoutURL(ctx, identity, s.absURL(logoutCallbackURI), redirectURL)
+	if err != nil {
+		// Failing to log out upstream shouldn't keep the user logged in to dex.
+		s.logger.Errorf("Failed to get upstream logout URL for connector %q: %v", connID, err)
+		return "", nil
+	}
+	return upstreamURL, nil
+}
+
+// parseIDTokenHint verifies an ID token previously issued by dex. Expired
+// tokens are accepted since the user may have been idle for a while, callers
+// must check the expiry before trusting the hint.
+func (s *Server) parseIDTokenHint(idTokenHint string) (*idTokenClaims, error) {
+	keySet := &storageKeySet{s.storage}
+	payload, err := keySet.VerifySignature(context.Background(), idTokenHint)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims idTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal claims: %v", err)
+	}
+	if claims.Issuer != s.issuerURL.String() {
+		return nil, errors.New("unexpected issuer")
+	}
+	return &claims, nil
+}
+
+// isPostLogoutURL reports whether the URL is a post logout redirect URI
+// registered by any client, with an optional state appended.
+func (s *Server) isPostLogoutURL(redirectURL string) (bool, error) {
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return false, nil
+	}
+	state := u.Query().Get("state")
+
+	clients, err := s.storage.ListClients()
+	if err != nil {
+		return false, err
+	}
+	for _, client := range clients {
+		for _, uri := range client.PostLogoutRedirectURIs {
+			if postLogoutURL(uri, state) == redirectURL {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func validatePostLogoutRedirectURI(client storage.Client, redirectURI string) bool {
+	return contains(client.PostLogoutRedirectURIs, redirectURI)
+}
+
+// postLogoutURL appends the state of a logout request to the redirect URI.
+func postLogoutURL(redirectURI, state string) string {
+	if state == "" {
+		return redirectURI
+	}
+	v := url.Values{}
+	v.Set("state", state)
+	if strings.Contains(redirectURI, "?") {
+		return redirectURI + "&" + v.Encode()
+	}
+	return redirectURI + "?" + v.Encode()
+}
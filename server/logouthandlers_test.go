@@ -0,0 +1,313 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestHandleLogout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	client := storage.Client{
+		ID:                     "foo",
+		Secret:                 "secret",
+		PostLogoutRedirectURIs: []string{"https://example.com/logged-out"},
+	}
+	if err := s.storage.CreateClient(client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	claims := storage.Claims{UserID: "user", Username: "jane"}
+	idToken, _, err := s.newIDToken("foo", claims, []string{"openid"}, "", "", "mock", "", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+	expiredIDToken := newExpiredIDToken(t, s, claims)
+
+	tests := []struct {
+		name             string
+		params           url.Values
+		expectedCode     int
+		expectedLocation string
+		expectConfirm    bool
+	}{
+		{
+			name:          "no parameters",
+			params:        url.Values{},
+			expectedCode:  http.StatusOK,
+			expectConfirm: true,
+		},
+		{
+			name:         "id token hint",
+			params:       url.Values{"id_token_hint": {idToken}},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:          "expired id token hint",
+			params:        url.Values{"id_token_hint": {expiredIDToken}},
+			expectedCode:  http.StatusOK,
+			expectConfirm: true,
+		},
+		{
+			name: "registered redirect",
+			params: url.Values{
+				"id_token_hint":            {idToken},
+				"post_logout_redirect_uri": {"https://example.com/logged-out"},
+				"state":                    {"xyz"},
+			},
+			expectedCode:     http.StatusFound,
+			expectedLocation: "https://example.com/logged-out?state=xyz",
+		},
+		{
+			name: "redirect with client id",
+			params: url.Values{
+				"client_id":                {"foo"},
+				"post_logout_redirect_uri": {"https://example.com/logged-out"},
+			},
+			expectedCode:  http.StatusOK,
+			expectConfirm: true,
+		},
+		{
+			name: "unregistered redirect",
+			params: url.Values{
+				"id_token_hint":            {idToken},
+				"post_logout_redirect_uri": {"https://evil.example.com"},
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "redirect without client",
+			params:       url.Values{"post_logout_redirect_uri": {"https://example.com/logged-out"}},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "client id not in audience",
+			params:       url.Values{"id_token_hint": {idToken}, "client_id": {"bar"}},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "tampered id token hint",
+			params:       url.Values{"id_token_hint": {idToken[:len(idToken)-4] + "AAAA"}},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, httptest.NewRequest("GET", "/logout?"+tc.params.Encode(), nil))
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+			if location := rr.Header().Get("Location"); location != tc.expectedLocation {
+				t.Errorf("expected location %q got %q", tc.expectedLocation, location)
+			}
+			if confirm := strings.Contains(rr.Body.String(), `name="logout_token"`); confirm != tc.expectConfirm {
+				t.Errorf("expected confirmation page %t got %t", tc.expectConfirm, confirm)
+			}
+		})
+	}
+}
+
+func TestHandleLogoutConfirm(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	client := storage.Client{
+		ID:                     "foo",
+		Secret:                 "secret",
+		PostLogoutRedirectURIs: []string{"https://example.com/logged-out"},
+	}
+	if err := s.storage.CreateClient(client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	params := url.Values{
+		"client_id":                {"foo"},
+		"post_logout_redirect_uri": {"https://example.com/logged-out"},
+	}
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/logout?"+params.Encode(), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var cookie *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == logoutCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatalf("expected %s cookie to be set", logoutCookieName)
+	}
+
+	tests := []struct {
+		name             string
+		token            string
+		cookie           *http.Cookie
+		expectedCode     int
+		expectedLocation string
+	}{
+		{"confirmed", cookie.Value, cookie, http.StatusFound, "https://example.com/logged-out"},
+		{"missing cookie", cookie.Value, nil, http.StatusOK, ""},
+		{"wrong token", "bad", cookie, http.StatusOK, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{"logout_token": {tc.token}}
+			for k, v := range params {
+				form[k] = v
+			}
+			req := httptest.NewRequest("POST", "/logout", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.cookie != nil {
+				req.AddCookie(tc.cookie)
+			}
+
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+			if location := rr.Header().Get("Location"); location != tc.expectedLocation {
+				t.Errorf("expected location %q got %q", tc.expectedLocation, location)
+			}
+		})
+	}
+}
+
+// newExpiredIDToken returns an ID token that expired well before the grace
+// period for id_token_hint.
+func newExpiredIDToken(t *testing.T, s *Server, claims storage.Claims) string {
+	now := s.now
+	defer func() { s.now = now }()
+	s.now = func() time.Time { return now().Add(-s.idTokensValidFor - 2*idTokenHintGracePeriod) }
+
+	idToken, _, err := s.newIDToken("foo", claims, []string{"openid"}, "", "", "mock", "", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+	return idToken
+}
+
+func TestHandleLogoutRevokesRefreshTokens(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.RevokeRefreshTokensOnLogout = true
+	})
+	defer httpServer.Close()
+
+	if err := s.storage.CreateClient(storage.Client{ID: "foo", Secret: "secret"}); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	claims := storage.Claims{UserID: "user", Username: "jane"}
+	refresh := storage.RefreshToken{
+		ID:          storage.NewID(),
+		Token:       storage.NewID(),
+		ClientID:    "foo",
+		ConnectorID: "mock",
+		Scopes:      []string{"openid", "offline_access"},
+		Claims:      claims,
+		CreatedAt:   s.now(),
+		LastUsed:    s.now(),
+	}
+	if err := s.storage.CreateRefresh(refresh); err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+	if err := s.storage.CreateOfflineSessions(storage.OfflineSessions{
+		UserID:  "user",
+		ConnID:  "mock",
+		Refresh: map[string]*storage.RefreshTokenRef{"foo": {ID: refresh.ID, ClientID: "foo"}},
+	}); err != nil {
+		t.Fatalf("failed to create offline session: %v", err)
+	}
+
+	idToken, _, err := s.newIDToken("foo", claims, []string{"openid"}, "", "", "mock", "", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+
+	// A confirmed logout with an expired hint doesn't revoke anything.
+	token := "logout-token"
+	form := url.Values{"id_token_hint": {newExpiredIDToken(t, s, claims)}, "logout_token": {token}}
+	req := httptest.NewRequest("POST", "/logout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: logoutCookieName, Value: token})
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := s.storage.GetRefresh(refresh.ID); err != nil {
+		t.Fatalf("expected refresh token to be kept, got error %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/logout?"+url.Values{"id_token_hint": {idToken}}.Encode(), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if _, err := s.storage.GetRefresh(refresh.ID); err != storage.ErrNotFound {
+		t.Errorf("expected refresh token to be revoked, got error %v", err)
+	}
+	if _, err := s.storage.GetOfflineSessions("user", "mock"); err != storage.ErrNotFound {
+		t.Errorf("expected offline session to be deleted, got error %v", err)
+	}
+}
+
+func TestHandleLogoutCallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	client := storage.Client{
+		ID:                     "foo",
+		Secret:                 "secret",
+		PostLogoutRedirectURIs: []string{"https://example.com/logged-out"},
+	}
+	if err := s.storage.CreateClient(client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		params       url.Values
+		expectedCode int
+	}{
+		{"no state", url.Values{}, http.StatusOK},
+		{"oidc state", url.Values{"state": {"https://example.com/logged-out?state=xyz"}}, http.StatusFound},
+		{"saml relay state", url.Values{"RelayState": {"https://example.com/logged-out"}}, http.StatusFound},
+		{"unregistered state", url.Values{"state": {"https://evil.example.com"}}, http.StatusBadRequest},
+		{"extra query parameters", url.Values{"state": {"https://example.com/logged-out?foo=bar"}}, http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, httptest.NewRequest("GET", logoutCallbackURI+"?"+tc.params.Encode(), nil))
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
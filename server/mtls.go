@@ -0,0 +1,98 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// clientCertificate returns the TLS client certificate of the request, or nil if
+// the client didn't present one.
+func clientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return r.TLS.PeerCertificates[0]
+}
+
+// certificateThumbprint returns the base64url encoded SHA-256 hash of the DER
+// encoding of the certificate.
+//
+// https://tools.ietf.org/html/rfc8705#section-3.1
+func certificateThumbprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// verifyClientCertificate authenticates a client by the TLS client certificate
+// of the request. Certificates registered with the client by their thumbprint
+// are self-signed, others must be issued by one of the configured CAs to the
+// subject DN of the client. It returns the authentication method.
+//
+// https://tools.ietf.org/html/rfc8705#section-2
+func (s *Server) verifyClientCertificate(client storage.Client, r *http.Request) (string, error) {
+	cert := clientCertificate(r)
+	if cert == nil {
+		return "", errors.New("no client certificate")
+	}
+	now := s.now()
+
+	thumbprint := certificateThumbprint(cert)
+	for _, registered := range client.TLSClientCertThumbprints {
+		if subtle.ConstantTimeCompare([]byte(registered), []byte(thumbprint)) != 1 {
+			continue
+		}
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			return "", errors.New("client certificate expired or not valid yet")
+		}
+		return authMethodSelfSignedTLSClientAuth, nil
+	}
+
+	if client.TLSClientAuthSubjectDN == "" {
+		return "", errors.New("client certificate not registered with the client")
+	}
+	if s.tlsClientCAs == nil {
+		return "", errors.New("no CAs configured to verify client certificates")
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         s.tlsClientCAs,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to verify client certificate: %v", err)
+	}
+	if subject := cert.Subject.String(); subject != client.TLSClientAuthSubjectDN {
+		return "", fmt.Errorf("unexpected client certificate subject %q", subject)
+	}
+	return authMethodTLSClientAuth, nil
+}
+
+// checkCertificateBinding verifies an access token bound to a client certificate
+// is presented over a connection authenticated with that certificate. On
+// failure the error has already been written to w.
+//
+// https://tools.ietf.org/html/rfc8705#section-3
+func (s *Server) checkCertificateBinding(w http.ResponseWriter, r *http.Request, cnf storage.Confirmation) bool {
+	if cnf.X5TS256 == "" {
+		return true
+	}
+	cert := clientCertificate(r)
+	if cert == nil || subtle.ConstantTimeCompare([]byte(certificateThumbprint(cert)), []byte(cnf.X5TS256)) != 1 {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="%s"`, errInvalidToken))
+		s.tokenErrHelper(w, errInvalidToken, "Access token is not bound to the client certificate.", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestTLSClientAuth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	serial := int64(0)
+	newCert := func(subject pkix.Name, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		serial++
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               subject,
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+		if err != nil {
+			t.Fatalf("failed to create certificate: %v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+
+	ca, caKey := newCert(pkix.Name{CommonName: "ca"}, true, nil, nil)
+	issued, _ := newCert(pkix.Name{CommonName: "service", Organization: []string{"example"}}, false, ca, caKey)
+	selfSigned, _ := newCert(pkix.Name{CommonName: "self-signed"}, false, nil, nil)
+	unknown, _ := newCert(pkix.Name{CommonName: "service", Organization: []string{"example"}}, false, nil, nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.TLSClientAuth = true
+		c.TLSClientCAs = pool
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{
+			{
+				ID:                       "pki",
+				TLSClientAuthSubjectDN:   "CN=service,O=example",
+				TokenEndpointAuthMethods: []string{authMethodTLSClientAuth},
+			},
+			{
+				ID:                       "other-dn",
+				TLSClientAuthSubjectDN:   "CN=other,O=example",
+				TokenEndpointAuthMethods: []string{authMethodTLSClientAuth},
+			},
+			{
+				ID:                       "self-signed",
+				TLSClientCertThumbprints: []string{certificateThumbprint(selfSigned)},
+				TokenEndpointAuthMethods: []string{authMethodSelfSignedTLSClientAuth},
+			},
+			{
+				ID:     "secret",
+				Secret: "secret-secret",
+			},
+		})
+	})
+	defer httpServer.Close()
+
+	requestToken := func(clientID string, certs ...*x509.Certificate) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {grantTypeClientCredentials}, "client_id": {clientID}}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if len(certs) > 0 {
+			req.TLS = &tls.ConnectionState{PeerCertificates: certs}
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rejected := []struct {
+		name     string
+		clientID string
+		certs    []*x509.Certificate
+	}{
+		{"no certificate", "pki", nil},
+		{"certificate of another subject", "other-dn", []*x509.Certificate{issued}},
+		{"certificate of unknown issuer", "pki", []*x509.Certificate{unknown}},
+		{"unregistered self-signed certificate", "self-signed", []*x509.Certificate{unknown}},
+		{"disallowed method", "self-signed", []*x509.Certificate{issued}},
+		{"certificate without secret", "secret", []*x509.Certificate{issued}},
+	}
+	for _, tc := range rejected {
+		if rr := requestToken(tc.clientID, tc.certs...); rr.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401 got %d: %s", tc.name, rr.Code, rr.Body.String())
+		}
+	}
+
+	accessToken := func(clientID string, cert *x509.Certificate) string {
+		rr := requestToken(clientID, cert)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 got %d: %s", clientID, rr.Code, rr.Body.String())
+		}
+		var resp accessTokenResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode token response: %v", err)
+		}
+		if resp.TokenType != "bearer" {
+			t.Errorf("%s: expected bearer token got %q", clientID, resp.TokenType)
+		}
+		jws, err := jose.ParseSigned(resp.AccessToken)
+		if err != nil {
+			t.Fatalf("failed to parse access token: %v", err)
+		}
+		var claims accessTokenClaims
+		if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+			t.Fatalf("failed to decode access token claims: %v", err)
+		}
+		if claims.Confirmation == nil || claims.Confirmation.X5TS256 != certificateThumbprint(cert) {
+			t.Errorf("%s: expected access token bound to %q, got %#v", clientID, certificateThumbprint(cert), claims.Confirmation)
+		}
+		return resp.AccessToken
+	}
+	token := accessToken("pki", issued)
+	accessToken("self-signed", selfSigned)
+
+	// Bound access tokens can only be used over a connection authenticated
+	// with the same certificate.
+	userInfoTests := []struct {
+		name     string
+		certs    []*x509.Certificate
+		wantCode int
+	}{
+		{"no certificate", nil, http.StatusUnauthorized},
+		{"other certificate", []*x509.Certificate{selfSigned}, http.StatusUnauthorized},
+		{"bound certificate", []*x509.Certificate{issued}, http.StatusOK},
+	}
+	for _, tc := range userInfoTests {
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if len(tc.certs) > 0 {
+			req.TLS = &tls.ConnectionState{PeerCertificates: tc.certs}
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		if rr.Code != tc.wantCode {
+			t.Errorf("userinfo %s: expected %d got %d: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+		}
+	}
+}
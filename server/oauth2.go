@@ -20,6 +20,7 @@ import (
 	"strings"
 	"time"
 
+	"golang.org/x/crypto/ed25519"
 	jose "gopkg.in/square/go-jose.v2"
 
 	"github.com/dexidp/dex/connector"
@@ -102,6 +103,23 @@ const (
 	errInvalidGrant            = "invalid_grant"
 	errInvalidClient           = "invalid_client"
 	errInvalidConnectorID      = "invalid_connector_id"
+	errUnsupportedTokenType    = "unsupported_token_type"
+	errInvalidTarget           = "invalid_target"
+	errInvalidToken            = "invalid_token"
+	errInvalidDPoPProof        = "invalid_dpop_proof"
+
+	// Request object errors, https://tools.ietf.org/html/rfc9101#section-6.3
+	errInvalidRequestObject = "invalid_request_object"
+	errInvalidRequestURI    = "invalid_request_uri"
+
+	// Dynamic client registration errors, https://tools.ietf.org/html/rfc7591#section-3.2.2
+	errInvalidRedirectURI    = "invalid_redirect_uri"
+	errInvalidClientMetadata = "invalid_client_metadata"
+
+	// Device authorization grant errors, https://tools.ietf.org/html/rfc8628#section-3.5
+	errAuthorizationPending = "authorization_pending"
+	errSlowDown             = "slow_down"
+	errExpiredToken         = "expired_token"
 )
 
 const (
@@ -122,6 +140,24 @@ const (
 	grantTypeAuthorizationCode = "authorization_code"
 	grantTypeRefreshToken      = "refresh_token"
 	grantTypePassword          = "password"
+	grantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	grantTypeClientCredentials = "client_credentials"
+	grantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// Token type identifiers, https://tools.ietf.org/html/rfc8693#section-3
+const (
+	tokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	tokenTypeIDToken     = "urn:ietf:params:oauth:token-type:id_token"
+)
+
+// Formats of the access tokens issued by the server.
+const (
+	accessTokenFormatJWT    = "jwt"    // RFC 9068 JWT access tokens.
+	accessTokenFormatOpaque = "opaque" // Random tokens resolved through storage.
+
+	// Media type of the "typ" header of JWT access tokens.
+	accessTokenJWTType = "at+jwt"
 )
 
 const (
@@ -130,6 +166,11 @@ const (
 	responseTypeIDToken = "id_token" // ID Token in url fragment
 )
 
+const (
+	codeChallengeMethodPlain = "plain"
+	codeChallengeMethodS256  = "S256"
+)
+
 func parseScopes(scopes []string) connector.Scopes {
 	var s connector.Scopes
 	for _, scope := range scopes {
@@ -157,9 +198,6 @@ func signatureAlgorithm(jwk *jose.JSONWebKey) (alg jose.SignatureAlgorithm, err
 		// See https://github.com/dexidp/dex/issues/692
 		return jose.RS256, nil
 	case *ecdsa.PrivateKey:
-		// We don't actually support ECDSA keys yet, but they're tested for
-		// in case we want to in the future.
-		//
 		// These values are prescribed depending on the ECDSA key type. We
 		// can't return different values.
 		switch key.Params() {
@@ -172,15 +210,20 @@ func signatureAlgorithm(jwk *jose.JSONWebKey) (alg jose.SignatureAlgorithm, err
 		default:
 			return alg, errors.New("unsupported ecdsa curve")
 		}
+	case ed25519.PrivateKey:
+		return jose.EdDSA, nil
 	default:
 		return alg, fmt.Errorf("unsupported signing key type %T", key)
 	}
 }
 
-func signPayload(key *jose.JSONWebKey, alg jose.SignatureAlgorithm, payload []byte) (jws string, err error) {
+func signPayload(key *jose.JSONWebKey, alg jose.SignatureAlgorithm, payload []byte, opts *jose.SignerOptions) (jws string, err error) {
 	signingKey := jose.SigningKey{Key: key, Algorithm: alg}
 
-	signer, err := jose.NewSigner(signingKey, &jose.SignerOptions{})
+	if opts == nil {
+		opts = &jose.SignerOptions{}
+	}
+	signer, err := jose.NewSigner(signingKey, opts)
 	if err != nil {
 		return "", fmt.Errorf("new signier: %v", err)
 	}
@@ -206,6 +249,9 @@ var hashForSigAlg = map[jose.SignatureAlgorithm]func() hash.Hash{
 	jose.ES256: sha256.New,
 	jose.ES384: sha512.New384,
 	jose.ES512: sha512.New,
+	// EdDSA isn't covered by the spec. Ed25519 is the only curve dex supports
+	// and it uses SHA-512 internally.
+	jose.EdDSA: sha512.New,
 }
 
 // Compute an at_hash from a raw access token and a signature algorithm
@@ -225,6 +271,32 @@ func accessTokenHash(alg jose.SignatureAlgorithm, accessToken string) (string, e
 	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
 }
 
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAllows reports whether one of a client's allow-lists permits value.
+// Empty allow-lists don't restrict the client.
+func clientAllows(allowed []string, value string) bool {
+	return len(allowed) == 0 || contains(allowed, value)
+}
+
+// disallowedScopes returns the scopes the client isn't allowed to request.
+func disallowedScopes(client storage.Client, scopes []string) []string {
+	var disallowed []string
+	for _, scope := range scopes {
+		if !clientAllows(client.AllowedScopes, scope) {
+			disallowed = append(disallowed, scope)
+		}
+	}
+	return disallowed
+}
+
 type audience []string
 
 func (a audience) contains(aud string) bool {
@@ -243,6 +315,20 @@ func (a audience) MarshalJSON() ([]byte, error) {
 	return json.Marshal([]string(a))
 }
 
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var auds []string
+	if err := json.Unmarshal(b, &auds); err != nil {
+		return err
+	}
+	*a = audience(auds)
+	return nil
+}
+
 type idTokenClaims struct {
 	Issuer           string   `json:"iss"`
 	Subject          string   `json:"sub"`
@@ -251,9 +337,16 @@ type idTokenClaims struct {
 	IssuedAt         int64    `json:"iat"`
 	AuthorizingParty string   `json:"azp,omitempty"`
 	Nonce            string   `json:"nonce,omitempty"`
+	AuthTime         int64    `json:"auth_time,omitempty"`
 
 	AccessTokenHash string `json:"at_hash,omitempty"`
 
+	identityClaims
+}
+
+// identityClaims are the claims about the user shared by ID tokens, JWT access
+// tokens and userinfo responses. Which ones are set depends on the scopes.
+type identityClaims struct {
 	Email         string `json:"email,omitempty"`
 	EmailVerified *bool  `json:"email_verified,omitempty"`
 
@@ -270,47 +363,162 @@ type federatedIDClaims struct {
 	UserID      string `json:"user_id,omitempty"`
 }
 
-func (s *Server) newAccessToken(clientID string, claims storage.Claims, scopes []string, nonce, connID string) (accessToken string, err error) {
-	idToken, _, err := s.newIDToken(clientID, claims, scopes, nonce, storage.NewID(), connID)
-	return idToken, err
+// newIdentityClaims returns the claims about the user the scopes grant access to.
+func newIdentityClaims(claims storage.Claims, scopes []string, connID string) identityClaims {
+	var tok identityClaims
+	for _, scope := range scopes {
+		switch scope {
+		case scopeEmail:
+			tok.Email = claims.Email
+			tok.EmailVerified = &claims.EmailVerified
+		case scopeGroups:
+			tok.Groups = claims.Groups
+		case scopeProfile:
+			tok.Name = claims.Username
+			tok.PreferredUsername = claims.PreferredUsername
+		case scopeFederatedID:
+			tok.FederatedIDClaims = &federatedIDClaims{
+				ConnectorID: connID,
+				UserID:      claims.UserID,
+			}
+		}
+	}
+	return tok
 }
 
-func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []string, nonce, accessToken, connID string) (idToken string, expiry time.Time, err error) {
-	keys, err := s.storage.GetKeys()
-	if err != nil {
-		s.logger.Errorf("Failed to get keys: %v", err)
-		return "", expiry, err
+// peerAudience returns the peers the client requested tokens for through
+// cross-client scopes.
+func (s *Server) peerAudience(clientID string, scopes []string) (audience, error) {
+	var aud audience
+	for _, scope := range scopes {
+		peerID, ok := parseCrossClientScope(scope)
+		if !ok {
+			// Ignore unknown scopes. These are already validated during the
+			// initial auth request.
+			continue
+		}
+		isTrusted, err := s.validateCrossClientTrust(clientID, peerID)
+		if err != nil {
+			return nil, err
+		}
+		if !isTrusted {
+			// TODO(ericchiang): propagate this error to the client.
+			return nil, fmt.Errorf("peer (%s) does not trust client", peerID)
+		}
+		aud = append(aud, peerID)
 	}
+	return aud, nil
+}
+
+// reservedClaims can't be used as custom claims, since they're either set by the
+// server or would be confused with registered claims.
+var reservedClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true,
+	"jti": true, "azp": true, "nonce": true, "at_hash": true, "c_hash": true,
+	"auth_time": true, "acr": true, "amr": true, "sid": true, "client_id": true,
+	"scope": true, "cnf": true, "email": true, "email_verified": true, "groups": true,
+	"name": true, "preferred_username": true, "federated_claims": true,
+}
 
-	signingKey := keys.SigningKey
-	if signingKey == nil {
-		return "", expiry, fmt.Errorf("no key to sign payload with")
+// validateCustomScopes checks the custom scopes don't shadow built-in scopes or
+// claims.
+func validateCustomScopes(customScopes map[string][]string) error {
+	for scope, claims := range customScopes {
+		switch scope {
+		case "", scopeOpenID, scopeOfflineAccess, scopeEmail, scopeProfile, scopeGroups, scopeFederatedID:
+			return fmt.Errorf("custom scope %q is reserved", scope)
+		}
+		if strings.HasPrefix(scope, scopeCrossClientPrefix) {
+			return fmt.Errorf("custom scope %q is reserved", scope)
+		}
+		for _, claim := range claims {
+			if claim == "" || reservedClaims[claim] {
+				return fmt.Errorf("custom scope %q: claim %q is reserved", scope, claim)
+			}
+		}
 	}
-	signingAlg, err := signatureAlgorithm(signingKey)
-	if err != nil {
-		return "", expiry, err
+	return nil
+}
+
+func (s *Server) isCustomScope(scope string) bool {
+	_, ok := s.customScopes[scope]
+	return ok
+}
+
+// customClaims returns the custom claims of the user the scopes grant access to.
+func (s *Server) customClaims(claims map[string]interface{}, scopes []string) map[string]interface{} {
+	var custom map[string]interface{}
+	for _, scope := range scopes {
+		for _, name := range s.customScopes[scope] {
+			value, ok := claims[name]
+			if !ok {
+				continue
+			}
+			if custom == nil {
+				custom = make(map[string]interface{})
+			}
+			custom[name] = value
+		}
 	}
+	return custom
+}
 
-	issuedAt := s.now()
-	expiry = issuedAt.Add(s.idTokensValidFor)
+// marshalClaims serializes the claims of a token or userinfo response along
+// with the custom claims, which never override the claims of v.
+func marshalClaims(v interface{}, custom map[string]interface{}) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil || len(custom) == 0 {
+		return payload, err
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+	for name, value := range custom {
+		if _, ok := claims[name]; !ok {
+			claims[name] = value
+		}
+	}
+	return json.Marshal(claims)
+}
 
+func newSubject(claims storage.Claims, connID string) (string, error) {
 	sub := &internal.IDTokenSubject{
 		UserId: claims.UserID,
 		ConnId: connID,
 	}
+	return internal.Marshal(sub)
+}
 
-	subjectString, err := internal.Marshal(sub)
+// newIDToken issues an ID token. authTime is the time the user authenticated,
+// it's omitted from the token if zero.
+func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []string, nonce, accessToken, connID, claimsRequest string, authTime time.Time) (idToken string, expiry time.Time, err error) {
+	keys, signingAlg, err := s.signingKeys()
+	if err != nil {
+		s.logger.Errorf("Failed to get keys: %v", err)
+		return "", expiry, err
+	}
+
+	idTokensValidFor, _ := s.tokensValidFor(clientID)
+	issuedAt := s.now()
+	expiry = issuedAt.Add(idTokensValidFor)
+
+	subjectString, err := newSubject(claims, connID)
 	if err != nil {
 		s.logger.Errorf("failed to marshal offline session ID: %v", err)
 		return "", expiry, fmt.Errorf("failed to marshal offline session ID: %v", err)
 	}
 
 	tok := idTokenClaims{
-		Issuer:   s.issuerURL.String(),
-		Subject:  subjectString,
-		Nonce:    nonce,
-		Expiry:   expiry.Unix(),
-		IssuedAt: issuedAt.Unix(),
+		Issuer:         s.issuerURL.String(),
+		Subject:        subjectString,
+		Nonce:          nonce,
+		Expiry:         expiry.Unix(),
+		IssuedAt:       issuedAt.Unix(),
+		identityClaims: newIdentityClaims(claims, scopes, connID),
+	}
+	if !authTime.IsZero() {
+		tok.AuthTime = authTime.Unix()
 	}
 
 	if accessToken != "" {
@@ -322,38 +530,8 @@ func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []str
 		tok.AccessTokenHash = atHash
 	}
 
-	for _, scope := range scopes {
-		switch {
-		case scope == scopeEmail:
-			tok.Email = claims.Email
-			tok.EmailVerified = &claims.EmailVerified
-		case scope == scopeGroups:
-			tok.Groups = claims.Groups
-		case scope == scopeProfile:
-			tok.Name = claims.Username
-			tok.PreferredUsername = claims.PreferredUsername
-		case scope == scopeFederatedID:
-			tok.FederatedIDClaims = &federatedIDClaims{
-				ConnectorID: connID,
-				UserID:      claims.UserID,
-			}
-		default:
-			peerID, ok := parseCrossClientScope(scope)
-			if !ok {
-				// Ignore unknown scopes. These are already validated during the
-				// initial auth request.
-				continue
-			}
-			isTrusted, err := s.validateCrossClientTrust(clientID, peerID)
-			if err != nil {
-				return "", expiry, err
-			}
-			if !isTrusted {
-				// TODO(ericchiang): propagate this error to the client.
-				return "", expiry, fmt.Errorf("peer (%s) does not trust client", peerID)
-			}
-			tok.Audience = append(tok.Audience, peerID)
-		}
+	if tok.Audience, err = s.peerAudience(clientID, scopes); err != nil {
+		return "", expiry, err
 	}
 
 	if len(tok.Audience) == 0 {
@@ -371,23 +549,167 @@ func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []str
 		tok.AuthorizingParty = clientID
 	}
 
-	payload, err := json.Marshal(tok)
+	extra, err := s.extraClaims(clientID, claims, scopes, connID, claimsRequest, claimsRequestIDToken)
+	if err != nil {
+		return "", expiry, err
+	}
+	payload, err := marshalClaims(tok, extra)
 	if err != nil {
 		return "", expiry, fmt.Errorf("could not serialize claims: %v", err)
 	}
 
-	if idToken, err = signPayload(signingKey, signingAlg, payload); err != nil {
+	if idToken, err = s.signer.Sign(keys, payload, nil); err != nil {
 		return "", expiry, fmt.Errorf("failed to sign payload: %v", err)
 	}
 	return idToken, expiry, nil
 }
 
+// accessTokenClaims are the claims of a JWT access token.
+//
+// See: https://tools.ietf.org/html/rfc9068#section-2.2
+type accessTokenClaims struct {
+	Issuer   string   `json:"iss"`
+	Subject  string   `json:"sub"`
+	Audience audience `json:"aud"`
+	Expiry   int64    `json:"exp"`
+	IssuedAt int64    `json:"iat"`
+	JWTID    string   `json:"jti"`
+	ClientID string   `json:"client_id"`
+	Scope    string   `json:"scope,omitempty"`
+
+	Confirmation *storage.Confirmation `json:"cnf,omitempty"`
+
+	identityClaims
+}
+
+// newAccessToken issues an access token for the user to the client.
+func (s *Server) newAccessToken(clientID string, claims storage.Claims, scopes []string, connID, claimsRequest string, cnf storage.Confirmation) (accessToken string, expiry time.Time, err error) {
+	subject, err := newSubject(claims, connID)
+	if err != nil {
+		return "", expiry, fmt.Errorf("failed to marshal subject: %v", err)
+	}
+	aud, err := s.peerAudience(clientID, scopes)
+	if err != nil {
+		return "", expiry, err
+	}
+	if !aud.contains(clientID) {
+		aud = append(aud, clientID)
+	}
+
+	_, accessTokensValidFor := s.tokensValidFor(clientID)
+	issuedAt := s.now()
+	token := storage.AccessToken{
+		ID:          storage.NewID(),
+		ClientID:    clientID,
+		Subject:     subject,
+		Audience:    aud,
+		Scopes:      scopes,
+		ConnectorID: connID,
+		Claims:      claims,
+		CreatedAt:   issuedAt,
+		Expiry:      issuedAt.Add(accessTokensValidFor),
+
+		ClaimsRequest: claimsRequest,
+		Confirmation:  cnf,
+	}
+	return s.issueAccessToken(token, newIdentityClaims(claims, scopes, connID))
+}
+
+// newClientCredentialsToken issues an access token the client requested for
+// itself using the client credentials grant.
+func (s *Server) newClientCredentialsToken(clientID string, scopes, audiences []string, cnf storage.Confirmation) (token string, expiry time.Time, err error) {
+	if len(audiences) == 0 {
+		audiences = []string{clientID}
+	}
+	_, accessTokensValidFor := s.tokensValidFor(clientID)
+	issuedAt := s.now()
+	return s.issueAccessToken(storage.AccessToken{
+		ID:        storage.NewID(),
+		ClientID:  clientID,
+		Subject:   clientID,
+		Audience:  audiences,
+		Scopes:    scopes,
+		CreatedAt: issuedAt,
+		Expiry:    issuedAt.Add(accessTokensValidFor),
+
+		Confirmation: cnf,
+	}, identityClaims{})
+}
+
+// issueAccessToken returns the access token in the configured format. Opaque
+// tokens are persisted so they can be resolved later, JWTs are self-contained.
+// isAccessTokenJWT reports whether a JWT was issued as an access token rather
+// than an ID token, which is told by its "typ" header.
+func isAccessTokenJWT(jws *jose.JSONWebSignature) bool {
+	if len(jws.Signatures) != 1 {
+		return false
+	}
+	typ, _ := jws.Signatures[0].Protected.ExtraHeaders[jose.HeaderType].(string)
+	return typ == accessTokenJWTType
+}
+
+func (s *Server) issueAccessToken(t storage.AccessToken, identity identityClaims) (accessToken string, expiry time.Time, err error) {
+	if s.accessTokenFormat == accessTokenFormatOpaque {
+		if err := s.storage.CreateAccessToken(t); err != nil {
+			return "", t.Expiry, fmt.Errorf("failed to create access token: %v", err)
+		}
+		return t.ID, t.Expiry, nil
+	}
+
+	keys, _, err := s.signingKeys()
+	if err != nil {
+		s.logger.Errorf("Failed to get keys: %v", err)
+		return "", t.Expiry, err
+	}
+
+	tok := accessTokenClaims{
+		Issuer:         s.issuerURL.String(),
+		Subject:        t.Subject,
+		Audience:       t.Audience,
+		Expiry:         t.Expiry.Unix(),
+		IssuedAt:       t.CreatedAt.Unix(),
+		JWTID:          t.ID,
+		ClientID:       t.ClientID,
+		Scope:          strings.Join(t.Scopes, " "),
+		Confirmation:   confirmationClaim(t.Confirmation),
+		identityClaims: identity,
+	}
+
+	// JWT access tokens carry the claims returned by the userinfo endpoint.
+	extra, err := s.extraClaims(t.ClientID, t.Claims, t.Scopes, t.ConnectorID, t.ClaimsRequest, claimsRequestUserInfo)
+	if err != nil {
+		return "", t.Expiry, err
+	}
+	payload, err := marshalClaims(tok, extra)
+	if err != nil {
+		return "", t.Expiry, fmt.Errorf("could not serialize claims: %v", err)
+	}
+
+	opts := (&jose.SignerOptions{}).WithType(accessTokenJWTType)
+	if accessToken, err = s.signer.Sign(keys, payload, opts); err != nil {
+		return "", t.Expiry, fmt.Errorf("failed to sign payload: %v", err)
+	}
+	return accessToken, t.Expiry, nil
+}
+
 // parse the initial request from the OAuth2 client.
 func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthRequest, error) {
 	if err := r.ParseForm(); err != nil {
 		return nil, &authErr{"", "", errInvalidRequest, "Failed to parse request body."}
 	}
 	q := r.Form
+	if strings.HasPrefix(q.Get("request_uri"), requestURIPrefix) {
+		return s.redeemPushedAuthRequest(q)
+	}
+	return s.parseAuthorizationParams(r.Context(), q, false)
+}
+
+// parseAuthorizationParams validates the parameters of an authorization request.
+// pushed is set when they're pushed to the pushed authorization request endpoint.
+func (s *Server) parseAuthorizationParams(ctx context.Context, q url.Values, pushed bool) (*storage.AuthRequest, error) {
+	if err := s.resolveRequestObject(ctx, q); err != nil {
+		return nil, err
+	}
 	redirectURI, err := url.QueryUnescape(q.Get("redirect_uri"))
 	if err != nil {
 		return nil, &authErr{"", "", errInvalidRequest, "No redirect_uri provided."}
@@ -396,7 +718,10 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 	clientID := q.Get("client_id")
 	state := q.Get("state")
 	nonce := q.Get("nonce")
+	claims := q.Get("claims")
 	connectorID := q.Get("connector_id")
+	codeChallenge := q.Get("code_challenge")
+	codeChallengeMethod := q.Get("code_challenge_method")
 	// Some clients, like the old go-oidc, provide extra whitespace. Tolerate this.
 	scopes := strings.Fields(q.Get("scope"))
 	responseTypes := strings.Fields(q.Get("response_type"))
@@ -410,6 +735,9 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 		s.logger.Errorf("Failed to get client: %v", err)
 		return nil, &authErr{"", "", errServerError, ""}
 	}
+	if client.RequirePushedAuthRequests && !pushed {
+		return nil, &authErr{"", "", errInvalidRequest, "Client requires pushed authorization requests."}
+	}
 
 	if connectorID != "" {
 		connectors, err := s.storage.ListConnectors()
@@ -419,9 +747,14 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 		if !validateConnectorID(connectors, connectorID) {
 			return nil, &authErr{"", "", errInvalidRequest, "Invalid ConnectorID"}
 		}
+		if !clientAllows(client.AllowedConnectors, connectorID) {
+			return nil, &authErr{"", "", errInvalidRequest, "Connector not allowed for this client"}
+		}
 	}
 
-	if !validateRedirectURI(client, redirectURI) {
+	// The device flow redirects to dex itself rather than to a registered
+	// client redirect URI.
+	if redirectURI != s.absURL(deviceCallbackURI) && !validateRedirectURI(client, redirectURI) {
 		description := fmt.Sprintf("Unregistered redirect_uri (%q).", redirectURI)
 		return nil, &authErr{"", "", errInvalidRequest, description}
 	}
@@ -442,6 +775,9 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 			hasOpenIDScope = true
 		case scopeOfflineAccess, scopeEmail, scopeProfile, scopeGroups, scopeFederatedID:
 		default:
+			if s.isCustomScope(scope) {
+				continue
+			}
 			peerID, ok := parseCrossClientScope(scope)
 			if !ok {
 				unrecognized = append(unrecognized, scope)
@@ -466,6 +802,9 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 	if len(invalidScopes) > 0 {
 		return nil, newErr("invalid_scope", "Client can't request scope(s) %q", invalidScopes)
 	}
+	if disallowed := disallowedScopes(client, scopes); len(disallowed) > 0 {
+		return nil, newErr(errInvalidScope, "Client can't request scope(s) %q", disallowed)
+	}
 
 	var rt struct {
 		code    bool
@@ -488,12 +827,38 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 		if !s.supportedResponseTypes[responseType] {
 			return nil, newErr(errUnsupportedResponseType, "Unsupported response type %q", responseType)
 		}
+		if !clientAllows(client.AllowedResponseTypes, responseType) {
+			return nil, newErr(errUnauthorizedClient, "Client can't use response type %q", responseType)
+		}
+	}
+	// Access tokens returned from the authorization endpoint can't be bound
+	// with DPoP.
+	if rt.token && client.RequireDPoP {
+		return nil, newErr(errUnauthorizedClient, "Client requiring DPoP can't use response type %q", responseTypeToken)
 	}
 
 	if len(responseTypes) == 0 {
 		return nil, newErr("invalid_requests", "No response_type provided")
 	}
 
+	// PKCE is only meaningful for flows which return a code. The challenge method
+	// defaults to "plain" if the client doesn't provide one.
+	//
+	// https://tools.ietf.org/html/rfc7636#section-4.3
+	if codeChallenge != "" {
+		if !rt.code {
+			return nil, newErr(errInvalidRequest, "PKCE can only be used with response type 'code'.")
+		}
+		if codeChallengeMethod == "" {
+			codeChallengeMethod = codeChallengeMethodPlain
+		}
+		if codeChallengeMethod != codeChallengeMethodPlain && codeChallengeMethod != codeChallengeMethodS256 {
+			return nil, newErr(errInvalidRequest, "Unsupported PKCE challenge method (%q).", codeChallengeMethod)
+		}
+	} else if codeChallengeMethod != "" {
+		return nil, newErr(errInvalidRequest, "Missing code_challenge for code_challenge_method %q.", codeChallengeMethod)
+	}
+
 	if rt.token && !rt.code && !rt.idToken {
 		// "token" can't be provided by its own.
 		//
@@ -516,19 +881,60 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 		}
 	}
 
+	if _, err := parseClaimsRequest(claims); err != nil {
+		return nil, newErr(errInvalidRequest, "Invalid claims parameter.")
+	}
+
+	// A max_age of zero requires the user to log in again, like prompt=login.
+	//
+	// https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+	prompt, maxAge := q.Get("prompt"), 0
+	if v := q.Get("max_age"); v != "" {
+		var err error
+		if maxAge, err = strconv.Atoi(v); err != nil || maxAge < 0 {
+			return nil, newErr(errInvalidRequest, "Invalid max_age value %q.", v)
+		}
+		if maxAge == 0 {
+			prompt = strings.TrimSpace(prompt + " login")
+		}
+	}
+
 	return &storage.AuthRequest{
 		ID:                  storage.NewID(),
 		ClientID:            client.ID,
 		State:               state,
 		Nonce:               nonce,
 		ForceApprovalPrompt: q.Get("approval_prompt") == "force",
+		Prompt:              prompt,
+		MaxAge:              maxAge,
 		Scopes:              scopes,
 		RedirectURI:         redirectURI,
 		ResponseTypes:       responseTypes,
 		ConnectorID:         connectorID,
+		PKCE: storage.PKCE{
+			CodeChallenge:       codeChallenge,
+			CodeChallengeMethod: codeChallengeMethod,
+		},
+		ClaimsRequest: claims,
 	}, nil
 }
 
+// calculateCodeChallenge derives the code challenge from a PKCE code verifier
+// using the method the client registered during the authorization request.
+//
+// https://tools.ietf.org/html/rfc7636#section-4.2
+func calculateCodeChallenge(codeVerifier, codeChallengeMethod string) (string, error) {
+	switch codeChallengeMethod {
+	case codeChallengeMethodPlain:
+		return codeVerifier, nil
+	case codeChallengeMethodS256:
+		shaSum := sha256.Sum256([]byte(codeVerifier))
+		return base64.RawURLEncoding.EncodeToString(shaSum[:]), nil
+	default:
+		return "", fmt.Errorf("unknown challenge method (%v)", codeChallengeMethod)
+	}
+}
+
 func parseCrossClientScope(scope string) (peerID string, ok bool) {
 	if ok = strings.HasPrefix(scope, scopeCrossClientPrefix); ok {
 		peerID = scope[len(scopeCrossClientPrefix):]
@@ -599,6 +1005,22 @@ type storageKeySet struct {
 	storage.Storage
 }
 
+// signingAlgorithms returns the algorithms of the keys tokens may currently be
+// signed with, starting with the active signing key's.
+func signingAlgorithms(keys storage.Keys) []string {
+	var algs []string
+	add := func(key *jose.JSONWebKey) {
+		if key != nil && key.Algorithm != "" && !contains(algs, key.Algorithm) {
+			algs = append(algs, key.Algorithm)
+		}
+	}
+	add(keys.SigningKeyPub)
+	for _, vk := range keys.VerificationKeys {
+		add(vk.PublicKey)
+	}
+	return algs
+}
+
 func (s *storageKeySet) VerifySignature(_ context.Context, jwt string) (payload []byte, err error) {
 	jws, err := jose.ParseSigned(jwt)
 	if err != nil {
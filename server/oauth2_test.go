@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"gopkg.in/square/go-jose.v2"
 
@@ -61,6 +62,24 @@ func TestParseAuthorizationRequest(t *testing.T) {
 			},
 			usePOST: true,
 		},
+		{
+			name: "invalid max_age",
+			clients: []storage.Client{
+				{
+					ID:           "foo",
+					RedirectURIs: []string{"https://example.com/foo"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":     "foo",
+				"redirect_uri":  "https://example.com/foo",
+				"response_type": "code",
+				"scope":         "openid email profile",
+				"max_age":       "-1",
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid client id",
 			clients: []storage.Client{
@@ -197,6 +216,209 @@ func TestParseAuthorizationRequest(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "PKCE code_challenge_method plain",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":             "bar",
+				"redirect_uri":          "https://example.com/bar",
+				"response_type":         "code",
+				"code_challenge":        "123",
+				"code_challenge_method": "plain",
+				"scope":                 "openid email profile",
+			},
+		},
+		{
+			name: "PKCE code_challenge_method default plain",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":      "bar",
+				"redirect_uri":   "https://example.com/bar",
+				"response_type":  "code",
+				"code_challenge": "123",
+				"scope":          "openid email profile",
+			},
+		},
+		{
+			name: "PKCE code_challenge_method S256",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":             "bar",
+				"redirect_uri":          "https://example.com/bar",
+				"response_type":         "code",
+				"code_challenge":        "123",
+				"code_challenge_method": "S256",
+				"scope":                 "openid email profile",
+			},
+		},
+		{
+			name: "PKCE invalid code_challenge_method",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":             "bar",
+				"redirect_uri":          "https://example.com/bar",
+				"response_type":         "code",
+				"code_challenge":        "123",
+				"code_challenge_method": "invalid_method",
+				"scope":                 "openid email profile",
+			},
+			wantErr: true,
+		},
+		{
+			name: "PKCE with implicit flow",
+			clients: []storage.Client{
+				{
+					ID:           "bar",
+					RedirectURIs: []string{"https://example.com/bar"},
+				},
+			},
+			supportedResponseTypes: []string{"id_token", "token"},
+			queryParams: map[string]string{
+				"client_id":             "bar",
+				"redirect_uri":          "https://example.com/bar",
+				"response_type":         "id_token token",
+				"nonce":                 "abc",
+				"code_challenge":        "123",
+				"code_challenge_method": "plain",
+				"scope":                 "openid email profile",
+			},
+			wantErr: true,
+		},
+		{
+			name: "claims request",
+			clients: []storage.Client{
+				{
+					ID:           "foo",
+					RedirectURIs: []string{"https://example.com/foo"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":     "foo",
+				"redirect_uri":  "https://example.com/foo",
+				"response_type": "code",
+				"scope":         "openid",
+				"claims":        `{"id_token":{"email":{"essential":true},"name":null}}`,
+			},
+		},
+		{
+			name: "malformed claims request",
+			clients: []storage.Client{
+				{
+					ID:           "foo",
+					RedirectURIs: []string{"https://example.com/foo"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":     "foo",
+				"redirect_uri":  "https://example.com/foo",
+				"response_type": "code",
+				"scope":         "openid",
+				"claims":        `{"id_token":["email"]}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "allowed by client policy",
+			clients: []storage.Client{
+				{
+					ID:                   "foo",
+					RedirectURIs:         []string{"https://example.com/foo"},
+					AllowedScopes:        []string{"openid", "email"},
+					AllowedResponseTypes: []string{"code"},
+					AllowedConnectors:    []string{"mock"},
+				},
+			},
+			supportedResponseTypes: []string{"code", "id_token"},
+			queryParams: map[string]string{
+				"client_id":     "foo",
+				"redirect_uri":  "https://example.com/foo",
+				"response_type": "code",
+				"scope":         "openid email",
+				"connector_id":  "mock",
+			},
+		},
+		{
+			name: "response type not allowed for client",
+			clients: []storage.Client{
+				{
+					ID:                   "foo",
+					RedirectURIs:         []string{"https://example.com/foo"},
+					AllowedResponseTypes: []string{"code"},
+				},
+			},
+			supportedResponseTypes: []string{"code", "id_token"},
+			queryParams: map[string]string{
+				"client_id":     "foo",
+				"redirect_uri":  "https://example.com/foo",
+				"response_type": "id_token",
+				"scope":         "openid",
+				"nonce":         "nonce",
+			},
+			wantErr: true,
+		},
+		{
+			name: "scope not allowed for client",
+			clients: []storage.Client{
+				{
+					ID:            "foo",
+					RedirectURIs:  []string{"https://example.com/foo"},
+					AllowedScopes: []string{"openid", "email"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":     "foo",
+				"redirect_uri":  "https://example.com/foo",
+				"response_type": "code",
+				"scope":         "openid email groups",
+			},
+			wantErr: true,
+		},
+		{
+			name: "connector not allowed for client",
+			clients: []storage.Client{
+				{
+					ID:                "foo",
+					RedirectURIs:      []string{"https://example.com/foo"},
+					AllowedConnectors: []string{"mock"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":     "foo",
+				"redirect_uri":  "https://example.com/foo",
+				"response_type": "code",
+				"scope":         "openid",
+				"connector_id":  "mock2",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -240,6 +462,48 @@ const (
 	googleSigningAlg      = jose.RS256
 )
 
+func TestClientTokenLifetimes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Now = func() time.Time { return now }
+		c.IDTokensValidFor = time.Hour
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{
+			{ID: "default"},
+			{ID: "dashboard", IDTokenValidFor: "10m", AccessTokenValidFor: "5m"},
+		})
+	})
+	defer httpServer.Close()
+
+	tests := []struct {
+		clientID         string
+		wantIDExpiry     time.Time
+		wantAccessExpiry time.Time
+	}{
+		{"default", now.Add(time.Hour), now.Add(time.Hour)},
+		{"dashboard", now.Add(10 * time.Minute), now.Add(5 * time.Minute)},
+	}
+	claims := storage.Claims{UserID: "1", Username: "jane"}
+	for _, tc := range tests {
+		_, idExpiry, err := s.newIDToken(tc.clientID, claims, []string{"openid"}, "", "", "mock", "", time.Time{})
+		if err != nil {
+			t.Fatalf("%s: failed to create ID token: %v", tc.clientID, err)
+		}
+		if !idExpiry.Equal(tc.wantIDExpiry) {
+			t.Errorf("%s: expected ID token to expire at %s, got %s", tc.clientID, tc.wantIDExpiry, idExpiry)
+		}
+		_, accessExpiry, err := s.newAccessToken(tc.clientID, claims, []string{"openid"}, "mock", "", storage.Confirmation{})
+		if err != nil {
+			t.Fatalf("%s: failed to create access token: %v", tc.clientID, err)
+		}
+		if !accessExpiry.Equal(tc.wantAccessExpiry) {
+			t.Errorf("%s: expected access token to expire at %s, got %s", tc.clientID, tc.wantAccessExpiry, accessExpiry)
+		}
+	}
+}
+
 func TestAccessTokenHash(t *testing.T) {
 	atHash, err := accessTokenHash(googleSigningAlg, googleAccessToken)
 	if err != nil {
@@ -397,3 +661,23 @@ func TestStorageKeySet(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateCustomScopes(t *testing.T) {
+	tests := []struct {
+		customScopes map[string][]string
+		wantErr      bool
+	}{
+		{customScopes: nil},
+		{customScopes: map[string][]string{"department": {"department", "cost_center"}}},
+		{customScopes: map[string][]string{"email": {"department"}}, wantErr: true},
+		{customScopes: map[string][]string{scopeCrossClientPrefix + "foo": {"department"}}, wantErr: true},
+		{customScopes: map[string][]string{"department": {"sub"}}, wantErr: true},
+		{customScopes: map[string][]string{"department": {""}}, wantErr: true},
+	}
+	for _, tc := range tests {
+		err := validateCustomScopes(tc.customScopes)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%v: wantErr=%t, got %v", tc.customScopes, tc.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+const (
+	// requestURIPrefix prefixes the ID of a pushed authorization request in the
+	// request_uri returned to the client.
+	requestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+	// Pushed authorization requests are meant to be used right away.
+	pushedAuthRequestsValidFor = time.Minute
+)
+
+type pushedAuthResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int    `json:"expires_in"`
+}
+
+// handlePushedAuthRequest handles the pushed authorization request endpoint,
+// which stores the parameters of an authorization request posted by an
+// authenticated client and returns a request_uri referencing them.
+//
+// https://tools.ietf.org/html/rfc9126
+func (s *Server) handlePushedAuthRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.tokenErrHelper(w, errInvalidRequest, "Invalid pushed authorization request type.", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "Failed to parse request body.", http.StatusBadRequest)
+		return
+	}
+
+	// Public clients can't authenticate, but still benefit from keeping the
+	// parameters out of the browser.
+	client, ok := s.authenticateClient(w, r, true)
+	if !ok {
+		return
+	}
+
+	q := r.PostForm
+	if q.Get("request_uri") != "" {
+		s.tokenErrHelper(w, errInvalidRequest, "request_uri can't be pushed.", http.StatusBadRequest)
+		return
+	}
+	if clientID := q.Get("client_id"); clientID != "" && clientID != client.ID {
+		s.tokenErrHelper(w, errInvalidRequest, "client_id does not match the authenticated client.", http.StatusBadRequest)
+		return
+	}
+	q.Set("client_id", client.ID)
+	q.Del("client_secret")
+
+	authReq, err := s.parseAuthorizationParams(r.Context(), q, true)
+	if err != nil {
+		if err, ok := err.(*authErr); ok && err.Type != errServerError {
+			s.tokenErrHelper(w, err.Type, err.Description, http.StatusBadRequest)
+			return
+		}
+		s.logger.Errorf("Failed to parse pushed authorization request: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	authReq.Expiry = s.now().Add(pushedAuthRequestsValidFor)
+	if err := s.storage.CreateAuthRequest(*authReq); err != nil {
+		s.logger.Errorf("Failed to create pushed authorization request: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(pushedAuthResponse{
+		RequestURI: requestURIPrefix + authReq.ID,
+		ExpiresIn:  int(pushedAuthRequestsValidFor.Seconds()),
+	})
+	if err != nil {
+		s.logger.Errorf("Failed to marshal pushed authorization response: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+}
+
+// redeemPushedAuthRequest returns the authorization request referenced by the
+// request_uri of a previously pushed request. Request URIs can only be used once.
+func (s *Server) redeemPushedAuthRequest(q url.Values) (*storage.AuthRequest, error) {
+	id := strings.TrimPrefix(q.Get("request_uri"), requestURIPrefix)
+	invalid := &authErr{"", "", errInvalidRequestURI, "Invalid or expired request_uri."}
+
+	authReq, err := s.storage.GetAuthRequest(id)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, invalid
+		}
+		s.logger.Errorf("Failed to get pushed authorization request: %v", err)
+		return nil, &authErr{"", "", errServerError, ""}
+	}
+	if authReq.ClientID != q.Get("client_id") || authReq.LoggedIn || s.now().After(authReq.Expiry) {
+		return nil, invalid
+	}
+	if err := s.storage.DeleteAuthRequest(id); err != nil {
+		if err == storage.ErrNotFound {
+			return nil, invalid
+		}
+		s.logger.Errorf("Failed to delete pushed authorization request: %v", err)
+		return nil, &authErr{"", "", errServerError, ""}
+	}
+
+	authReq.ID = storage.NewID()
+	return &authReq, nil
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestPushedAuthRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{
+			{
+				ID:           "foo",
+				Secret:       "foo-secret",
+				RedirectURIs: []string{"https://example.com/foo"},
+			},
+			{
+				ID:                        "strict",
+				Secret:                    "strict-secret",
+				RedirectURIs:              []string{"https://example.com/strict"},
+				RequirePushedAuthRequests: true,
+			},
+		})
+	})
+	defer httpServer.Close()
+
+	authParams := func(clientID, redirectURI string) url.Values {
+		params := url.Values{}
+		params.Set("client_id", clientID)
+		params.Set("redirect_uri", redirectURI)
+		params.Set("response_type", "code")
+		params.Set("scope", "openid email")
+		params.Set("state", "pushed-state")
+		return params
+	}
+	push := func(clientID, secret string, params url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/par", strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, secret)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+	authorize := func(params url.Values) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest("GET", "/auth?"+params.Encode(), nil))
+		return rr
+	}
+
+	rejected := []struct {
+		name         string
+		clientID     string
+		secret       string
+		params       url.Values
+		expectedCode int
+		expectedErr  string
+	}{
+		{"wrong secret", "foo", "wrong", authParams("foo", "https://example.com/foo"), http.StatusUnauthorized, errInvalidClient},
+		{"mismatched client_id", "foo", "foo-secret", authParams("strict", "https://example.com/foo"), http.StatusBadRequest, errInvalidRequest},
+		{"unregistered redirect uri", "foo", "foo-secret", authParams("foo", "https://example.com/bar"), http.StatusBadRequest, errInvalidRequest},
+		{"request_uri", "foo", "foo-secret", url.Values{"request_uri": {requestURIPrefix + "foo"}}, http.StatusBadRequest, errInvalidRequest},
+	}
+	for _, tc := range rejected {
+		rr := push(tc.clientID, tc.secret, tc.params)
+		if rr.Code != tc.expectedCode {
+			t.Errorf("%s: expected %d got %d: %s", tc.name, tc.expectedCode, rr.Code, rr.Body.String())
+			continue
+		}
+		if !strings.Contains(rr.Body.String(), tc.expectedErr) {
+			t.Errorf("%s: expected error %q got %s", tc.name, tc.expectedErr, rr.Body.String())
+		}
+	}
+
+	// Clients requiring pushed authorization requests can't send them directly.
+	if rr := authorize(authParams("strict", "https://example.com/strict")); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected direct authorization request to be rejected, got %d", rr.Code)
+	}
+
+	rr := push("strict", "strict-secret", authParams("strict", "https://example.com/strict"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp pushedAuthResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode pushed authorization response: %v", err)
+	}
+	if !strings.HasPrefix(resp.RequestURI, requestURIPrefix) || resp.ExpiresIn <= 0 {
+		t.Fatalf("unexpected pushed authorization response %#v", resp)
+	}
+
+	redeem := url.Values{"client_id": {"strict"}, "request_uri": {resp.RequestURI}}
+	if rr := authorize(url.Values{"client_id": {"foo"}, "request_uri": {resp.RequestURI}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected request_uri of another client to be rejected, got %d", rr.Code)
+	}
+	rr = authorize(redeem)
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected redirect to the connector, got %d: %s", rr.Code, rr.Body.String())
+	}
+	u, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authReq, err := s.storage.GetAuthRequest(u.Query().Get("req"))
+	if err != nil {
+		t.Fatalf("failed to get authorization request: %v", err)
+	}
+	if authReq.ClientID != "strict" || authReq.State != "pushed-state" || len(authReq.Scopes) != 2 {
+		t.Errorf("unexpected authorization request %#v", authReq)
+	}
+
+	if rr := authorize(redeem); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected request_uri to be single use, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,401 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+const registrationURI = "/register"
+
+// clientMetadata is the subset of the client metadata defined by RFC 7591
+// dex supports. Other fields of registration requests are ignored.
+//
+// https://tools.ietf.org/html/rfc7591#section-2
+type clientMetadata struct {
+	RedirectURIs            []string `json:"redirect_uris,omitempty"`
+	PostLogoutRedirectURIs  []string `json:"post_logout_redirect_uris,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	ClientName              string   `json:"client_name,omitempty"`
+	LogoURI                 string   `json:"logo_uri,omitempty"`
+
+	JWKS    json.RawMessage `json:"jwks,omitempty"`
+	JWKSURI string          `json:"jwks_uri,omitempty"`
+
+	// RequestURIs are the URLs request objects of the client may be fetched from.
+	//
+	// https://tools.ietf.org/html/rfc9101#section-10.5
+	RequestURIs []string `json:"request_uris,omitempty"`
+
+	// TLSClientAuthSubjectDN is the subject DN of the certificate
+	// tls_client_auth clients authenticate with.
+	//
+	// https://tools.ietf.org/html/rfc8705#section-2.1.2
+	TLSClientAuthSubjectDN string `json:"tls_client_auth_subject_dn,omitempty"`
+
+	RequirePushedAuthRequests bool `json:"require_pushed_authorization_requests,omitempty"`
+
+	// DPoPBoundAccessTokens requires the client to bind all its tokens with DPoP.
+	//
+	// https://tools.ietf.org/html/rfc9449#section-5.2
+	DPoPBoundAccessTokens bool `json:"dpop_bound_access_tokens,omitempty"`
+
+	// Registered grant types, response types and scopes limit what the client
+	// can request.
+	GrantTypes    []string `json:"grant_types,omitempty"`
+	ResponseTypes []string `json:"response_types,omitempty"`
+	Scope         string   `json:"scope,omitempty"`
+}
+
+// clientInformation is the response to registration and client read requests.
+//
+// https://tools.ietf.org/html/rfc7591#section-3.2.1
+type clientInformation struct {
+	ClientID                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`
+	RegistrationAccessToken string `json:"registration_access_token"`
+	RegistrationClientURI   string `json:"registration_client_uri"`
+
+	clientMetadata
+}
+
+func (s *Server) registrationEnabled() bool {
+	return s.initialAccessToken != ""
+}
+
+// handleRegister creates a client from a registration request. Callers must
+// present the configured initial access token.
+//
+// https://tools.ietf.org/html/rfc7591#section-3
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.tokenErrHelper(w, errInvalidRequest, "Method not allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.checkBearerToken(w, r, s.initialAccessToken) {
+		return
+	}
+
+	var metadata clientMetadata
+	if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+		s.tokenErrHelper(w, errInvalidClientMetadata, "Failed to parse client metadata.", http.StatusBadRequest)
+		return
+	}
+	if typ, desc := validateClientMetadata(metadata); typ != "" {
+		s.tokenErrHelper(w, typ, desc, http.StatusBadRequest)
+		return
+	}
+
+	client := storage.Client{
+		ID:                      storage.NewID(),
+		RegistrationAccessToken: storage.NewID() + storage.NewID(),
+	}
+	secret, err := applyClientMetadata(&client, metadata)
+	if err != nil {
+		s.logger.Errorf("Failed to create client secret: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	if err := s.storage.CreateClient(client); err != nil {
+		s.logger.Errorf("Failed to create registered client: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Infof("registered client %q", client.ID)
+
+	s.writeClientInformation(w, client, secret, http.StatusCreated)
+}
+
+// handleRegisteredClient lets a registered client read, update or delete its
+// registration using its registration access token.
+//
+// https://tools.ietf.org/html/rfc7592#section-2
+func (s *Server) handleRegisteredClient(w http.ResponseWriter, r *http.Request) {
+	clientID := mux.Vars(r)["client"]
+	client, err := s.storage.GetClient(clientID)
+	if err != nil && err != storage.ErrNotFound {
+		s.logger.Errorf("Failed to get client %q: %v", clientID, err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	// Unknown clients and clients that weren't registered dynamically are
+	// indistinguishable from an invalid token.
+	if !s.checkBearerToken(w, r, client.RegistrationAccessToken) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeClientInformation(w, client, "", http.StatusOK)
+	case http.MethodPut:
+		var req struct {
+			ClientID     string `json:"client_id"`
+			ClientSecret string `json:"client_secret"`
+			clientMetadata
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.tokenErrHelper(w, errInvalidClientMetadata, "Failed to parse client metadata.", http.StatusBadRequest)
+			return
+		}
+		if req.ClientID != client.ID {
+			s.tokenErrHelper(w, errInvalidRequest, "client_id doesn't match the registered client.", http.StatusBadRequest)
+			return
+		}
+		if req.ClientSecret != "" && !s.verifyClientSecret(client, req.ClientSecret) {
+			s.tokenErrHelper(w, errInvalidRequest, "client_secret doesn't match the registered client.", http.StatusBadRequest)
+			return
+		}
+		if typ, desc := validateClientMetadata(req.clientMetadata); typ != "" {
+			s.tokenErrHelper(w, typ, desc, http.StatusBadRequest)
+			return
+		}
+
+		var (
+			updated storage.Client
+			secret  string
+		)
+		err := s.storage.UpdateClient(client.ID, func(old storage.Client) (storage.Client, error) {
+			var err error
+			if secret, err = applyClientMetadata(&old, req.clientMetadata); err != nil {
+				return old, err
+			}
+			updated = old
+			return old, nil
+		})
+		if err != nil {
+			s.logger.Errorf("Failed to update registered client %q: %v", client.ID, err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		s.forgetClientKeySet(client.ID)
+		s.writeClientInformation(w, updated, secret, http.StatusOK)
+	case http.MethodDelete:
+		if err := s.storage.DeleteClient(client.ID); err != nil && err != storage.ErrNotFound {
+			s.logger.Errorf("Failed to delete registered client %q: %v", client.ID, err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		s.forgetClientKeySet(client.ID)
+		s.logger.Infof("deleted registered client %q", client.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.tokenErrHelper(w, errInvalidRequest, "Method not allowed.", http.StatusMethodNotAllowed)
+	}
+}
+
+// checkBearerToken reports whether the request carries the expected bearer
+// token, writing an error response if it doesn't.
+func (s *Server) checkBearerToken(w http.ResponseWriter, r *http.Request, expected string) bool {
+	const prefix = "Bearer "
+
+	auth := r.Header.Get("authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(prefix, auth[:len(prefix)]) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		s.tokenErrHelper(w, errInvalidToken, "Missing bearer token.", http.StatusUnauthorized)
+		return false
+	}
+	token := auth[len(prefix):]
+	if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		s.tokenErrHelper(w, errInvalidToken, "Invalid bearer token.", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// validateClientMetadata returns an error type and description if the
+// metadata can't be used to register a client.
+func validateClientMetadata(metadata clientMetadata) (typ, desc string) {
+	for _, uri := range metadata.RedirectURIs {
+		if !isAbsoluteURL(uri) {
+			return errInvalidRedirectURI, fmt.Sprintf("Invalid redirect URI %q.", uri)
+		}
+	}
+	for _, uri := range metadata.PostLogoutRedirectURIs {
+		if !isAbsoluteURL(uri) {
+			return errInvalidClientMetadata, fmt.Sprintf("Invalid post logout redirect URI %q.", uri)
+		}
+	}
+	if metadata.LogoURI != "" && !isAbsoluteURL(metadata.LogoURI) {
+		return errInvalidClientMetadata, fmt.Sprintf("Invalid logo URI %q.", metadata.LogoURI)
+	}
+	switch metadata.TokenEndpointAuthMethod {
+	case "", authMethodNone, authMethodClientSecretBasic, authMethodClientSecretPost, authMethodClientSecretJWT:
+	case authMethodPrivateKeyJWT:
+		if len(metadata.JWKS) == 0 && metadata.JWKSURI == "" {
+			return errInvalidClientMetadata, "private_key_jwt requires jwks or jwks_uri."
+		}
+	case authMethodTLSClientAuth:
+		if metadata.TLSClientAuthSubjectDN == "" {
+			return errInvalidClientMetadata, "tls_client_auth requires tls_client_auth_subject_dn."
+		}
+	default:
+		return errInvalidClientMetadata, fmt.Sprintf("Unsupported token endpoint auth method %q.", metadata.TokenEndpointAuthMethod)
+	}
+	if len(metadata.JWKS) > 0 && metadata.JWKSURI != "" {
+		return errInvalidClientMetadata, "Only one of jwks and jwks_uri may be provided."
+	}
+	if len(metadata.JWKS) > 0 {
+		var jwks jose.JSONWebKeySet
+		if err := json.Unmarshal(metadata.JWKS, &jwks); err != nil {
+			return errInvalidClientMetadata, "Invalid JWKS."
+		}
+	}
+	if metadata.JWKSURI != "" && !isHTTPSURL(metadata.JWKSURI) {
+		return errInvalidClientMetadata, fmt.Sprintf("Invalid JWKS URI %q.", metadata.JWKSURI)
+	}
+	for _, uri := range metadata.RequestURIs {
+		if !isHTTPSURL(uri) {
+			return errInvalidClientMetadata, fmt.Sprintf("Invalid request URI %q.", uri)
+		}
+	}
+	for _, grantType := range metadata.GrantTypes {
+		switch grantType {
+		case grantTypeAuthorizationCode, grantTypeRefreshToken, grantTypePassword, grantTypeDeviceCode,
+			grantTypeClientCredentials, grantTypeTokenExchange:
+		default:
+			return errInvalidClientMetadata, fmt.Sprintf("Unsupported grant type %q.", grantType)
+		}
+	}
+	for _, responseType := range responseTypes(metadata.ResponseTypes) {
+		switch responseType {
+		case responseTypeCode, responseTypeIDToken, responseTypeToken:
+		default:
+			return errInvalidClientMetadata, fmt.Sprintf("Unsupported response type %q.", responseType)
+		}
+	}
+	return "", ""
+}
+
+// responseTypes splits registered response types, which may combine several
+// types like "code id_token", into the individual types.
+func responseTypes(registered []string) []string {
+	var types []string
+	for _, combined := range registered {
+		for _, typ := range strings.Fields(combined) {
+			if !contains(types, typ) {
+				types = append(types, typ)
+			}
+		}
+	}
+	return types
+}
+
+// isAbsoluteURL reports whether uri is an absolute URL without a fragment.
+func isAbsoluteURL(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.Scheme != "" && u.Host != "" && u.Fragment == ""
+}
+
+// isHTTPSURL reports whether uri is an absolute https URL without a fragment.
+func isHTTPSURL(uri string) bool {
+	return isAbsoluteURL(uri) && strings.HasPrefix(uri, "https://")
+}
+
+// applyClientMetadata replaces the client's registered metadata. Confidential
+// clients are given a secret if they don't have one yet, which is returned in
+// plaintext since only its hash is stored.
+func applyClientMetadata(client *storage.Client, metadata clientMetadata) (string, error) {
+	client.RedirectURIs = metadata.RedirectURIs
+	client.PostLogoutRedirectURIs = metadata.PostLogoutRedirectURIs
+	client.Name = metadata.ClientName
+	client.LogoURL = metadata.LogoURI
+	client.Public = metadata.TokenEndpointAuthMethod == authMethodNone
+	client.JWKS = string(metadata.JWKS)
+	client.JWKSURI = metadata.JWKSURI
+	client.RequestURIs = metadata.RequestURIs
+	client.TLSClientAuthSubjectDN = metadata.TLSClientAuthSubjectDN
+	client.RequirePushedAuthRequests = metadata.RequirePushedAuthRequests
+	client.RequireDPoP = metadata.DPoPBoundAccessTokens
+	client.AllowedGrantTypes = metadata.GrantTypes
+	client.AllowedResponseTypes = responseTypes(metadata.ResponseTypes)
+	client.AllowedScopes = strings.Fields(metadata.Scope)
+	client.ClientCredentialsScopes = client.AllowedScopes
+	client.TokenEndpointAuthMethods = nil
+	if metadata.TokenEndpointAuthMethod != "" && !client.Public {
+		client.TokenEndpointAuthMethods = []string{metadata.TokenEndpointAuthMethod}
+	}
+
+	// Clients authenticating with their private key or certificate don't need a
+	// secret, while client_secret_jwt clients need one in plaintext to verify
+	// their JWTs.
+	switch {
+	case client.Public || metadata.TokenEndpointAuthMethod == authMethodPrivateKeyJWT ||
+		metadata.TokenEndpointAuthMethod == authMethodTLSClientAuth:
+		return "", nil
+	case metadata.TokenEndpointAuthMethod == authMethodClientSecretJWT:
+		if client.Secret == "" {
+			client.Secret = storage.NewID() + storage.NewID()
+			return client.Secret, nil
+		}
+		return "", nil
+	case client.Secret != "" || len(client.Secrets) > 0:
+		return "", nil
+	}
+	secret := storage.NewID() + storage.NewID()
+	client.Secrets = []storage.ClientSecret{storage.NewClientSecret(secret, time.Time{})}
+	return secret, nil
+}
+
+// writeClientInformation writes the client's registration. Hashed secrets can't
+// be read back, so a secret is only included when it was just issued or is
+// stored in plaintext.
+func (s *Server) writeClientInformation(w http.ResponseWriter, client storage.Client, secret string, status int) {
+	info := clientInformation{
+		ClientID:                client.ID,
+		RegistrationAccessToken: client.RegistrationAccessToken,
+		RegistrationClientURI:   s.absURL(registrationURI, client.ID),
+		clientMetadata: clientMetadata{
+			RedirectURIs:            client.RedirectURIs,
+			PostLogoutRedirectURIs:  client.PostLogoutRedirectURIs,
+			TokenEndpointAuthMethod: authMethodClientSecretBasic,
+			ClientName:              client.Name,
+			LogoURI:                 client.LogoURL,
+			JWKSURI:                 client.JWKSURI,
+			RequestURIs:             client.RequestURIs,
+			TLSClientAuthSubjectDN:  client.TLSClientAuthSubjectDN,
+
+			RequirePushedAuthRequests: client.RequirePushedAuthRequests,
+			DPoPBoundAccessTokens:     client.RequireDPoP,
+
+			GrantTypes:    client.AllowedGrantTypes,
+			ResponseTypes: client.AllowedResponseTypes,
+			Scope:         strings.Join(client.AllowedScopes, " "),
+		},
+	}
+	if client.JWKS != "" {
+		info.JWKS = json.RawMessage(client.JWKS)
+	}
+	if len(client.TokenEndpointAuthMethods) == 1 {
+		info.TokenEndpointAuthMethod = client.TokenEndpointAuthMethods[0]
+	}
+	if client.Public {
+		info.TokenEndpointAuthMethod = authMethodNone
+	} else if secret != "" {
+		info.ClientSecret = secret
+	} else {
+		info.ClientSecret = client.Secret
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		s.logger.Errorf("Failed to marshal client information: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	w.Write(data)
+}
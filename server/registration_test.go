@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestClientRegistration(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.InitialAccessToken = "initial-token"
+	})
+	defer httpServer.Close()
+
+	do := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder) clientInformation {
+		var info clientInformation
+		if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+			t.Fatalf("failed to decode client information: %v", err)
+		}
+		return info
+	}
+
+	rr := do("GET", "/.well-known/openid-configuration", "", "")
+	var d discovery
+	if err := json.Unmarshal(rr.Body.Bytes(), &d); err != nil {
+		t.Fatalf("failed to decode discovery: %v", err)
+	}
+	if d.Registration != s.absURL(registrationURI) {
+		t.Errorf("expected registration endpoint %q got %q", s.absURL(registrationURI), d.Registration)
+	}
+
+	rejected := []struct {
+		name         string
+		token        string
+		body         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"no token", "", `{}`, http.StatusUnauthorized, errInvalidToken},
+		{"wrong token", "wrong", `{}`, http.StatusUnauthorized, errInvalidToken},
+		{"relative redirect uri", "initial-token", `{"redirect_uris": ["/callback"]}`, http.StatusBadRequest, errInvalidRedirectURI},
+		{"unsupported auth method", "initial-token", `{"token_endpoint_auth_method": "unknown"}`, http.StatusBadRequest, errInvalidClientMetadata},
+		{"private_key_jwt without keys", "initial-token", `{"token_endpoint_auth_method": "private_key_jwt"}`, http.StatusBadRequest, errInvalidClientMetadata},
+		{"malformed jwks", "initial-token", `{"jwks": {"keys": "none"}}`, http.StatusBadRequest, errInvalidClientMetadata},
+		{"http jwks uri", "initial-token", `{"jwks_uri": "http://example.com/jwks"}`, http.StatusBadRequest, errInvalidClientMetadata},
+		{"jwks and jwks uri", "initial-token", `{"jwks": {"keys": []}, "jwks_uri": "https://example.com/jwks"}`, http.StatusBadRequest, errInvalidClientMetadata},
+		{"http request uri", "initial-token", `{"request_uris": ["http://example.com/request.jwt"]}`, http.StatusBadRequest, errInvalidClientMetadata},
+		{"unsupported grant type", "initial-token", `{"grant_types": ["implicit"]}`, http.StatusBadRequest, errInvalidClientMetadata},
+		{"unsupported response type", "initial-token", `{"response_types": ["code none"]}`, http.StatusBadRequest, errInvalidClientMetadata},
+	}
+	for _, tc := range rejected {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := do("POST", registrationURI, tc.token, tc.body)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected %d got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), tc.expectedErr) {
+				t.Errorf("expected error %q got %s", tc.expectedErr, rr.Body.String())
+			}
+		})
+	}
+
+	rr = do("POST", registrationURI, "initial-token", `{
+		"redirect_uris": ["https://preview.example.com/callback"],
+		"client_name": "Preview",
+		"grant_types": ["authorization_code"],
+		"request_uris": ["https://preview.example.com/request.jwt"]
+	}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	info := decode(rr)
+	if info.ClientSecret == "" || info.RegistrationAccessToken == "" {
+		t.Fatalf("expected client secret and registration access token, got %#v", info)
+	}
+	if info.RegistrationClientURI != s.absURL(registrationURI, info.ClientID) {
+		t.Errorf("unexpected registration client URI %q", info.RegistrationClientURI)
+	}
+
+	client, err := s.storage.GetClient(info.ClientID)
+	if err != nil {
+		t.Fatalf("failed to get registered client: %v", err)
+	}
+	if client.Name != "Preview" || client.Public || client.Secret != "" || len(client.AllowedGrantTypes) != 1 || len(client.RequestURIs) != 1 {
+		t.Errorf("unexpected registered client %#v", client)
+	}
+	if !s.verifyClientSecret(client, info.ClientSecret) {
+		t.Errorf("expected client secret to be stored hashed")
+	}
+
+	clientURI := registrationURI + "/" + info.ClientID
+	if rr := do("GET", clientURI, "initial-token", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected initial access token to be rejected for client read, got %d", rr.Code)
+	}
+	rr = do("GET", clientURI, info.RegistrationAccessToken, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := decode(rr); got.ClientName != "Preview" || got.ClientSecret != "" {
+		t.Errorf("unexpected client information %#v", got)
+	}
+
+	rr = do("PUT", clientURI, info.RegistrationAccessToken, `{"client_id": "other"}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected mismatched client_id to be rejected, got %d", rr.Code)
+	}
+	rr = do("PUT", clientURI, info.RegistrationAccessToken, `{
+		"client_id": "`+info.ClientID+`",
+		"redirect_uris": ["https://preview2.example.com/callback"],
+		"client_name": "Preview 2"
+	}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	client, err = s.storage.GetClient(info.ClientID)
+	if err != nil {
+		t.Fatalf("failed to get registered client: %v", err)
+	}
+	if client.Name != "Preview 2" || len(client.RedirectURIs) != 1 || client.RedirectURIs[0] != "https://preview2.example.com/callback" {
+		t.Errorf("unexpected updated client %#v", client)
+	}
+	if got := decode(rr); got.ClientSecret != "" || !s.verifyClientSecret(client, info.ClientSecret) {
+		t.Errorf("expected client secret to be kept on update")
+	}
+
+	if rr := do("DELETE", clientURI, info.RegistrationAccessToken, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := s.storage.GetClient(info.ClientID); err != storage.ErrNotFound {
+		t.Errorf("expected client to be deleted, got error %v", err)
+	}
+	if rr := do("GET", clientURI, info.RegistrationAccessToken, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected deleted client to be unauthorized, got %d", rr.Code)
+	}
+}
+
+func TestClientRegistrationDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("POST", registrationURI, strings.NewReader(`{}`)))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 got %d", rr.Code)
+	}
+}
@@ -0,0 +1,319 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+const (
+	// Request objects larger than this are rejected when fetched from a request_uri.
+	maxRequestObjectSize = 64 << 10
+	// requestObjectFetchTimeout bounds fetching a request object, which blocks
+	// the authorization request.
+	requestObjectFetchTimeout = 5 * time.Second
+)
+
+// requestObjectAlgs are the algorithms request objects may be signed with.
+// Unsigned request objects and symmetric algorithms are not accepted.
+var requestObjectAlgs = []string{
+	string(jose.RS256), string(jose.RS384), string(jose.RS512),
+	string(jose.ES256), string(jose.ES384), string(jose.ES512),
+	string(jose.PS256), string(jose.PS384), string(jose.PS512),
+	string(jose.EdDSA),
+}
+
+// Claims of a request object which are not authorization request parameters.
+var requestObjectClaims = map[string]bool{
+	"iss":         true,
+	"aud":         true,
+	"exp":         true,
+	"nbf":         true,
+	"iat":         true,
+	"jti":         true,
+	"request":     true,
+	"request_uri": true,
+}
+
+// keySet verifies JWT signatures against a set of public keys.
+type keySet interface {
+	VerifySignature(ctx context.Context, jwt string) (payload []byte, err error)
+}
+
+// staticKeySet is a key set registered with the client itself.
+type staticKeySet []jose.JSONWebKey
+
+func (keys staticKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, fmt.Errorf("malformed jwt: %v", err)
+	}
+	keyID := ""
+	for _, sig := range jws.Signatures {
+		keyID = sig.Header.KeyID
+		break
+	}
+	for _, key := range keys {
+		if keyID != "" && key.KeyID != keyID {
+			continue
+		}
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if payload, err := jws.Verify(&key); err == nil {
+			return payload, nil
+		}
+	}
+	return nil, errors.New("failed to verify signature")
+}
+
+// remoteKeySet is the cached key set of a client's JWKS URI.
+type remoteKeySet struct {
+	uri  string
+	keys keySet
+}
+
+// clientKeySet returns the key set a client signs its JWTs with.
+func (s *Server) clientKeySet(client storage.Client) (keySet, error) {
+	switch {
+	case client.JWKS != "":
+		var jwks jose.JSONWebKeySet
+		if err := json.Unmarshal([]byte(client.JWKS), &jwks); err != nil {
+			return nil, fmt.Errorf("malformed jwks: %v", err)
+		}
+		return staticKeySet(jwks.Keys), nil
+	case client.JWKSURI != "":
+		s.keySetsMu.Lock()
+		defer s.keySetsMu.Unlock()
+
+		// Remote key sets cache their keys, so keep one per client, as long as
+		// the client's URI doesn't change.
+		if cached, ok := s.remoteKeySets[client.ID]; ok && cached.uri == client.JWKSURI {
+			return cached.keys, nil
+		}
+		ctx := oidc.ClientContext(context.Background(), s.httpClient)
+		keys := oidc.NewRemoteKeySet(ctx, client.JWKSURI)
+		s.remoteKeySets[client.ID] = remoteKeySet{uri: client.JWKSURI, keys: keys}
+		return keys, nil
+	}
+	return nil, errors.New("client has no registered keys")
+}
+
+// forgetClientKeySet drops the cached key set of a client that was updated or
+// deleted.
+func (s *Server) forgetClientKeySet(clientID string) {
+	s.keySetsMu.Lock()
+	defer s.keySetsMu.Unlock()
+	delete(s.remoteKeySets, clientID)
+}
+
+// resolveRequestObject verifies a request object passed by value through the
+// "request" parameter or by reference through the "request_uri" parameter, and
+// replaces the query parameters with the values of the request object. Only
+// client_id is kept from the query, other unsigned parameters are dropped.
+//
+// https://tools.ietf.org/html/rfc9101
+func (s *Server) resolveRequestObject(ctx context.Context, q url.Values) error {
+	request := q.Get("request")
+	requestURI := q.Get("request_uri")
+	if request == "" && requestURI == "" {
+		return nil
+	}
+	if request != "" && requestURI != "" {
+		return &authErr{"", "", errInvalidRequest, "Only one of request and request_uri may be provided."}
+	}
+
+	clientID := q.Get("client_id")
+	client, err := s.storage.GetClient(clientID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			description := fmt.Sprintf("Invalid client_id (%q).", clientID)
+			return &authErr{"", "", errUnauthorizedClient, description}
+		}
+		s.logger.Errorf("Failed to get client: %v", err)
+		return &authErr{"", "", errServerError, ""}
+	}
+
+	if requestURI != "" {
+		// Only URIs registered by the client are fetched, so dex can't be used
+		// to send requests to arbitrary hosts.
+		//
+		// https://tools.ietf.org/html/rfc9101#section-5.2.3
+		if !contains(client.RequestURIs, requestURI) {
+			return &authErr{"", "", errInvalidRequestURI, "request_uri is not registered for the client."}
+		}
+		request, err = s.fetchRequestObject(ctx, requestURI)
+		if err != nil {
+			s.logger.Errorf("Failed to fetch request object: %v", err)
+			return &authErr{"", "", errInvalidRequestURI, "Failed to fetch request_uri."}
+		}
+	}
+
+	params, err := s.verifyRequestObject(ctx, client, request)
+	if err != nil {
+		s.logger.Errorf("Invalid request object: %v", err)
+		return &authErr{"", "", errInvalidRequestObject, "Invalid request object."}
+	}
+
+	// https://tools.ietf.org/html/rfc9101#section-6.3
+	for name := range q {
+		delete(q, name)
+	}
+	for name, values := range params {
+		q[name] = values
+	}
+	q.Set("client_id", clientID)
+	return nil
+}
+
+// fetchRequestObject retrieves a request object from a request_uri.
+func (s *Server) fetchRequestObject(ctx context.Context, requestURI string) (string, error) {
+	if !isHTTPSURL(requestURI) {
+		return "", fmt.Errorf("request_uri %q is not an https URL", requestURI)
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestObjectFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", requestURI, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRequestObjectSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > maxRequestObjectSize {
+		return "", errors.New("request object too large")
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// verifyRequestObject verifies the signature and claims of a request object and
+// returns the authorization request parameters it holds.
+func (s *Server) verifyRequestObject(ctx context.Context, client storage.Client, request string) (url.Values, error) {
+	jws, err := jose.ParseSigned(request)
+	if err != nil {
+		return nil, fmt.Errorf("malformed request object: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, errors.New("request object must have exactly one signature")
+	}
+	alg := jws.Signatures[0].Header.Algorithm
+	supported := false
+	for _, a := range requestObjectAlgs {
+		if a == alg {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+
+	keys, err := s.clientKeySet(client)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := keys.VerifySignature(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	d := json.NewDecoder(strings.NewReader(string(payload)))
+	d.UseNumber()
+	if err := d.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("malformed request object claims: %v", err)
+	}
+
+	if iss, ok := claims["iss"]; ok && iss != client.ID {
+		return nil, fmt.Errorf("unexpected issuer %v", iss)
+	}
+	if aud, ok := claims["aud"]; ok && !audienceContains(aud, s.issuerURL.String()) {
+		return nil, fmt.Errorf("unexpected audience %v", aud)
+	}
+	if clientID, ok := claims["client_id"]; ok && clientID != client.ID {
+		return nil, fmt.Errorf("client_id %v does not match the client", clientID)
+	}
+	now := s.now()
+	if exp, ok := numericDate(claims["exp"]); ok && !now.Before(exp) {
+		return nil, errors.New("request object expired")
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Before(nbf) {
+		return nil, errors.New("request object not valid yet")
+	}
+
+	params := make(url.Values)
+	for name, value := range claims {
+		if requestObjectClaims[name] {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			params.Set(name, v)
+		case json.Number:
+			params.Set(name, v.String())
+		case bool:
+			params.Set(name, strconv.FormatBool(v))
+		default:
+			// Objects such as the claims request parameter keep their JSON encoding.
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode %q: %v", name, err)
+			}
+			params.Set(name, string(data))
+		}
+	}
+	return params, nil
+}
+
+// audienceContains reports whether an "aud" claim, a string or an array of
+// strings, contains the audience.
+func audienceContains(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// numericDate converts a NumericDate claim decoded as a json.Number to a time.
+func numericDate(v interface{}) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
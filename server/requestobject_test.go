@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func signRequestObject(t *testing.T, key *jose.JSONWebKey, claims map[string]interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, nil)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal claims: %v", err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("failed to sign request object: %v", err)
+	}
+	request, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatalf("failed to serialize request object: %v", err)
+	}
+	return request
+}
+
+func TestRequestObject(t *testing.T) {
+	key := &jose.JSONWebKey{Key: testKey, KeyID: "request-key", Algorithm: string(jose.RS256), Use: "sig"}
+	otherKey := &jose.JSONWebKey{Key: testKey, KeyID: "other-key", Algorithm: string(jose.RS256), Use: "sig"}
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.Public()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requestObject string
+	requestServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/oauth-authz-req+jwt")
+		w.Write([]byte(requestObject))
+	}))
+	defer requestServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{
+			{
+				ID:           "foo",
+				RedirectURIs: []string{"https://example.com/foo", "https://example.com/bar"},
+				JWKS:         string(jwks),
+				RequestURIs:  []string{requestServer.URL + "/request.jwt"},
+			},
+			{
+				ID:           "nokeys",
+				RedirectURIs: []string{"https://example.com/foo"},
+			},
+		})
+	})
+	defer httpServer.Close()
+	s.httpClient = requestServer.Client()
+
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":           "foo",
+			"aud":           s.issuerURL.String(),
+			"exp":           time.Now().Add(time.Minute).Unix(),
+			"client_id":     "foo",
+			"redirect_uri":  "https://example.com/bar",
+			"response_type": "code",
+			"scope":         "openid email",
+			"state":         "from-request-object",
+			"claims":        map[string]interface{}{"id_token": map[string]interface{}{"email": nil}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		clientID string
+		claims   func() map[string]interface{}
+		key      *jose.JSONWebKey
+		byRef    bool
+		both     bool
+		uri      string
+		wantErr  string
+	}{
+		{name: "request", claims: validClaims, key: key},
+		{name: "request_uri", claims: validClaims, key: key, byRef: true},
+		{name: "unregistered request_uri", claims: validClaims, key: key, byRef: true, uri: "/other.jwt", wantErr: errInvalidRequestURI},
+		{name: "unknown key", claims: validClaims, key: otherKey, wantErr: errInvalidRequestObject},
+		{name: "client without keys", clientID: "nokeys", claims: validClaims, key: key, wantErr: errInvalidRequestObject},
+		{
+			name: "wrong issuer",
+			claims: func() map[string]interface{} {
+				c := validClaims()
+				c["iss"] = "bar"
+				return c
+			},
+			key:     key,
+			wantErr: errInvalidRequestObject,
+		},
+		{
+			name: "wrong audience",
+			claims: func() map[string]interface{} {
+				c := validClaims()
+				c["aud"] = []string{"https://other.example.com"}
+				return c
+			},
+			key:     key,
+			wantErr: errInvalidRequestObject,
+		},
+		{
+			name: "expired",
+			claims: func() map[string]interface{} {
+				c := validClaims()
+				c["exp"] = time.Now().Add(-time.Minute).Unix()
+				return c
+			},
+			key:     key,
+			wantErr: errInvalidRequestObject,
+		},
+		{name: "request and request_uri", claims: validClaims, key: key, both: true, wantErr: errInvalidRequest},
+	}
+
+	for _, tc := range tests {
+		clientID := tc.clientID
+		if clientID == "" {
+			clientID = "foo"
+		}
+		requestObject = signRequestObject(t, tc.key, tc.claims())
+
+		params := url.Values{}
+		params.Set("client_id", clientID)
+		params.Set("redirect_uri", "https://example.com/foo")
+		params.Set("response_type", "code")
+		params.Set("scope", "openid")
+		params.Set("state", "from-query")
+		params.Set("nonce", "from-query")
+		if tc.byRef || tc.both {
+			uri := tc.uri
+			if uri == "" {
+				uri = "/request.jwt"
+			}
+			params.Set("request_uri", requestServer.URL+uri)
+		}
+		if !tc.byRef || tc.both {
+			params.Set("request", requestObject)
+		}
+
+		req := httptest.NewRequest("GET", httpServer.URL+"/auth?"+params.Encode(), nil)
+		authReq, err := s.parseAuthorizationRequest(req)
+		if tc.wantErr != "" {
+			aErr, ok := err.(*authErr)
+			if !ok || aErr.Type != tc.wantErr {
+				t.Errorf("%s: expected error %q, got %v", tc.name, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if authReq.State != "from-request-object" || authReq.RedirectURI != "https://example.com/bar" {
+			t.Errorf("%s: expected request object to take precedence, got state %q redirect_uri %q", tc.name, authReq.State, authReq.RedirectURI)
+		}
+		if authReq.Nonce != "" {
+			t.Errorf("%s: expected parameters outside the request object to be ignored, got nonce %q", tc.name, authReq.Nonce)
+		}
+		if len(authReq.Scopes) != 2 {
+			t.Errorf("%s: expected scopes from request object, got %v", tc.name, authReq.Scopes)
+		}
+		if _, err := parseClaimsRequest(authReq.ClaimsRequest); err != nil || authReq.ClaimsRequest == "" {
+			t.Errorf("%s: expected claims request from request object, got %q", tc.name, authReq.ClaimsRequest)
+		}
+	}
+}
+
+func TestClientKeySetCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	client := storage.Client{ID: "foo", JWKSURI: "https://foo.example.com/jwks"}
+	keys, err := s.clientKeySet(client)
+	if err != nil {
+		t.Fatalf("failed to get key set: %v", err)
+	}
+	if cached, _ := s.clientKeySet(client); cached != keys {
+		t.Errorf("expected key set to be cached")
+	}
+
+	// Clients don't share key sets even if they use the same URI.
+	other := storage.Client{ID: "bar", JWKSURI: client.JWKSURI}
+	if otherKeys, _ := s.clientKeySet(other); otherKeys == keys {
+		t.Errorf("expected key set of another client not to be shared")
+	}
+
+	client.JWKSURI = "https://foo.example.com/new-jwks"
+	updated, _ := s.clientKeySet(client)
+	if updated == keys {
+		t.Errorf("expected a new key set after the JWKS URI changed")
+	}
+
+	s.forgetClientKeySet(client.ID)
+	if _, ok := s.remoteKeySets[client.ID]; ok {
+		t.Errorf("expected key set to be dropped")
+	}
+}
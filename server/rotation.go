@@ -2,14 +2,16 @@ package server
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
-	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
 	"time"
 
+	"golang.org/x/crypto/ed25519"
 	"gopkg.in/square/go-jose.v2"
 
 	"github.com/dexidp/dex/pkg/log"
@@ -25,33 +27,69 @@ type rotationStrategy struct {
 	rotationFrequency time.Duration
 
 	// After being rotated how long should the key be kept around for validating
-	// signatues?
+	// signatues? Clients with longer token lifetimes extend this.
 	idTokenValidFor time.Duration
 
-	// Keys are always RSA keys. Though cryptopasta recommends ECDSA keys, not every
+	// Keys default to RSA keys. Though cryptopasta recommends ECDSA keys, not every
 	// client may support these (e.g. github.com/coreos/go-oidc/oidc).
-	key func() (*rsa.PrivateKey, error)
+	key func() (crypto.Signer, error)
 }
 
 // staticRotationStrategy returns a strategy which never rotates keys.
-func staticRotationStrategy(key *rsa.PrivateKey) rotationStrategy {
+func staticRotationStrategy(key crypto.Signer) rotationStrategy {
 	return rotationStrategy{
 		// Setting these values to 100 years is easier than having a flag indicating no rotation.
 		rotationFrequency: time.Hour * 8760 * 100,
 		idTokenValidFor:   time.Hour * 8760 * 100,
-		key:               func() (*rsa.PrivateKey, error) { return key, nil },
+		key:               func() (crypto.Signer, error) { return key, nil },
 	}
 }
 
 // defaultRotationStrategy returns a strategy which rotates keys every provided period,
 // holding onto the public parts for some specified amount of time.
-func defaultRotationStrategy(rotationFrequency, idTokenValidFor time.Duration) rotationStrategy {
+func defaultRotationStrategy(rotationFrequency, idTokenValidFor time.Duration, key func() (crypto.Signer, error)) rotationStrategy {
 	return rotationStrategy{
 		rotationFrequency: rotationFrequency,
 		idTokenValidFor:   idTokenValidFor,
-		key: func() (*rsa.PrivateKey, error) {
-			return rsa.GenerateKey(rand.Reader, 2048)
-		},
+		key:               key,
+	}
+}
+
+// signingKeyGenerator returns a function generating signing keys for the
+// algorithm, one of "RS256", "ES256", "ES384" or "EdDSA". size is the size of
+// RSA keys in bits and must be zero for other algorithms.
+func signingKeyGenerator(algorithm string, size int) (func() (crypto.Signer, error), error) {
+	if algorithm != "" && algorithm != string(jose.RS256) && size != 0 {
+		return nil, fmt.Errorf("key size can't be configured for signing algorithm %q", algorithm)
+	}
+
+	switch jose.SignatureAlgorithm(algorithm) {
+	case "", jose.RS256:
+		switch size {
+		case 0:
+			size = 2048
+		case 2048, 3072, 4096:
+		default:
+			return nil, fmt.Errorf("unsupported RSA key size %d", size)
+		}
+		return func() (crypto.Signer, error) {
+			return rsa.GenerateKey(rand.Reader, size)
+		}, nil
+	case jose.ES256:
+		return func() (crypto.Signer, error) {
+			return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}, nil
+	case jose.ES384:
+		return func() (crypto.Signer, error) {
+			return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		}, nil
+	case jose.EdDSA:
+		return func() (crypto.Signer, error) {
+			_, key, err := ed25519.GenerateKey(rand.Reader)
+			return key, err
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
 	}
 }
 
@@ -59,6 +97,7 @@ type keyRotater struct {
 	storage.Storage
 
 	strategy rotationStrategy
+	signer   Signer
 	now      func() time.Time
 
 	logger log.Logger
@@ -69,7 +108,7 @@ type keyRotater struct {
 // The method blocks until after the first attempt to rotate keys has completed. That way
 // healthy storages will return from this call with valid keys.
 func (s *Server) startKeyRotation(ctx context.Context, strategy rotationStrategy, now func() time.Time) {
-	rotater := keyRotater{s.storage, strategy, now, s.logger}
+	rotater := keyRotater{s.storage, strategy, s.signer, now, s.logger}
 
 	// Try to rotate immediately so properly configured storages will have keys.
 	if err := rotater.rotate(); err != nil {
@@ -79,6 +118,14 @@ func (s *Server) startKeyRotation(ctx context.Context, strategy rotationStrategy
 			s.logger.Errorf("failed to rotate keys: %v", err)
 		}
 	}
+	// The signer may have changed since the current signing key was created.
+	if err := rotater.rotateUnusableKey(); err != nil {
+		if err == errAlreadyRotated {
+			s.logger.Infof("Key rotation not needed: %v", err)
+		} else {
+			s.logger.Errorf("failed to rotate keys: %v", err)
+		}
+	}
 
 	go func() {
 		for {
@@ -94,6 +141,25 @@ func (s *Server) startKeyRotation(ctx context.Context, strategy rotationStrategy
 	}()
 }
 
+// tokensValidFor returns the longest lifetime of tokens signed by the current
+// signing key, which includes the lifetimes configured for clients.
+func (k keyRotater) tokensValidFor() time.Duration {
+	validFor := k.strategy.idTokenValidFor
+	clients, err := k.ListClients()
+	if err != nil {
+		k.logger.Errorf("failed to list clients, keeping verification keys for %s: %v", validFor, err)
+		return validFor
+	}
+	for _, client := range clients {
+		for _, value := range []string{client.IDTokenValidFor, client.AccessTokenValidFor} {
+			if d, err := time.ParseDuration(value); err == nil && d > validFor {
+				validFor = d
+			}
+		}
+	}
+	return validFor
+}
+
 func (k keyRotater) rotate() error {
 	keys, err := k.GetKeys()
 	if err != nil && err != storage.ErrNotFound {
@@ -103,37 +169,55 @@ func (k keyRotater) rotate() error {
 		return nil
 	}
 	k.logger.Infof("keys expired, rotating")
+	return k.rotateKeys("")
+}
 
-	// Generate the key outside of a storage transaction.
-	key, err := k.strategy.key()
+// rotateUnusableKey rotates the signing key right away if the signer can't sign
+// with it. This happens after switching to an external signer, which leaves the
+// private key of the local signer in storage.
+func (k keyRotater) rotateUnusableKey() error {
+	keys, err := k.GetKeys()
 	if err != nil {
-		return fmt.Errorf("generate key: %v", err)
+		if err == storage.ErrNotFound {
+			return nil
+		}
+		return fmt.Errorf("get keys: %v", err)
 	}
-	b := make([]byte, 20)
-	if _, err := io.ReadFull(rand.Reader, b); err != nil {
-		panic(err)
+	if keys.SigningKeyPub == nil {
+		return nil
 	}
-	keyID := hex.EncodeToString(b)
-	priv := &jose.JSONWebKey{
-		Key:       key,
-		KeyID:     keyID,
-		Algorithm: "RS256",
-		Use:       "sig",
+	ok, err := k.signer.CanSign(keys)
+	if err != nil {
+		return fmt.Errorf("check signing key: %v", err)
 	}
-	pub := &jose.JSONWebKey{
-		Key:       key.Public(),
-		KeyID:     keyID,
-		Algorithm: "RS256",
-		Use:       "sig",
+	if ok {
+		return nil
 	}
+	k.logger.Infof("signer doesn't hold signing key %s, rotating", keys.SigningKeyPub.KeyID)
+	return k.rotateKeys(keys.SigningKeyPub.KeyID)
+}
 
-	var nextRotation time.Time
+// rotateKeys replaces the signing key by a new one. If unusableKeyID is set, the
+// key with that ID is replaced even though it isn't due for rotation yet.
+func (k keyRotater) rotateKeys(unusableKeyID string) error {
+	// Generate the key outside of a storage transaction.
+	priv, pub, err := k.signer.GenerateKey()
+	if err != nil {
+		return fmt.Errorf("generate key: %v", err)
+	}
+
+	var (
+		nextRotation time.Time
+		demotedKeyID string
+		validFor     = k.tokensValidFor()
+	)
 	err = k.Storage.UpdateKeys(func(keys storage.Keys) (storage.Keys, error) {
 		tNow := k.now()
 
 		// if you are running multiple instances of dex, another instance
 		// could have already rotated the keys.
-		if tNow.Before(keys.NextRotation) {
+		unusable := unusableKeyID != "" && keys.SigningKeyPub != nil && keys.SigningKeyPub.KeyID == unusableKeyID
+		if tNow.Before(keys.NextRotation) && !unusable {
 			return storage.Keys{}, errAlreadyRotated
 		}
 
@@ -152,6 +236,8 @@ func (k keyRotater) rotate() error {
 		keys.VerificationKeys = keys.VerificationKeys[:i]
 
 		if keys.SigningKeyPub != nil {
+			demotedKeyID = keys.SigningKeyPub.KeyID
+
 			// Move current signing key to a verification only key, throwing
 			// away the private part.
 			verificationKey := storage.VerificationKey{
@@ -160,7 +246,7 @@ func (k keyRotater) rotate() error {
 				// the amount of time an ID Token is valid for. This ensures the
 				// verification key won't expire until all ID Tokens it's signed
 				// expired as well.
-				Expiry: tNow.Add(k.strategy.idTokenValidFor),
+				Expiry: tNow.Add(validFor),
 			}
 			keys.VerificationKeys = append(keys.VerificationKeys, verificationKey)
 		}
@@ -172,8 +258,19 @@ func (k keyRotater) rotate() error {
 		return keys, nil
 	})
 	if err != nil {
+		// The generated key will never be used.
+		if err := k.signer.DeleteKey(pub.KeyID); err != nil {
+			k.logger.Errorf("failed to delete unused signing key %s: %v", pub.KeyID, err)
+		}
 		return err
 	}
 	k.logger.Infof("keys rotated, next rotation: %s", nextRotation)
+
+	// The signer never held the unusable key, so there's nothing to delete.
+	if demotedKeyID != "" && demotedKeyID != unusableKeyID {
+		if err := k.signer.DeleteKey(demotedKeyID); err != nil {
+			k.logger.Errorf("failed to delete rotated signing key %s: %v", demotedKeyID, err)
+		}
+	}
 	return nil
 }
@@ -1,6 +1,10 @@
 package server
 
 import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
 	"os"
 	"sort"
 	"testing"
@@ -17,7 +21,7 @@ func signingKeyID(t *testing.T, s storage.Storage) string {
 	if err != nil {
 		t.Fatal(err)
 	}
-	return keys.SigningKey.KeyID
+	return keys.SigningKeyPub.KeyID
 }
 
 func verificationKeyIDs(t *testing.T, s storage.Storage) (ids []string) {
@@ -73,9 +77,13 @@ func TestKeyRotater(t *testing.T) {
 		Level:     logrus.DebugLevel,
 	}
 
+	key := func() (crypto.Signer, error) {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
 	r := &keyRotater{
 		Storage:  memory.New(l),
-		strategy: defaultRotationStrategy(rotationFrequency, validFor),
+		strategy: defaultRotationStrategy(rotationFrequency, validFor, key),
+		signer:   &localSigner{key: key},
 		now:      func() time.Time { return now },
 		logger:   l,
 	}
@@ -100,3 +108,147 @@ func TestKeyRotater(t *testing.T) {
 		}
 	}
 }
+
+func TestKeyRotaterClientTokenLifetimes(t *testing.T) {
+	now := time.Now()
+
+	l := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: &logrus.TextFormatter{DisableColors: true},
+		Level:     logrus.DebugLevel,
+	}
+
+	s := memory.New(l)
+	if err := s.CreateClient(storage.Client{ID: "kubectl", IDTokenValidFor: "2h"}); err != nil {
+		t.Fatal(err)
+	}
+	key := func() (crypto.Signer, error) {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+	r := &keyRotater{
+		Storage:  s,
+		strategy: defaultRotationStrategy(time.Minute, time.Hour, key),
+		signer:   &localSigner{key: key},
+		now:      func() time.Time { return now },
+		logger:   l,
+	}
+	for i := 0; i < 2; i++ {
+		now = now.Add(time.Minute + time.Millisecond)
+		if err := r.rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := s.GetKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.VerificationKeys) != 1 {
+		t.Fatalf("expected 1 verification key, got %d", len(keys.VerificationKeys))
+	}
+	if expiry, want := keys.VerificationKeys[0].Expiry, now.Add(2*time.Hour); !expiry.Equal(want) {
+		t.Errorf("expected verification key to expire with the longest token lifetime at %s, got %s", want, expiry)
+	}
+}
+
+func TestVerificationKeysValidFor(t *testing.T) {
+	tests := []struct {
+		config Config
+		want   time.Duration
+	}{
+		{Config{}, 24 * time.Hour},
+		{Config{IDTokensValidFor: time.Hour, AccessTokensValidFor: 2 * time.Hour}, 2 * time.Hour},
+		{Config{IDTokensValidFor: 2 * time.Hour, AccessTokensValidFor: time.Hour}, 2 * time.Hour},
+		// Session cookies aren't signed, so long sessions don't keep keys around.
+		{Config{IDTokensValidFor: time.Hour, SessionsValidFor: 30 * 24 * time.Hour}, time.Hour},
+	}
+	for i, tc := range tests {
+		if got := verificationKeysValidFor(tc.config); got != tc.want {
+			t.Errorf("case %d: expected verification keys to be kept for %s, got %s", i, tc.want, got)
+		}
+	}
+}
+
+func TestSigningKeyGenerator(t *testing.T) {
+	invalid := []struct {
+		algorithm string
+		size      int
+	}{
+		{"RS256", 1024},
+		{"ES256", 2048},
+		{"HS256", 0},
+	}
+	for _, tc := range invalid {
+		if _, err := signingKeyGenerator(tc.algorithm, tc.size); err == nil {
+			t.Errorf("expected algorithm %q with size %d to be rejected", tc.algorithm, tc.size)
+		}
+	}
+}
+
+func TestKeyRotaterAlgorithmChange(t *testing.T) {
+	now := time.Now()
+	validFor := time.Hour
+
+	l := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: &logrus.TextFormatter{DisableColors: true},
+		Level:     logrus.DebugLevel,
+	}
+	s := memory.New(l)
+
+	var (
+		payloads []string
+		algs     []string
+	)
+	for _, alg := range []string{"RS256", "ES256", "ES384", "EdDSA"} {
+		key, err := signingKeyGenerator(alg, 0)
+		if err != nil {
+			t.Fatalf("failed to get key generator for %q: %v", alg, err)
+		}
+		r := &keyRotater{
+			Storage:  s,
+			strategy: defaultRotationStrategy(time.Minute, validFor, key),
+			signer:   &localSigner{key: key},
+			now:      func() time.Time { return now },
+			logger:   l,
+		}
+		now = now.Add(time.Minute + time.Millisecond)
+		if err := r.rotate(); err != nil {
+			t.Fatalf("failed to rotate to %q key: %v", alg, err)
+		}
+
+		keys, err := s.GetKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys.SigningKey.Algorithm != alg || keys.SigningKeyPub.Algorithm != alg {
+			t.Errorf("expected %q signing key, got %q", alg, keys.SigningKey.Algorithm)
+		}
+		algs = append([]string{alg}, algs...)
+		if got := signingAlgorithms(keys); !slicesEq(got, algs) {
+			t.Errorf("expected signing algorithms %q got %q", algs, got)
+		}
+
+		signingAlg, err := signatureAlgorithm(keys.SigningKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := accessTokenHash(signingAlg, "access-token"); err != nil {
+			t.Errorf("failed to compute at_hash for %q: %v", alg, err)
+		}
+		jws, err := signPayload(keys.SigningKey, signingAlg, []byte(alg), nil)
+		if err != nil {
+			t.Fatalf("failed to sign with %q key: %v", alg, err)
+		}
+		payloads = append(payloads, jws)
+
+		// Payloads signed with the keys of previous algorithms must still
+		// validate.
+		keySet := &storageKeySet{s}
+		for _, jws := range payloads {
+			if _, err := keySet.VerifySignature(context.Background(), jws); err != nil {
+				t.Errorf("after rotating to %q, failed to verify payload: %v", alg, err)
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -75,12 +76,74 @@ type Config struct {
 	// If enabled, the connectors selection page will always be shown even if there's only one
 	AlwaysShowLoginScreen bool
 
+	// Algorithm of the signing keys generated on rotation: "RS256", "ES256",
+	// "ES384" or "EdDSA". Defaults to "RS256". Changing the algorithm takes
+	// effect at the next rotation, tokens signed with old keys remain valid.
+	SigningKeyAlgorithm string
+	// Size of RSA signing keys in bits: 2048, 3072 or 4096. Defaults to 2048.
+	SigningKeySize int
+
+	// Signer generating and holding the signing keys. If nil, keys are
+	// generated in memory according to SigningKeyAlgorithm and SigningKeySize,
+	// and stored along with their private parts. The signer picks the algorithm
+	// of its keys, so SigningKeyAlgorithm and SigningKeySize must not be set.
+	Signer Signer
+
 	RotateKeysAfter      time.Duration // Defaults to 6 hours.
 	IDTokensValidFor     time.Duration // Defaults to 24 hours
+	AccessTokensValidFor time.Duration // Defaults to IDTokensValidFor
 	AuthRequestsValidFor time.Duration // Defaults to 24 hours
+
+	// Format of issued access tokens: "jwt" for RFC 9068 JWT access tokens or
+	// "opaque" for random tokens resolved through storage by the userinfo and
+	// introspection endpoints. Defaults to "jwt".
+	AccessTokenFormat string
+
+	// Duration a device code remains valid for the device flow.
+	DeviceRequestsValidFor time.Duration // Defaults to 5 minutes
+
+	// Lifetime of a user's browser session. While the session is valid, users
+	// don't have to log in with a connector again. Sessions are disabled if zero.
+	SessionsValidFor time.Duration
+	// Sessions not used for this long expire early. No idle timeout if zero.
+	SessionIdleTimeout time.Duration
+
+	// Absolute lifetime of refresh tokens, counted from the initial login. Refresh
+	// tokens never expire if zero. Clients may override it.
+	RefreshTokensValidFor time.Duration
+	// Refresh tokens not used for this long expire early. No idle timeout if zero.
+	// Clients may override it.
+	RefreshTokenIdleTimeout time.Duration
+	// Duration after a refresh during which the previous refresh token is still
+	// accepted, for clients sending concurrent refresh requests. Past it, using a
+	// rotated refresh token revokes that refresh token. Reuse is never allowed if
+	// zero.
+	RefreshTokenReuseInterval time.Duration
+
 	// If set, the server will use this connector to handle password grants
 	PasswordConnector string
 
+	// Additional scopes clients can request, mapped to the custom claims from
+	// connectors they grant access to in ID tokens, access tokens and userinfo.
+	CustomScopes map[string][]string
+
+	// If enabled, logging out through the end session endpoint with an
+	// unexpired id_token_hint also revokes all refresh tokens of the user.
+	RevokeRefreshTokensOnLogout bool
+
+	// If set, clients can register themselves at the registration endpoint by
+	// presenting this token. Dynamic client registration is disabled if empty.
+	InitialAccessToken string
+
+	// If enabled, clients can authenticate at the token endpoint with TLS client
+	// certificates, and tokens issued to them are bound to their certificate.
+	// The HTTPS listener must request client certificates.
+	TLSClientAuth bool
+
+	// CAs client certificates are verified against for tls_client_auth. Only
+	// self-signed certificates registered with clients are accepted if nil.
+	TLSClientCAs *x509.CertPool
+
 	GCFrequency time.Duration // Defaults to 5 minutes
 
 	// If specified, the server will use this function for determining time.
@@ -152,24 +215,79 @@ type Server struct {
 	// Used for password grant
 	passwordConnector string
 
+	// If enabled, revoke the user's refresh tokens on logout
+	revokeRefreshTokensOnLogout bool
+
+	// Required to register clients dynamically
+	initialAccessToken string
+
+	// Used for TLS client certificate authentication
+	tlsClientAuth bool
+	tlsClientCAs  *x509.CertPool
+
 	supportedResponseTypes map[string]bool
 
+	// Either "jwt" or "opaque".
+	accessTokenFormat string
+
 	now func() time.Time
 
-	idTokensValidFor     time.Duration
-	authRequestsValidFor time.Duration
+	idTokensValidFor       time.Duration
+	accessTokensValidFor   time.Duration
+	authRequestsValidFor   time.Duration
+	deviceRequestsValidFor time.Duration
+	sessionsValidFor       time.Duration
+	sessionIdleTimeout     time.Duration
+
+	refreshTokensValidFor     time.Duration
+	refreshTokenIdleTimeout   time.Duration
+	refreshTokenReuseInterval time.Duration
+
+	customScopes map[string][]string
+
+	// Used to fetch request objects and client key sets.
+	httpClient *http.Client
+
+	// mutex for the remoteKeySets map.
+	keySetsMu sync.Mutex
+	// Map of client IDs to the cached key sets of their JWKS URIs.
+	remoteKeySets map[string]remoteKeySet
+
+	// Counts security events by type, nil without a Prometheus registry.
+	securityEvents *prometheus.CounterVec
+
+	signer Signer
 
 	logger log.Logger
 }
 
 // NewServer constructs a server from the provided config.
 func NewServer(ctx context.Context, c Config) (*Server, error) {
+	if c.Signer != nil && (c.SigningKeyAlgorithm != "" || c.SigningKeySize != 0) {
+		return nil, errors.New("server: signing key algorithm and size can't be configured with a signer")
+	}
+	key, err := signingKeyGenerator(c.SigningKeyAlgorithm, c.SigningKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("server: %v", err)
+	}
 	return newServer(ctx, c, defaultRotationStrategy(
 		value(c.RotateKeysAfter, 6*time.Hour),
-		value(c.IDTokensValidFor, 24*time.Hour),
+		verificationKeysValidFor(c),
+		key,
 	))
 }
 
+// verificationKeysValidFor returns how long keys are kept after being rotated.
+// They must outlive the ID and access tokens they signed.
+func verificationKeysValidFor(c Config) time.Duration {
+	idTokensValidFor := value(c.IDTokensValidFor, 24*time.Hour)
+	validFor := value(c.AccessTokensValidFor, idTokensValidFor)
+	if validFor < idTokensValidFor {
+		validFor = idTokensValidFor
+	}
+	return validFor
+}
+
 func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy) (*Server, error) {
 	issuerURL, err := url.Parse(c.Issuer)
 	if err != nil {
@@ -193,6 +311,18 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 		supported[respType] = true
 	}
 
+	switch c.AccessTokenFormat {
+	case "":
+		c.AccessTokenFormat = accessTokenFormatJWT
+	case accessTokenFormatJWT, accessTokenFormatOpaque:
+	default:
+		return nil, fmt.Errorf("server: unsupported access token format %q", c.AccessTokenFormat)
+	}
+
+	if err := validateCustomScopes(c.CustomScopes); err != nil {
+		return nil, fmt.Errorf("server: %v", err)
+	}
+
 	web := webConfig{
 		dir:       c.Web.Dir,
 		logoURL:   c.Web.LogoURL,
@@ -212,19 +342,42 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 		now = time.Now
 	}
 
+	idTokensValidFor := value(c.IDTokensValidFor, 24*time.Hour)
+
 	s := &Server{
 		issuerURL:              *issuerURL,
 		connectors:             make(map[string]Connector),
 		storage:                newKeyCacher(c.Storage, now),
 		supportedResponseTypes: supported,
-		idTokensValidFor:       value(c.IDTokensValidFor, 24*time.Hour),
+		idTokensValidFor:       idTokensValidFor,
+		accessTokensValidFor:   value(c.AccessTokensValidFor, idTokensValidFor),
+		accessTokenFormat:      c.AccessTokenFormat,
 		authRequestsValidFor:   value(c.AuthRequestsValidFor, 24*time.Hour),
+		deviceRequestsValidFor: value(c.DeviceRequestsValidFor, 5*time.Minute),
+		sessionsValidFor:       c.SessionsValidFor,
+		sessionIdleTimeout:     c.SessionIdleTimeout,
 		skipApproval:           c.SkipApprovalScreen,
 		alwaysShowLogin:        c.AlwaysShowLoginScreen,
 		now:                    now,
 		templates:              tmpls,
 		passwordConnector:      c.PasswordConnector,
 		logger:                 c.Logger,
+
+		revokeRefreshTokensOnLogout: c.RevokeRefreshTokensOnLogout,
+		refreshTokensValidFor:       c.RefreshTokensValidFor,
+		refreshTokenIdleTimeout:     c.RefreshTokenIdleTimeout,
+		refreshTokenReuseInterval:   c.RefreshTokenReuseInterval,
+		initialAccessToken:          c.InitialAccessToken,
+		tlsClientAuth:               c.TLSClientAuth,
+		tlsClientCAs:                c.TLSClientCAs,
+		customScopes:                c.CustomScopes,
+		httpClient:                  &http.Client{Timeout: 10 * time.Second},
+		remoteKeySets:               make(map[string]remoteKeySet),
+	}
+
+	s.signer = c.Signer
+	if s.signer == nil {
+		s.signer = &localSigner{key: rotationStrategy.key}
 	}
 
 	// Retrieves connector objects in backend storage. This list includes the static connectors
@@ -267,6 +420,16 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 				requestCounter.With(prometheus.Labels{"handler": handlerName, "code": strconv.Itoa(m.Code), "method": r.Method}).Inc()
 			})
 		}
+
+		s.securityEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "security_events_total",
+			Help: "Count of security events, such as the reuse of a rotated refresh token.",
+		}, []string{"event"})
+
+		err = c.PrometheusRegistry.Register(s.securityEvents)
+		if err != nil {
+			return nil, fmt.Errorf("server: Failed to register Prometheus security event metrics: %v", err)
+		}
 	}
 
 	r := mux.NewRouter()
@@ -290,14 +453,13 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 	}
 	r.NotFoundHandler = http.HandlerFunc(http.NotFound)
 
-	discoveryHandler, err := s.discoveryHandler()
-	if err != nil {
-		return nil, err
-	}
-	handleWithCORS("/.well-known/openid-configuration", discoveryHandler)
+	handleWithCORS("/.well-known/openid-configuration", s.discoveryHandler())
 
 	// TODO(ericchiang): rate limit certain paths based on IP.
 	handleWithCORS("/token", s.handleToken)
+	handleWithCORS("/par", s.handlePushedAuthRequest)
+	handleWithCORS("/token/revoke", s.handleRevokeToken)
+	handleWithCORS("/token/introspect", s.handleIntrospectToken)
 	handleWithCORS("/keys", s.handlePublicKeys)
 	handleWithCORS("/userinfo", s.handleUserInfo)
 	handleFunc("/auth", s.handleAuthorization)
@@ -316,6 +478,16 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 	// "authproxy" connector.
 	handleFunc("/callback/{connector}", s.handleConnectorCallback)
 	handleFunc("/approval", s.handleApproval)
+	handleWithCORS("/device/code", s.handleDeviceCode)
+	handleFunc("/device", s.handleDeviceExchange)
+	handleFunc("/device/auth/verify_code", s.verifyUserCode)
+	handleFunc("/device/callback", s.handleDeviceCallback)
+	handleFunc("/logout", s.handleLogout)
+	handleFunc(logoutCallbackURI, s.handleLogoutCallback)
+	if s.registrationEnabled() {
+		handleFunc(registrationURI, s.handleRegister)
+		handleFunc(registrationURI+"/{client}", s.handleRegisteredClient)
+	}
 	handle("/healthz", s.newHealthChecker(ctx))
 	handlePrefix("/static", static)
 	handlePrefix("/theme", theme)
@@ -440,6 +612,20 @@ func (k *keyCacher) GetKeys() (storage.Keys, error) {
 	return storageKeys, nil
 }
 
+// Security events reported by securityEvent.
+const (
+	securityEventRefreshTokenReuse = "refresh_token_reuse"
+)
+
+// securityEvent reports suspicious activity in the logs and, if enabled, in the
+// security_events_total metric.
+func (s *Server) securityEvent(event string, format string, args ...interface{}) {
+	s.logger.Warnf("security event %s: %s", event, fmt.Sprintf(format, args...))
+	if s.securityEvents != nil {
+		s.securityEvents.WithLabelValues(event).Inc()
+	}
+}
+
 func (s *Server) startGarbageCollection(ctx context.Context, frequency time.Duration, now func() time.Time) {
 	go func() {
 		for {
@@ -449,8 +635,10 @@ func (s *Server) startGarbageCollection(ctx context.Context, frequency time.Dura
 			case <-time.After(frequency):
 				if r, err := s.storage.GarbageCollect(now()); err != nil {
 					s.logger.Errorf("garbage collection failed: %v", err)
-				} else if r.AuthRequests > 0 || r.AuthCodes > 0 {
-					s.logger.Infof("garbage collection run, delete auth requests=%d, auth codes=%d", r.AuthRequests, r.AuthCodes)
+				} else if !r.IsEmpty() {
+					s.logger.Infof("garbage collection run, delete auth requests=%d, auth codes=%d, device requests=%d, device tokens=%d, sessions=%d, access tokens=%d, refresh tokens=%d, offline sessions=%d, client assertions=%d, dpop proofs=%d",
+						r.AuthRequests, r.AuthCodes, r.DeviceRequests, r.DeviceTokens, r.Sessions, r.AccessTokens,
+						r.RefreshTokens, r.OfflineSessions, r.ClientAssertions, r.DPoPProofs)
 				}
 			}
 		}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// sessionCookieName is the name of the cookie referencing the user's browser
+// session. Its value is the session ID, a random value which can't be guessed,
+// so it doesn't need to be signed.
+const sessionCookieName = "dex_session"
+
+func (s *Server) sessionsEnabled() bool {
+	return s.sessionsValidFor > 0
+}
+
+// createSession stores a browser session for an identity that has just been
+// authenticated by a connector and sets the session cookie.
+func (s *Server) createSession(w http.ResponseWriter, authReq storage.AuthRequest, claims storage.Claims, connectorData []byte) error {
+	now := s.now()
+	session := storage.Session{
+		ID:            storage.NewID(),
+		ConnectorID:   authReq.ConnectorID,
+		ConnectorData: connectorData,
+		Claims:        claims,
+		Scopes:        authReq.Scopes,
+		CreatedAt:     now,
+		LastUsed:      now,
+		Expiry:        now.Add(s.sessionsValidFor),
+	}
+	if err := s.storage.CreateSession(session); err != nil {
+		return fmt.Errorf("failed to create session: %v", err)
+	}
+
+	http.SetCookie(w, s.sessionCookie(session.ID, session.Expiry))
+	return nil
+}
+
+// sessionFromRequest returns the valid session referenced by the request's
+// session cookie, if any.
+func (s *Server) sessionFromRequest(r *http.Request) (storage.Session, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return storage.Session{}, false
+	}
+
+	session, err := s.storage.GetSession(cookie.Value)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			s.logger.Errorf("Failed to get session: %v", err)
+		}
+		return storage.Session{}, false
+	}
+
+	now := s.now()
+	if now.After(session.Expiry) {
+		return storage.Session{}, false
+	}
+	if s.sessionIdleTimeout > 0 && now.After(session.LastUsed.Add(s.sessionIdleTimeout)) {
+		return storage.Session{}, false
+	}
+	return session, true
+}
+
+// reusableSession returns the user's session if it can satisfy the
+// authorization request without logging in with a connector again.
+func (s *Server) reusableSession(r *http.Request, authReq storage.AuthRequest) (storage.Session, bool) {
+	if !s.sessionsEnabled() {
+		return storage.Session{}, false
+	}
+	session, ok := s.sessionFromRequest(r)
+	if !ok {
+		return storage.Session{}, false
+	}
+
+	for _, prompt := range strings.Fields(authReq.Prompt) {
+		if prompt == "login" {
+			return storage.Session{}, false
+		}
+	}
+	if authReq.MaxAge > 0 && s.now().Sub(session.CreatedAt) > time.Duration(authReq.MaxAge)*time.Second {
+		return storage.Session{}, false
+	}
+
+	if authReq.ConnectorID != "" && authReq.ConnectorID != session.ConnectorID {
+		return storage.Session{}, false
+	}
+	if _, err := s.getConnector(session.ConnectorID); err != nil {
+		return storage.Session{}, false
+	}
+	client, err := s.storage.GetClient(authReq.ClientID)
+	if err != nil || !clientAllows(client.AllowedConnectors, session.ConnectorID) {
+		return storage.Session{}, false
+	}
+
+	// The connector only returned groups and refresh data if they were
+	// requested when the user logged in.
+	have, want := parseScopes(session.Scopes), parseScopes(authReq.Scopes)
+	if (want.Groups && !have.Groups) || (want.OfflineAccess && !have.OfflineAccess) {
+		return storage.Session{}, false
+	}
+	return session, true
+}
+
+// resumeSession associates the identity of an existing session with the
+// AuthRequest, then returns the approval page's path.
+func (s *Server) resumeSession(authReq storage.AuthRequest, session storage.Session) (string, error) {
+	updater := func(a storage.AuthRequest) (storage.AuthRequest, error) {
+		a.LoggedIn = true
+		a.Claims = session.Claims
+		a.ConnectorID = session.ConnectorID
+		a.ConnectorData = session.ConnectorData
+		a.AuthTime = session.CreatedAt
+		return a, nil
+	}
+	if err := s.storage.UpdateAuthRequest(authReq.ID, updater); err != nil {
+		return "", fmt.Errorf("failed to update auth request: %v", err)
+	}
+
+	if err := s.storage.UpdateSession(session.ID, func(old storage.Session) (storage.Session, error) {
+		old.LastUsed = s.now()
+		return old, nil
+	}); err != nil {
+		return "", fmt.Errorf("failed to update session: %v", err)
+	}
+
+	s.logger.Infof("login resumed from session: connector %q, username=%q", session.ConnectorID, session.Claims.Username)
+
+	return path.Join(s.issuerURL.Path, "/approval") + "?req=" + authReq.ID, nil
+}
+
+// deleteSession removes the session referenced by the request's session
+// cookie, if any, and clears the cookie.
+func (s *Server) deleteSession(w http.ResponseWriter, r *http.Request) error {
+	session, ok := s.sessionFromRequest(r)
+	if !ok {
+		if _, err := r.Cookie(sessionCookieName); err == nil {
+			http.SetCookie(w, s.sessionCookie("", time.Unix(0, 0)))
+		}
+		return nil
+	}
+
+	if err := s.storage.DeleteSession(session.ID); err != nil && err != storage.ErrNotFound {
+		return fmt.Errorf("failed to delete session: %v", err)
+	}
+	http.SetCookie(w, s.sessionCookie("", time.Unix(0, 0)))
+	return nil
+}
+
+func (s *Server) sessionCookie(value string, expiry time.Time) *http.Cookie {
+	return s.cookie(sessionCookieName, value, expiry)
+}
+
+func (s *Server) cookie(name, value string, expiry time.Time) *http.Cookie {
+	cookiePath := s.issuerURL.Path
+	if cookiePath == "" {
+		cookiePath = "/"
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     cookiePath,
+		Expires:  expiry,
+		Secure:   s.issuerURL.Scheme == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
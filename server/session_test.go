@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestSessionReuse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.SessionsValidFor = time.Hour
+		c.SessionIdleTimeout = 10 * time.Minute
+		c.Now = func() time.Time { return now }
+	})
+	defer httpServer.Close()
+
+	for _, id := range []string{"foo", "bar"} {
+		client := storage.Client{ID: id, Secret: "secret", RedirectURIs: []string{"https://example.com/callback"}}
+		if err := s.storage.CreateClient(client); err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+	}
+
+	do := func(target string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+	authorize := func(clientID string, params url.Values, cookie *http.Cookie) string {
+		v := url.Values{
+			"client_id":     {clientID},
+			"redirect_uri":  {"https://example.com/callback"},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+			"state":         {"state"},
+		}
+		for key, values := range params {
+			v[key] = values
+		}
+		rr := do("/auth?"+v.Encode(), cookie)
+		if rr.Code != http.StatusFound {
+			t.Fatalf("expected 302 from /auth got %d: %s", rr.Code, rr.Body.String())
+		}
+		return rr.Header().Get("Location")
+	}
+
+	// Log in through the mock connector to start a session.
+	location := authorize("foo", nil, nil)
+	if !strings.HasPrefix(location, "/auth/mock") {
+		t.Fatalf("expected redirect to connector login, got %q", location)
+	}
+	rr := do(location, nil)
+	callbackURL, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to parse callback URL: %v", err)
+	}
+	rr = do(callbackURL.RequestURI(), nil)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303 from callback got %d: %s", rr.Code, rr.Body.String())
+	}
+	var cookie *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatalf("expected session cookie to be set")
+	}
+	if !cookie.HttpOnly {
+		t.Errorf("expected session cookie to be HttpOnly")
+	}
+
+	loginTime := now
+
+	tampered := *cookie
+	tampered.Value = cookie.Value[:len(cookie.Value)-4] + "AAAA"
+
+	// push sends the authorization request to the pushed authorization request
+	// endpoint and returns the parameters referencing it.
+	push := func(clientID string, params url.Values) url.Values {
+		v := url.Values{
+			"client_id":     {clientID},
+			"redirect_uri":  {"https://example.com/callback"},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+			"state":         {"state"},
+		}
+		for key, values := range params {
+			v[key] = values
+		}
+		req := httptest.NewRequest("POST", "/par", strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, "secret")
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected 201 from /par got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp pushedAuthResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode pushed authorization response: %v", err)
+		}
+		return url.Values{"request_uri": {resp.RequestURI}}
+	}
+
+	tests := []struct {
+		name    string
+		params  url.Values
+		pushed  bool
+		cookie  *http.Cookie
+		elapsed time.Duration
+		reused  bool
+	}{
+		{"no cookie", nil, false, nil, 0, false},
+		{"valid session", nil, false, cookie, time.Minute, true},
+		{"tampered cookie", nil, false, &tampered, 0, false},
+		{"prompt login", url.Values{"prompt": {"login"}}, false, cookie, 0, false},
+		{"max age satisfied", url.Values{"max_age": {"3600"}}, false, cookie, 0, true},
+		{"max age exceeded", url.Values{"max_age": {"30"}}, false, cookie, 0, false},
+		{"max age zero", url.Values{"max_age": {"0"}}, false, cookie, 0, false},
+		{"pushed request", nil, true, cookie, 0, true},
+		{"pushed prompt login", url.Values{"prompt": {"login"}}, true, cookie, 0, false},
+		{"pushed max age exceeded", url.Values{"max_age": {"30"}}, true, cookie, 0, false},
+		{"groups not requested at login", url.Values{"scope": {"openid groups"}}, false, cookie, 0, false},
+		{"idle timeout", nil, false, cookie, 11 * time.Minute, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now = now.Add(tc.elapsed)
+
+			params := tc.params
+			if tc.pushed {
+				params = push("bar", tc.params)
+			}
+			location := authorize("bar", params, tc.cookie)
+			if reused := strings.HasPrefix(location, "/approval"); reused != tc.reused {
+				t.Fatalf("expected session reused=%t, got redirect to %q", tc.reused, location)
+			}
+			if !tc.reused {
+				return
+			}
+
+			authReqID, err := url.Parse(location)
+			if err != nil {
+				t.Fatalf("failed to parse approval URL: %v", err)
+			}
+			authReq, err := s.storage.GetAuthRequest(authReqID.Query().Get("req"))
+			if err != nil {
+				t.Fatalf("failed to get auth request: %v", err)
+			}
+			if !authReq.LoggedIn || authReq.ConnectorID != "mock" || authReq.Claims.UserID == "" {
+				t.Errorf("expected auth request to be logged in, got %#v", authReq)
+			}
+			if !authReq.AuthTime.Equal(loginTime) {
+				t.Errorf("expected auth time of the session %s, got %s", loginTime, authReq.AuthTime)
+			}
+
+			// ID tokens issued for the request carry the original auth time.
+			idToken, _, err := s.newIDToken(authReq.ClientID, authReq.Claims, authReq.Scopes, authReq.Nonce, "", authReq.ConnectorID, "", authReq.AuthTime)
+			if err != nil {
+				t.Fatalf("failed to issue ID token: %v", err)
+			}
+			jws, err := jose.ParseSigned(idToken)
+			if err != nil {
+				t.Fatalf("failed to parse ID token: %v", err)
+			}
+			var claims idTokenClaims
+			if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+				t.Fatalf("failed to decode ID token claims: %v", err)
+			}
+			if claims.AuthTime != loginTime.Unix() {
+				t.Errorf("expected auth_time %d, got %d", loginTime.Unix(), claims.AuthTime)
+			}
+		})
+	}
+}
+
+func TestLogoutEndsSession(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.SessionsValidFor = time.Hour
+	})
+	defer httpServer.Close()
+
+	rr := httptest.NewRecorder()
+	authReq := storage.AuthRequest{ID: storage.NewID(), ConnectorID: "mock", Scopes: []string{"openid"}}
+	if err := s.createSession(rr, authReq, storage.Claims{UserID: "user"}, nil); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	cookie := rr.Result().Cookies()[0]
+
+	req := httptest.NewRequest("GET", "/logout", nil)
+	req.AddCookie(cookie)
+	session, ok := s.sessionFromRequest(req)
+	if !ok {
+		t.Fatalf("expected session to be valid")
+	}
+
+	// Without an id_token_hint the user has to confirm the logout.
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := s.storage.GetSession(session.ID); err != nil {
+		t.Fatalf("expected session to be kept, got error %v", err)
+	}
+	logoutCookie := rr.Result().Cookies()[0]
+
+	form := url.Values{"logout_token": {logoutCookie.Value}}
+	req = httptest.NewRequest("POST", "/logout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookie)
+	req.AddCookie(logoutCookie)
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := s.storage.GetSession(session.ID); err != storage.ErrNotFound {
+		t.Errorf("expected session to be deleted, got error %v", err)
+	}
+	cleared := map[string]bool{}
+	for _, c := range rr.Result().Cookies() {
+		cleared[c.Name] = c.Value == ""
+	}
+	if !cleared[sessionCookieName] || !cleared[logoutCookieName] {
+		t.Errorf("expected cookies to be cleared, got %v", rr.Result().Cookies())
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// Signer creates and holds the keys used to sign ID tokens and access tokens.
+//
+// Implementations backed by a token device or a key management service never
+// return the private part of the keys they generate. In that case only the
+// public keys end up in storage and are served through the "/keys" endpoint.
+type Signer interface {
+	// GenerateKey creates a new signing key. priv is nil if the private key
+	// never leaves the signer.
+	GenerateKey() (priv, pub *jose.JSONWebKey, err error)
+
+	// Sign signs the payload with the active signing key of keys, returning
+	// the compact serialized JWS. opts holds additional protected headers,
+	// such as "typ", and may be nil.
+	Sign(keys storage.Keys, payload []byte, opts *jose.SignerOptions) (jws string, err error)
+
+	// CanSign reports whether the signer holds the active signing key of keys.
+	// Keys created by another signer, for example before switching to a token
+	// device, are rotated out when the server starts.
+	CanSign(keys storage.Keys) (bool, error)
+
+	// DeleteKey is called once a signing key has been rotated out and is only
+	// used to verify signatures.
+	DeleteKey(keyID string) error
+}
+
+// localSigner generates keys in memory and keeps the private keys in storage.
+type localSigner struct {
+	key func() (crypto.Signer, error)
+}
+
+func (l *localSigner) GenerateKey() (priv, pub *jose.JSONWebKey, err error) {
+	key, err := l.key()
+	if err != nil {
+		return nil, nil, err
+	}
+	alg, err := signatureAlgorithm(&jose.JSONWebKey{Key: key})
+	if err != nil {
+		return nil, nil, err
+	}
+	keyID := newKeyID()
+	priv = &jose.JSONWebKey{
+		Key:       key,
+		KeyID:     keyID,
+		Algorithm: string(alg),
+		Use:       "sig",
+	}
+	pub = &jose.JSONWebKey{
+		Key:       key.Public(),
+		KeyID:     keyID,
+		Algorithm: string(alg),
+		Use:       "sig",
+	}
+	return priv, pub, nil
+}
+
+func (l *localSigner) Sign(keys storage.Keys, payload []byte, opts *jose.SignerOptions) (string, error) {
+	if keys.SigningKey == nil {
+		return "", errors.New("no key to sign payload with")
+	}
+	alg, err := signatureAlgorithm(keys.SigningKey)
+	if err != nil {
+		return "", err
+	}
+	return signPayload(keys.SigningKey, alg, payload, opts)
+}
+
+func (l *localSigner) CanSign(keys storage.Keys) (bool, error) {
+	return keys.SigningKey != nil, nil
+}
+
+func (l *localSigner) DeleteKey(keyID string) error {
+	// The private key is thrown away by the rotation itself.
+	return nil
+}
+
+func newKeyID() string {
+	b := make([]byte, 20)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// signingKeys returns the keys new payloads are signed with, along with the
+// algorithm of the active signing key.
+func (s *Server) signingKeys() (storage.Keys, jose.SignatureAlgorithm, error) {
+	keys, err := s.storage.GetKeys()
+	if err != nil {
+		return keys, "", err
+	}
+	if keys.SigningKeyPub == nil {
+		return keys, "", errors.New("no key to sign payload with")
+	}
+	if alg := keys.SigningKeyPub.Algorithm; alg != "" {
+		return keys, jose.SignatureAlgorithm(alg), nil
+	}
+	// Keys stored without an algorithm were always RSA keys.
+	return keys, jose.RS256, nil
+}
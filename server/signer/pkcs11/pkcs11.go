@@ -0,0 +1,198 @@
+// Package pkcs11 implements a signer keeping the signing keys inside a PKCS#11
+// token, such as a hardware security module.
+package pkcs11
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ThalesIgnite/crypto11"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/cryptosigner"
+
+	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/server"
+	"github.com/dexidp/dex/storage"
+)
+
+// Config holds the options to connect to a PKCS#11 token. Exactly one of
+// TokenLabel, TokenSerial or SlotNumber must be set to select the token.
+type Config struct {
+	// Path of the PKCS#11 module, e.g. "/usr/lib/softhsm/libsofthsm2.so".
+	Path string `json:"path"`
+
+	TokenLabel  string `json:"tokenLabel"`
+	TokenSerial string `json:"tokenSerial"`
+	SlotNumber  *int   `json:"slotNumber"`
+
+	// User PIN of the token.
+	Pin string `json:"pin"`
+
+	// Algorithm of the generated keys: "RS256", "ES256" or "ES384".
+	// Defaults to "RS256".
+	Algorithm string `json:"algorithm"`
+	// Size of RSA keys in bits: 2048, 3072 or 4096. Defaults to 2048.
+	KeySize int `json:"keySize"`
+}
+
+// Open connects to the PKCS#11 token.
+func (c *Config) Open(logger log.Logger) (server.Signer, error) {
+	s, err := c.open(logger)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *Config) open(logger log.Logger) (*signer, error) {
+	if c.Path == "" {
+		return nil, errors.New("pkcs11: no module path specified")
+	}
+
+	alg := jose.SignatureAlgorithm(c.Algorithm)
+	if alg == "" {
+		alg = jose.RS256
+	}
+	if alg != jose.RS256 && c.KeySize != 0 {
+		return nil, fmt.Errorf("pkcs11: key size can't be configured for signing algorithm %q", alg)
+	}
+
+	s := &signer{alg: alg, keySize: c.KeySize, logger: logger}
+	switch alg {
+	case jose.RS256:
+		switch s.keySize {
+		case 0:
+			s.keySize = 2048
+		case 2048, 3072, 4096:
+		default:
+			return nil, fmt.Errorf("pkcs11: unsupported RSA key size %d", c.KeySize)
+		}
+	case jose.ES256:
+		s.curve = elliptic.P256()
+	case jose.ES384:
+		s.curve = elliptic.P384()
+	default:
+		return nil, fmt.Errorf("pkcs11: unsupported signing algorithm %q", alg)
+	}
+
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path:        c.Path,
+		TokenLabel:  c.TokenLabel,
+		TokenSerial: c.TokenSerial,
+		SlotNumber:  c.SlotNumber,
+		Pin:         c.Pin,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: failed to open token: %v", err)
+	}
+	s.ctx = ctx
+	return s, nil
+}
+
+// signer generates key pairs inside the token, identified by their key ID.
+// The private keys are never exported.
+type signer struct {
+	ctx *crypto11.Context
+
+	alg     jose.SignatureAlgorithm
+	keySize int
+	curve   elliptic.Curve
+
+	logger log.Logger
+}
+
+func (s *signer) GenerateKey() (priv, pub *jose.JSONWebKey, err error) {
+	b := make([]byte, 20)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, nil, err
+	}
+	keyID := hex.EncodeToString(b)
+
+	var key crypto.Signer
+	if s.alg == jose.RS256 {
+		key, err = s.ctx.GenerateRSAKeyPair([]byte(keyID), s.keySize)
+	} else {
+		key, err = s.ctx.GenerateECDSAKeyPair([]byte(keyID), s.curve)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("pkcs11: failed to generate key pair: %v", err)
+	}
+	s.logger.Infof("pkcs11: generated signing key %s", keyID)
+
+	pub = &jose.JSONWebKey{
+		Key:       key.Public(),
+		KeyID:     keyID,
+		Algorithm: string(s.alg),
+		Use:       "sig",
+	}
+	return nil, pub, nil
+}
+
+func (s *signer) Sign(keys storage.Keys, payload []byte, opts *jose.SignerOptions) (string, error) {
+	if keys.SigningKeyPub == nil {
+		return "", errors.New("no key to sign payload with")
+	}
+	keyID := keys.SigningKeyPub.KeyID
+	key, err := s.findKey(keyID)
+	if err != nil {
+		return "", err
+	}
+
+	signingKey := jose.SigningKey{
+		Key:       &jose.JSONWebKey{Key: cryptosigner.Opaque(key), KeyID: keyID},
+		Algorithm: jose.SignatureAlgorithm(keys.SigningKeyPub.Algorithm),
+	}
+	if opts == nil {
+		opts = &jose.SignerOptions{}
+	}
+	jwsSigner, err := jose.NewSigner(signingKey, opts)
+	if err != nil {
+		return "", fmt.Errorf("new signer: %v", err)
+	}
+	signature, err := jwsSigner.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("signing payload: %v", err)
+	}
+	return signature.CompactSerialize()
+}
+
+func (s *signer) CanSign(keys storage.Keys) (bool, error) {
+	// Private keys in storage were generated by the local signer and must be
+	// rotated out, even if the token happens to hold a key with the same ID.
+	if keys.SigningKey != nil || keys.SigningKeyPub == nil {
+		return false, nil
+	}
+	key, err := s.ctx.FindKeyPair([]byte(keys.SigningKeyPub.KeyID), nil)
+	if err != nil {
+		return false, fmt.Errorf("pkcs11: failed to find key %s: %v", keys.SigningKeyPub.KeyID, err)
+	}
+	return key != nil, nil
+}
+
+func (s *signer) DeleteKey(keyID string) error {
+	key, err := s.findKey(keyID)
+	if err != nil {
+		return err
+	}
+	if err := key.Delete(); err != nil {
+		return fmt.Errorf("pkcs11: failed to delete key %s: %v", keyID, err)
+	}
+	s.logger.Infof("pkcs11: deleted signing key %s", keyID)
+	return nil
+}
+
+func (s *signer) findKey(keyID string) (crypto11.Signer, error) {
+	key, err := s.ctx.FindKeyPair([]byte(keyID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: failed to find key %s: %v", keyID, err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("pkcs11: key %s not found in token", keyID)
+	}
+	return key, nil
+}
@@ -0,0 +1,105 @@
+package pkcs11
+
+import (
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// The tests run against a token set up for instance with SoftHSM:
+//
+//   softhsm2-util --init-token --free --label dex --pin 1234 --so-pin 1234
+//   DEX_PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so DEX_PKCS11_PIN=1234 go test
+const testModuleEnv = "DEX_PKCS11_MODULE"
+
+func getenv(key, defaultVal string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defaultVal
+}
+
+func TestSigner(t *testing.T) {
+	path := os.Getenv(testModuleEnv)
+	if path == "" {
+		t.Skipf("test environment variable %q not set, skipping", testModuleEnv)
+	}
+
+	logger := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: &logrus.TextFormatter{DisableColors: true},
+		Level:     logrus.DebugLevel,
+	}
+
+	for _, alg := range []string{"RS256", "ES256", "ES384"} {
+		t.Run(alg, func(t *testing.T) {
+			c := &Config{
+				Path:       path,
+				TokenLabel: getenv("DEX_PKCS11_TOKEN_LABEL", "dex"),
+				Pin:        getenv("DEX_PKCS11_PIN", "1234"),
+				Algorithm:  alg,
+			}
+			s, err := c.open(logger)
+			if err != nil {
+				t.Fatalf("failed to open token: %v", err)
+			}
+			defer s.ctx.Close()
+
+			priv, pub, err := s.GenerateKey()
+			if err != nil {
+				t.Fatalf("failed to generate key: %v", err)
+			}
+			if priv != nil {
+				t.Errorf("expected private key to stay in the token")
+			}
+			if pub.Algorithm != alg {
+				t.Errorf("expected %q key, got %q", alg, pub.Algorithm)
+			}
+
+			payload := []byte("payload")
+			jws, err := s.Sign(storage.Keys{SigningKeyPub: pub}, payload, nil)
+			if err != nil {
+				t.Fatalf("failed to sign payload: %v", err)
+			}
+			sig, err := jose.ParseSigned(jws)
+			if err != nil {
+				t.Fatalf("failed to parse signature: %v", err)
+			}
+			if kid := sig.Signatures[0].Header.KeyID; kid != pub.KeyID {
+				t.Errorf("expected key ID %q, got %q", pub.KeyID, kid)
+			}
+			got, err := sig.Verify(pub)
+			if err != nil {
+				t.Fatalf("failed to verify signature: %v", err)
+			}
+			if string(got) != string(payload) {
+				t.Errorf("expected payload %q, got %q", payload, got)
+			}
+
+			if err := s.DeleteKey(pub.KeyID); err != nil {
+				t.Fatalf("failed to delete key: %v", err)
+			}
+			if _, err := s.Sign(storage.Keys{SigningKeyPub: pub}, payload, nil); err == nil {
+				t.Errorf("expected signing with a deleted key to fail")
+			}
+		})
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	invalid := []Config{
+		{},
+		{Path: "libsofthsm2.so", Algorithm: "EdDSA"},
+		{Path: "libsofthsm2.so", Algorithm: "ES256", KeySize: 2048},
+		{Path: "libsofthsm2.so", KeySize: 1024},
+	}
+	for _, c := range invalid {
+		if _, err := c.open(nil); err == nil {
+			t.Errorf("expected config %+v to be rejected", c)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+// deviceSigner mimics a signer keeping its private keys in a token device.
+type deviceSigner struct {
+	mu      sync.Mutex
+	keys    map[string]crypto.Signer
+	deleted []string
+}
+
+func newDeviceSigner() *deviceSigner {
+	return &deviceSigner{keys: make(map[string]crypto.Signer)}
+}
+
+func (d *deviceSigner) GenerateKey() (priv, pub *jose.JSONWebKey, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyID := newKeyID()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keys[keyID] = key
+	return nil, &jose.JSONWebKey{
+		Key:       key.Public(),
+		KeyID:     keyID,
+		Algorithm: string(jose.ES256),
+		Use:       "sig",
+	}, nil
+}
+
+func (d *deviceSigner) Sign(keys storage.Keys, payload []byte, opts *jose.SignerOptions) (string, error) {
+	d.mu.Lock()
+	key, ok := d.keys[keys.SigningKeyPub.KeyID]
+	d.mu.Unlock()
+	if !ok {
+		return "", errors.New("key not found")
+	}
+	return signPayload(&jose.JSONWebKey{Key: key, KeyID: keys.SigningKeyPub.KeyID}, jose.ES256, payload, opts)
+}
+
+func (d *deviceSigner) CanSign(keys storage.Keys) (bool, error) {
+	if keys.SigningKey != nil || keys.SigningKeyPub == nil {
+		return false, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.keys[keys.SigningKeyPub.KeyID]
+	return ok, nil
+}
+
+func (d *deviceSigner) DeleteKey(keyID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.keys, keyID)
+	d.deleted = append(d.deleted, keyID)
+	return nil
+}
+
+func TestExternalSigner(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signer := newDeviceSigner()
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Signer = signer
+	})
+	defer httpServer.Close()
+
+	keys, err := s.storage.GetKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.SigningKey != nil {
+		t.Fatalf("expected no private key in storage")
+	}
+	if keys.SigningKeyPub == nil {
+		t.Fatalf("expected public signing key in storage")
+	}
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/keys", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from /keys, got %d", rr.Code)
+	}
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(rr.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("failed to decode keys: %v", err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != keys.SigningKeyPub.KeyID {
+		t.Fatalf("expected /keys to serve key %s, got %v", keys.SigningKeyPub.KeyID, jwks.Keys)
+	}
+
+	idToken, _, err := s.newIDToken("client", storage.Claims{UserID: "1"}, []string{"openid"}, "", "access-token", "mock", "", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create ID token: %v", err)
+	}
+	keySet := &storageKeySet{s.storage}
+	if _, err := keySet.VerifySignature(ctx, idToken); err != nil {
+		t.Errorf("failed to verify ID token: %v", err)
+	}
+}
+
+func TestKeyRotaterExternalSigner(t *testing.T) {
+	now := time.Now()
+	signer := newDeviceSigner()
+	r := &keyRotater{
+		Storage:  memory.New(logger),
+		strategy: defaultRotationStrategy(time.Minute, time.Hour, nil),
+		signer:   signer,
+		now:      func() time.Time { return now },
+		logger:   logger,
+	}
+
+	var keyIDs []string
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Minute + time.Millisecond)
+		if err := r.rotate(); err != nil {
+			t.Fatal(err)
+		}
+		keys, err := r.GetKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys.SigningKey != nil {
+			t.Errorf("expected no private key in storage after rotation %d", i+1)
+		}
+		keyIDs = append(keyIDs, keys.SigningKeyPub.KeyID)
+	}
+
+	// Every key but the active one is removed from the device.
+	if !slicesEq(signer.deleted, keyIDs[:2]) {
+		t.Errorf("expected keys %q to be deleted, got %q", keyIDs[:2], signer.deleted)
+	}
+	if _, ok := signer.keys[keyIDs[2]]; !ok || len(signer.keys) != 1 {
+		t.Errorf("expected only the active key to remain in the device")
+	}
+}
+
+func TestKeyRotaterSwitchToExternalSigner(t *testing.T) {
+	now := time.Now()
+	s := memory.New(logger)
+	key := func() (crypto.Signer, error) {
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	local := &keyRotater{
+		Storage:  s,
+		strategy: defaultRotationStrategy(time.Hour, time.Hour, key),
+		signer:   &localSigner{key: key},
+		now:      func() time.Time { return now },
+		logger:   logger,
+	}
+	if err := local.rotate(); err != nil {
+		t.Fatal(err)
+	}
+	localKeyID := signingKeyID(t, s)
+
+	// The key of the local signer isn't due for rotation, but the device
+	// can't sign with it.
+	signer := newDeviceSigner()
+	r := &keyRotater{
+		Storage:  s,
+		strategy: defaultRotationStrategy(time.Hour, time.Hour, nil),
+		signer:   signer,
+		now:      func() time.Time { return now },
+		logger:   logger,
+	}
+	if err := r.rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := signingKeyID(t, s); got != localKeyID {
+		t.Fatalf("expected regular rotation to keep key %s, got %s", localKeyID, got)
+	}
+	if err := r.rotateUnusableKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := s.GetKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.SigningKey != nil {
+		t.Errorf("expected the private key of the local signer to be removed from storage")
+	}
+	if keys.SigningKeyPub.KeyID == localKeyID {
+		t.Fatalf("expected key %s to be rotated", localKeyID)
+	}
+	if ok, _ := signer.CanSign(keys); !ok {
+		t.Errorf("expected the device to hold the new signing key")
+	}
+	if got := verificationKeyIDs(t, s); !slicesEq(got, []string{localKeyID}) {
+		t.Errorf("expected key %s to still verify signatures, got %q", localKeyID, got)
+	}
+	if len(signer.deleted) != 0 {
+		t.Errorf("expected no keys to be deleted from the device, got %q", signer.deleted)
+	}
+
+	// Once the device holds the signing key there's nothing to rotate.
+	if err := r.rotateUnusableKey(); err != nil {
+		t.Fatal(err)
+	}
+	if got := signingKeyID(t, s); got != keys.SigningKeyPub.KeyID {
+		t.Errorf("expected key %s to be kept, got %s", keys.SigningKeyPub.KeyID, got)
+	}
+}
@@ -2,6 +2,7 @@
 package conformance
 
 import (
+	"encoding/json"
 	"reflect"
 	"sort"
 	"testing"
@@ -19,6 +20,24 @@ import (
 // ensure that values being tested on never expire.
 var neverExpire = time.Now().UTC().Add(time.Hour * 24 * 365 * 100)
 
+// claimsRequest is longer than the short text columns some backends use, so
+// truncation shows up as a mismatch.
+var claimsRequest = `{
+	"userinfo": {
+		"name": {"essential": true},
+		"email": {"essential": true},
+		"email_verified": {"essential": true},
+		"preferred_username": null,
+		"groups": {"values": ["admins", "developers", "operators"]}
+	},
+	"id_token": {
+		"email": {"essential": true},
+		"email_verified": {"essential": true},
+		"groups": {"values": ["admins", "developers", "operators"]},
+		"acr": {"values": ["urn:mace:incommon:iap:silver", "urn:mace:incommon:iap:bronze"]}
+	}
+}`
+
 type subTest struct {
 	name string
 	run  func(t *testing.T, s storage.Storage)
@@ -42,11 +61,18 @@ func RunTests(t *testing.T, newStorage func() storage.Storage) {
 		{"AuthCodeCRUD", testAuthCodeCRUD},
 		{"AuthRequestCRUD", testAuthRequestCRUD},
 		{"ClientCRUD", testClientCRUD},
+		{"ClientSecretMigration", testClientSecretMigration},
 		{"RefreshTokenCRUD", testRefreshTokenCRUD},
 		{"PasswordCRUD", testPasswordCRUD},
 		{"KeysCRUD", testKeysCRUD},
 		{"OfflineSessionCRUD", testOfflineSessionCRUD},
 		{"ConnectorCRUD", testConnectorCRUD},
+		{"DeviceRequestCRUD", testDeviceRequestCRUD},
+		{"DeviceTokenCRUD", testDeviceTokenCRUD},
+		{"SessionCRUD", testSessionCRUD},
+		{"AccessTokenCRUD", testAccessTokenCRUD},
+		{"ClientAssertionCRUD", testClientAssertionCRUD},
+		{"DPoPProofCRUD", testDPoPProofCRUD},
 		{"GarbageCollection", testGC},
 		{"TimezoneSupport", testTimezones},
 	})
@@ -60,6 +86,18 @@ func mustLoadJWK(b string) *jose.JSONWebKey {
 	return &jwt
 }
 
+func mustMarshalJWKS(keys ...*jose.JSONWebKey) string {
+	set := jose.JSONWebKeySet{}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, *key)
+	}
+	b, err := json.Marshal(set)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
 func mustBeErrNotFound(t *testing.T, kind string, err error) {
 	switch {
 	case err == nil:
@@ -88,17 +126,26 @@ func testAuthRequestCRUD(t *testing.T, s storage.Storage) {
 		Nonce:               "foo",
 		State:               "bar",
 		ForceApprovalPrompt: true,
+		Prompt:              "login consent",
+		MaxAge:              600,
 		LoggedIn:            true,
 		Expiry:              neverExpire,
 		ConnectorID:         "ldap",
 		ConnectorData:       []byte(`{"some":"data"}`),
+		AuthTime:            time.Now().UTC().Round(time.Second),
+		PKCE: storage.PKCE{
+			CodeChallenge:       "code_challenge_test",
+			CodeChallengeMethod: "plain",
+		},
 		Claims: storage.Claims{
 			UserID:        "1",
 			Username:      "jane",
 			Email:         "jane.doe@example.com",
 			EmailVerified: true,
 			Groups:        []string{"a", "b"},
+			CustomClaims:  map[string]interface{}{"department": "engineering"},
 		},
+		ClaimsRequest: claimsRequest,
 	}
 
 	identity := storage.Claims{Email: "foobar"}
@@ -153,6 +200,17 @@ func testAuthRequestCRUD(t *testing.T, s storage.Storage) {
 		t.Fatalf("update failed, wanted identity=%#v got %#v", identity, got.Claims)
 	}
 
+	if !reflect.DeepEqual(got.PKCE, a1.PKCE) {
+		t.Fatalf("storage does not support PKCE, wanted challenge=%#v got %#v", a1.PKCE, got.PKCE)
+	}
+
+	if got.Prompt != a1.Prompt || got.MaxAge != a1.MaxAge {
+		t.Fatalf("wanted prompt=%q max_age=%d got prompt=%q max_age=%d", a1.Prompt, a1.MaxAge, got.Prompt, got.MaxAge)
+	}
+	if got.AuthTime.Unix() != a1.AuthTime.Unix() {
+		t.Fatalf("auth request auth_time did not match want=%s vs got=%s", a1.AuthTime, got.AuthTime)
+	}
+
 	if err := s.DeleteAuthRequest(a1.ID); err != nil {
 		t.Fatalf("failed to delete auth request: %v", err)
 	}
@@ -172,16 +230,23 @@ func testAuthCodeCRUD(t *testing.T, s storage.Storage) {
 		RedirectURI:   "https://localhost:80/callback",
 		Nonce:         "foobar",
 		Scopes:        []string{"openid", "email"},
+		AuthTime:      time.Now().UTC().Round(time.Second),
 		Expiry:        neverExpire,
 		ConnectorID:   "ldap",
 		ConnectorData: []byte(`{"some":"data"}`),
+		PKCE: storage.PKCE{
+			CodeChallenge:       "12345",
+			CodeChallengeMethod: "Whatever",
+		},
 		Claims: storage.Claims{
 			UserID:        "1",
 			Username:      "jane",
 			Email:         "jane.doe@example.com",
 			EmailVerified: true,
 			Groups:        []string{"a", "b"},
+			CustomClaims:  map[string]interface{}{"department": "engineering"},
 		},
+		ClaimsRequest: claimsRequest,
 	}
 
 	if err := s.CreateAuthCode(a1); err != nil {
@@ -221,7 +286,11 @@ func testAuthCodeCRUD(t *testing.T, s storage.Storage) {
 	if a1.Expiry.Unix() != got.Expiry.Unix() {
 		t.Errorf("auth code expiry did not match want=%s vs got=%s", a1.Expiry, got.Expiry)
 	}
+	if a1.AuthTime.Unix() != got.AuthTime.Unix() {
+		t.Errorf("auth code auth_time did not match want=%s vs got=%s", a1.AuthTime, got.AuthTime)
+	}
 	got.Expiry = a1.Expiry // time fields do not compare well
+	got.AuthTime = a1.AuthTime
 	if diff := pretty.Compare(a1, got); diff != "" {
 		t.Errorf("auth code retrieved from storage did not match: %s", diff)
 	}
@@ -241,11 +310,34 @@ func testAuthCodeCRUD(t *testing.T, s storage.Storage) {
 func testClientCRUD(t *testing.T, s storage.Storage) {
 	id1 := storage.NewID()
 	c1 := storage.Client{
-		ID:           id1,
-		Secret:       "foobar",
-		RedirectURIs: []string{"foo://bar.com/", "https://auth.example.com"},
-		Name:         "dex client",
-		LogoURL:      "https://goo.gl/JIyzIC",
+		ID:                      id1,
+		Secret:                  "foobar",
+		RedirectURIs:            []string{"foo://bar.com/", "https://auth.example.com"},
+		PostLogoutRedirectURIs:  []string{"https://auth.example.com/logged-out"},
+		AllowedScopes:           []string{"openid", "email"},
+		ClientCredentialsScopes: []string{"read"},
+		AllowedAudiences:        []string{"https://api.example.com"},
+		AllowedGrantTypes:       []string{"authorization_code", "refresh_token"},
+		AllowedResponseTypes:    []string{"code"},
+		AllowedConnectors:       []string{"github"},
+		Name:                    "dex client",
+		LogoURL:                 "https://goo.gl/JIyzIC",
+
+		RegistrationAccessToken: "registration-token",
+		RefreshTokenValidFor:    "720h",
+		RefreshTokenIdleTimeout: "0s",
+		IDTokenValidFor:         "1h",
+		AccessTokenValidFor:     "10m",
+		JWKS:                    mustMarshalJWKS(jsonWebKeys[0].Public, jsonWebKeys[1].Public, jsonWebKeys[2].Public),
+		RequestURIs:             []string{"https://localhost/request.jwt"},
+
+		TokenEndpointAuthMethods: []string{"private_key_jwt"},
+		RequireDPoP:              true,
+		TLSClientAuthSubjectDN:   "CN=client,O=example",
+		TLSClientCertThumbprints: []string{"A4DtL2JmUMhAsvJj5tKyn64SqzmuXbMrJa0n761y5v0"},
+		Secrets: []storage.ClientSecret{
+			{ID: "secret-1", Hash: []byte("hash-1")},
+		},
 	}
 	err := s.DeleteClient(id1)
 	mustBeErrNotFound(t, "client", err)
@@ -285,14 +377,36 @@ func testClientCRUD(t *testing.T, s storage.Storage) {
 	getAndCompare(id1, c1)
 
 	newSecret := "barfoo"
+	newPostLogoutRedirectURIs := []string{"https://auth.example.com/bye"}
+	newAllowedScopes := []string{"read", "write"}
+	newJWKSURI := "https://auth.example.com/jwks"
+	newAllowedConnectors := []string{"github", "ldap"}
+	newSecrets := []storage.ClientSecret{
+		{ID: "secret-1", Hash: []byte("hash-1"), Expiry: time.Now().UTC().Add(time.Hour).Round(time.Millisecond)},
+		{ID: "secret-2", Hash: []byte("hash-2")},
+	}
 	err = s.UpdateClient(id1, func(old storage.Client) (storage.Client, error) {
 		old.Secret = newSecret
+		old.PostLogoutRedirectURIs = newPostLogoutRedirectURIs
+		old.AllowedScopes = newAllowedScopes
+		old.JWKS = ""
+		old.JWKSURI = newJWKSURI
+		old.RequirePushedAuthRequests = true
+		old.Secrets = newSecrets
+		old.AllowedConnectors = newAllowedConnectors
 		return old, nil
 	})
 	if err != nil {
 		t.Errorf("update client: %v", err)
 	}
 	c1.Secret = newSecret
+	c1.PostLogoutRedirectURIs = newPostLogoutRedirectURIs
+	c1.AllowedScopes = newAllowedScopes
+	c1.JWKS = ""
+	c1.JWKSURI = newJWKSURI
+	c1.RequirePushedAuthRequests = true
+	c1.Secrets = newSecrets
+	c1.AllowedConnectors = newAllowedConnectors
 	getAndCompare(id1, c1)
 
 	if err := s.DeleteClient(id1); err != nil {
@@ -307,6 +421,58 @@ func testClientCRUD(t *testing.T, s storage.Storage) {
 	mustBeErrNotFound(t, "client", err)
 }
 
+func testClientSecretMigration(t *testing.T, s storage.Storage) {
+	plaintext := storage.Client{
+		ID:           storage.NewID(),
+		Secret:       "plaintext-secret",
+		RedirectURIs: []string{"https://auth.example.com"},
+	}
+	jwt := storage.Client{
+		ID:                       storage.NewID(),
+		Secret:                   "jwt-secret",
+		RedirectURIs:             []string{"https://auth.example.com"},
+		TokenEndpointAuthMethods: []string{"client_secret_jwt"},
+	}
+	for _, c := range []storage.Client{plaintext, jwt} {
+		if err := s.CreateClient(c); err != nil {
+			t.Fatalf("create client: %v", err)
+		}
+	}
+
+	n, err := storage.MigrateClientSecrets(s)
+	if err != nil {
+		t.Fatalf("migrate client secrets: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 migrated client, got %d", n)
+	}
+
+	got, err := s.GetClient(plaintext.ID)
+	if err != nil {
+		t.Fatalf("get client: %v", err)
+	}
+	if got.Secret != "" {
+		t.Errorf("expected plaintext secret to be removed")
+	}
+	if len(got.Secrets) != 1 || !got.Secrets[0].Matches(plaintext.Secret, time.Now()) {
+		t.Errorf("expected hashed secret to match, got %#v", got.Secrets)
+	}
+
+	got, err = s.GetClient(jwt.ID)
+	if err != nil {
+		t.Fatalf("get client: %v", err)
+	}
+	if got.Secret != jwt.Secret || len(got.Secrets) != 0 {
+		t.Errorf("expected client_secret_jwt client to keep its plaintext secret")
+	}
+
+	for _, c := range []storage.Client{plaintext, jwt} {
+		if err := s.DeleteClient(c.ID); err != nil {
+			t.Fatalf("delete client: %v", err)
+		}
+	}
+}
+
 func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
 	id := storage.NewID()
 	refresh := storage.RefreshToken{
@@ -318,14 +484,18 @@ func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
 		Scopes:      []string{"openid", "email", "profile"},
 		CreatedAt:   time.Now().UTC().Round(time.Millisecond),
 		LastUsed:    time.Now().UTC().Round(time.Millisecond),
+		Expiry:      time.Now().UTC().Add(time.Hour).Round(time.Millisecond),
 		Claims: storage.Claims{
 			UserID:        "1",
 			Username:      "jane",
 			Email:         "jane.doe@example.com",
 			EmailVerified: true,
 			Groups:        []string{"a", "b"},
+			CustomClaims:  map[string]interface{}{"department": "engineering"},
 		},
+		ClaimsRequest: claimsRequest,
 		ConnectorData: []byte(`{"some":"data"}`),
+		Confirmation:  storage.Confirmation{JKT: "0ZcOCORZNYy-DWpqq30jZyJGHTN0d2HglBV3uiguA4I"},
 	}
 	if err := s.CreateRefresh(refresh); err != nil {
 		t.Fatalf("create refresh token: %v", err)
@@ -350,10 +520,16 @@ func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
 			t.Errorf("refresh token last used timestamp retrieved from storage did not match: %s", diff)
 		}
 
+		if !gr.Expiry.Equal(want.Expiry) {
+			t.Errorf("refresh token expiry retrieved from storage did not match: want %v, got %v", want.Expiry, gr.Expiry)
+		}
+
 		gr.CreatedAt = time.Time{}
 		gr.LastUsed = time.Time{}
+		gr.Expiry = time.Time{}
 		want.CreatedAt = time.Time{}
 		want.LastUsed = time.Time{}
+		want.Expiry = time.Time{}
 
 		if diff := pretty.Compare(want, gr); diff != "" {
 			t.Errorf("refresh token retrieved from storage did not match: %s", diff)
@@ -391,15 +567,21 @@ func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
 	updatedAt := time.Now().UTC().Round(time.Millisecond)
 
 	updater := func(r storage.RefreshToken) (storage.RefreshToken, error) {
+		r.RotatedTokens = []string{"eggs"}
+		r.ObsoleteToken = r.Token
 		r.Token = "spam"
 		r.LastUsed = updatedAt
+		r.Expiry = updatedAt.Add(time.Hour)
 		return r, nil
 	}
 	if err := s.UpdateRefreshToken(id, updater); err != nil {
 		t.Errorf("failed to udpate refresh token: %v", err)
 	}
+	refresh.RotatedTokens = []string{"eggs"}
+	refresh.ObsoleteToken = refresh.Token
 	refresh.Token = "spam"
 	refresh.LastUsed = updatedAt
+	refresh.Expiry = updatedAt.Add(time.Hour)
 	getAndCompare(id, refresh)
 
 	// Ensure that updating the first token doesn't impact the second. Issue #847.
@@ -675,6 +857,277 @@ func testConnectorCRUD(t *testing.T, s storage.Storage) {
 	mustBeErrNotFound(t, "connector", err)
 }
 
+func testDeviceRequestCRUD(t *testing.T, s storage.Storage) {
+	d1 := storage.DeviceRequest{
+		UserCode:   storage.NewUserCode(),
+		DeviceCode: storage.NewID(),
+		ClientID:   "client1",
+		Scopes:     []string{"openid", "email"},
+		Expiry:     neverExpire,
+	}
+
+	if err := s.CreateDeviceRequest(d1); err != nil {
+		t.Fatalf("failed creating device request: %v", err)
+	}
+
+	// Attempt to create same DeviceRequest twice.
+	err := s.CreateDeviceRequest(d1)
+	mustBeErrAlreadyExists(t, "device request", err)
+
+	got, err := s.GetDeviceRequest(d1.UserCode)
+	if err != nil {
+		t.Fatalf("failed to get device request: %v", err)
+	}
+	got.Expiry = got.Expiry.UTC()
+	d1.Expiry = d1.Expiry.UTC()
+	if diff := pretty.Compare(d1, got); diff != "" {
+		t.Errorf("device request retrieved from storage did not match: %s", diff)
+	}
+
+	_, err = s.GetDeviceRequest(storage.NewUserCode())
+	mustBeErrNotFound(t, "device request", err)
+}
+
+func testDeviceTokenCRUD(t *testing.T, s storage.Storage) {
+	// Create a Token
+	d1 := storage.DeviceToken{
+		DeviceCode:          storage.NewID(),
+		ClientID:            "client1",
+		Status:              "pending",
+		Token:               storage.NewID(),
+		Expiry:              neverExpire,
+		LastRequestTime:     time.Now(),
+		PollIntervalSeconds: 0,
+	}
+
+	if err := s.CreateDeviceToken(d1); err != nil {
+		t.Fatalf("failed creating device token: %v", err)
+	}
+
+	// Attempt to create same Device Token twice.
+	err := s.CreateDeviceToken(d1)
+	mustBeErrAlreadyExists(t, "device token", err)
+
+	// Update the device token, simulate a redemption
+	if err := s.UpdateDeviceToken(d1.DeviceCode, func(old storage.DeviceToken) (storage.DeviceToken, error) {
+		old.Token = "token data"
+		old.Status = "complete"
+		return old, nil
+	}); err != nil {
+		t.Fatalf("failed to update device token: %v", err)
+	}
+
+	// Retrieve the device token
+	got, err := s.GetDeviceToken(d1.DeviceCode)
+	if err != nil {
+		t.Fatalf("failed to get device token: %v", err)
+	}
+
+	// Validate the update
+	if got.Status != "complete" {
+		t.Fatalf("update failed, wrong status, expected %v got %v", "complete", got.Status)
+	}
+	if got.Token != "token data" {
+		t.Fatalf("update failed, wrong token, expected %v got %v", "token data", got.Token)
+	}
+	if got.ClientID != d1.ClientID {
+		t.Fatalf("wrong client ID, expected %v got %v", d1.ClientID, got.ClientID)
+	}
+
+	_, err = s.GetDeviceToken(storage.NewID())
+	mustBeErrNotFound(t, "device token", err)
+}
+
+func testSessionCRUD(t *testing.T, s storage.Storage) {
+	now := time.Now().UTC().Round(time.Millisecond)
+	session := storage.Session{
+		ID:            storage.NewID(),
+		ConnectorID:   "mock",
+		ConnectorData: []byte(`{"some":"data"}`),
+		Claims: storage.Claims{
+			UserID:        "1",
+			Username:      "jane",
+			Email:         "jane.doe@example.com",
+			EmailVerified: true,
+			Groups:        []string{"a", "b"},
+			CustomClaims:  map[string]interface{}{"department": "engineering"},
+		},
+		Scopes:    []string{"openid", "groups"},
+		CreatedAt: now,
+		LastUsed:  now,
+		Expiry:    neverExpire,
+	}
+
+	if err := s.CreateSession(session); err != nil {
+		t.Fatalf("failed creating session: %v", err)
+	}
+
+	err := s.CreateSession(session)
+	mustBeErrAlreadyExists(t, "session", err)
+
+	getAndCompare := func(id string, want storage.Session) {
+		gr, err := s.GetSession(id)
+		if err != nil {
+			t.Errorf("get session: %v", err)
+			return
+		}
+
+		if diff := pretty.Compare(want.CreatedAt.UnixNano(), gr.CreatedAt.UnixNano()); diff != "" {
+			t.Errorf("session created at retrieved from storage did not match: %s", diff)
+		}
+		if diff := pretty.Compare(want.LastUsed.UnixNano(), gr.LastUsed.UnixNano()); diff != "" {
+			t.Errorf("session last used retrieved from storage did not match: %s", diff)
+		}
+
+		// Times are compared above.
+		gr.CreatedAt = want.CreatedAt
+		gr.LastUsed = want.LastUsed
+		gr.Expiry = want.Expiry
+
+		if diff := pretty.Compare(want, gr); diff != "" {
+			t.Errorf("session retrieved from storage did not match: %s", diff)
+		}
+	}
+
+	getAndCompare(session.ID, session)
+
+	lastUsed := now.Add(time.Hour)
+	if err := s.UpdateSession(session.ID, func(old storage.Session) (storage.Session, error) {
+		old.LastUsed = lastUsed
+		return old, nil
+	}); err != nil {
+		t.Fatalf("failed to update session: %v", err)
+	}
+
+	session.LastUsed = lastUsed
+	getAndCompare(session.ID, session)
+
+	if err := s.DeleteSession(session.ID); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+
+	_, err = s.GetSession(session.ID)
+	mustBeErrNotFound(t, "session", err)
+}
+
+func testAccessTokenCRUD(t *testing.T, s storage.Storage) {
+	now := time.Now().UTC().Round(time.Millisecond)
+	accessToken := storage.AccessToken{
+		ID:          storage.NewID(),
+		ClientID:    "client1",
+		Subject:     "subject",
+		Audience:    []string{"client1", "client2"},
+		Scopes:      []string{"openid", "groups"},
+		ConnectorID: "mock",
+		Claims: storage.Claims{
+			UserID:        "1",
+			Username:      "jane",
+			Email:         "jane.doe@example.com",
+			EmailVerified: true,
+			Groups:        []string{"a", "b"},
+			CustomClaims:  map[string]interface{}{"department": "engineering"},
+		},
+		ClaimsRequest: claimsRequest,
+		Confirmation: storage.Confirmation{
+			JKT:     "0ZcOCORZNYy-DWpqq30jZyJGHTN0d2HglBV3uiguA4I",
+			X5TS256: "A4DtL2JmUMhAsvJj5tKyn64SqzmuXbMrJa0n761y5v0",
+		},
+		CreatedAt: now,
+		Expiry:    neverExpire,
+	}
+
+	if err := s.CreateAccessToken(accessToken); err != nil {
+		t.Fatalf("failed creating access token: %v", err)
+	}
+
+	err := s.CreateAccessToken(accessToken)
+	mustBeErrAlreadyExists(t, "access token", err)
+
+	got, err := s.GetAccessToken(accessToken.ID)
+	if err != nil {
+		t.Fatalf("get access token: %v", err)
+	}
+	if diff := pretty.Compare(accessToken.CreatedAt.UnixNano(), got.CreatedAt.UnixNano()); diff != "" {
+		t.Errorf("access token created at retrieved from storage did not match: %s", diff)
+	}
+
+	// Times are compared above.
+	got.CreatedAt = accessToken.CreatedAt
+	got.Expiry = accessToken.Expiry
+
+	if diff := pretty.Compare(accessToken, got); diff != "" {
+		t.Errorf("access token retrieved from storage did not match: %s", diff)
+	}
+
+	if err := s.DeleteAccessToken(accessToken.ID); err != nil {
+		t.Fatalf("failed to delete access token: %v", err)
+	}
+
+	_, err = s.GetAccessToken(accessToken.ID)
+	mustBeErrNotFound(t, "access token", err)
+}
+
+func testClientAssertionCRUD(t *testing.T, s storage.Storage) {
+	clientAssertion := storage.ClientAssertion{
+		ID:       storage.NewID(),
+		ClientID: "client1",
+		Expiry:   neverExpire,
+	}
+
+	_, err := s.GetClientAssertion(clientAssertion.ID)
+	mustBeErrNotFound(t, "client assertion", err)
+
+	if err := s.CreateClientAssertion(clientAssertion); err != nil {
+		t.Fatalf("failed creating client assertion: %v", err)
+	}
+
+	// Replaying the same assertion must fail.
+	err = s.CreateClientAssertion(clientAssertion)
+	mustBeErrAlreadyExists(t, "client assertion", err)
+
+	got, err := s.GetClientAssertion(clientAssertion.ID)
+	if err != nil {
+		t.Fatalf("get client assertion: %v", err)
+	}
+	if diff := pretty.Compare(clientAssertion.Expiry.UnixNano(), got.Expiry.UnixNano()); diff != "" {
+		t.Errorf("client assertion expiry retrieved from storage did not match: %s", diff)
+	}
+	got.Expiry = clientAssertion.Expiry
+	if diff := pretty.Compare(clientAssertion, got); diff != "" {
+		t.Errorf("client assertion retrieved from storage did not match: %s", diff)
+	}
+}
+
+func testDPoPProofCRUD(t *testing.T, s storage.Storage) {
+	dpopProof := storage.DPoPProof{
+		ID:     storage.NewID(),
+		Expiry: neverExpire,
+	}
+
+	_, err := s.GetDPoPProof(dpopProof.ID)
+	mustBeErrNotFound(t, "dpop proof", err)
+
+	if err := s.CreateDPoPProof(dpopProof); err != nil {
+		t.Fatalf("failed creating dpop proof: %v", err)
+	}
+
+	// Replaying the same proof must fail.
+	err = s.CreateDPoPProof(dpopProof)
+	mustBeErrAlreadyExists(t, "dpop proof", err)
+
+	got, err := s.GetDPoPProof(dpopProof.ID)
+	if err != nil {
+		t.Fatalf("get dpop proof: %v", err)
+	}
+	if diff := pretty.Compare(dpopProof.Expiry.UnixNano(), got.Expiry.UnixNano()); diff != "" {
+		t.Errorf("dpop proof expiry retrieved from storage did not match: %s", diff)
+	}
+	got.Expiry = dpopProof.Expiry
+	if diff := pretty.Compare(dpopProof, got); diff != "" {
+		t.Errorf("dpop proof retrieved from storage did not match: %s", diff)
+	}
+}
+
 func testKeysCRUD(t *testing.T, s storage.Storage) {
 	updateAndCompare := func(k storage.Keys) {
 		err := s.UpdateKeys(func(oldKeys storage.Keys) (storage.Keys, error) {
@@ -720,8 +1173,21 @@ func testKeysCRUD(t *testing.T, s storage.Storage) {
 		},
 	}
 
+	// Keys held by an external signer only store their public part.
+	keys3 := storage.Keys{
+		SigningKeyPub: jsonWebKeys[1].Public,
+		NextRotation:  n.Add(time.Hour * 2),
+		VerificationKeys: []storage.VerificationKey{
+			{
+				PublicKey: jsonWebKeys[2].Public,
+				Expiry:    n.Add(time.Hour * 3),
+			},
+		},
+	}
+
 	updateAndCompare(keys1)
 	updateAndCompare(keys2)
+	updateAndCompare(keys3)
 }
 
 func testGC(t *testing.T, s storage.Storage) {
@@ -834,6 +1300,335 @@ func testGC(t *testing.T, s storage.Storage) {
 	} else if err != storage.ErrNotFound {
 		t.Errorf("expected storage.ErrNotFound, got %v", err)
 	}
+
+	d := storage.DeviceRequest{
+		UserCode:   storage.NewUserCode(),
+		DeviceCode: storage.NewID(),
+		ClientID:   "client1",
+		Scopes:     []string{"openid", "email"},
+		Expiry:     expiry,
+	}
+
+	if err := s.CreateDeviceRequest(d); err != nil {
+		t.Fatalf("failed creating device request: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if !result.IsEmpty() {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+		if _, err := s.GetDeviceRequest(d.UserCode); err != nil {
+			t.Errorf("expected to be able to get device request after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.DeviceRequests != 1 {
+		t.Errorf("expected to garbage collect 1 device request, got %d", r.DeviceRequests)
+	}
+
+	if _, err := s.GetDeviceRequest(d.UserCode); err == nil {
+		t.Errorf("expected device request to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	dt := storage.DeviceToken{
+		DeviceCode:          storage.NewID(),
+		Status:              "pending",
+		Token:               "foo",
+		Expiry:              expiry,
+		LastRequestTime:     time.Now(),
+		PollIntervalSeconds: 0,
+	}
+
+	if err := s.CreateDeviceToken(dt); err != nil {
+		t.Fatalf("failed creating device token: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if !result.IsEmpty() {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+		if _, err := s.GetDeviceToken(dt.DeviceCode); err != nil {
+			t.Errorf("expected to be able to get device token after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.DeviceTokens != 1 {
+		t.Errorf("expected to garbage collect 1 device token, got %d", r.DeviceTokens)
+	}
+
+	if _, err := s.GetDeviceToken(dt.DeviceCode); err == nil {
+		t.Errorf("expected device token to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	session := storage.Session{
+		ID:          storage.NewID(),
+		ConnectorID: "mock",
+		Claims: storage.Claims{
+			UserID:   "1",
+			Username: "jane",
+			Groups:   []string{"a", "b"},
+		},
+		Scopes:    []string{"openid"},
+		CreatedAt: time.Now(),
+		LastUsed:  time.Now(),
+		Expiry:    expiry,
+	}
+
+	if err := s.CreateSession(session); err != nil {
+		t.Fatalf("failed creating session: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if !result.IsEmpty() {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+		if _, err := s.GetSession(session.ID); err != nil {
+			t.Errorf("expected to be able to get session after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.Sessions != 1 {
+		t.Errorf("expected to garbage collect 1 session, got %d", r.Sessions)
+	}
+
+	if _, err := s.GetSession(session.ID); err == nil {
+		t.Errorf("expected session to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	accessToken := storage.AccessToken{
+		ID:        storage.NewID(),
+		ClientID:  "client1",
+		Subject:   "client1",
+		Audience:  []string{"client1"},
+		CreatedAt: time.Now(),
+		Expiry:    expiry,
+	}
+
+	if err := s.CreateAccessToken(accessToken); err != nil {
+		t.Fatalf("failed creating access token: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if !result.IsEmpty() {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+		if _, err := s.GetAccessToken(accessToken.ID); err != nil {
+			t.Errorf("expected to be able to get access token after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.AccessTokens != 1 {
+		t.Errorf("expected to garbage collect 1 access token, got %d", r.AccessTokens)
+	}
+
+	if _, err := s.GetAccessToken(accessToken.ID); err == nil {
+		t.Errorf("expected access token to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	clientAssertion := storage.ClientAssertion{
+		ID:       storage.NewID(),
+		ClientID: "client1",
+		Expiry:   expiry,
+	}
+
+	if err := s.CreateClientAssertion(clientAssertion); err != nil {
+		t.Fatalf("failed creating client assertion: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if !result.IsEmpty() {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+		if _, err := s.GetClientAssertion(clientAssertion.ID); err != nil {
+			t.Errorf("expected to be able to get client assertion after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.ClientAssertions != 1 {
+		t.Errorf("expected to garbage collect 1 client assertion, got %d", r.ClientAssertions)
+	}
+
+	if _, err := s.GetClientAssertion(clientAssertion.ID); err == nil {
+		t.Errorf("expected client assertion to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	dpopProof := storage.DPoPProof{
+		ID:     storage.NewID(),
+		Expiry: expiry,
+	}
+
+	if err := s.CreateDPoPProof(dpopProof); err != nil {
+		t.Fatalf("failed creating dpop proof: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if !result.IsEmpty() {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+		if _, err := s.GetDPoPProof(dpopProof.ID); err != nil {
+			t.Errorf("expected to be able to get dpop proof after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.DPoPProofs != 1 {
+		t.Errorf("expected to garbage collect 1 dpop proof, got %d", r.DPoPProofs)
+	}
+
+	if _, err := s.GetDPoPProof(dpopProof.ID); err == nil {
+		t.Errorf("expected dpop proof to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	// Expired refresh tokens are removed from the offline sessions of the user,
+	// which are deleted once they don't reference any refresh token.
+	newRefresh := func(clientID, userID string, expiry time.Time) storage.RefreshToken {
+		r := storage.RefreshToken{
+			ID:          storage.NewID(),
+			Token:       "bar",
+			ClientID:    clientID,
+			ConnectorID: "ldap",
+			Scopes:      []string{"openid", "offline_access"},
+			CreatedAt:   time.Now(),
+			LastUsed:    time.Now(),
+			Expiry:      expiry,
+			Claims:      storage.Claims{UserID: userID},
+		}
+		if err := s.CreateRefresh(r); err != nil {
+			t.Fatalf("failed creating refresh token: %v", err)
+		}
+		return r
+	}
+	refreshRef := func(r storage.RefreshToken) *storage.RefreshTokenRef {
+		return &storage.RefreshTokenRef{ID: r.ID, ClientID: r.ClientID, CreatedAt: r.CreatedAt, LastUsed: r.LastUsed}
+	}
+
+	expiredRefresh := newRefresh("client1", "refresh-user-1", expiry)
+	validRefresh := newRefresh("client2", "refresh-user-1", expiry.Add(2*time.Hour))
+	eternalRefresh := newRefresh("client1", "refresh-user-2", time.Time{})
+	lastRefresh := newRefresh("client1", "refresh-user-3", expiry)
+
+	offlineSessions := []storage.OfflineSessions{
+		{
+			UserID: "refresh-user-1",
+			ConnID: "ldap",
+			Refresh: map[string]*storage.RefreshTokenRef{
+				"client1": refreshRef(expiredRefresh),
+				"client2": refreshRef(validRefresh),
+			},
+		},
+		{
+			UserID:  "refresh-user-2",
+			ConnID:  "ldap",
+			Refresh: map[string]*storage.RefreshTokenRef{"client1": refreshRef(eternalRefresh)},
+		},
+		{
+			UserID:  "refresh-user-3",
+			ConnID:  "ldap",
+			Refresh: map[string]*storage.RefreshTokenRef{"client1": refreshRef(lastRefresh)},
+		},
+	}
+	for _, o := range offlineSessions {
+		if err := s.CreateOfflineSessions(o); err != nil {
+			t.Fatalf("failed creating offline session: %v", err)
+		}
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if !result.IsEmpty() {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+		if _, err := s.GetRefresh(expiredRefresh.ID); err != nil {
+			t.Errorf("expected to be able to get refresh token after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else {
+		if r.RefreshTokens != 2 {
+			t.Errorf("expected to garbage collect 2 refresh tokens, got %d", r.RefreshTokens)
+		}
+		if r.OfflineSessions != 1 {
+			t.Errorf("expected to garbage collect 1 offline session, got %d", r.OfflineSessions)
+		}
+	}
+
+	for _, r := range []storage.RefreshToken{expiredRefresh, lastRefresh} {
+		if _, err := s.GetRefresh(r.ID); err == nil {
+			t.Errorf("expected refresh token to be GC'd")
+		} else if err != storage.ErrNotFound {
+			t.Errorf("expected storage.ErrNotFound, got %v", err)
+		}
+	}
+	for _, r := range []storage.RefreshToken{validRefresh, eternalRefresh} {
+		if _, err := s.GetRefresh(r.ID); err != nil {
+			t.Errorf("expected to be able to get refresh token after GC: %v", err)
+		}
+	}
+
+	o, err := s.GetOfflineSessions("refresh-user-1", "ldap")
+	if err != nil {
+		t.Errorf("expected to be able to get offline session after GC: %v", err)
+	} else {
+		if _, ok := o.Refresh["client1"]; ok {
+			t.Errorf("expected expired refresh token to be removed from offline session")
+		}
+		if _, ok := o.Refresh["client2"]; !ok {
+			t.Errorf("expected valid refresh token to remain in offline session")
+		}
+	}
+	if _, err := s.GetOfflineSessions("refresh-user-2", "ldap"); err != nil {
+		t.Errorf("expected to be able to get offline session after GC: %v", err)
+	}
+	if _, err := s.GetOfflineSessions("refresh-user-3", "ldap"); err == nil {
+		t.Errorf("expected offline session to be GC'd")
+	} else if err != storage.ErrNotFound {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
 }
 
 // testTimezones tests that backends either fully support timezones or
@@ -37,7 +37,16 @@ type Etcd struct {
 
 // Open creates a new storage implementation backed by Etcd
 func (p *Etcd) Open(logger log.Logger) (storage.Storage, error) {
-	return p.open(logger)
+	c, err := p.open(logger)
+	if err != nil {
+		return nil, err
+	}
+	// Secrets of clients created by earlier versions are stored in plaintext.
+	if _, err := storage.MigrateClientSecrets(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
 }
 
 func (p *Etcd) open(logger log.Logger) (*conn, error) {
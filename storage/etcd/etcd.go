@@ -14,14 +14,20 @@ import (
 )
 
 const (
-	clientPrefix         = "client/"
-	authCodePrefix       = "auth_code/"
-	refreshTokenPrefix   = "refresh_token/"
-	authRequestPrefix    = "auth_req/"
-	passwordPrefix       = "password/"
-	offlineSessionPrefix = "offline_session/"
-	connectorPrefix      = "connector/"
-	keysName             = "openid-connect-keys"
+	clientPrefix          = "client/"
+	authCodePrefix        = "auth_code/"
+	refreshTokenPrefix    = "refresh_token/"
+	authRequestPrefix     = "auth_req/"
+	passwordPrefix        = "password/"
+	offlineSessionPrefix  = "offline_session/"
+	connectorPrefix       = "connector/"
+	deviceRequestPrefix   = "device_req/"
+	deviceTokenPrefix     = "device_token/"
+	sessionPrefix         = "session/"
+	accessTokenPrefix     = "access_token/"
+	clientAssertionPrefix = "client_assertion/"
+	dpopProofPrefix       = "dpop_proof/"
+	keysName              = "openid-connect-keys"
 
 	// defaultStorageTimeout will be applied to all storage's operations.
 	defaultStorageTimeout = 5 * time.Second
@@ -72,9 +78,181 @@ func (c *conn) GarbageCollect(now time.Time) (result storage.GCResult, err error
 			result.AuthCodes++
 		}
 	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	deviceRequests, err := c.listDeviceRequests(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, deviceRequest := range deviceRequests {
+		if now.After(deviceRequest.Expiry) {
+			if err := c.deleteKey(ctx, keyID(deviceRequestPrefix, deviceRequest.UserCode)); err != nil {
+				c.logger.Errorf("failed to delete device request %v", err)
+				delErr = fmt.Errorf("failed to delete device request: %v", err)
+			}
+			result.DeviceRequests++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	deviceTokens, err := c.listDeviceTokens(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, deviceToken := range deviceTokens {
+		if now.After(deviceToken.Expiry) {
+			if err := c.deleteKey(ctx, keyID(deviceTokenPrefix, deviceToken.DeviceCode)); err != nil {
+				c.logger.Errorf("failed to delete device token %v", err)
+				delErr = fmt.Errorf("failed to delete device token: %v", err)
+			}
+			result.DeviceTokens++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	sessions, err := c.listSessions(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, session := range sessions {
+		if now.After(session.Expiry) {
+			if err := c.deleteKey(ctx, keyID(sessionPrefix, session.ID)); err != nil {
+				c.logger.Errorf("failed to delete session %v", err)
+				delErr = fmt.Errorf("failed to delete session: %v", err)
+			}
+			result.Sessions++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	accessTokens, err := c.listAccessTokens(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, accessToken := range accessTokens {
+		if now.After(accessToken.Expiry) {
+			if err := c.deleteKey(ctx, keyID(accessTokenPrefix, accessToken.ID)); err != nil {
+				c.logger.Errorf("failed to delete access token %v", err)
+				delErr = fmt.Errorf("failed to delete access token: %v", err)
+			}
+			result.AccessTokens++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	clientAssertions, err := c.listClientAssertions(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, clientAssertion := range clientAssertions {
+		if now.After(clientAssertion.Expiry) {
+			if err := c.deleteKey(ctx, keyID(clientAssertionPrefix, clientAssertion.ID)); err != nil {
+				c.logger.Errorf("failed to delete client assertion %v", err)
+				delErr = fmt.Errorf("failed to delete client assertion: %v", err)
+			}
+			result.ClientAssertions++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	dpopProofs, err := c.listDPoPProofs(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, dpopProof := range dpopProofs {
+		if now.After(dpopProof.Expiry) {
+			if err := c.deleteKey(ctx, keyID(dpopProofPrefix, dpopProof.ID)); err != nil {
+				c.logger.Errorf("failed to delete dpop proof %v", err)
+				delErr = fmt.Errorf("failed to delete dpop proof: %v", err)
+			}
+			result.DPoPProofs++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	refreshTokens, err := c.ListRefreshTokens()
+	if err != nil {
+		return result, err
+	}
+
+	for _, refreshToken := range refreshTokens {
+		if refreshToken.Expiry.IsZero() || !now.After(refreshToken.Expiry) {
+			continue
+		}
+		if err := c.deleteKey(ctx, keyID(refreshTokenPrefix, refreshToken.ID)); err != nil {
+			c.logger.Errorf("failed to delete refresh token %v", err)
+			delErr = fmt.Errorf("failed to delete refresh token: %v", err)
+			continue
+		}
+		result.RefreshTokens++
+
+		sessionDeleted, err := c.gcOfflineSessions(ctx, refreshToken)
+		if err != nil {
+			c.logger.Errorf("failed to clean up offline session %v", err)
+			delErr = fmt.Errorf("failed to clean up offline session: %v", err)
+		}
+		if sessionDeleted {
+			result.OfflineSessions++
+		}
+	}
 	return result, delErr
 }
 
+// gcOfflineSessions removes the reference to a deleted refresh token from the
+// offline session of the user, and deletes the offline session once it doesn't
+// hold any refresh token anymore.
+func (c *conn) gcOfflineSessions(ctx context.Context, r storage.RefreshToken) (sessionDeleted bool, err error) {
+	key := keySession(offlineSessionPrefix, r.Claims.UserID, r.ConnectorID)
+	empty := false
+	err = c.txnUpdate(ctx, key, func(currentValue []byte) ([]byte, error) {
+		if len(currentValue) == 0 {
+			return nil, storage.ErrNotFound
+		}
+		var current OfflineSessions
+		if err := json.Unmarshal(currentValue, &current); err != nil {
+			return nil, err
+		}
+		if ref, ok := current.Refresh[r.ClientID]; ok && ref.ID == r.ID {
+			delete(current.Refresh, r.ClientID)
+		}
+		empty = len(current.Refresh) == 0
+		return json.Marshal(current)
+	})
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	if !empty {
+		return false, nil
+	}
+	if err := c.deleteKey(ctx, key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (c *conn) CreateAuthRequest(a storage.AuthRequest) error {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
 	defer cancel()
@@ -124,8 +302,11 @@ func (c *conn) CreateAuthCode(a storage.AuthCode) error {
 func (c *conn) GetAuthCode(id string) (a storage.AuthCode, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
 	defer cancel()
-	err = c.getKey(ctx, keyID(authCodePrefix, id), &a)
-	return a, err
+	var code AuthCode
+	if err = c.getKey(ctx, keyID(authCodePrefix, id), &code); err != nil {
+		return
+	}
+	return toStorageAuthCode(code), nil
 }
 
 func (c *conn) DeleteAuthCode(id string) error {
@@ -476,6 +657,96 @@ func (c *conn) listAuthCodes(ctx context.Context) (codes []AuthCode, err error)
 	return codes, nil
 }
 
+func (c *conn) listDeviceRequests(ctx context.Context) (requests []DeviceRequest, err error) {
+	res, err := c.db.Get(ctx, deviceRequestPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return requests, err
+	}
+	for _, v := range res.Kvs {
+		var r DeviceRequest
+		if err = json.Unmarshal(v.Value, &r); err != nil {
+			return requests, err
+		}
+		requests = append(requests, r)
+	}
+	return requests, nil
+}
+
+func (c *conn) listDeviceTokens(ctx context.Context) (deviceTokens []DeviceToken, err error) {
+	res, err := c.db.Get(ctx, deviceTokenPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return deviceTokens, err
+	}
+	for _, v := range res.Kvs {
+		var dt DeviceToken
+		if err = json.Unmarshal(v.Value, &dt); err != nil {
+			return deviceTokens, err
+		}
+		deviceTokens = append(deviceTokens, dt)
+	}
+	return deviceTokens, nil
+}
+
+func (c *conn) listSessions(ctx context.Context) (sessions []Session, err error) {
+	res, err := c.db.Get(ctx, sessionPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return sessions, err
+	}
+	for _, v := range res.Kvs {
+		var s Session
+		if err = json.Unmarshal(v.Value, &s); err != nil {
+			return sessions, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+func (c *conn) listAccessTokens(ctx context.Context) (accessTokens []AccessToken, err error) {
+	res, err := c.db.Get(ctx, accessTokenPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return accessTokens, err
+	}
+	for _, v := range res.Kvs {
+		var t AccessToken
+		if err = json.Unmarshal(v.Value, &t); err != nil {
+			return accessTokens, err
+		}
+		accessTokens = append(accessTokens, t)
+	}
+	return accessTokens, nil
+}
+
+func (c *conn) listClientAssertions(ctx context.Context) (clientAssertions []ClientAssertion, err error) {
+	res, err := c.db.Get(ctx, clientAssertionPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return clientAssertions, err
+	}
+	for _, v := range res.Kvs {
+		var a ClientAssertion
+		if err = json.Unmarshal(v.Value, &a); err != nil {
+			return clientAssertions, err
+		}
+		clientAssertions = append(clientAssertions, a)
+	}
+	return clientAssertions, nil
+}
+
+func (c *conn) listDPoPProofs(ctx context.Context) (dpopProofs []DPoPProof, err error) {
+	res, err := c.db.Get(ctx, dpopProofPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return dpopProofs, err
+	}
+	for _, v := range res.Kvs {
+		var p DPoPProof
+		if err = json.Unmarshal(v.Value, &p); err != nil {
+			return dpopProofs, err
+		}
+		dpopProofs = append(dpopProofs, p)
+	}
+	return dpopProofs, nil
+}
+
 func (c *conn) txnCreate(ctx context.Context, key string, value interface{}) error {
 	b, err := json.Marshal(value)
 	if err != nil {
@@ -526,8 +797,152 @@ func (c *conn) txnUpdate(ctx context.Context, key string, update func(current []
 	return nil
 }
 
+func (c *conn) CreateDeviceRequest(d storage.DeviceRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(deviceRequestPrefix, d.UserCode), fromStorageDeviceRequest(d))
+}
+
+func (c *conn) GetDeviceRequest(userCode string) (r storage.DeviceRequest, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	var dr DeviceRequest
+	if err = c.getKey(ctx, keyID(deviceRequestPrefix, userCode), &dr); err != nil {
+		return
+	}
+	return toStorageDeviceRequest(dr), nil
+}
+
+func (c *conn) CreateDeviceToken(t storage.DeviceToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(deviceTokenPrefix, t.DeviceCode), fromStorageDeviceToken(t))
+}
+
+func (c *conn) GetDeviceToken(deviceCode string) (t storage.DeviceToken, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	var dt DeviceToken
+	if err = c.getKey(ctx, keyID(deviceTokenPrefix, deviceCode), &dt); err != nil {
+		return
+	}
+	return toStorageDeviceToken(dt), nil
+}
+
+func (c *conn) UpdateDeviceToken(deviceCode string, updater func(old storage.DeviceToken) (storage.DeviceToken, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	return c.txnUpdate(ctx, keyID(deviceTokenPrefix, deviceCode), func(currentValue []byte) ([]byte, error) {
+		var current DeviceToken
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, &current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(toStorageDeviceToken(current))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(fromStorageDeviceToken(updated))
+	})
+}
+
 func keyID(prefix, id string) string       { return prefix + id }
 func keyEmail(prefix, email string) string { return prefix + strings.ToLower(email) }
 func keySession(prefix, userID, connID string) string {
 	return prefix + strings.ToLower(userID+"|"+connID)
 }
+
+func (c *conn) CreateSession(s storage.Session) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(sessionPrefix, s.ID), fromStorageSession(s))
+}
+
+func (c *conn) GetSession(id string) (s storage.Session, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	var session Session
+	if err = c.getKey(ctx, keyID(sessionPrefix, id), &session); err != nil {
+		return
+	}
+	return toStorageSession(session), nil
+}
+
+func (c *conn) UpdateSession(id string, updater func(old storage.Session) (storage.Session, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	return c.txnUpdate(ctx, keyID(sessionPrefix, id), func(currentValue []byte) ([]byte, error) {
+		var current Session
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, &current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(toStorageSession(current))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(fromStorageSession(updated))
+	})
+}
+
+func (c *conn) DeleteSession(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	return c.deleteKey(ctx, keyID(sessionPrefix, id))
+}
+
+func (c *conn) CreateAccessToken(t storage.AccessToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(accessTokenPrefix, t.ID), fromStorageAccessToken(t))
+}
+
+func (c *conn) GetAccessToken(id string) (t storage.AccessToken, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	var accessToken AccessToken
+	if err = c.getKey(ctx, keyID(accessTokenPrefix, id), &accessToken); err != nil {
+		return
+	}
+	return toStorageAccessToken(accessToken), nil
+}
+
+func (c *conn) DeleteAccessToken(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	return c.deleteKey(ctx, keyID(accessTokenPrefix, id))
+}
+
+func (c *conn) CreateClientAssertion(a storage.ClientAssertion) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(clientAssertionPrefix, a.ID), fromStorageClientAssertion(a))
+}
+
+func (c *conn) GetClientAssertion(id string) (a storage.ClientAssertion, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	var clientAssertion ClientAssertion
+	if err = c.getKey(ctx, keyID(clientAssertionPrefix, id), &clientAssertion); err != nil {
+		return
+	}
+	return toStorageClientAssertion(clientAssertion), nil
+}
+
+func (c *conn) CreateDPoPProof(p storage.DPoPProof) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(dpopProofPrefix, p.ID), fromStorageDPoPProof(p))
+}
+
+func (c *conn) GetDPoPProof(id string) (p storage.DPoPProof, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+	defer cancel()
+	var dpopProof DPoPProof
+	if err = c.getKey(ctx, keyID(dpopProofPrefix, id), &dpopProof); err != nil {
+		return
+	}
+	return toStorageDPoPProof(dpopProof), nil
+}
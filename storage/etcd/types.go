@@ -20,11 +20,36 @@ type AuthCode struct {
 	ConnectorData []byte `json:"connectorData,omitempty"`
 	Claims        Claims `json:"claims,omitempty"`
 
+	AuthTime time.Time `json:"auth_time"`
+
 	Expiry time.Time `json:"expiry"`
+
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+
+	ClaimsRequest string `json:"claims_request,omitempty"`
 }
 
 func fromStorageAuthCode(a storage.AuthCode) AuthCode {
 	return AuthCode{
+		ID:                  a.ID,
+		ClientID:            a.ClientID,
+		RedirectURI:         a.RedirectURI,
+		ConnectorID:         a.ConnectorID,
+		ConnectorData:       a.ConnectorData,
+		Nonce:               a.Nonce,
+		Scopes:              a.Scopes,
+		Claims:              fromStorageClaims(a.Claims),
+		AuthTime:            a.AuthTime,
+		Expiry:              a.Expiry,
+		CodeChallenge:       a.PKCE.CodeChallenge,
+		CodeChallengeMethod: a.PKCE.CodeChallengeMethod,
+		ClaimsRequest:       a.ClaimsRequest,
+	}
+}
+
+func toStorageAuthCode(a AuthCode) storage.AuthCode {
+	return storage.AuthCode{
 		ID:            a.ID,
 		ClientID:      a.ClientID,
 		RedirectURI:   a.RedirectURI,
@@ -32,8 +57,14 @@ func fromStorageAuthCode(a storage.AuthCode) AuthCode {
 		ConnectorData: a.ConnectorData,
 		Nonce:         a.Nonce,
 		Scopes:        a.Scopes,
-		Claims:        fromStorageClaims(a.Claims),
+		Claims:        toStorageClaims(a.Claims),
+		AuthTime:      a.AuthTime,
 		Expiry:        a.Expiry,
+		PKCE: storage.PKCE{
+			CodeChallenge:       a.CodeChallenge,
+			CodeChallengeMethod: a.CodeChallengeMethod,
+		},
+		ClaimsRequest: a.ClaimsRequest,
 	}
 }
 
@@ -50,6 +81,9 @@ type AuthRequest struct {
 
 	ForceApprovalPrompt bool `json:"force_approval_prompt"`
 
+	Prompt string `json:"prompt,omitempty"`
+	MaxAge int    `json:"max_age,omitempty"`
+
 	Expiry time.Time `json:"expiry"`
 
 	LoggedIn bool `json:"logged_in"`
@@ -58,6 +92,13 @@ type AuthRequest struct {
 
 	ConnectorID   string `json:"connector_id"`
 	ConnectorData []byte `json:"connector_data"`
+
+	AuthTime time.Time `json:"auth_time"`
+
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+
+	ClaimsRequest string `json:"claims_request,omitempty"`
 }
 
 func fromStorageAuthRequest(a storage.AuthRequest) AuthRequest {
@@ -70,11 +111,17 @@ func fromStorageAuthRequest(a storage.AuthRequest) AuthRequest {
 		Nonce:               a.Nonce,
 		State:               a.State,
 		ForceApprovalPrompt: a.ForceApprovalPrompt,
+		Prompt:              a.Prompt,
+		MaxAge:              a.MaxAge,
 		Expiry:              a.Expiry,
 		LoggedIn:            a.LoggedIn,
 		Claims:              fromStorageClaims(a.Claims),
 		ConnectorID:         a.ConnectorID,
 		ConnectorData:       a.ConnectorData,
+		AuthTime:            a.AuthTime,
+		CodeChallenge:       a.PKCE.CodeChallenge,
+		CodeChallengeMethod: a.PKCE.CodeChallengeMethod,
+		ClaimsRequest:       a.ClaimsRequest,
 	}
 }
 
@@ -88,11 +135,19 @@ func toStorageAuthRequest(a AuthRequest) storage.AuthRequest {
 		Nonce:               a.Nonce,
 		State:               a.State,
 		ForceApprovalPrompt: a.ForceApprovalPrompt,
+		Prompt:              a.Prompt,
+		MaxAge:              a.MaxAge,
 		LoggedIn:            a.LoggedIn,
 		ConnectorID:         a.ConnectorID,
 		ConnectorData:       a.ConnectorData,
+		AuthTime:            a.AuthTime,
 		Expiry:              a.Expiry,
 		Claims:              toStorageClaims(a.Claims),
+		PKCE: storage.PKCE{
+			CodeChallenge:       a.CodeChallenge,
+			CodeChallengeMethod: a.CodeChallengeMethod,
+		},
+		ClaimsRequest: a.ClaimsRequest,
 	}
 }
 
@@ -100,10 +155,13 @@ func toStorageAuthRequest(a AuthRequest) storage.AuthRequest {
 type RefreshToken struct {
 	ID string `json:"id"`
 
-	Token string `json:"token"`
+	Token         string   `json:"token"`
+	ObsoleteToken string   `json:"obsolete_token,omitempty"`
+	RotatedTokens []string `json:"rotated_tokens,omitempty"`
 
 	CreatedAt time.Time `json:"created_at"`
 	LastUsed  time.Time `json:"last_used"`
+	Expiry    time.Time `json:"expiry,omitempty"`
 
 	ClientID string `json:"client_id"`
 
@@ -114,20 +172,29 @@ type RefreshToken struct {
 	Scopes []string `json:"scopes"`
 
 	Nonce string `json:"nonce"`
+
+	ClaimsRequest string `json:"claims_request,omitempty"`
+
+	Confirmation Confirmation `json:"confirmation"`
 }
 
 func toStorageRefreshToken(r RefreshToken) storage.RefreshToken {
 	return storage.RefreshToken{
 		ID:            r.ID,
 		Token:         r.Token,
+		ObsoleteToken: r.ObsoleteToken,
+		RotatedTokens: r.RotatedTokens,
 		CreatedAt:     r.CreatedAt,
 		LastUsed:      r.LastUsed,
+		Expiry:        r.Expiry,
 		ClientID:      r.ClientID,
 		ConnectorID:   r.ConnectorID,
 		ConnectorData: r.ConnectorData,
 		Scopes:        r.Scopes,
 		Nonce:         r.Nonce,
 		Claims:        toStorageClaims(r.Claims),
+		ClaimsRequest: r.ClaimsRequest,
+		Confirmation:  toStorageConfirmation(r.Confirmation),
 	}
 }
 
@@ -135,14 +202,19 @@ func fromStorageRefreshToken(r storage.RefreshToken) RefreshToken {
 	return RefreshToken{
 		ID:            r.ID,
 		Token:         r.Token,
+		ObsoleteToken: r.ObsoleteToken,
+		RotatedTokens: r.RotatedTokens,
 		CreatedAt:     r.CreatedAt,
 		LastUsed:      r.LastUsed,
+		Expiry:        r.Expiry,
 		ClientID:      r.ClientID,
 		ConnectorID:   r.ConnectorID,
 		ConnectorData: r.ConnectorData,
 		Scopes:        r.Scopes,
 		Nonce:         r.Nonce,
 		Claims:        fromStorageClaims(r.Claims),
+		ClaimsRequest: r.ClaimsRequest,
+		Confirmation:  fromStorageConfirmation(r.Confirmation),
 	}
 }
 
@@ -154,6 +226,8 @@ type Claims struct {
 	Email             string   `json:"email"`
 	EmailVerified     bool     `json:"emailVerified"`
 	Groups            []string `json:"groups,omitempty"`
+
+	CustomClaims map[string]interface{} `json:"customClaims,omitempty"`
 }
 
 func fromStorageClaims(i storage.Claims) Claims {
@@ -164,6 +238,7 @@ func fromStorageClaims(i storage.Claims) Claims {
 		Email:             i.Email,
 		EmailVerified:     i.EmailVerified,
 		Groups:            i.Groups,
+		CustomClaims:      i.CustomClaims,
 	}
 }
 
@@ -175,6 +250,7 @@ func toStorageClaims(i Claims) storage.Claims {
 		Email:             i.Email,
 		EmailVerified:     i.EmailVerified,
 		Groups:            i.Groups,
+		CustomClaims:      i.CustomClaims,
 	}
 }
 
@@ -216,3 +292,225 @@ func toStorageOfflineSessions(o OfflineSessions) storage.OfflineSessions {
 	}
 	return s
 }
+
+// DeviceRequest is a mirrored struct from storage with JSON struct tags
+type DeviceRequest struct {
+	UserCode   string    `json:"user_code"`
+	DeviceCode string    `json:"device_code"`
+	ClientID   string    `json:"client_id"`
+	Scopes     []string  `json:"scopes"`
+	Expiry     time.Time `json:"expiry"`
+}
+
+func fromStorageDeviceRequest(d storage.DeviceRequest) DeviceRequest {
+	return DeviceRequest{
+		UserCode:   d.UserCode,
+		DeviceCode: d.DeviceCode,
+		ClientID:   d.ClientID,
+		Scopes:     d.Scopes,
+		Expiry:     d.Expiry,
+	}
+}
+
+func toStorageDeviceRequest(d DeviceRequest) storage.DeviceRequest {
+	return storage.DeviceRequest{
+		UserCode:   d.UserCode,
+		DeviceCode: d.DeviceCode,
+		ClientID:   d.ClientID,
+		Scopes:     d.Scopes,
+		Expiry:     d.Expiry,
+	}
+}
+
+// DeviceToken is a mirrored struct from storage with JSON struct tags
+type DeviceToken struct {
+	DeviceCode          string    `json:"device_code"`
+	ClientID            string    `json:"client_id"`
+	Status              string    `json:"status"`
+	Token               string    `json:"token"`
+	Expiry              time.Time `json:"expiry"`
+	LastRequestTime     time.Time `json:"last_request"`
+	PollIntervalSeconds int       `json:"poll_interval"`
+}
+
+func fromStorageDeviceToken(t storage.DeviceToken) DeviceToken {
+	return DeviceToken{
+		DeviceCode:          t.DeviceCode,
+		ClientID:            t.ClientID,
+		Status:              t.Status,
+		Token:               t.Token,
+		Expiry:              t.Expiry,
+		LastRequestTime:     t.LastRequestTime,
+		PollIntervalSeconds: t.PollIntervalSeconds,
+	}
+}
+
+func toStorageDeviceToken(t DeviceToken) storage.DeviceToken {
+	return storage.DeviceToken{
+		DeviceCode:          t.DeviceCode,
+		ClientID:            t.ClientID,
+		Status:              t.Status,
+		Token:               t.Token,
+		Expiry:              t.Expiry,
+		LastRequestTime:     t.LastRequestTime,
+		PollIntervalSeconds: t.PollIntervalSeconds,
+	}
+}
+
+// Session is a mirrored struct from storage with JSON struct tags
+type Session struct {
+	ID string `json:"id"`
+
+	ConnectorID   string `json:"connector_id"`
+	ConnectorData []byte `json:"connector_data"`
+	Claims        Claims `json:"claims"`
+
+	Scopes []string `json:"scopes"`
+
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+func fromStorageSession(s storage.Session) Session {
+	return Session{
+		ID:            s.ID,
+		ConnectorID:   s.ConnectorID,
+		ConnectorData: s.ConnectorData,
+		Claims:        fromStorageClaims(s.Claims),
+		Scopes:        s.Scopes,
+		CreatedAt:     s.CreatedAt,
+		LastUsed:      s.LastUsed,
+		Expiry:        s.Expiry,
+	}
+}
+
+func toStorageSession(s Session) storage.Session {
+	return storage.Session{
+		ID:            s.ID,
+		ConnectorID:   s.ConnectorID,
+		ConnectorData: s.ConnectorData,
+		Claims:        toStorageClaims(s.Claims),
+		Scopes:        s.Scopes,
+		CreatedAt:     s.CreatedAt,
+		LastUsed:      s.LastUsed,
+		Expiry:        s.Expiry,
+	}
+}
+
+// AccessToken is a mirrored struct from storage with JSON struct tags
+type AccessToken struct {
+	ID string `json:"id"`
+
+	ClientID string   `json:"client_id"`
+	Subject  string   `json:"subject"`
+	Audience []string `json:"audience"`
+	Scopes   []string `json:"scopes"`
+
+	ConnectorID string `json:"connector_id"`
+	Claims      Claims `json:"claims"`
+
+	CreatedAt time.Time `json:"created_at"`
+	Expiry    time.Time `json:"expiry"`
+
+	ClaimsRequest string `json:"claims_request,omitempty"`
+
+	Confirmation Confirmation `json:"confirmation"`
+}
+
+func fromStorageAccessToken(t storage.AccessToken) AccessToken {
+	return AccessToken{
+		ID:          t.ID,
+		ClientID:    t.ClientID,
+		Subject:     t.Subject,
+		Audience:    t.Audience,
+		Scopes:      t.Scopes,
+		ConnectorID: t.ConnectorID,
+		Claims:      fromStorageClaims(t.Claims),
+		CreatedAt:   t.CreatedAt,
+		Expiry:      t.Expiry,
+
+		ClaimsRequest: t.ClaimsRequest,
+		Confirmation:  fromStorageConfirmation(t.Confirmation),
+	}
+}
+
+func toStorageAccessToken(t AccessToken) storage.AccessToken {
+	return storage.AccessToken{
+		ID:          t.ID,
+		ClientID:    t.ClientID,
+		Subject:     t.Subject,
+		Audience:    t.Audience,
+		Scopes:      t.Scopes,
+		ConnectorID: t.ConnectorID,
+		Claims:      toStorageClaims(t.Claims),
+		CreatedAt:   t.CreatedAt,
+		Expiry:      t.Expiry,
+
+		ClaimsRequest: t.ClaimsRequest,
+		Confirmation:  toStorageConfirmation(t.Confirmation),
+	}
+}
+
+// Confirmation is a mirrored struct from storage with JSON struct tags
+type Confirmation struct {
+	JKT     string `json:"jkt,omitempty"`
+	X5TS256 string `json:"x5t#S256,omitempty"`
+}
+
+func fromStorageConfirmation(c storage.Confirmation) Confirmation {
+	return Confirmation{
+		JKT:     c.JKT,
+		X5TS256: c.X5TS256,
+	}
+}
+
+func toStorageConfirmation(c Confirmation) storage.Confirmation {
+	return storage.Confirmation{
+		JKT:     c.JKT,
+		X5TS256: c.X5TS256,
+	}
+}
+
+// ClientAssertion is a mirrored struct from storage with JSON struct tags
+type ClientAssertion struct {
+	ID       string    `json:"id"`
+	ClientID string    `json:"client_id"`
+	Expiry   time.Time `json:"expiry"`
+}
+
+func fromStorageClientAssertion(a storage.ClientAssertion) ClientAssertion {
+	return ClientAssertion{
+		ID:       a.ID,
+		ClientID: a.ClientID,
+		Expiry:   a.Expiry,
+	}
+}
+
+func toStorageClientAssertion(a ClientAssertion) storage.ClientAssertion {
+	return storage.ClientAssertion{
+		ID:       a.ID,
+		ClientID: a.ClientID,
+		Expiry:   a.Expiry,
+	}
+}
+
+// DPoPProof is a mirrored struct from storage with JSON struct tags
+type DPoPProof struct {
+	ID     string    `json:"id"`
+	Expiry time.Time `json:"expiry"`
+}
+
+func fromStorageDPoPProof(p storage.DPoPProof) DPoPProof {
+	return DPoPProof{
+		ID:     p.ID,
+		Expiry: p.Expiry,
+	}
+}
+
+func toStorageDPoPProof(p DPoPProof) storage.DPoPProof {
+	return storage.DPoPProof{
+		ID:     p.ID,
+		Expiry: p.Expiry,
+	}
+}
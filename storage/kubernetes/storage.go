@@ -21,6 +21,12 @@ const (
 	kindPassword        = "Password"
 	kindOfflineSessions = "OfflineSessions"
 	kindConnector       = "Connector"
+	kindDeviceRequest   = "DeviceRequest"
+	kindDeviceToken     = "DeviceToken"
+	kindSession         = "Session"
+	kindAccessToken     = "AccessToken"
+	kindClientAssertion = "ClientAssertion"
+	kindDPoPProof       = "DPoPProof"
 )
 
 const (
@@ -32,6 +38,12 @@ const (
 	resourcePassword        = "passwords"
 	resourceOfflineSessions = "offlinesessionses" // Again attempts to pluralize.
 	resourceConnector       = "connectors"
+	resourceDeviceRequest   = "devicerequests"
+	resourceDeviceToken     = "devicetokens"
+	resourceSession         = "sessions"
+	resourceAccessToken     = "accesstokens"
+	resourceClientAssertion = "clientassertions"
+	resourceDPoPProof       = "dpopproofs"
 )
 
 // Config values for the Kubernetes storage type.
@@ -46,6 +58,15 @@ func (c *Config) Open(logger log.Logger) (storage.Storage, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Secrets of clients created by earlier versions are stored in plaintext.
+	n, err := storage.MigrateClientSecrets(cli)
+	if err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("failed to hash client secrets: %v", err)
+	}
+	if n > 0 {
+		logger.Infof("hashed the secrets of %d clients", n)
+	}
 	return cli, nil
 }
 
@@ -349,8 +370,18 @@ func (cli *client) GetConnector(id string) (storage.Connector, error) {
 	return toStorageConnector(c), nil
 }
 
-func (cli *client) ListClients() ([]storage.Client, error) {
-	return nil, errors.New("not implemented")
+func (cli *client) ListClients() (clients []storage.Client, err error) {
+	var clientList ClientList
+	if err = cli.list(resourceClient, &clientList); err != nil {
+		return clients, fmt.Errorf("failed to list clients: %v", err)
+	}
+
+	clients = make([]storage.Client, len(clientList.Clients))
+	for i, client := range clientList.Clients {
+		clients[i] = toStorageClient(client)
+	}
+
+	return
 }
 
 func (cli *client) ListRefreshTokens() ([]storage.RefreshToken, error) {
@@ -593,5 +624,279 @@ func (cli *client) GarbageCollect(now time.Time) (result storage.GCResult, err e
 			result.AuthCodes++
 		}
 	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var deviceRequests DeviceRequestList
+	if err := cli.list(resourceDeviceRequest, &deviceRequests); err != nil {
+		return result, fmt.Errorf("failed to list device requests: %v", err)
+	}
+
+	for _, deviceRequest := range deviceRequests.DeviceRequests {
+		if now.After(deviceRequest.Expiry) {
+			if err := cli.delete(resourceDeviceRequest, deviceRequest.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete device request: %v", err)
+				delErr = fmt.Errorf("failed to delete device request: %v", err)
+			}
+			result.DeviceRequests++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var deviceTokens DeviceTokenList
+	if err := cli.list(resourceDeviceToken, &deviceTokens); err != nil {
+		return result, fmt.Errorf("failed to list device tokens: %v", err)
+	}
+
+	for _, deviceToken := range deviceTokens.DeviceTokens {
+		if now.After(deviceToken.Expiry) {
+			if err := cli.delete(resourceDeviceToken, deviceToken.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete device token: %v", err)
+				delErr = fmt.Errorf("failed to delete device token: %v", err)
+			}
+			result.DeviceTokens++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var sessions SessionList
+	if err := cli.list(resourceSession, &sessions); err != nil {
+		return result, fmt.Errorf("failed to list sessions: %v", err)
+	}
+
+	for _, session := range sessions.Sessions {
+		if now.After(session.Expiry) {
+			if err := cli.delete(resourceSession, session.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete session: %v", err)
+				delErr = fmt.Errorf("failed to delete session: %v", err)
+			}
+			result.Sessions++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var accessTokens AccessTokenList
+	if err := cli.list(resourceAccessToken, &accessTokens); err != nil {
+		return result, fmt.Errorf("failed to list access tokens: %v", err)
+	}
+
+	for _, accessToken := range accessTokens.AccessTokens {
+		if now.After(accessToken.Expiry) {
+			if err := cli.delete(resourceAccessToken, accessToken.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete access token: %v", err)
+				delErr = fmt.Errorf("failed to delete access token: %v", err)
+			}
+			result.AccessTokens++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var clientAssertions ClientAssertionList
+	if err := cli.list(resourceClientAssertion, &clientAssertions); err != nil {
+		return result, fmt.Errorf("failed to list client assertions: %v", err)
+	}
+
+	for _, clientAssertion := range clientAssertions.ClientAssertions {
+		if now.After(clientAssertion.Expiry) {
+			if err := cli.delete(resourceClientAssertion, clientAssertion.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete client assertion: %v", err)
+				delErr = fmt.Errorf("failed to delete client assertion: %v", err)
+			}
+			result.ClientAssertions++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var dpopProofs DPoPProofList
+	if err := cli.list(resourceDPoPProof, &dpopProofs); err != nil {
+		return result, fmt.Errorf("failed to list dpop proofs: %v", err)
+	}
+
+	for _, dpopProof := range dpopProofs.DPoPProofs {
+		if now.After(dpopProof.Expiry) {
+			if err := cli.delete(resourceDPoPProof, dpopProof.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete dpop proof: %v", err)
+				delErr = fmt.Errorf("failed to delete dpop proof: %v", err)
+			}
+			result.DPoPProofs++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var refreshTokens RefreshList
+	if err := cli.list(resourceRefreshToken, &refreshTokens); err != nil {
+		return result, fmt.Errorf("failed to list refresh tokens: %v", err)
+	}
+
+	for _, refreshToken := range refreshTokens.RefreshTokens {
+		if refreshToken.Expiry.IsZero() || !now.After(refreshToken.Expiry) {
+			continue
+		}
+		if err := cli.delete(resourceRefreshToken, refreshToken.ObjectMeta.Name); err != nil {
+			cli.logger.Errorf("failed to delete refresh token: %v", err)
+			delErr = fmt.Errorf("failed to delete refresh token: %v", err)
+			continue
+		}
+		result.RefreshTokens++
+
+		sessionDeleted, err := cli.gcOfflineSessions(toStorageRefreshToken(refreshToken))
+		if err != nil {
+			cli.logger.Errorf("failed to clean up offline session: %v", err)
+			delErr = fmt.Errorf("failed to clean up offline session: %v", err)
+		}
+		if sessionDeleted {
+			result.OfflineSessions++
+		}
+	}
 	return result, delErr
 }
+
+// gcOfflineSessions removes the reference to a deleted refresh token from the
+// offline session of the user, and deletes the offline session once it doesn't
+// hold any refresh token anymore.
+func (cli *client) gcOfflineSessions(r storage.RefreshToken) (sessionDeleted bool, err error) {
+	o, err := cli.getOfflineSessions(r.Claims.UserID, r.ConnectorID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	if ref, ok := o.Refresh[r.ClientID]; ok && ref.ID == r.ID {
+		delete(o.Refresh, r.ClientID)
+	}
+	if len(o.Refresh) > 0 {
+		return false, cli.put(resourceOfflineSessions, o.ObjectMeta.Name, o)
+	}
+	if err := cli.delete(resourceOfflineSessions, o.ObjectMeta.Name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (cli *client) CreateDeviceRequest(d storage.DeviceRequest) error {
+	return cli.post(resourceDeviceRequest, cli.fromStorageDeviceRequest(d))
+}
+
+func (cli *client) GetDeviceRequest(userCode string) (storage.DeviceRequest, error) {
+	var req DeviceRequest
+	if err := cli.get(resourceDeviceRequest, strings.ToLower(userCode), &req); err != nil {
+		return storage.DeviceRequest{}, err
+	}
+	return toStorageDeviceRequest(req), nil
+}
+
+func (cli *client) CreateDeviceToken(t storage.DeviceToken) error {
+	return cli.post(resourceDeviceToken, cli.fromStorageDeviceToken(t))
+}
+
+func (cli *client) GetDeviceToken(deviceCode string) (storage.DeviceToken, error) {
+	var token DeviceToken
+	if err := cli.get(resourceDeviceToken, deviceCode, &token); err != nil {
+		return storage.DeviceToken{}, err
+	}
+	return toStorageDeviceToken(token), nil
+}
+
+func (cli *client) UpdateDeviceToken(deviceCode string, updater func(old storage.DeviceToken) (storage.DeviceToken, error)) error {
+	var token DeviceToken
+	if err := cli.get(resourceDeviceToken, deviceCode, &token); err != nil {
+		return err
+	}
+
+	updated, err := updater(toStorageDeviceToken(token))
+	if err != nil {
+		return err
+	}
+	updated.DeviceCode = deviceCode
+
+	newToken := cli.fromStorageDeviceToken(updated)
+	newToken.ObjectMeta = token.ObjectMeta
+	return cli.put(resourceDeviceToken, deviceCode, newToken)
+}
+
+func (cli *client) CreateSession(s storage.Session) error {
+	return cli.post(resourceSession, cli.fromStorageSession(s))
+}
+
+func (cli *client) GetSession(id string) (storage.Session, error) {
+	var session Session
+	if err := cli.get(resourceSession, id, &session); err != nil {
+		return storage.Session{}, err
+	}
+	return toStorageSession(session), nil
+}
+
+func (cli *client) UpdateSession(id string, updater func(old storage.Session) (storage.Session, error)) error {
+	var session Session
+	if err := cli.get(resourceSession, id, &session); err != nil {
+		return err
+	}
+
+	updated, err := updater(toStorageSession(session))
+	if err != nil {
+		return err
+	}
+	updated.ID = id
+
+	newSession := cli.fromStorageSession(updated)
+	newSession.ObjectMeta = session.ObjectMeta
+	return cli.put(resourceSession, id, newSession)
+}
+
+func (cli *client) DeleteSession(id string) error {
+	return cli.delete(resourceSession, id)
+}
+
+func (cli *client) CreateAccessToken(t storage.AccessToken) error {
+	return cli.post(resourceAccessToken, cli.fromStorageAccessToken(t))
+}
+
+func (cli *client) GetAccessToken(id string) (storage.AccessToken, error) {
+	var accessToken AccessToken
+	if err := cli.get(resourceAccessToken, id, &accessToken); err != nil {
+		return storage.AccessToken{}, err
+	}
+	return toStorageAccessToken(accessToken), nil
+}
+
+func (cli *client) DeleteAccessToken(id string) error {
+	return cli.delete(resourceAccessToken, id)
+}
+
+func (cli *client) CreateClientAssertion(a storage.ClientAssertion) error {
+	return cli.post(resourceClientAssertion, cli.fromStorageClientAssertion(a))
+}
+
+func (cli *client) GetClientAssertion(id string) (storage.ClientAssertion, error) {
+	var clientAssertion ClientAssertion
+	if err := cli.get(resourceClientAssertion, id, &clientAssertion); err != nil {
+		return storage.ClientAssertion{}, err
+	}
+	return toStorageClientAssertion(clientAssertion), nil
+}
+
+func (cli *client) CreateDPoPProof(p storage.DPoPProof) error {
+	return cli.post(resourceDPoPProof, cli.fromStorageDPoPProof(p))
+}
+
+func (cli *client) GetDPoPProof(id string) (storage.DPoPProof, error) {
+	var dpopProof DPoPProof
+	if err := cli.get(resourceDPoPProof, id, &dpopProof); err != nil {
+		return storage.DPoPProof{}, err
+	}
+	return toStorageDPoPProof(dpopProof), nil
+}
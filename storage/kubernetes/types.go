@@ -143,6 +143,96 @@ var customResourceDefinitions = []k8sapi.CustomResourceDefinition{
 			},
 		},
 	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "devicerequests.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "devicerequests",
+				Singular: "devicerequest",
+				Kind:     "DeviceRequest",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "devicetokens.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "devicetokens",
+				Singular: "devicetoken",
+				Kind:     "DeviceToken",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "sessions.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "sessions",
+				Singular: "session",
+				Kind:     "Session",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "accesstokens.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "accesstokens",
+				Singular: "accesstoken",
+				Kind:     "AccessToken",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "clientassertions.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "clientassertions",
+				Singular: "clientassertion",
+				Kind:     "ClientAssertion",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "dpopproofs.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "dpopproofs",
+				Singular: "dpopproof",
+				Kind:     "DPoPProof",
+			},
+		},
+	},
 }
 
 // There will only ever be a single keys resource. Maintain this by setting a
@@ -163,10 +253,42 @@ type Client struct {
 	RedirectURIs []string `json:"redirectURIs,omitempty"`
 	TrustedPeers []string `json:"trustedPeers,omitempty"`
 
+	Secrets []storage.ClientSecret `json:"secrets,omitempty"`
+
+	PostLogoutRedirectURIs []string `json:"postLogoutRedirectURIs,omitempty"`
+
 	Public bool `json:"public"`
 
+	AllowedScopes           []string `json:"allowedScopes,omitempty"`
+	ClientCredentialsScopes []string `json:"clientCredentialsScopes,omitempty"`
+	AllowedAudiences        []string `json:"allowedAudiences,omitempty"`
+
+	AllowedGrantTypes    []string `json:"allowedGrantTypes,omitempty"`
+	AllowedResponseTypes []string `json:"allowedResponseTypes,omitempty"`
+	AllowedConnectors    []string `json:"allowedConnectors,omitempty"`
+
 	Name    string `json:"name,omitempty"`
 	LogoURL string `json:"logoURL,omitempty"`
+
+	RegistrationAccessToken string `json:"registrationAccessToken,omitempty"`
+
+	RefreshTokenValidFor    string `json:"refreshTokenValidFor,omitempty"`
+	RefreshTokenIdleTimeout string `json:"refreshTokenIdleTimeout,omitempty"`
+
+	IDTokenValidFor     string `json:"idTokenValidFor,omitempty"`
+	AccessTokenValidFor string `json:"accessTokenValidFor,omitempty"`
+
+	JWKS        string   `json:"jwks,omitempty"`
+	JWKSURI     string   `json:"jwksURI,omitempty"`
+	RequestURIs []string `json:"requestURIs,omitempty"`
+
+	RequirePushedAuthRequests bool     `json:"requirePushedAuthRequests,omitempty"`
+	TokenEndpointAuthMethods  []string `json:"tokenEndpointAuthMethods,omitempty"`
+
+	RequireDPoP bool `json:"requireDPoP,omitempty"`
+
+	TLSClientAuthSubjectDN   string   `json:"tlsClientAuthSubjectDN,omitempty"`
+	TLSClientCertThumbprints []string `json:"tlsClientCertThumbprints,omitempty"`
 }
 
 // ClientList is a list of Clients.
@@ -188,11 +310,35 @@ func (cli *client) fromStorageClient(c storage.Client) Client {
 		},
 		ID:           c.ID,
 		Secret:       c.Secret,
+		Secrets:      c.Secrets,
 		RedirectURIs: c.RedirectURIs,
 		TrustedPeers: c.TrustedPeers,
 		Public:       c.Public,
 		Name:         c.Name,
 		LogoURL:      c.LogoURL,
+
+		PostLogoutRedirectURIs:  c.PostLogoutRedirectURIs,
+		AllowedScopes:           c.AllowedScopes,
+		ClientCredentialsScopes: c.ClientCredentialsScopes,
+		AllowedAudiences:        c.AllowedAudiences,
+		AllowedGrantTypes:       c.AllowedGrantTypes,
+		AllowedResponseTypes:    c.AllowedResponseTypes,
+		AllowedConnectors:       c.AllowedConnectors,
+
+		RegistrationAccessToken: c.RegistrationAccessToken,
+		RefreshTokenValidFor:    c.RefreshTokenValidFor,
+		RefreshTokenIdleTimeout: c.RefreshTokenIdleTimeout,
+		IDTokenValidFor:         c.IDTokenValidFor,
+		AccessTokenValidFor:     c.AccessTokenValidFor,
+		JWKS:                    c.JWKS,
+		JWKSURI:                 c.JWKSURI,
+		RequestURIs:             c.RequestURIs,
+
+		RequirePushedAuthRequests: c.RequirePushedAuthRequests,
+		TokenEndpointAuthMethods:  c.TokenEndpointAuthMethods,
+		RequireDPoP:               c.RequireDPoP,
+		TLSClientAuthSubjectDN:    c.TLSClientAuthSubjectDN,
+		TLSClientCertThumbprints:  c.TLSClientCertThumbprints,
 	}
 }
 
@@ -200,11 +346,35 @@ func toStorageClient(c Client) storage.Client {
 	return storage.Client{
 		ID:           c.ID,
 		Secret:       c.Secret,
+		Secrets:      c.Secrets,
 		RedirectURIs: c.RedirectURIs,
 		TrustedPeers: c.TrustedPeers,
 		Public:       c.Public,
 		Name:         c.Name,
 		LogoURL:      c.LogoURL,
+
+		PostLogoutRedirectURIs:  c.PostLogoutRedirectURIs,
+		AllowedScopes:           c.AllowedScopes,
+		ClientCredentialsScopes: c.ClientCredentialsScopes,
+		AllowedAudiences:        c.AllowedAudiences,
+		AllowedGrantTypes:       c.AllowedGrantTypes,
+		AllowedResponseTypes:    c.AllowedResponseTypes,
+		AllowedConnectors:       c.AllowedConnectors,
+
+		RegistrationAccessToken: c.RegistrationAccessToken,
+		RefreshTokenValidFor:    c.RefreshTokenValidFor,
+		RefreshTokenIdleTimeout: c.RefreshTokenIdleTimeout,
+		IDTokenValidFor:         c.IDTokenValidFor,
+		AccessTokenValidFor:     c.AccessTokenValidFor,
+		JWKS:                    c.JWKS,
+		JWKSURI:                 c.JWKSURI,
+		RequestURIs:             c.RequestURIs,
+
+		RequirePushedAuthRequests: c.RequirePushedAuthRequests,
+		TokenEndpointAuthMethods:  c.TokenEndpointAuthMethods,
+		RequireDPoP:               c.RequireDPoP,
+		TLSClientAuthSubjectDN:    c.TLSClientAuthSubjectDN,
+		TLSClientCertThumbprints:  c.TLSClientCertThumbprints,
 	}
 }
 
@@ -216,6 +386,8 @@ type Claims struct {
 	Email             string   `json:"email"`
 	EmailVerified     bool     `json:"emailVerified"`
 	Groups            []string `json:"groups,omitempty"`
+
+	CustomClaims map[string]interface{} `json:"customClaims,omitempty"`
 }
 
 func fromStorageClaims(i storage.Claims) Claims {
@@ -226,6 +398,7 @@ func fromStorageClaims(i storage.Claims) Claims {
 		Email:             i.Email,
 		EmailVerified:     i.EmailVerified,
 		Groups:            i.Groups,
+		CustomClaims:      i.CustomClaims,
 	}
 }
 
@@ -237,6 +410,7 @@ func toStorageClaims(i Claims) storage.Claims {
 		Email:             i.Email,
 		EmailVerified:     i.EmailVerified,
 		Groups:            i.Groups,
+		CustomClaims:      i.CustomClaims,
 	}
 }
 
@@ -259,6 +433,9 @@ type AuthRequest struct {
 	// attempts.
 	ForceApprovalPrompt bool `json:"forceApprovalPrompt,omitempty"`
 
+	Prompt string `json:"prompt,omitempty"`
+	MaxAge int    `json:"maxAge,omitempty"`
+
 	LoggedIn bool `json:"loggedIn"`
 
 	// The identity of the end user. Generally nil until the user authenticates
@@ -268,7 +445,14 @@ type AuthRequest struct {
 	ConnectorID   string `json:"connectorID,omitempty"`
 	ConnectorData []byte `json:"connectorData,omitempty"`
 
+	AuthTime time.Time `json:"authTime"`
+
 	Expiry time.Time `json:"expiry"`
+
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+
+	ClaimsRequest string `json:"claimsRequest,omitempty"`
 }
 
 // AuthRequestList is a list of AuthRequests.
@@ -288,11 +472,19 @@ func toStorageAuthRequest(req AuthRequest) storage.AuthRequest {
 		Nonce:               req.Nonce,
 		State:               req.State,
 		ForceApprovalPrompt: req.ForceApprovalPrompt,
+		Prompt:              req.Prompt,
+		MaxAge:              req.MaxAge,
 		LoggedIn:            req.LoggedIn,
 		ConnectorID:         req.ConnectorID,
 		ConnectorData:       req.ConnectorData,
+		AuthTime:            req.AuthTime,
 		Expiry:              req.Expiry,
 		Claims:              toStorageClaims(req.Claims),
+		PKCE: storage.PKCE{
+			CodeChallenge:       req.CodeChallenge,
+			CodeChallengeMethod: req.CodeChallengeMethod,
+		},
+		ClaimsRequest: req.ClaimsRequest,
 	}
 	return a
 }
@@ -315,10 +507,16 @@ func (cli *client) fromStorageAuthRequest(a storage.AuthRequest) AuthRequest {
 		State:               a.State,
 		LoggedIn:            a.LoggedIn,
 		ForceApprovalPrompt: a.ForceApprovalPrompt,
+		Prompt:              a.Prompt,
+		MaxAge:              a.MaxAge,
 		ConnectorID:         a.ConnectorID,
 		ConnectorData:       a.ConnectorData,
+		AuthTime:            a.AuthTime,
 		Expiry:              a.Expiry,
 		Claims:              fromStorageClaims(a.Claims),
+		CodeChallenge:       a.PKCE.CodeChallenge,
+		CodeChallengeMethod: a.PKCE.CodeChallengeMethod,
+		ClaimsRequest:       a.ClaimsRequest,
 	}
 	return req
 }
@@ -391,7 +589,14 @@ type AuthCode struct {
 	ConnectorID   string `json:"connectorID,omitempty"`
 	ConnectorData []byte `json:"connectorData,omitempty"`
 
+	AuthTime time.Time `json:"authTime"`
+
 	Expiry time.Time `json:"expiry"`
+
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+
+	ClaimsRequest string `json:"claimsRequest,omitempty"`
 }
 
 // AuthCodeList is a list of AuthCodes.
@@ -411,14 +616,18 @@ func (cli *client) fromStorageAuthCode(a storage.AuthCode) AuthCode {
 			Name:      a.ID,
 			Namespace: cli.namespace,
 		},
-		ClientID:      a.ClientID,
-		RedirectURI:   a.RedirectURI,
-		ConnectorID:   a.ConnectorID,
-		ConnectorData: a.ConnectorData,
-		Nonce:         a.Nonce,
-		Scopes:        a.Scopes,
-		Claims:        fromStorageClaims(a.Claims),
-		Expiry:        a.Expiry,
+		ClientID:            a.ClientID,
+		RedirectURI:         a.RedirectURI,
+		ConnectorID:         a.ConnectorID,
+		ConnectorData:       a.ConnectorData,
+		Nonce:               a.Nonce,
+		Scopes:              a.Scopes,
+		Claims:              fromStorageClaims(a.Claims),
+		AuthTime:            a.AuthTime,
+		Expiry:              a.Expiry,
+		CodeChallenge:       a.PKCE.CodeChallenge,
+		CodeChallengeMethod: a.PKCE.CodeChallengeMethod,
+		ClaimsRequest:       a.ClaimsRequest,
 	}
 }
 
@@ -432,7 +641,13 @@ func toStorageAuthCode(a AuthCode) storage.AuthCode {
 		Nonce:         a.Nonce,
 		Scopes:        a.Scopes,
 		Claims:        toStorageClaims(a.Claims),
+		AuthTime:      a.AuthTime,
 		Expiry:        a.Expiry,
+		PKCE: storage.PKCE{
+			CodeChallenge:       a.CodeChallenge,
+			CodeChallengeMethod: a.CodeChallengeMethod,
+		},
+		ClaimsRequest: a.ClaimsRequest,
 	}
 }
 
@@ -444,17 +659,24 @@ type RefreshToken struct {
 
 	CreatedAt time.Time
 	LastUsed  time.Time
+	Expiry    time.Time `json:"expiry,omitempty"`
 
 	ClientID string   `json:"clientID"`
 	Scopes   []string `json:"scopes,omitempty"`
 
-	Token string `json:"token,omitempty"`
+	Token         string   `json:"token,omitempty"`
+	ObsoleteToken string   `json:"obsoleteToken,omitempty"`
+	RotatedTokens []string `json:"rotatedTokens,omitempty"`
 
 	Nonce string `json:"nonce,omitempty"`
 
 	Claims        Claims `json:"claims,omitempty"`
 	ConnectorID   string `json:"connectorID,omitempty"`
 	ConnectorData []byte `json:"connectorData,omitempty"`
+
+	ClaimsRequest string `json:"claimsRequest,omitempty"`
+
+	Confirmation storage.Confirmation `json:"confirmation,omitempty"`
 }
 
 // RefreshList is a list of refresh tokens.
@@ -468,14 +690,19 @@ func toStorageRefreshToken(r RefreshToken) storage.RefreshToken {
 	return storage.RefreshToken{
 		ID:            r.ObjectMeta.Name,
 		Token:         r.Token,
+		ObsoleteToken: r.ObsoleteToken,
+		RotatedTokens: r.RotatedTokens,
 		CreatedAt:     r.CreatedAt,
 		LastUsed:      r.LastUsed,
+		Expiry:        r.Expiry,
 		ClientID:      r.ClientID,
 		ConnectorID:   r.ConnectorID,
 		ConnectorData: r.ConnectorData,
 		Scopes:        r.Scopes,
 		Nonce:         r.Nonce,
 		Claims:        toStorageClaims(r.Claims),
+		ClaimsRequest: r.ClaimsRequest,
+		Confirmation:  r.Confirmation,
 	}
 }
 
@@ -490,14 +717,19 @@ func (cli *client) fromStorageRefreshToken(r storage.RefreshToken) RefreshToken
 			Namespace: cli.namespace,
 		},
 		Token:         r.Token,
+		ObsoleteToken: r.ObsoleteToken,
+		RotatedTokens: r.RotatedTokens,
 		CreatedAt:     r.CreatedAt,
 		LastUsed:      r.LastUsed,
+		Expiry:        r.Expiry,
 		ClientID:      r.ClientID,
 		ConnectorID:   r.ConnectorID,
 		ConnectorData: r.ConnectorData,
 		Scopes:        r.Scopes,
 		Nonce:         r.Nonce,
 		Claims:        fromStorageClaims(r.Claims),
+		ClaimsRequest: r.ClaimsRequest,
+		Confirmation:  r.Confirmation,
 	}
 }
 
@@ -635,3 +867,307 @@ type ConnectorList struct {
 	k8sapi.ListMeta `json:"metadata,omitempty"`
 	Connectors      []Connector `json:"items"`
 }
+
+// DeviceRequest is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type DeviceRequest struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	// Kubernetes names must be lower case, so the user code is stored as well.
+	UserCode   string    `json:"user_code,omitempty"`
+	DeviceCode string    `json:"device_code,omitempty"`
+	ClientID   string    `json:"client_id,omitempty"`
+	Scopes     []string  `json:"scopes,omitempty"`
+	Expiry     time.Time `json:"expiry"`
+}
+
+// DeviceRequestList is a list of DeviceRequests.
+type DeviceRequestList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	DeviceRequests  []DeviceRequest `json:"items"`
+}
+
+func (cli *client) fromStorageDeviceRequest(d storage.DeviceRequest) DeviceRequest {
+	return DeviceRequest{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindDeviceRequest,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      strings.ToLower(d.UserCode),
+			Namespace: cli.namespace,
+		},
+		UserCode:   d.UserCode,
+		DeviceCode: d.DeviceCode,
+		ClientID:   d.ClientID,
+		Scopes:     d.Scopes,
+		Expiry:     d.Expiry,
+	}
+}
+
+func toStorageDeviceRequest(req DeviceRequest) storage.DeviceRequest {
+	return storage.DeviceRequest{
+		UserCode:   req.UserCode,
+		DeviceCode: req.DeviceCode,
+		ClientID:   req.ClientID,
+		Scopes:     req.Scopes,
+		Expiry:     req.Expiry,
+	}
+}
+
+// DeviceToken is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type DeviceToken struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ClientID            string    `json:"clientID,omitempty"`
+	Status              string    `json:"status,omitempty"`
+	Token               string    `json:"token,omitempty"`
+	Expiry              time.Time `json:"expiry"`
+	LastRequestTime     time.Time `json:"last_request"`
+	PollIntervalSeconds int       `json:"poll_interval"`
+}
+
+// DeviceTokenList is a list of DeviceTokens.
+type DeviceTokenList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	DeviceTokens    []DeviceToken `json:"items"`
+}
+
+func (cli *client) fromStorageDeviceToken(t storage.DeviceToken) DeviceToken {
+	return DeviceToken{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindDeviceToken,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      t.DeviceCode,
+			Namespace: cli.namespace,
+		},
+		ClientID:            t.ClientID,
+		Status:              t.Status,
+		Token:               t.Token,
+		Expiry:              t.Expiry,
+		LastRequestTime:     t.LastRequestTime,
+		PollIntervalSeconds: t.PollIntervalSeconds,
+	}
+}
+
+func toStorageDeviceToken(t DeviceToken) storage.DeviceToken {
+	return storage.DeviceToken{
+		DeviceCode:          t.ObjectMeta.Name,
+		ClientID:            t.ClientID,
+		Status:              t.Status,
+		Token:               t.Token,
+		Expiry:              t.Expiry,
+		LastRequestTime:     t.LastRequestTime,
+		PollIntervalSeconds: t.PollIntervalSeconds,
+	}
+}
+
+// Session is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type Session struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ConnectorID   string   `json:"connectorID,omitempty"`
+	ConnectorData []byte   `json:"connectorData,omitempty"`
+	Claims        Claims   `json:"claims,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	LastUsed  time.Time `json:"lastUsed"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+// SessionList is a list of Sessions.
+type SessionList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	Sessions        []Session `json:"items"`
+}
+
+func (cli *client) fromStorageSession(s storage.Session) Session {
+	return Session{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindSession,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      s.ID,
+			Namespace: cli.namespace,
+		},
+		ConnectorID:   s.ConnectorID,
+		ConnectorData: s.ConnectorData,
+		Claims:        fromStorageClaims(s.Claims),
+		Scopes:        s.Scopes,
+		CreatedAt:     s.CreatedAt,
+		LastUsed:      s.LastUsed,
+		Expiry:        s.Expiry,
+	}
+}
+
+func toStorageSession(s Session) storage.Session {
+	return storage.Session{
+		ID:            s.ObjectMeta.Name,
+		ConnectorID:   s.ConnectorID,
+		ConnectorData: s.ConnectorData,
+		Claims:        toStorageClaims(s.Claims),
+		Scopes:        s.Scopes,
+		CreatedAt:     s.CreatedAt,
+		LastUsed:      s.LastUsed,
+		Expiry:        s.Expiry,
+	}
+}
+
+// AccessToken is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type AccessToken struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ClientID string   `json:"clientID"`
+	Subject  string   `json:"subject"`
+	Audience []string `json:"audience,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+
+	ConnectorID string `json:"connectorID,omitempty"`
+	Claims      Claims `json:"claims,omitempty"`
+
+	ClaimsRequest string `json:"claimsRequest,omitempty"`
+
+	Confirmation storage.Confirmation `json:"confirmation,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+// AccessTokenList is a list of AccessTokens.
+type AccessTokenList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	AccessTokens    []AccessToken `json:"items"`
+}
+
+func (cli *client) fromStorageAccessToken(t storage.AccessToken) AccessToken {
+	return AccessToken{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindAccessToken,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      t.ID,
+			Namespace: cli.namespace,
+		},
+		ClientID:    t.ClientID,
+		Subject:     t.Subject,
+		Audience:    t.Audience,
+		Scopes:      t.Scopes,
+		ConnectorID: t.ConnectorID,
+		Claims:      fromStorageClaims(t.Claims),
+		CreatedAt:   t.CreatedAt,
+		Expiry:      t.Expiry,
+
+		ClaimsRequest: t.ClaimsRequest,
+		Confirmation:  t.Confirmation,
+	}
+}
+
+func toStorageAccessToken(t AccessToken) storage.AccessToken {
+	return storage.AccessToken{
+		ID:          t.ObjectMeta.Name,
+		ClientID:    t.ClientID,
+		Subject:     t.Subject,
+		Audience:    t.Audience,
+		Scopes:      t.Scopes,
+		ConnectorID: t.ConnectorID,
+		Claims:      toStorageClaims(t.Claims),
+		CreatedAt:   t.CreatedAt,
+		Expiry:      t.Expiry,
+
+		ClaimsRequest: t.ClaimsRequest,
+		Confirmation:  t.Confirmation,
+	}
+}
+
+// ClientAssertion is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type ClientAssertion struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ClientID string    `json:"clientID"`
+	Expiry   time.Time `json:"expiry"`
+}
+
+// ClientAssertionList is a list of ClientAssertions.
+type ClientAssertionList struct {
+	k8sapi.TypeMeta  `json:",inline"`
+	k8sapi.ListMeta  `json:"metadata,omitempty"`
+	ClientAssertions []ClientAssertion `json:"items"`
+}
+
+func (cli *client) fromStorageClientAssertion(a storage.ClientAssertion) ClientAssertion {
+	return ClientAssertion{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindClientAssertion,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      a.ID,
+			Namespace: cli.namespace,
+		},
+		ClientID: a.ClientID,
+		Expiry:   a.Expiry,
+	}
+}
+
+func toStorageClientAssertion(a ClientAssertion) storage.ClientAssertion {
+	return storage.ClientAssertion{
+		ID:       a.ObjectMeta.Name,
+		ClientID: a.ClientID,
+		Expiry:   a.Expiry,
+	}
+}
+
+// DPoPProof is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type DPoPProof struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	Expiry time.Time `json:"expiry"`
+}
+
+// DPoPProofList is a list of DPoPProofs.
+type DPoPProofList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	DPoPProofs      []DPoPProof `json:"items"`
+}
+
+func (cli *client) fromStorageDPoPProof(p storage.DPoPProof) DPoPProof {
+	return DPoPProof{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindDPoPProof,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      p.ID,
+			Namespace: cli.namespace,
+		},
+		Expiry: p.Expiry,
+	}
+}
+
+func toStorageDPoPProof(p DPoPProof) storage.DPoPProof {
+	return storage.DPoPProof{
+		ID:     p.ObjectMeta.Name,
+		Expiry: p.Expiry,
+	}
+}
@@ -20,7 +20,15 @@ func New(logger log.Logger) storage.Storage {
 		passwords:       make(map[string]storage.Password),
 		offlineSessions: make(map[offlineSessionID]storage.OfflineSessions),
 		connectors:      make(map[string]storage.Connector),
-		logger:          logger,
+		deviceRequests:  make(map[string]storage.DeviceRequest),
+		deviceTokens:    make(map[string]storage.DeviceToken),
+		sessions:        make(map[string]storage.Session),
+		accessTokens:    make(map[string]storage.AccessToken),
+
+		clientAssertions: make(map[string]storage.ClientAssertion),
+		dpopProofs:       make(map[string]storage.DPoPProof),
+
+		logger: logger,
 	}
 }
 
@@ -46,6 +54,13 @@ type memStorage struct {
 	passwords       map[string]storage.Password
 	offlineSessions map[offlineSessionID]storage.OfflineSessions
 	connectors      map[string]storage.Connector
+	deviceRequests  map[string]storage.DeviceRequest
+	deviceTokens    map[string]storage.DeviceToken
+	sessions        map[string]storage.Session
+	accessTokens    map[string]storage.AccessToken
+
+	clientAssertions map[string]storage.ClientAssertion
+	dpopProofs       map[string]storage.DPoPProof
 
 	keys storage.Keys
 
@@ -79,6 +94,62 @@ func (s *memStorage) GarbageCollect(now time.Time) (result storage.GCResult, err
 				result.AuthRequests++
 			}
 		}
+		for id, a := range s.deviceRequests {
+			if now.After(a.Expiry) {
+				delete(s.deviceRequests, id)
+				result.DeviceRequests++
+			}
+		}
+		for id, a := range s.deviceTokens {
+			if now.After(a.Expiry) {
+				delete(s.deviceTokens, id)
+				result.DeviceTokens++
+			}
+		}
+		for id, a := range s.sessions {
+			if now.After(a.Expiry) {
+				delete(s.sessions, id)
+				result.Sessions++
+			}
+		}
+		for id, a := range s.accessTokens {
+			if now.After(a.Expiry) {
+				delete(s.accessTokens, id)
+				result.AccessTokens++
+			}
+		}
+		for id, a := range s.clientAssertions {
+			if now.After(a.Expiry) {
+				delete(s.clientAssertions, id)
+				result.ClientAssertions++
+			}
+		}
+		for id, p := range s.dpopProofs {
+			if now.After(p.Expiry) {
+				delete(s.dpopProofs, id)
+				result.DPoPProofs++
+			}
+		}
+		for id, r := range s.refreshTokens {
+			if r.Expiry.IsZero() || !now.After(r.Expiry) {
+				continue
+			}
+			delete(s.refreshTokens, id)
+			result.RefreshTokens++
+
+			sessionID := offlineSessionID{userID: r.Claims.UserID, connID: r.ConnectorID}
+			o, ok := s.offlineSessions[sessionID]
+			if !ok {
+				continue
+			}
+			if ref, ok := o.Refresh[r.ClientID]; ok && ref.ID == r.ID {
+				delete(o.Refresh, r.ClientID)
+			}
+			if len(o.Refresh) == 0 {
+				delete(s.offlineSessions, sessionID)
+				result.OfflineSessions++
+			}
+		}
 	})
 	return result, nil
 }
@@ -465,3 +536,185 @@ func (s *memStorage) UpdateConnector(id string, updater func(c storage.Connector
 	})
 	return
 }
+
+func (s *memStorage) CreateDeviceRequest(d storage.DeviceRequest) (err error) {
+	s.tx(func() {
+		if _, ok := s.deviceRequests[d.UserCode]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.deviceRequests[d.UserCode] = d
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetDeviceRequest(userCode string) (req storage.DeviceRequest, err error) {
+	s.tx(func() {
+		var ok bool
+		if req, ok = s.deviceRequests[userCode]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreateDeviceToken(t storage.DeviceToken) (err error) {
+	s.tx(func() {
+		if _, ok := s.deviceTokens[t.DeviceCode]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.deviceTokens[t.DeviceCode] = t
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetDeviceToken(deviceCode string) (t storage.DeviceToken, err error) {
+	s.tx(func() {
+		var ok bool
+		if t, ok = s.deviceTokens[deviceCode]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+	})
+	return
+}
+
+func (s *memStorage) UpdateDeviceToken(deviceCode string, updater func(p storage.DeviceToken) (storage.DeviceToken, error)) (err error) {
+	s.tx(func() {
+		r, ok := s.deviceTokens[deviceCode]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if r, err = updater(r); err == nil {
+			s.deviceTokens[deviceCode] = r
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreateSession(session storage.Session) (err error) {
+	s.tx(func() {
+		if _, ok := s.sessions[session.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.sessions[session.ID] = session
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetSession(id string) (session storage.Session, err error) {
+	s.tx(func() {
+		var ok bool
+		if session, ok = s.sessions[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+	})
+	return
+}
+
+func (s *memStorage) DeleteSession(id string) (err error) {
+	s.tx(func() {
+		if _, ok := s.sessions[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.sessions, id)
+	})
+	return
+}
+
+func (s *memStorage) UpdateSession(id string, updater func(old storage.Session) (storage.Session, error)) (err error) {
+	s.tx(func() {
+		r, ok := s.sessions[id]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if r, err = updater(r); err == nil {
+			s.sessions[id] = r
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreateAccessToken(t storage.AccessToken) (err error) {
+	s.tx(func() {
+		if _, ok := s.accessTokens[t.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.accessTokens[t.ID] = t
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetAccessToken(id string) (t storage.AccessToken, err error) {
+	s.tx(func() {
+		var ok bool
+		if t, ok = s.accessTokens[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+	})
+	return
+}
+
+func (s *memStorage) DeleteAccessToken(id string) (err error) {
+	s.tx(func() {
+		if _, ok := s.accessTokens[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.accessTokens, id)
+	})
+	return
+}
+
+func (s *memStorage) CreateClientAssertion(a storage.ClientAssertion) (err error) {
+	s.tx(func() {
+		if _, ok := s.clientAssertions[a.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.clientAssertions[a.ID] = a
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetClientAssertion(id string) (a storage.ClientAssertion, err error) {
+	s.tx(func() {
+		var ok bool
+		if a, ok = s.clientAssertions[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreateDPoPProof(p storage.DPoPProof) (err error) {
+	s.tx(func() {
+		if _, ok := s.dpopProofs[p.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.dpopProofs[p.ID] = p
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetDPoPProof(id string) (p storage.DPoPProof, err error) {
+	s.tx(func() {
+		var ok bool
+		if p, ok = s.dpopProofs[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+	})
+	return
+}
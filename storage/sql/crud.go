@@ -74,6 +74,31 @@ func (j jsonDecoder) Scan(dest interface{}) error {
 	return nil
 }
 
+// nullableDecoder is like decoder but leaves the value untouched for NULL columns,
+// such as columns added to an existing table by a migration.
+func nullableDecoder(i interface{}) sql.Scanner {
+	return nullableJSONDecoder{i}
+}
+
+type nullableJSONDecoder struct {
+	i interface{}
+}
+
+func (j nullableJSONDecoder) Scan(dest interface{}) error {
+	if dest == nil {
+		return nil
+	}
+	return jsonDecoder{j.i}.Scan(dest)
+}
+
+// nullableTime stores zero times as NULL, for optional timestamp columns.
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
 // Abstract conn vs trans.
 type querier interface {
 	QueryRow(query string, args ...interface{}) *sql.Row
@@ -100,9 +125,127 @@ func (c *conn) GarbageCollect(now time.Time) (result storage.GCResult, err error
 	if n, err := r.RowsAffected(); err == nil {
 		result.AuthCodes = n
 	}
+
+	r, err = c.Exec(`delete from device_request where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc device_request: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.DeviceRequests = n
+	}
+
+	r, err = c.Exec(`delete from device_token where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc device_token: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.DeviceTokens = n
+	}
+
+	r, err = c.Exec(`delete from session where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc session: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.Sessions = n
+	}
+
+	r, err = c.Exec(`delete from access_token where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc access_token: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.AccessTokens = n
+	}
+
+	r, err = c.Exec(`delete from client_assertion where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc client_assertion: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.ClientAssertions = n
+	}
+
+	r, err = c.Exec(`delete from dpop_proof where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc dpop_proof: %v", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.DPoPProofs = n
+	}
+
+	// Refresh tokens are referenced by offline sessions, so they're collected
+	// one by one to keep both consistent.
+	rows, err := c.Query(`
+		select id, client_id, claims_user_id, connector_id
+		from refresh_token where expiry < $1;
+	`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc refresh_token: %v", err)
+	}
+	var expired []storage.RefreshToken
+	for rows.Next() {
+		var r storage.RefreshToken
+		if err := rows.Scan(&r.ID, &r.ClientID, &r.Claims.UserID, &r.ConnectorID); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("gc refresh_token: scan: %v", err)
+		}
+		expired = append(expired, r)
+	}
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("gc refresh_token: scan: %v", err)
+	}
+	for _, r := range expired {
+		sessionDeleted, err := c.gcRefreshToken(r)
+		if err != nil {
+			return result, fmt.Errorf("gc refresh_token: %v", err)
+		}
+		result.RefreshTokens++
+		if sessionDeleted {
+			result.OfflineSessions++
+		}
+	}
 	return
 }
 
+// gcRefreshToken deletes an expired refresh token and its reference in the offline
+// session of the user. Offline sessions left without any refresh token are deleted.
+func (c *conn) gcRefreshToken(r storage.RefreshToken) (sessionDeleted bool, err error) {
+	err = c.ExecTx(func(tx *trans) error {
+		if _, err := tx.Exec(`delete from refresh_token where id = $1`, r.ID); err != nil {
+			return fmt.Errorf("delete refresh_token: %v", err)
+		}
+
+		s, err := getOfflineSessions(tx, r.Claims.UserID, r.ConnectorID)
+		if err != nil {
+			if err == storage.ErrNotFound {
+				return nil
+			}
+			return err
+		}
+		if ref, ok := s.Refresh[r.ClientID]; ok && ref.ID == r.ID {
+			delete(s.Refresh, r.ClientID)
+		}
+		if len(s.Refresh) > 0 {
+			_, err = tx.Exec(`
+				update offline_session set refresh = $1
+				where user_id = $2 AND conn_id = $3;
+			`, encoder(s.Refresh), s.UserID, s.ConnID)
+			if err != nil {
+				return fmt.Errorf("update offline session: %v", err)
+			}
+			return nil
+		}
+		_, err = tx.Exec(`delete from offline_session where user_id = $1 AND conn_id = $2`, s.UserID, s.ConnID)
+		if err != nil {
+			return fmt.Errorf("delete offline session: %v", err)
+		}
+		sessionDeleted = true
+		return nil
+	})
+	return sessionDeleted, err
+}
+
 func (c *conn) CreateAuthRequest(a storage.AuthRequest) error {
 	_, err := c.Exec(`
 		insert into auth_request (
@@ -111,10 +254,14 @@ func (c *conn) CreateAuthRequest(a storage.AuthRequest) error {
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
 			connector_id, connector_data,
-			expiry
+			expiry,
+			code_challenge, code_challenge_method,
+			claims_custom, claims_request,
+			prompt, max_age, auth_time
 		)
 		values (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22,
+			$23, $24, $25
 		);
 	`,
 		a.ID, a.ClientID, encoder(a.ResponseTypes), encoder(a.Scopes), a.RedirectURI, a.Nonce, a.State,
@@ -123,6 +270,9 @@ func (c *conn) CreateAuthRequest(a storage.AuthRequest) error {
 		a.Claims.Email, a.Claims.EmailVerified, encoder(a.Claims.Groups),
 		a.ConnectorID, a.ConnectorData,
 		a.Expiry,
+		a.PKCE.CodeChallenge, a.PKCE.CodeChallengeMethod,
+		encoder(a.Claims.CustomClaims), a.ClaimsRequest,
+		a.Prompt, a.MaxAge, a.AuthTime,
 	)
 	if err != nil {
 		if c.alreadyExistsCheck(err) {
@@ -153,8 +303,11 @@ func (c *conn) UpdateAuthRequest(id string, updater func(a storage.AuthRequest)
 				claims_email = $12, claims_email_verified = $13,
 				claims_groups = $14,
 				connector_id = $15, connector_data = $16,
-				expiry = $17
-			where id = $18;
+				expiry = $17,
+				code_challenge = $18, code_challenge_method = $19,
+				claims_custom = $20, claims_request = $21,
+				prompt = $22, max_age = $23, auth_time = $24
+			where id = $25;
 		`,
 			a.ClientID, encoder(a.ResponseTypes), encoder(a.Scopes), a.RedirectURI, a.Nonce, a.State,
 			a.ForceApprovalPrompt, a.LoggedIn,
@@ -162,7 +315,11 @@ func (c *conn) UpdateAuthRequest(id string, updater func(a storage.AuthRequest)
 			a.Claims.Email, a.Claims.EmailVerified,
 			encoder(a.Claims.Groups),
 			a.ConnectorID, a.ConnectorData,
-			a.Expiry, r.ID,
+			a.Expiry,
+			a.PKCE.CodeChallenge, a.PKCE.CodeChallengeMethod,
+			encoder(a.Claims.CustomClaims), a.ClaimsRequest,
+			a.Prompt, a.MaxAge, a.AuthTime,
+			r.ID,
 		)
 		if err != nil {
 			return fmt.Errorf("update auth request: %v", err)
@@ -182,7 +339,10 @@ func getAuthRequest(q querier, id string) (a storage.AuthRequest, err error) {
 			force_approval_prompt, logged_in,
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
-			connector_id, connector_data, expiry
+			connector_id, connector_data, expiry,
+			code_challenge, code_challenge_method,
+			claims_custom, claims_request,
+			prompt, max_age, auth_time
 		from auth_request where id = $1;
 	`, id).Scan(
 		&a.ID, &a.ClientID, decoder(&a.ResponseTypes), decoder(&a.Scopes), &a.RedirectURI, &a.Nonce, &a.State,
@@ -191,6 +351,9 @@ func getAuthRequest(q querier, id string) (a storage.AuthRequest, err error) {
 		&a.Claims.Email, &a.Claims.EmailVerified,
 		decoder(&a.Claims.Groups),
 		&a.ConnectorID, &a.ConnectorData, &a.Expiry,
+		&a.PKCE.CodeChallenge, &a.PKCE.CodeChallengeMethod,
+		nullableDecoder(&a.Claims.CustomClaims), &a.ClaimsRequest,
+		&a.Prompt, &a.MaxAge, &a.AuthTime,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -208,13 +371,17 @@ func (c *conn) CreateAuthCode(a storage.AuthCode) error {
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
 			connector_id, connector_data,
-			expiry
+			expiry,
+			code_challenge, code_challenge_method,
+			claims_custom, claims_request, auth_time
 		)
-		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14);
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19);
 	`,
 		a.ID, a.ClientID, encoder(a.Scopes), a.Nonce, a.RedirectURI, a.Claims.UserID,
 		a.Claims.Username, a.Claims.PreferredUsername, a.Claims.Email, a.Claims.EmailVerified,
 		encoder(a.Claims.Groups), a.ConnectorID, a.ConnectorData, a.Expiry,
+		a.PKCE.CodeChallenge, a.PKCE.CodeChallengeMethod,
+		encoder(a.Claims.CustomClaims), a.ClaimsRequest, a.AuthTime,
 	)
 
 	if err != nil {
@@ -233,12 +400,16 @@ func (c *conn) GetAuthCode(id string) (a storage.AuthCode, err error) {
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
 			connector_id, connector_data,
-			expiry
+			expiry,
+			code_challenge, code_challenge_method,
+			claims_custom, claims_request, auth_time
 		from auth_code where id = $1;
 	`, id).Scan(
 		&a.ID, &a.ClientID, decoder(&a.Scopes), &a.Nonce, &a.RedirectURI, &a.Claims.UserID,
 		&a.Claims.Username, &a.Claims.PreferredUsername, &a.Claims.Email, &a.Claims.EmailVerified,
 		decoder(&a.Claims.Groups), &a.ConnectorID, &a.ConnectorData, &a.Expiry,
+		&a.PKCE.CodeChallenge, &a.PKCE.CodeChallengeMethod,
+		nullableDecoder(&a.Claims.CustomClaims), &a.ClaimsRequest, &a.AuthTime,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -256,16 +427,18 @@ func (c *conn) CreateRefresh(r storage.RefreshToken) error {
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
 			connector_id, connector_data,
-			token, created_at, last_used
+			token, obsolete_token, created_at, last_used, expiry,
+			claims_custom, claims_request, confirmation, rotated_tokens
 		)
-		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15);
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21);
 	`,
 		r.ID, r.ClientID, encoder(r.Scopes), r.Nonce,
 		r.Claims.UserID, r.Claims.Username, r.Claims.PreferredUsername,
 		r.Claims.Email, r.Claims.EmailVerified,
 		encoder(r.Claims.Groups),
 		r.ConnectorID, r.ConnectorData,
-		r.Token, r.CreatedAt, r.LastUsed,
+		r.Token, r.ObsoleteToken, r.CreatedAt, r.LastUsed, nullableTime(r.Expiry),
+		encoder(r.Claims.CustomClaims), r.ClaimsRequest, encoder(r.Confirmation), encoder(r.RotatedTokens),
 	)
 	if err != nil {
 		if c.alreadyExistsCheck(err) {
@@ -300,17 +473,24 @@ func (c *conn) UpdateRefreshToken(id string, updater func(old storage.RefreshTok
 				connector_id = $10,
 								connector_data = $11,
 				token = $12,
-				created_at = $13,
-				last_used = $14
+				obsolete_token = $13,
+				created_at = $14,
+				last_used = $15,
+				expiry = $16,
+				claims_custom = $17,
+				claims_request = $18,
+				confirmation = $19,
+				rotated_tokens = $20
 			where
-				id = $15
+				id = $21
 		`,
 			r.ClientID, encoder(r.Scopes), r.Nonce,
 			r.Claims.UserID, r.Claims.Username, r.Claims.PreferredUsername,
 			r.Claims.Email, r.Claims.EmailVerified,
 			encoder(r.Claims.Groups),
 			r.ConnectorID, r.ConnectorData,
-			r.Token, r.CreatedAt, r.LastUsed, id,
+			r.Token, r.ObsoleteToken, r.CreatedAt, r.LastUsed, nullableTime(r.Expiry),
+			encoder(r.Claims.CustomClaims), r.ClaimsRequest, encoder(r.Confirmation), encoder(r.RotatedTokens), id,
 		)
 		if err != nil {
 			return fmt.Errorf("update refresh token: %v", err)
@@ -331,7 +511,8 @@ func getRefresh(q querier, id string) (storage.RefreshToken, error) {
 			claims_email, claims_email_verified,
 			claims_groups,
 			connector_id, connector_data,
-			token, created_at, last_used
+			token, obsolete_token, created_at, last_used, expiry,
+			claims_custom, claims_request, confirmation, rotated_tokens
 		from refresh_token where id = $1;
 	`, id))
 }
@@ -343,7 +524,8 @@ func (c *conn) ListRefreshTokens() ([]storage.RefreshToken, error) {
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
 			connector_id, connector_data,
-			token, created_at, last_used
+			token, obsolete_token, created_at, last_used, expiry,
+			claims_custom, claims_request, confirmation, rotated_tokens
 		from refresh_token;
 	`)
 	if err != nil {
@@ -364,13 +546,16 @@ func (c *conn) ListRefreshTokens() ([]storage.RefreshToken, error) {
 }
 
 func scanRefresh(s scanner) (r storage.RefreshToken, err error) {
+	var expiry sql.NullTime
 	err = s.Scan(
 		&r.ID, &r.ClientID, decoder(&r.Scopes), &r.Nonce,
 		&r.Claims.UserID, &r.Claims.Username, &r.Claims.PreferredUsername,
 		&r.Claims.Email, &r.Claims.EmailVerified,
 		decoder(&r.Claims.Groups),
 		&r.ConnectorID, &r.ConnectorData,
-		&r.Token, &r.CreatedAt, &r.LastUsed,
+		&r.Token, &r.ObsoleteToken, &r.CreatedAt, &r.LastUsed, &expiry,
+		nullableDecoder(&r.Claims.CustomClaims), &r.ClaimsRequest, nullableDecoder(&r.Confirmation),
+		nullableDecoder(&r.RotatedTokens),
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -378,6 +563,9 @@ func scanRefresh(s scanner) (r storage.RefreshToken, err error) {
 		}
 		return r, fmt.Errorf("scan refresh_token: %v", err)
 	}
+	if expiry.Valid {
+		r.Expiry = expiry.Time
+	}
 	return r, nil
 }
 
@@ -476,9 +664,37 @@ func (c *conn) UpdateClient(id string, updater func(old storage.Client) (storage
 				trusted_peers = $3,
 				public = $4,
 				name = $5,
-				logo_url = $6
-			where id = $7;
-		`, nc.Secret, encoder(nc.RedirectURIs), encoder(nc.TrustedPeers), nc.Public, nc.Name, nc.LogoURL, id,
+				logo_url = $6,
+				post_logout_redirect_uris = $7,
+				allowed_scopes = $8,
+				allowed_audiences = $9,
+				registration_access_token = $10,
+				refresh_token_valid_for = $11,
+				refresh_token_idle_timeout = $12,
+				jwks = $13,
+				jwks_uri = $14,
+				require_pushed_auth_requests = $15,
+				token_endpoint_auth_methods = $16,
+				secrets = $17,
+				allowed_grant_types = $18,
+				allowed_response_types = $19,
+				allowed_connectors = $20,
+				id_token_valid_for = $21,
+				access_token_valid_for = $22,
+				require_dpop = $23,
+				tls_client_auth_subject_dn = $24,
+				tls_client_cert_thumbprints = $25,
+				request_uris = $26,
+				client_credentials_scopes = $27
+			where id = $28;
+		`, nc.Secret, encoder(nc.RedirectURIs), encoder(nc.TrustedPeers), nc.Public, nc.Name, nc.LogoURL,
+			encoder(nc.PostLogoutRedirectURIs), encoder(nc.AllowedScopes), encoder(nc.AllowedAudiences),
+			nc.RegistrationAccessToken, nc.RefreshTokenValidFor, nc.RefreshTokenIdleTimeout,
+			nc.JWKS, nc.JWKSURI, nc.RequirePushedAuthRequests, encoder(nc.TokenEndpointAuthMethods),
+			encoder(nc.Secrets), encoder(nc.AllowedGrantTypes), encoder(nc.AllowedResponseTypes),
+			encoder(nc.AllowedConnectors), nc.IDTokenValidFor, nc.AccessTokenValidFor, nc.RequireDPoP,
+			nc.TLSClientAuthSubjectDN, encoder(nc.TLSClientCertThumbprints), encoder(nc.RequestURIs),
+			encoder(nc.ClientCredentialsScopes), id,
 		)
 		if err != nil {
 			return fmt.Errorf("update client: %v", err)
@@ -490,12 +706,26 @@ func (c *conn) UpdateClient(id string, updater func(old storage.Client) (storage
 func (c *conn) CreateClient(cli storage.Client) error {
 	_, err := c.Exec(`
 		insert into client (
-			id, secret, redirect_uris, trusted_peers, public, name, logo_url
+			id, secret, redirect_uris, trusted_peers, public, name, logo_url,
+			post_logout_redirect_uris, allowed_scopes, allowed_audiences,
+			registration_access_token, refresh_token_valid_for, refresh_token_idle_timeout,
+			jwks, jwks_uri, require_pushed_auth_requests, token_endpoint_auth_methods,
+			secrets, allowed_grant_types, allowed_response_types, allowed_connectors,
+			id_token_valid_for, access_token_valid_for, require_dpop,
+			tls_client_auth_subject_dn, tls_client_cert_thumbprints, request_uris,
+			client_credentials_scopes
 		)
-		values ($1, $2, $3, $4, $5, $6, $7);
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28);
 	`,
 		cli.ID, cli.Secret, encoder(cli.RedirectURIs), encoder(cli.TrustedPeers),
-		cli.Public, cli.Name, cli.LogoURL,
+		cli.Public, cli.Name, cli.LogoURL, encoder(cli.PostLogoutRedirectURIs),
+		encoder(cli.AllowedScopes), encoder(cli.AllowedAudiences),
+		cli.RegistrationAccessToken, cli.RefreshTokenValidFor, cli.RefreshTokenIdleTimeout,
+		cli.JWKS, cli.JWKSURI, cli.RequirePushedAuthRequests, encoder(cli.TokenEndpointAuthMethods),
+		encoder(cli.Secrets), encoder(cli.AllowedGrantTypes), encoder(cli.AllowedResponseTypes),
+		encoder(cli.AllowedConnectors), cli.IDTokenValidFor, cli.AccessTokenValidFor, cli.RequireDPoP,
+		cli.TLSClientAuthSubjectDN, encoder(cli.TLSClientCertThumbprints), encoder(cli.RequestURIs),
+		encoder(cli.ClientCredentialsScopes),
 	)
 	if err != nil {
 		if c.alreadyExistsCheck(err) {
@@ -509,7 +739,14 @@ func (c *conn) CreateClient(cli storage.Client) error {
 func getClient(q querier, id string) (storage.Client, error) {
 	return scanClient(q.QueryRow(`
 		select
-			id, secret, redirect_uris, trusted_peers, public, name, logo_url
+			id, secret, redirect_uris, trusted_peers, public, name, logo_url,
+			post_logout_redirect_uris, allowed_scopes, allowed_audiences,
+			registration_access_token, refresh_token_valid_for, refresh_token_idle_timeout,
+			jwks, jwks_uri, require_pushed_auth_requests, token_endpoint_auth_methods,
+			secrets, allowed_grant_types, allowed_response_types, allowed_connectors,
+			id_token_valid_for, access_token_valid_for, require_dpop,
+			tls_client_auth_subject_dn, tls_client_cert_thumbprints, request_uris,
+			client_credentials_scopes
 	    from client where id = $1;
 	`, id))
 }
@@ -521,7 +758,14 @@ func (c *conn) GetClient(id string) (storage.Client, error) {
 func (c *conn) ListClients() ([]storage.Client, error) {
 	rows, err := c.Query(`
 		select
-			id, secret, redirect_uris, trusted_peers, public, name, logo_url
+			id, secret, redirect_uris, trusted_peers, public, name, logo_url,
+			post_logout_redirect_uris, allowed_scopes, allowed_audiences,
+			registration_access_token, refresh_token_valid_for, refresh_token_idle_timeout,
+			jwks, jwks_uri, require_pushed_auth_requests, token_endpoint_auth_methods,
+			secrets, allowed_grant_types, allowed_response_types, allowed_connectors,
+			id_token_valid_for, access_token_valid_for, require_dpop,
+			tls_client_auth_subject_dn, tls_client_cert_thumbprints, request_uris,
+			client_credentials_scopes
 		from client;
 	`)
 	if err != nil {
@@ -544,7 +788,15 @@ func (c *conn) ListClients() ([]storage.Client, error) {
 func scanClient(s scanner) (cli storage.Client, err error) {
 	err = s.Scan(
 		&cli.ID, &cli.Secret, decoder(&cli.RedirectURIs), decoder(&cli.TrustedPeers),
-		&cli.Public, &cli.Name, &cli.LogoURL,
+		&cli.Public, &cli.Name, &cli.LogoURL, nullableDecoder(&cli.PostLogoutRedirectURIs),
+		nullableDecoder(&cli.AllowedScopes), nullableDecoder(&cli.AllowedAudiences),
+		&cli.RegistrationAccessToken, &cli.RefreshTokenValidFor, &cli.RefreshTokenIdleTimeout,
+		&cli.JWKS, &cli.JWKSURI, &cli.RequirePushedAuthRequests, nullableDecoder(&cli.TokenEndpointAuthMethods),
+		nullableDecoder(&cli.Secrets), nullableDecoder(&cli.AllowedGrantTypes),
+		nullableDecoder(&cli.AllowedResponseTypes), nullableDecoder(&cli.AllowedConnectors),
+		&cli.IDTokenValidFor, &cli.AccessTokenValidFor, &cli.RequireDPoP,
+		&cli.TLSClientAuthSubjectDN, nullableDecoder(&cli.TLSClientCertThumbprints),
+		nullableDecoder(&cli.RequestURIs), nullableDecoder(&cli.ClientCredentialsScopes),
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -830,6 +1082,10 @@ func (c *conn) DeletePassword(email string) error {
 	return c.delete("password", "email", strings.ToLower(email))
 }
 func (c *conn) DeleteConnector(id string) error { return c.delete("connector", "id", id) }
+func (c *conn) DeleteSession(id string) error   { return c.delete("session", "id", id) }
+func (c *conn) DeleteAccessToken(id string) error {
+	return c.delete("access_token", "id", id)
+}
 
 func (c *conn) DeleteOfflineSessions(userID string, connID string) error {
 	result, err := c.Exec(`delete from offline_session where user_id = $1 AND conn_id = $2`, userID, connID)
@@ -867,3 +1123,319 @@ func (c *conn) delete(table, field, id string) error {
 	}
 	return nil
 }
+
+func (c *conn) CreateDeviceRequest(d storage.DeviceRequest) error {
+	_, err := c.Exec(`
+		insert into device_request (
+			user_code, device_code, client_id, scopes, expiry
+		)
+		values (
+			$1, $2, $3, $4, $5
+		);`,
+		d.UserCode, d.DeviceCode, d.ClientID, encoder(d.Scopes), d.Expiry,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert device request: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) GetDeviceRequest(userCode string) (storage.DeviceRequest, error) {
+	return getDeviceRequest(c, userCode)
+}
+
+func getDeviceRequest(q querier, userCode string) (d storage.DeviceRequest, err error) {
+	err = q.QueryRow(`
+		select
+			user_code, device_code, client_id, scopes, expiry
+		from device_request where user_code = $1;
+	`, userCode).Scan(
+		&d.UserCode, &d.DeviceCode, &d.ClientID, decoder(&d.Scopes), &d.Expiry,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return d, storage.ErrNotFound
+		}
+		return d, fmt.Errorf("select device request: %v", err)
+	}
+	return d, nil
+}
+
+func (c *conn) CreateDeviceToken(t storage.DeviceToken) error {
+	_, err := c.Exec(`
+		insert into device_token (
+			device_code, status, token, expiry, last_request, poll_interval, client_id
+		)
+		values (
+			$1, $2, $3, $4, $5, $6, $7
+		);`,
+		t.DeviceCode, t.Status, []byte(t.Token), t.Expiry, t.LastRequestTime, t.PollIntervalSeconds, t.ClientID,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert device token: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) GetDeviceToken(deviceCode string) (storage.DeviceToken, error) {
+	return getDeviceToken(c, deviceCode)
+}
+
+func getDeviceToken(q querier, deviceCode string) (t storage.DeviceToken, err error) {
+	var token []byte
+	err = q.QueryRow(`
+		select
+			device_code, status, token, expiry, last_request, poll_interval, client_id
+		from device_token where device_code = $1;
+	`, deviceCode).Scan(
+		&t.DeviceCode, &t.Status, &token, &t.Expiry, &t.LastRequestTime, &t.PollIntervalSeconds, &t.ClientID,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return t, storage.ErrNotFound
+		}
+		return t, fmt.Errorf("select device token: %v", err)
+	}
+	t.Token = string(token)
+	return t, nil
+}
+
+func (c *conn) UpdateDeviceToken(deviceCode string, updater func(old storage.DeviceToken) (storage.DeviceToken, error)) error {
+	return c.ExecTx(func(tx *trans) error {
+		r, err := getDeviceToken(tx, deviceCode)
+		if err != nil {
+			return err
+		}
+		if r, err = updater(r); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			update device_token
+			set
+				status = $1,
+				token = $2,
+				last_request = $3,
+				poll_interval = $4
+			where
+				device_code = $5
+		`,
+			r.Status, []byte(r.Token), r.LastRequestTime, r.PollIntervalSeconds, r.DeviceCode,
+		)
+		if err != nil {
+			return fmt.Errorf("update device token: %v", err)
+		}
+		return nil
+	})
+}
+
+func (c *conn) CreateSession(s storage.Session) error {
+	_, err := c.Exec(`
+		insert into session (
+			id, connector_id, connector_data,
+			claims_user_id, claims_username, claims_preferred_username,
+			claims_email, claims_email_verified, claims_groups,
+			scopes, created_at, last_used, expiry,
+			claims_custom
+		)
+		values (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+		);`,
+		s.ID, s.ConnectorID, s.ConnectorData,
+		s.Claims.UserID, s.Claims.Username, s.Claims.PreferredUsername,
+		s.Claims.Email, s.Claims.EmailVerified, encoder(s.Claims.Groups),
+		encoder(s.Scopes), s.CreatedAt, s.LastUsed, s.Expiry,
+		encoder(s.Claims.CustomClaims),
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert session: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) GetSession(id string) (storage.Session, error) {
+	return getSession(c, id)
+}
+
+func getSession(q querier, id string) (s storage.Session, err error) {
+	err = q.QueryRow(`
+		select
+			id, connector_id, connector_data,
+			claims_user_id, claims_username, claims_preferred_username,
+			claims_email, claims_email_verified, claims_groups,
+			scopes, created_at, last_used, expiry,
+			claims_custom
+		from session where id = $1;
+	`, id).Scan(
+		&s.ID, &s.ConnectorID, &s.ConnectorData,
+		&s.Claims.UserID, &s.Claims.Username, &s.Claims.PreferredUsername,
+		&s.Claims.Email, &s.Claims.EmailVerified, decoder(&s.Claims.Groups),
+		decoder(&s.Scopes), &s.CreatedAt, &s.LastUsed, &s.Expiry,
+		nullableDecoder(&s.Claims.CustomClaims),
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return s, storage.ErrNotFound
+		}
+		return s, fmt.Errorf("select session: %v", err)
+	}
+	return s, nil
+}
+
+func (c *conn) UpdateSession(id string, updater func(old storage.Session) (storage.Session, error)) error {
+	return c.ExecTx(func(tx *trans) error {
+		s, err := getSession(tx, id)
+		if err != nil {
+			return err
+		}
+		if s, err = updater(s); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			update session
+			set
+				connector_id = $1,
+				connector_data = $2,
+				claims_user_id = $3,
+				claims_username = $4,
+				claims_preferred_username = $5,
+				claims_email = $6,
+				claims_email_verified = $7,
+				claims_groups = $8,
+				scopes = $9,
+				last_used = $10,
+				expiry = $11,
+				claims_custom = $12
+			where
+				id = $13
+		`,
+			s.ConnectorID, s.ConnectorData,
+			s.Claims.UserID, s.Claims.Username, s.Claims.PreferredUsername,
+			s.Claims.Email, s.Claims.EmailVerified, encoder(s.Claims.Groups),
+			encoder(s.Scopes), s.LastUsed, s.Expiry,
+			encoder(s.Claims.CustomClaims), id,
+		)
+		if err != nil {
+			return fmt.Errorf("update session: %v", err)
+		}
+		return nil
+	})
+}
+
+func (c *conn) CreateAccessToken(t storage.AccessToken) error {
+	_, err := c.Exec(`
+		insert into access_token (
+			id, client_id, subject, audience, scopes, connector_id,
+			claims_user_id, claims_username, claims_preferred_username,
+			claims_email, claims_email_verified, claims_groups,
+			created_at, expiry,
+			claims_custom, claims_request, confirmation
+		)
+		values (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+		);`,
+		t.ID, t.ClientID, t.Subject, encoder(t.Audience), encoder(t.Scopes), t.ConnectorID,
+		t.Claims.UserID, t.Claims.Username, t.Claims.PreferredUsername,
+		t.Claims.Email, t.Claims.EmailVerified, encoder(t.Claims.Groups),
+		t.CreatedAt, t.Expiry,
+		encoder(t.Claims.CustomClaims), t.ClaimsRequest, encoder(t.Confirmation),
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert access token: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) GetAccessToken(id string) (t storage.AccessToken, err error) {
+	err = c.QueryRow(`
+		select
+			id, client_id, subject, audience, scopes, connector_id,
+			claims_user_id, claims_username, claims_preferred_username,
+			claims_email, claims_email_verified, claims_groups,
+			created_at, expiry,
+			claims_custom, claims_request, confirmation
+		from access_token where id = $1;
+	`, id).Scan(
+		&t.ID, &t.ClientID, &t.Subject, decoder(&t.Audience), decoder(&t.Scopes), &t.ConnectorID,
+		&t.Claims.UserID, &t.Claims.Username, &t.Claims.PreferredUsername,
+		&t.Claims.Email, &t.Claims.EmailVerified, decoder(&t.Claims.Groups),
+		&t.CreatedAt, &t.Expiry,
+		nullableDecoder(&t.Claims.CustomClaims), &t.ClaimsRequest, nullableDecoder(&t.Confirmation),
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return t, storage.ErrNotFound
+		}
+		return t, fmt.Errorf("select access token: %v", err)
+	}
+	return t, nil
+}
+
+func (c *conn) CreateClientAssertion(a storage.ClientAssertion) error {
+	_, err := c.Exec(`
+		insert into client_assertion (id, client_id, expiry)
+		values ($1, $2, $3);`,
+		a.ID, a.ClientID, a.Expiry,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert client assertion: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) GetClientAssertion(id string) (a storage.ClientAssertion, err error) {
+	err = c.QueryRow(`
+		select id, client_id, expiry
+		from client_assertion where id = $1;
+	`, id).Scan(&a.ID, &a.ClientID, &a.Expiry)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return a, storage.ErrNotFound
+		}
+		return a, fmt.Errorf("select client assertion: %v", err)
+	}
+	return a, nil
+}
+
+func (c *conn) CreateDPoPProof(p storage.DPoPProof) error {
+	_, err := c.Exec(`
+		insert into dpop_proof (id, expiry)
+		values ($1, $2);`,
+		p.ID, p.Expiry,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert dpop proof: %v", err)
+	}
+	return nil
+}
+
+func (c *conn) GetDPoPProof(id string) (p storage.DPoPProof, err error) {
+	err = c.QueryRow(`
+		select id, expiry
+		from dpop_proof where id = $1;
+	`, id).Scan(&p.ID, &p.Expiry)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return p, storage.ErrNotFound
+		}
+		return p, fmt.Errorf("select dpop proof: %v", err)
+	}
+	return p, nil
+}
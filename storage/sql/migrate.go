@@ -3,6 +3,8 @@ package sql
 import (
 	"database/sql"
 	"fmt"
+
+	"github.com/dexidp/dex/storage"
 )
 
 func (c *conn) migrate() (int, error) {
@@ -51,6 +53,11 @@ func (c *conn) migrate() (int, error) {
 					return fmt.Errorf("migration %d statement %d failed: %v", migrationNum, i+1, err)
 				}
 			}
+			if m.fn != nil {
+				if err := m.fn(tx); err != nil {
+					return fmt.Errorf("migration %d failed: %v", migrationNum, err)
+				}
+			}
 
 			q := `insert into migrations (num, at) values ($1, now());`
 			if _, err := tx.Exec(q, migrationNum); err != nil {
@@ -77,6 +84,10 @@ type migration struct {
 	// If specified, only for that corresponding flavor, in that case stmts can be written
 	// in the specific SQL dialect.
 	flavor *flavor
+
+	// If set, fn runs after stmts within the same transaction, for migrations
+	// which can't be expressed in SQL.
+	fn func(tx *trans) error
 }
 
 // All SQL flavors share migration strategies.
@@ -229,4 +240,335 @@ var migrations = []migration{
 		},
 		flavor: &flavorMySQL,
 	},
+	{
+		stmts: []string{`
+			alter table auth_request
+				add column code_challenge text not null default '';`,
+			`
+			alter table auth_request
+				add column code_challenge_method text not null default '';`,
+			`
+			alter table auth_code
+				add column code_challenge text not null default '';`,
+			`
+			alter table auth_code
+				add column code_challenge_method text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table device_request (
+				user_code text not null primary key,
+				device_code text not null,
+				client_id text not null,
+				scopes bytea not null, -- JSON array of strings
+				expiry timestamptz not null
+			);`,
+			`
+			create table device_token (
+				device_code text not null primary key,
+				status text not null,
+				token bytea,
+				expiry timestamptz not null,
+				last_request timestamptz not null,
+				poll_interval integer not null
+			);`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column post_logout_redirect_uris bytea;`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table session (
+				id text not null primary key,
+				connector_id text not null,
+				connector_data bytea,
+				claims_user_id text not null,
+				claims_username text not null,
+				claims_preferred_username text not null,
+				claims_email text not null,
+				claims_email_verified boolean not null,
+				claims_groups bytea not null, -- JSON array of strings
+				scopes bytea not null,        -- JSON array of strings
+				created_at timestamptz not null,
+				last_used timestamptz not null,
+				expiry timestamptz not null
+			);`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column allowed_scopes bytea;`,
+			`
+			alter table client
+				add column allowed_audiences bytea;`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column registration_access_token text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table access_token (
+				id text not null primary key,
+				client_id text not null,
+				subject text not null,
+				audience bytea not null,      -- JSON array of strings
+				scopes bytea not null,        -- JSON array of strings
+				connector_id text not null,
+				claims_user_id text not null,
+				claims_username text not null,
+				claims_preferred_username text not null,
+				claims_email text not null,
+				claims_email_verified boolean not null,
+				claims_groups bytea not null, -- JSON array of strings
+				created_at timestamptz not null,
+				expiry timestamptz not null
+			);`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table refresh_token
+				add column expiry timestamptz;`,
+			`
+			alter table client
+				add column refresh_token_valid_for text not null default '';`,
+			`
+			alter table client
+				add column refresh_token_idle_timeout text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table refresh_token
+				add column obsolete_token text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table auth_request
+				add column claims_custom bytea;`,
+			`
+			alter table auth_code
+				add column claims_custom bytea;`,
+			`
+			alter table refresh_token
+				add column claims_custom bytea;`,
+			`
+			alter table session
+				add column claims_custom bytea;`,
+			`
+			alter table access_token
+				add column claims_custom bytea;`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table auth_request
+				add column claims_request text not null default '';`,
+			`
+			alter table auth_code
+				add column claims_request text not null default '';`,
+			`
+			alter table refresh_token
+				add column claims_request text not null default '';`,
+			`
+			alter table access_token
+				add column claims_request text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column jwks text not null default '';`,
+			`
+			alter table client
+				add column jwks_uri text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column require_pushed_auth_requests boolean not null default false;`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table client_assertion (
+				id text not null primary key,
+				client_id text not null,
+				expiry timestamptz not null
+			);`,
+			`
+			alter table client
+				add column token_endpoint_auth_methods bytea;`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column secrets bytea;`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column allowed_grant_types bytea;`,
+			`
+			alter table client
+				add column allowed_response_types bytea;`,
+			`
+			alter table client
+				add column allowed_connectors bytea;`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column id_token_valid_for text not null default '';`,
+			`
+			alter table client
+				add column access_token_valid_for text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table dpop_proof (
+				id text not null primary key,
+				expiry timestamptz not null
+			);`,
+			`
+			alter table access_token
+				add column confirmation bytea;`,
+			`
+			alter table refresh_token
+				add column confirmation bytea;`,
+			`
+			alter table client
+				add column require_dpop boolean not null default false;`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column tls_client_auth_subject_dn text not null default '';`,
+			`
+			alter table client
+				add column tls_client_cert_thumbprints bytea;`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table auth_request
+				add column prompt text not null default '';`,
+			`
+			alter table auth_request
+				add column max_age integer not null default 0;`,
+			`
+			alter table auth_request
+				add column auth_time timestamptz not null default '0001-01-01 00:00:00 UTC';`,
+			`
+			alter table auth_code
+				add column auth_time timestamptz not null default '0001-01-01 00:00:00 UTC';`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column request_uris bytea;`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table refresh_token
+				add column rotated_tokens bytea;`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table device_token
+				add column client_id text not null default '';`,
+		},
+	},
+	{
+		// MySQL translates text to varchar(384), which is too short for key sets.
+		stmts: []string{`
+			alter table client
+				modify column jwks bytea not null;`,
+		},
+		flavor: &flavorMySQL,
+	},
+	{
+		// Claims requests can outgrow MySQL's varchar(384) translation of text.
+		stmts: []string{`
+			alter table auth_request
+				modify column claims_request bytea not null;`,
+			`
+			alter table auth_code
+				modify column claims_request bytea not null;`,
+			`
+			alter table refresh_token
+				modify column claims_request bytea not null;`,
+			`
+			alter table access_token
+				modify column claims_request bytea not null;`,
+		},
+		flavor: &flavorMySQL,
+	},
+	{
+		// Secrets of clients created by earlier versions are stored in plaintext.
+		fn: migrateClientSecrets,
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column client_credentials_scopes bytea;`,
+		},
+	},
+}
+
+// migrateClientSecrets replaces the plaintext client secrets with hashed ones.
+func migrateClientSecrets(tx *trans) error {
+	rows, err := tx.Query(`select id from client;`)
+	if err != nil {
+		return fmt.Errorf("select clients: %v", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan client: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("select clients: %v", err)
+	}
+
+	for _, id := range ids {
+		cli, err := getClient(tx, id)
+		if err != nil {
+			return err
+		}
+		cli, ok := storage.HashClientSecret(cli)
+		if !ok {
+			continue
+		}
+		if _, err := tx.Exec(`update client set secret = $1, secrets = $2 where id = $3;`,
+			cli.Secret, encoder(cli.Secrets), id); err != nil {
+			return fmt.Errorf("hash secret of client %q: %v", id, err)
+		}
+	}
+	return nil
 }
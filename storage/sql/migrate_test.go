@@ -4,9 +4,12 @@ import (
 	"database/sql"
 	"os"
 	"testing"
+	"time"
 
 	sqlite3 "github.com/mattn/go-sqlite3"
 	"github.com/sirupsen/logrus"
+
+	"github.com/dexidp/dex/storage"
 )
 
 func TestMigrate(t *testing.T) {
@@ -48,3 +51,39 @@ func TestMigrate(t *testing.T) {
 		}
 	}
 }
+
+func TestMigrateClientSecrets(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: &logrus.TextFormatter{DisableColors: true},
+		Level:     logrus.DebugLevel,
+	}
+
+	c := &conn{db, &flavorSQLite3, logger, nil}
+	if _, err := c.migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend the client was created before its secret was hashed.
+	client := storage.Client{ID: "foo", Secret: "plaintext-secret", RedirectURIs: []string{"https://auth.example.com"}}
+	if err := c.CreateClient(client); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ExecTx(migrateClientSecrets); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.GetClient(client.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Secret != "" || len(got.Secrets) != 1 || !got.Secrets[0].Matches(client.Secret, time.Now()) {
+		t.Errorf("expected plaintext secret to be hashed, got %#v", got)
+	}
+}
@@ -2,9 +2,14 @@ package storage
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base32"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
+	"math/big"
 	"strings"
 	"time"
 
@@ -34,10 +39,61 @@ func NewID() string {
 	return string(buff[0]%26+'a') + strings.TrimRight(encoding.EncodeToString(buff[1:]), "=")
 }
 
+// NewDeviceCode returns a 32 char alphanumeric cryptographically secure string
+func NewDeviceCode() string {
+	buff := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, buff); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buff)
+}
+
+// validUserCharacters is the set of characters used in user codes. Vowels are
+// omitted to avoid accidentally spelling words, and the remaining characters
+// are easy to read and type.
+const validUserCharacters = "BCDFGHJKLMNPQRSTVWXZ"
+
+// NewUserCode returns a randomized 8 character user code for the device flow.
+// No vowels are included to prevent accidental generation of words.
+func NewUserCode() string {
+	code := make([]byte, 8)
+	max := big.NewInt(int64(len(validUserCharacters)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		code[i] = validUserCharacters[n.Int64()]
+	}
+	return string(code[:4]) + "-" + string(code[4:])
+}
+
 // GCResult returns the number of objects deleted by garbage collection.
 type GCResult struct {
-	AuthRequests int64
-	AuthCodes    int64
+	AuthRequests     int64
+	AuthCodes        int64
+	DeviceRequests   int64
+	DeviceTokens     int64
+	Sessions         int64
+	AccessTokens     int64
+	RefreshTokens    int64
+	ClientAssertions int64
+	DPoPProofs       int64
+	OfflineSessions  int64
+}
+
+// IsEmpty returns whether the garbage collection result is empty or not.
+func (g *GCResult) IsEmpty() bool {
+	return g.AuthRequests == 0 &&
+		g.AuthCodes == 0 &&
+		g.DeviceRequests == 0 &&
+		g.DeviceTokens == 0 &&
+		g.Sessions == 0 &&
+		g.AccessTokens == 0 &&
+		g.RefreshTokens == 0 &&
+		g.ClientAssertions == 0 &&
+		g.DPoPProofs == 0 &&
+		g.OfflineSessions == 0
 }
 
 // Storage is the storage interface used by the server. Implementations are
@@ -54,6 +110,12 @@ type Storage interface {
 	CreatePassword(p Password) error
 	CreateOfflineSessions(s OfflineSessions) error
 	CreateConnector(c Connector) error
+	CreateDeviceRequest(d DeviceRequest) error
+	CreateDeviceToken(d DeviceToken) error
+	CreateSession(s Session) error
+	CreateAccessToken(t AccessToken) error
+	CreateClientAssertion(a ClientAssertion) error
+	CreateDPoPProof(p DPoPProof) error
 
 	// TODO(ericchiang): return (T, bool, error) so we can indicate not found
 	// requests that way instead of using ErrNotFound.
@@ -65,6 +127,12 @@ type Storage interface {
 	GetPassword(email string) (Password, error)
 	GetOfflineSessions(userID string, connID string) (OfflineSessions, error)
 	GetConnector(id string) (Connector, error)
+	GetDeviceRequest(userCode string) (DeviceRequest, error)
+	GetDeviceToken(deviceCode string) (DeviceToken, error)
+	GetSession(id string) (Session, error)
+	GetAccessToken(id string) (AccessToken, error)
+	GetClientAssertion(id string) (ClientAssertion, error)
+	GetDPoPProof(id string) (DPoPProof, error)
 
 	ListClients() ([]Client, error)
 	ListRefreshTokens() ([]RefreshToken, error)
@@ -79,6 +147,8 @@ type Storage interface {
 	DeletePassword(email string) error
 	DeleteOfflineSessions(userID string, connID string) error
 	DeleteConnector(id string) error
+	DeleteSession(id string) error
+	DeleteAccessToken(id string) error
 
 	// Update methods take a function for updating an object then performs that update within
 	// a transaction. "updater" functions may be called multiple times by a single update call.
@@ -101,8 +171,12 @@ type Storage interface {
 	UpdatePassword(email string, updater func(p Password) (Password, error)) error
 	UpdateOfflineSessions(userID string, connID string, updater func(s OfflineSessions) (OfflineSessions, error)) error
 	UpdateConnector(id string, updater func(c Connector) (Connector, error)) error
+	UpdateDeviceToken(deviceCode string, updater func(t DeviceToken) (DeviceToken, error)) error
+	UpdateSession(id string, updater func(s Session) (Session, error)) error
 
-	// GarbageCollect deletes all expired AuthCodes and AuthRequests.
+	// GarbageCollect deletes all expired AuthCodes, AuthRequests, DeviceRequests,
+	// DeviceTokens, Sessions, AccessTokens, ClientAssertions, DPoPProofs and
+	// RefreshTokens, along with OfflineSessions left without refresh tokens.
 	GarbageCollect(now time.Time) (GCResult, error)
 }
 
@@ -113,15 +187,26 @@ type Storage interface {
 //   * Public clients: https://developers.google.com/api-client-library/python/auth/installed-app
 type Client struct {
 	// Client ID and secret used to identify the client.
+	//
+	// Secret is a plaintext secret, used by static clients and clients authenticating
+	// with client_secret_jwt. Other clients only store hashes of their Secrets.
 	ID        string `json:"id" yaml:"id"`
 	IDEnv     string `json:"idEnv" yaml:"idEnv"`
 	Secret    string `json:"secret" yaml:"secret"`
 	SecretEnv string `json:"secretEnv" yaml:"secretEnv"`
 
+	// Secrets are the hashed secrets the client can authenticate with. Several secrets
+	// can be active at once to rotate them.
+	Secrets []ClientSecret `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+
 	// A registered set of redirect URIs. When redirecting from dex to the client, the URI
 	// requested to redirect to MUST match one of these values, unless the client is "public".
 	RedirectURIs []string `json:"redirectURIs" yaml:"redirectURIs"`
 
+	// A registered set of URIs the client may ask dex to redirect to after logging out
+	// the user. The post_logout_redirect_uri of a logout request MUST match one of these.
+	PostLogoutRedirectURIs []string `json:"postLogoutRedirectURIs" yaml:"postLogoutRedirectURIs"`
+
 	// TrustedPeers are a list of peers which can issue tokens on this client's behalf using
 	// the dynamic "oauth2:server:client_id:(client_id)" scope. If a peer makes such a request,
 	// this client's ID will appear as the ID Token's audience.
@@ -132,9 +217,144 @@ type Client struct {
 	// Public clients must use either use a redirectURL 127.0.0.1:X or "urn:ietf:wg:oauth:2.0:oob"
 	Public bool `json:"public" yaml:"public"`
 
+	// AllowedScopes limits the scopes the client can request on behalf of users. Clients
+	// without it are only limited by the server's configuration.
+	AllowedScopes []string `json:"allowedScopes" yaml:"allowedScopes"`
+
+	// ClientCredentialsScopes and AllowedAudiences are the scopes and audiences of tokens
+	// the client requests for itself using the client credentials grant, which requires
+	// them to be set.
+	ClientCredentialsScopes []string `json:"clientCredentialsScopes,omitempty" yaml:"clientCredentialsScopes,omitempty"`
+	AllowedAudiences        []string `json:"allowedAudiences" yaml:"allowedAudiences"`
+
+	// AllowedGrantTypes, AllowedResponseTypes and AllowedConnectors limit the grant types,
+	// response types and connectors the client can use. Clients without them are only
+	// limited by the server's configuration.
+	AllowedGrantTypes    []string `json:"allowedGrantTypes,omitempty" yaml:"allowedGrantTypes,omitempty"`
+	AllowedResponseTypes []string `json:"allowedResponseTypes,omitempty" yaml:"allowedResponseTypes,omitempty"`
+	AllowedConnectors    []string `json:"allowedConnectors,omitempty" yaml:"allowedConnectors,omitempty"`
+
 	// Name and LogoURL used when displaying this client to the end user.
 	Name    string `json:"name" yaml:"name"`
 	LogoURL string `json:"logoURL" yaml:"logoURL"`
+
+	// RegistrationAccessToken is used by clients created through dynamic client
+	// registration to read, update or delete their own registration.
+	RegistrationAccessToken string `json:"registrationAccessToken,omitempty" yaml:"registrationAccessToken,omitempty"`
+
+	// RefreshTokenValidFor and RefreshTokenIdleTimeout override the server-wide absolute
+	// lifetime and idle timeout of refresh tokens issued to this client. Values are
+	// durations such as "720h", "0s" disables the limit and an empty value keeps the
+	// server default.
+	RefreshTokenValidFor    string `json:"refreshTokenValidFor,omitempty" yaml:"refreshTokenValidFor,omitempty"`
+	RefreshTokenIdleTimeout string `json:"refreshTokenIdleTimeout,omitempty" yaml:"refreshTokenIdleTimeout,omitempty"`
+
+	// IDTokenValidFor and AccessTokenValidFor override the server-wide lifetimes of ID
+	// and access tokens issued to this client. Values are durations such as "1h", an
+	// empty value keeps the server default.
+	IDTokenValidFor     string `json:"idTokenValidFor,omitempty" yaml:"idTokenValidFor,omitempty"`
+	AccessTokenValidFor string `json:"accessTokenValidFor,omitempty" yaml:"accessTokenValidFor,omitempty"`
+
+	// JWKS is a JSON encoded JSON Web Key Set, and JWKSURI the URL of one, holding the
+	// public keys the client signs request objects with. At most one of them is set.
+	JWKS    string `json:"jwks,omitempty" yaml:"jwks,omitempty"`
+	JWKSURI string `json:"jwksURI,omitempty" yaml:"jwksURI,omitempty"`
+
+	// RequestURIs are the https URLs the client's request objects may be fetched
+	// from. Other request_uri values aren't fetched.
+	RequestURIs []string `json:"requestURIs,omitempty" yaml:"requestURIs,omitempty"`
+
+	// RequirePushedAuthRequests rejects authorization requests of the client which
+	// weren't pushed to the pushed authorization request endpoint first.
+	RequirePushedAuthRequests bool `json:"requirePushedAuthRequests,omitempty" yaml:"requirePushedAuthRequests,omitempty"`
+
+	// TokenEndpointAuthMethods restricts the methods the client may authenticate with,
+	// such as "client_secret_basic" or "private_key_jwt". Empty allows all of them.
+	TokenEndpointAuthMethods []string `json:"tokenEndpointAuthMethods,omitempty" yaml:"tokenEndpointAuthMethods,omitempty"`
+
+	// RequireDPoP rejects token requests of the client without a DPoP proof, so all
+	// its tokens are bound to a key it holds.
+	RequireDPoP bool `json:"requireDPoP,omitempty" yaml:"requireDPoP,omitempty"`
+
+	// TLSClientAuthSubjectDN is the subject distinguished name of the TLS client
+	// certificates the client authenticates with, such as "CN=client,O=example".
+	// The certificates must be issued by one of the CAs trusted by the server.
+	TLSClientAuthSubjectDN string `json:"tlsClientAuthSubjectDN,omitempty" yaml:"tlsClientAuthSubjectDN,omitempty"`
+	// TLSClientCertThumbprints are the base64url encoded SHA-256 thumbprints of the
+	// self-signed TLS client certificates the client authenticates with.
+	TLSClientCertThumbprints []string `json:"tlsClientCertThumbprints,omitempty" yaml:"tlsClientCertThumbprints,omitempty"`
+}
+
+// ClientSecret is a secret a client authenticates with. Only a SHA-256 hash of
+// the secret is stored. Client secrets are long random values, so unlike
+// passwords they don't need a slow hash, which would make every failed client
+// authentication expensive.
+type ClientSecret struct {
+	ID   string `json:"id" yaml:"id"`
+	Hash []byte `json:"hash" yaml:"hash"`
+
+	// The secret can't be used after Expiry, unless it's zero.
+	Expiry time.Time `json:"expiry,omitempty" yaml:"expiry,omitempty"`
+}
+
+// NewClientSecret hashes a client secret. A zero expiry never expires.
+func NewClientSecret(secret string, expiry time.Time) ClientSecret {
+	hash := sha256.Sum256([]byte(secret))
+	return ClientSecret{ID: NewID(), Hash: hash[:], Expiry: expiry}
+}
+
+// Matches reports whether secret is the hashed secret and it hasn't expired.
+func (s ClientSecret) Matches(secret string, now time.Time) bool {
+	if !s.Expiry.IsZero() && now.After(s.Expiry) {
+		return false
+	}
+	hash := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(s.Hash, hash[:]) == 1
+}
+
+// MigrateClientSecrets replaces the plaintext secrets of stored clients with hashed
+// ones, returning the number of migrated clients. Clients authenticating with
+// client_secret_jwt keep their plaintext secret, which verifying their JWTs requires.
+func MigrateClientSecrets(s Storage) (int, error) {
+	clients, err := s.ListClients()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, c := range clients {
+		if _, ok := HashClientSecret(c); !ok {
+			continue
+		}
+		err := s.UpdateClient(c.ID, func(old Client) (Client, error) {
+			nc, _ := HashClientSecret(old)
+			return nc, nil
+		})
+		if err != nil {
+			return n, fmt.Errorf("migrate secret of client %q: %v", c.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// HashClientSecret replaces the plaintext secret of a client with a hashed one,
+// reporting whether the client had a secret to hash.
+func HashClientSecret(c Client) (Client, bool) {
+	if c.Secret == "" || keepsPlaintextSecret(c) {
+		return c, false
+	}
+	c.Secrets = append(c.Secrets, NewClientSecret(c.Secret, time.Time{}))
+	c.Secret = ""
+	return c, true
+}
+
+func keepsPlaintextSecret(c Client) bool {
+	for _, method := range c.TokenEndpointAuthMethods {
+		if method == "client_secret_jwt" {
+			return true
+		}
+	}
+	return false
 }
 
 // Claims represents the ID Token claims supported by the server.
@@ -146,6 +366,10 @@ type Claims struct {
 	EmailVerified     bool
 
 	Groups []string
+
+	// CustomClaims holds additional attributes of the user mapped from the
+	// upstream identity provider, such as a department or an employee ID.
+	CustomClaims map[string]interface{}
 }
 
 // AuthRequest represents a OAuth2 client authorization request. It holds the state
@@ -170,6 +394,14 @@ type AuthRequest struct {
 	// attempts.
 	ForceApprovalPrompt bool
 
+	// Space separated prompt values and the maximum authentication age in
+	// seconds requested by the client. They decide whether an existing browser
+	// session can be reused. A zero MaxAge means no maximum.
+	//
+	// https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+	Prompt string
+	MaxAge int
+
 	Expiry time.Time
 
 	// Has the user proved their identity through a backing identity provider?
@@ -185,6 +417,25 @@ type AuthRequest struct {
 	// Set when the user authenticates.
 	ConnectorID   string
 	ConnectorData []byte
+
+	// Time the user authenticated with the connector, which is earlier than
+	// the request if the login was resumed from a browser session.
+	AuthTime time.Time
+
+	// PKCE CodeChallenge and CodeChallengeMethod
+	PKCE PKCE
+
+	// JSON encoded claims request parameter, used to request individual claims
+	// in ID tokens and userinfo responses.
+	//
+	// https://openid.net/specs/openid-connect-core-1_0.html#ClaimsParameter
+	ClaimsRequest string
+}
+
+// PKCE is a container for the data needed to perform Proof Key for Code Exchange (RFC 7636) auth flow.
+type PKCE struct {
+	CodeChallenge       string
+	CodeChallengeMethod string
 }
 
 // AuthCode represents a code which can be exchanged for an OAuth2 token response.
@@ -219,7 +470,16 @@ type AuthCode struct {
 	ConnectorData []byte
 	Claims        Claims
 
+	// Time the user authenticated with the connector.
+	AuthTime time.Time
+
 	Expiry time.Time
+
+	// PKCE CodeChallenge and CodeChallengeMethod
+	PKCE PKCE
+
+	// Claims request parameter of the initial request.
+	ClaimsRequest string
 }
 
 // RefreshToken is an OAuth2 refresh token which allows a client to request new
@@ -232,9 +492,24 @@ type RefreshToken struct {
 	// May be empty.
 	Token string
 
+	// ObsoleteToken is the value Token had before the last refresh. Presenting it
+	// shortly after the refresh is tolerated for clients sending concurrent refresh
+	// requests.
+	ObsoleteToken string
+	// RotatedTokens are the values Token had before ObsoleteToken, oldest first,
+	// so presenting any of them can be recognized as the reuse of a leaked token.
+	// Only a bounded number of them is kept.
+	RotatedTokens []string
+
 	CreatedAt time.Time
 	LastUsed  time.Time
 
+	// Expiry is the time the refresh token stops being usable, either because
+	// it reached its absolute lifetime or because it wasn't used for too long.
+	// It's updated every time the token is refreshed. A zero value means the
+	// token never expires.
+	Expiry time.Time
+
 	// Client this refresh token is valid for.
 	ClientID string
 
@@ -251,6 +526,14 @@ type RefreshToken struct {
 	// Nonce value supplied during the initial redirect. This is required to be part
 	// of the claims of any future id_token generated by the client.
 	Nonce string
+
+	// Claims request parameter of the initial request, honored by any future
+	// id_token as well.
+	ClaimsRequest string
+
+	// Confirmation binds the refresh token to a key the client must prove
+	// possession of to refresh it.
+	Confirmation Confirmation
 }
 
 // RefreshTokenRef is a reference object that contains metadata about refresh tokens.
@@ -342,3 +625,125 @@ type Keys struct {
 	// For caching purposes, implementations MUST NOT update keys before this time.
 	NextRotation time.Time
 }
+
+// DeviceRequest represents an OAuth2 device authorization request. It holds the
+// state of a device request until the user authenticates using their user code
+// or the expiry time passes.
+//
+// https://tools.ietf.org/html/rfc8628#section-3.1
+type DeviceRequest struct {
+	// The code the user will enter in a browser.
+	UserCode string
+	// The unique code the device uses to poll for tokens.
+	DeviceCode string
+	// The client the device request was issued to.
+	ClientID string
+	// The scopes requested by the device.
+	Scopes []string
+	Expiry time.Time
+}
+
+// DeviceToken is the state of a device authorization as seen by the polling
+// device. Once the user has authenticated, Token holds the token response the
+// device will receive.
+type DeviceToken struct {
+	DeviceCode string
+	// The client the device request was issued to, the only one which may poll
+	// for the token.
+	ClientID string
+	// One of "pending", "complete" or "redeemed".
+	Status string
+	// JSON encoded token response, set while Status is "complete".
+	Token  string
+	Expiry time.Time
+	// LastRequestTime and PollIntervalSeconds are used to rate limit a device
+	// polling the token endpoint.
+	LastRequestTime     time.Time
+	PollIntervalSeconds int
+}
+
+// Session is a user's login session with dex, referenced by a browser cookie.
+// While the session is valid, authorization requests from any client can skip
+// the connector login.
+type Session struct {
+	ID string
+
+	// Identity returned by the connector the user logged in with.
+	ConnectorID   string
+	ConnectorData []byte
+	Claims        Claims
+
+	// Scopes of the authorization request the user logged in for. They
+	// determine which claims the connector returned.
+	Scopes []string
+
+	// CreatedAt is the time the user authenticated with the connector.
+	CreatedAt time.Time
+	LastUsed  time.Time
+
+	// The session is invalid after Expiry regardless of its use.
+	Expiry time.Time
+}
+
+// AccessToken is an opaque access token. The value handed to the client is
+// the ID, the userinfo and introspection endpoints resolve it to the claims
+// it was issued for.
+type AccessToken struct {
+	ID string
+
+	// Client the token was issued to.
+	ClientID string
+
+	// Subject and audience of the token. Tokens issued through the client
+	// credentials grant have the client as their subject and no user claims.
+	Subject  string
+	Audience []string
+
+	Scopes []string
+
+	ConnectorID string
+	Claims      Claims
+
+	// Claims request parameter of the initial request, whose userinfo claims
+	// are returned by the userinfo endpoint.
+	ClaimsRequest string
+
+	// Confirmation binds the token to a key the client must prove possession of
+	// to use it.
+	Confirmation Confirmation
+
+	CreatedAt time.Time
+	Expiry    time.Time
+}
+
+// Confirmation identifies the key a sender-constrained token is bound to. The zero
+// value is used for bearer tokens.
+//
+// https://tools.ietf.org/html/rfc7800#section-3.1
+type Confirmation struct {
+	// JKT is the JWK SHA-256 thumbprint of the key the client signs DPoP proofs with.
+	JKT string `json:"jkt,omitempty"`
+	// X5TS256 is the SHA-256 thumbprint of the TLS client certificate the client
+	// sends its requests with.
+	X5TS256 string `json:"x5t#S256,omitempty"`
+}
+
+// DPoPProof records a DPoP proof a client sent, so the proof can't be replayed.
+// It's kept until the proof is too old to be accepted anyway.
+type DPoPProof struct {
+	// ID is derived from the key thumbprint and the "jti" claim of the proof.
+	ID string
+
+	Expiry time.Time
+}
+
+// ClientAssertion records a JWT a client authenticated with, so the JWT can't be
+// replayed. It's kept until the JWT expires.
+type ClientAssertion struct {
+	// ID is derived from the client ID and the "jti" claim of the JWT.
+	ID string
+
+	ClientID string
+
+	Expiry time.Time
+}